@@ -1,74 +1,87 @@
 package main
 
 import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"time"
 
-	"github/joja5627/old-automation/internal/sessions"
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/go-redis/redis"
+	_ "github.com/lib/pq"
+	"github/joja5627/old-automation/internal/database/psql"
+	redisdb "github/joja5627/old-automation/internal/database/redis"
+	"github/joja5627/old-automation/internal/env"
 	"github/joja5627/old-automation/internal/facebook"
-	"golang.org/x/oauth2"
-	facebookOAuth2 "golang.org/x/oauth2/facebook"
+	"github/joja5627/old-automation/internal/jwks"
+	"github/joja5627/old-automation/internal/sessions"
+	"github/joja5627/old-automation/internal/user"
 )
 
 const (
 	sessionName    = "example-facebook-app"
-	sessionSecret  = "example cookie signing secret"
-	sessionUserKey = "facebookID"
+	sessionUserKey = "accountID"
+
+	DefaultRedisUrl      = "localhost:6379"
+	DefaultRedisPassword = ""
+	DefaultPostgresUrl   = "postgresql://postgres@localhost/ticket?sslmode=disable"
+	DefaultSigningAlg    = jwks.ES256
+	DefaultKeyRotation   = 24 * time.Hour
+	DefaultKeyRetention  = time.Hour
+	keyRefreshInterval   = time.Minute
+	// new keys sign once every instance has refreshed and published them
+	keyPublishDelay = 2 * keyRefreshInterval
+	// long-lived Facebook tokens last 60 days; refresh them in their last week
+	tokenRefreshInterval = time.Hour
+	tokenRefreshWindow   = 7 * 24 * time.Hour
+	// facebookProvider names Facebook identities linked to accounts
+	facebookProvider = "facebook"
+	// DefaultSessionKeysKept is how many old session key pairs keygen keeps
+	DefaultSessionKeysKept = 2
 )
 
-// sessionStore encodes and decodes session data stored in signed cookies
-var sessionStore = sessions.NewCookieStore([]byte(sessionSecret), nil)
+// sessionStore encodes and decodes the session cookies; main keeps sessions
+// in redis, or postgres when SESSION_STORE is psql, with the keys from
+// SESSION_KEYS
+var sessionStore sessions.Store
 
 // Config configures the main ServeMux.
 type Config struct {
 	FacebookClientID     string
 	FacebookClientSecret string
+	// FacebookGraphVersion is the Graph API version, facebook.DefaultVersion
+	// if empty.
+	FacebookGraphVersion string
+	// Accounts logs in Facebook users as user-API accounts.
+	Accounts user.UserService
+	// Tokens keeps long-lived Facebook tokens of users who logged in. Tokens
+	// are not kept when nil.
+	Tokens facebook.TokenManager
+	// Deletions keeps data deletion requests so users can check on them.
+	Deletions facebook.DeletionStore
+	// PublicURL is the URL this app is reached at, for the deletion status
+	// URL.
+	PublicURL string
 }
-/ CookieConfig configures http.Cookie creation.
-type CookieConfig struct {
-	// Name is the desired cookie name.
-	Name string
-	// Domain sets the cookie domain. Defaults to the host name of the responding
-	// server when left zero valued.
-	Domain string
-	// Path sets the cookie path. Defaults to the path of the URL responding to
-	// the request when left zero valued.
-	Path string
-	// MaxAge=0 means no 'Max-Age' attribute should be set.
-	// MaxAge<0 means delete cookie now, equivalently 'Max-Age: 0'.
-	// MaxAge>0 means Max-Age attribute present and given in seconds.
-	// Cookie 'Expires' will be set (or left unset) according to MaxAge
-	MaxAge int
-	// HTTPOnly indicates whether the browser should prohibit a cookie from
-	// being accessible via Javascript. Recommended true.
-	HTTPOnly bool
-	// Secure flag indicating to the browser that the cookie should only be
-	// transmitted over a TLS HTTPS connection. Recommended true in production.
-	Secure bool
-}
-
-// DefaultCookieConfig configures short-lived temporary http.Cookie creation.
-var DefaultCookieConfig = CookieConfig{
-	Name:     "gologin-temporary-cookie",
-	Path:     "/",
-	MaxAge:   60, // 60 seconds
-	HTTPOnly: true,
-	Secure:   true, // HTTPS only
-}
-
-// DebugOnlyCookieConfig configures creation of short-lived temporary
-// http.Cookie's which do NOT require cookies be sent over HTTPS! Use this
-// config for development only.
-var DebugOnlyCookieConfig = CookieConfig{
-	Name:     "gologin-temporary-cookie",
-	Path:     "/",
-	MaxAge:   60, // 60 seconds
-	HTTPOnly: true,
-	Secure:   false, // allows cookies to be send over HTTP
+
+// facebook returns the Facebook login and Graph API configuration.
+func (c *Config) facebook() *facebook.Config {
+	return &facebook.Config{
+		ClientID:     c.FacebookClientID,
+		ClientSecret: c.FacebookClientSecret,
+		RedirectURL:  "http://localhost:8080/facebook/callback",
+		Scopes:       []string{"public_profile", "email"},
+		Version:      c.FacebookGraphVersion,
+	}
 }
 
 // New returns a new ServeMux with app routes.
@@ -77,23 +90,31 @@ func New(config *Config) *http.ServeMux {
 	mux.HandleFunc("/", welcomeHandler)
 	mux.Handle("/profile", requireLogin(http.HandlerFunc(profileHandler)))
 	mux.HandleFunc("/logout", logoutHandler)
+	mux.HandleFunc("/logout/all", logoutAllHandler)
 	// 1. Register Login and Callback handlers
-	oauth2Config := &oauth2.Config{
-		ClientID:     config.FacebookClientID,
-		ClientSecret: config.FacebookClientSecret,
-		RedirectURL:  "http://localhost:8080/facebook/callback",
-		Endpoint:     facebookOAuth2.Endpoint,
-		Scopes:       []string{"email"},
-	}
+	facebookConfig := config.facebook()
 	// state param cookies require HTTPS by default; disable for localhost development
 	stateConfig := gologin.DebugOnlyCookieConfig
-	mux.Handle("/facebook/login", facebook.StateHandler(stateConfig, facebook.LoginHandler(oauth2Config, nil)))
-	mux.Handle("/facebook/callback", facebook.StateHandler(stateConfig, facebook.CallbackHandler(oauth2Config, issueSession(), nil)))
+	// the code verifier travels in its own cookie next to the state
+	verifierConfig := gologin.DebugOnlyCookieConfig
+	verifierConfig.Name = "facebook-verifier"
+	mux.Handle("/facebook/login", facebook.StateHandler(stateConfig, facebook.PKCEHandler(verifierConfig, facebook.LoginHandler(facebookConfig, nil))))
+	mux.Handle("/facebook/callback", facebook.StateHandler(stateConfig, facebook.PKCEHandler(verifierConfig, facebook.CallbackHandler(facebookConfig, issueLogin(config.Accounts, config.Tokens), nil))))
+	// 2. Register the callbacks Facebook calls when users remove the app or
+	// ask for their data to be deleted
+	mux.Handle("/facebook/deauthorize", requirePost(facebook.SignedRequestHandler(facebookConfig, deauthorize(config), http.HandlerFunc(signedRequestFailure))))
+	mux.Handle("/facebook/deletion", requirePost(facebook.SignedRequestHandler(facebookConfig, deleteData(config), http.HandlerFunc(signedRequestFailure))))
+	mux.HandleFunc("/facebook/deletion/status", deletionStatus(config.Deletions))
 	return mux
 }
 
-// issueSession issues a cookie session after successful Facebook login
-func issueSession() http.Handler {
+// issueLogin logs the Facebook user in as the linked account, creating it on
+// first login, and responds with the same tokens as the user API so that
+// they can call the ticket API. A cookie session is issued as well for the
+// pages of this app, unless the account still has to complete two-factor
+// authentication at the user API. The Facebook token is exchanged for a
+// long-lived one and kept in tokens, if not nil.
+func issueLogin(accounts user.UserService, tokens facebook.TokenManager) http.Handler {
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
 		facebookUser, err := facebook.UserFromContext(ctx)
@@ -101,11 +122,155 @@ func issueSession() http.Handler {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		// 2. Implement a success handler to issue some form of session
-		session := sessionStore.New(sessionName)
-		session.Values[sessionUserKey] = facebookUser.ID
-		session.Save(w)
-		http.Redirect(w, req, "/profile", http.StatusFound)
+		login, err := accounts.LoginExternal(&user.ExternalProfile{
+			Provider: facebookProvider,
+			Subject:  facebookUser.ID,
+			Name:     facebookUser.Name,
+			Email:    facebookUser.Email,
+			// Facebook only returns confirmed email addresses
+			EmailVerified: facebookUser.Email != "",
+		})
+		if err == user.ErrAccountDeactivated {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if tokens != nil {
+			if token, err := oauth2Login.TokenFromContext(ctx); err == nil {
+				if _, err := tokens.Exchange(facebookUser.ID, token.AccessToken); err != nil {
+					log.Printf("Error keeping Facebook token: %v", err)
+				}
+			}
+		}
+
+		if login.Token != "" {
+			session := sessionStore.New(sessionName)
+			session.Values[sessionUserKey] = login.AccountID
+			session.Save(w)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(login); err != nil {
+			log.Printf("Error writing response: %v", err)
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+// deauthorize forgets the Facebook token of a user who removed the app,
+// unlinks their Facebook identity from their account, which is kept, and
+// ends their sessions.
+func deauthorize(config *Config) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		request, err := facebook.SignedRequestFromContext(req.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if config.Tokens != nil {
+			if err := config.Tokens.DeleteToken(request.UserID); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		account, err := config.Accounts.UnlinkExternal(facebookProvider, request.UserID)
+		if _, ok := err.(*user.NotFoundError); err != nil && !ok {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if account != nil {
+			if err := destroyUserSessions(account.ID); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// deleteData deletes the Facebook token of the user and their Facebook
+// identity, with the account if it was created for that identity, ends
+// their sessions, then responds with where they can check on the request.
+func deleteData(config *Config) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		request, err := facebook.SignedRequestFromContext(req.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if config.Tokens != nil {
+			if err := config.Tokens.DeleteToken(request.UserID); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		deletion := &facebook.Deletion{
+			ConfirmationCode: facebook.NewConfirmationCode(),
+			Status:           facebook.DeletionCompleted,
+			Requested:        time.Now(),
+		}
+		account, err := config.Accounts.DeleteExternal(facebookProvider, request.UserID)
+		if _, ok := err.(*user.NotFoundError); ok {
+			deletion.Status = facebook.DeletionNoData
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if err := destroyUserSessions(account.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := config.Deletions.SaveDeletion(deletion); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		statusURL := config.PublicURL + "/facebook/deletion/status?" + url.Values{"code": {deletion.ConfirmationCode}}.Encode()
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(&facebook.DeletionResponse{URL: statusURL, ConfirmationCode: deletion.ConfirmationCode}); err != nil {
+			log.Printf("Error writing response: %v", err)
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+// deletionStatus looks up a data deletion request by its confirmation code.
+func deletionStatus(deletions facebook.DeletionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		deletion, err := deletions.GetDeletion(req.URL.Query().Get("code"))
+		if err == facebook.ErrDeletionNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(deletion); err != nil {
+			log.Printf("Error writing response: %v", err)
+		}
+	}
+}
+
+// signedRequestFailure rejects callbacks without a valid signed_request.
+func signedRequestFailure(w http.ResponseWriter, req *http.Request) {
+	http.Error(w, gologin.ErrorFromContext(req.Context()).Error(), http.StatusBadRequest)
+}
+
+// requirePost rejects requests other than POSTs.
+func requirePost(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		next.ServeHTTP(w, req)
 	}
 	return http.HandlerFunc(fn)
 }
@@ -121,29 +286,71 @@ func welcomeHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	page, _ := ioutil.ReadFile("home.html")
-	fmt.Fprintf(w, string(page))
+	fmt.Fprint(w, string(page))
 }
 
 // profileHandler shows protected user content.
 func profileHandler(w http.ResponseWriter, req *http.Request) {
-	fmt.Fprint(w, `<p>You are logged in!</p><form action="/logout" method="post"><input type="submit" value="Logout"></form>`)
+	fmt.Fprint(w, `<p>You are logged in!</p><form action="/logout" method="post"><input type="submit" value="Logout"></form>`+
+		`<form action="/logout/all" method="post"><input type="submit" value="Logout everywhere"></form>`)
 }
 
 // logoutHandler destroys the session on POSTs and redirects to home.
 func logoutHandler(w http.ResponseWriter, req *http.Request) {
 	if req.Method == "POST" {
+		if session, err := sessionStore.Get(req, sessionName); err == nil {
+			if err := session.Destroy(w); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		} else {
+			sessionStore.Destroy(w, sessionName)
+		}
+	}
+	http.Redirect(w, req, "/", http.StatusFound)
+}
+
+// logoutAllHandler destroys every session of the user on POSTs and
+// redirects to home.
+func logoutAllHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method == "POST" {
+		if session, err := sessionStore.Get(req, sessionName); err == nil {
+			if accountID, ok := session.Values[sessionUserKey].(string); ok {
+				if err := destroyUserSessions(accountID); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+		}
 		sessionStore.Destroy(w, sessionName)
 	}
 	http.Redirect(w, req, "/", http.StatusFound)
 }
 
-// requireLogin redirects unauthenticated users to the login route.
+// destroyUserSessions logs accountID out of this app everywhere. Cookie
+// sessions cannot be ended that way and are left to expire.
+func destroyUserSessions(accountID string) error {
+	if store, ok := sessionStore.(*sessions.ServerStore); ok {
+		return store.DestroyUser(accountID)
+	}
+	return nil
+}
+
+// requireLogin redirects unauthenticated users to the login route. Sessions
+// whose cookie was encoded with an old key are saved again with the current
+// one, so that old keys can be dropped at the next rotation.
 func requireLogin(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, req *http.Request) {
-		if !isAuthenticated(req) {
+		session, err := sessionStore.Get(req, sessionName)
+		if err != nil {
 			http.Redirect(w, req, "/", http.StatusFound)
 			return
 		}
+		if session.Stale() {
+			if err := session.Save(w); err != nil {
+				log.Printf("Unable to re-encode session: %v", err)
+			}
+		}
 		next.ServeHTTP(w, req)
 	}
 	return http.HandlerFunc(fn)
@@ -157,17 +364,72 @@ func isAuthenticated(req *http.Request) bool {
 	return false
 }
 
-// main creates and starts a Server listening.
+// sessionKeys returns the session key pairs in SESSION_KEYS, as written by
+// the keygen command. Without them it fails, unless random keys are allowed
+// for development, in which case sessions only last until the server
+// restarts and are not shared between instances.
+func sessionKeys(allowRandom bool) ([][]byte, error) {
+	if keys := os.Getenv("SESSION_KEYS"); keys != "" {
+		return sessions.ParseKeyPairs(keys)
+	}
+	if !allowRandom {
+		return nil, errors.New("not set, run the keygen command to create them")
+	}
+	log.Print("SESSION_KEYS not set, sessions end when the server restarts")
+	hashKey, blockKey, err := sessions.GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{hashKey, blockKey}, nil
+}
+
+// keygen prints a new SESSION_KEYS value. With -rotate, the new key pair
+// is put in front of the pairs in SESSION_KEYS, keeping the newest -keep
+// of them to decode cookies until they are re-encoded.
+func keygen(args []string) {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	rotate := flags.Bool("rotate", false, "keep the key pairs in SESSION_KEYS after the new one")
+	keep := flags.Int("keep", DefaultSessionKeysKept, "number of old key pairs to keep when rotating")
+	flags.Parse(args)
+
+	hashKey, blockKey, err := sessions.GenerateKeyPair()
+	if err != nil {
+		log.Fatal(err)
+	}
+	keyPairs := [][]byte{hashKey, blockKey}
+	if *rotate && os.Getenv("SESSION_KEYS") != "" {
+		old, err := sessions.ParseKeyPairs(os.Getenv("SESSION_KEYS"))
+		if err != nil {
+			log.Fatal("Invalid SESSION_KEYS: ", err)
+		}
+		if len(old) > 2**keep {
+			old = old[:2**keep]
+		}
+		keyPairs = append(keyPairs, old...)
+	}
+	fmt.Println(sessions.EncodeKeyPairs(keyPairs...))
+}
+
+// main creates and starts a Server listening, or runs the keygen command.
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "keygen" {
+		keygen(os.Args[2:])
+		return
+	}
 	const address = "localhost:8080"
 	// read credentials from environment variables if available
 	config := &Config{
 		FacebookClientID:     os.Getenv("FACEBOOK_CLIENT_ID"),
 		FacebookClientSecret: os.Getenv("FACEBOOK_CLIENT_SECRET"),
+		FacebookGraphVersion: os.Getenv("FACEBOOK_GRAPH_VERSION"),
+		PublicURL:            env.EnvString("PUBLIC_URL", "http://"+address),
 	}
 	// allow consumer credential flags to override config fields
 	clientID := flag.String("client-id", "", "Facebook Client ID")
 	clientSecret := flag.String("client-secret", "", "Facebook Client Secret")
+	dbType := flag.String("database", env.EnvString("USER_DATABASE", "redis"), "account database type [redis, psql]")
+	sessionBackend := flag.String("session-store", env.EnvString("SESSION_STORE", "redis"), "session store type [redis, psql]")
+	devSessionKeys := flag.Bool("dev-session-keys", false, "use random session keys when SESSION_KEYS is not set (development only)")
 	flag.Parse()
 	if *clientID != "" {
 		config.FacebookClientID = *clientID
@@ -182,9 +444,124 @@ func main() {
 		log.Fatal("Missing Facebook Client Secret")
 	}
 
+	// accounts, tokens and signing keys are shared with the user API
+	rconn := redisConnect(env.EnvString("DATABASE_URL", DefaultRedisUrl), env.EnvString("REDIS_PASSWORD", DefaultRedisPassword))
+	defer rconn.Close()
+	var pconn *sql.DB
+	if *dbType == "psql" || *sessionBackend == "psql" {
+		pconn = postgresConnection(env.EnvString("POSTGRES_URL", DefaultPostgresUrl))
+		defer pconn.Close()
+	}
+	var userRepo user.UserRepo
+	switch *dbType {
+	case "psql":
+		userRepo = psql.NewPostgresUserRepository(pconn)
+	case "redis":
+		userRepo = redisdb.NewRedisUserRepository(rconn)
+	default:
+		log.Fatal("Unknown database")
+	}
+	keys, err := jwks.NewKeyRing(
+		signingKeyRepository(rconn),
+		env.EnvString("JWT_SIGNING_ALG", DefaultSigningAlg),
+		env.EnvDuration("JWT_KEY_ROTATION", DefaultKeyRotation),
+		DefaultKeyRetention,
+		keyPublishDelay,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go keys.Run(keyRefreshInterval, stop)
+	config.Accounts = user.NewUserService(userRepo,
+		redisdb.NewRedisTokenRepository(rconn),
+		mfaRepository(rconn),
+		redisdb.NewRedisActionTokenRepository(rconn),
+		keys,
+	)
+	config.Deletions = redisdb.NewRedisFacebookDeletionRepository(rconn)
+	// sessions live on the server so that logging out ends them for good
+	var backend sessions.Backend
+	switch *sessionBackend {
+	case "psql":
+		backend = psql.NewPostgresSessionRepository(pconn)
+	case "redis":
+		backend = redisdb.NewRedisSessionRepository(rconn)
+	default:
+		log.Fatal("Unknown session store")
+	}
+	sessionKeyPairs, err := sessionKeys(*devSessionKeys)
+	if err != nil {
+		log.Fatal("SESSION_KEYS: ", err)
+	}
+	serverStore := sessions.NewServerStore(backend, sessionKeyPairs...)
+	serverStore.UserKey = sessionUserKey
+	sessionStore = serverStore
+	// FACEBOOK_TOKEN_KEY is a base64 AES key sealing the stored tokens
+	if tokenKey := os.Getenv("FACEBOOK_TOKEN_KEY"); tokenKey != "" {
+		key, err := base64.StdEncoding.DecodeString(tokenKey)
+		if err != nil {
+			log.Fatal("Invalid FACEBOOK_TOKEN_KEY: ", err)
+		}
+		config.Tokens, err = facebook.NewTokenManager(nil, config.facebook(), redisdb.NewRedisFacebookTokenRepository(rconn), key)
+		if err != nil {
+			log.Fatal("Invalid FACEBOOK_TOKEN_KEY: ", err)
+		}
+		go config.Tokens.Run(tokenRefreshInterval, tokenRefreshWindow, stop)
+	}
+
 	log.Printf("Starting Server listening on %s\n", address)
-	err := http.ListenAndServe(address, New(config))
+	err = http.ListenAndServe(address, New(config))
 	if err != nil {
 		log.Fatal("ListenAndServe: ", err)
 	}
 }
+
+// mfaRepository stores second factors sealed with MFA_SECRET_KEY, the key
+// the user API seals them with.
+func mfaRepository(rconn *redis.Client) user.MFARepo {
+	key, err := env.EnvKey("MFA_SECRET_KEY")
+	if err != nil || key == nil {
+		log.Fatal("MFA_SECRET_KEY must be set to a base64 AES key")
+	}
+	repo, err := redisdb.NewRedisMFARepository(rconn, key)
+	if err != nil {
+		log.Fatal("Invalid MFA_SECRET_KEY: ", err)
+	}
+	return repo
+}
+
+// signingKeyRepository stores the JWT signing keys sealed with
+// SIGNING_SECRET_KEY, the key the user API seals them with.
+func signingKeyRepository(rconn *redis.Client) jwks.KeyStore {
+	key, err := env.EnvKey("SIGNING_SECRET_KEY")
+	if err != nil || key == nil {
+		log.Fatal("SIGNING_SECRET_KEY must be set to a base64 AES key")
+	}
+	repo, err := redisdb.NewRedisSigningKeyRepository(rconn, key)
+	if err != nil {
+		log.Fatal("Invalid SIGNING_SECRET_KEY: ", err)
+	}
+	return repo
+}
+
+func redisConnect(url string, password string) *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr:     url,
+		Password: password,
+		DB:       0,
+	})
+	if err := client.Ping().Err(); err != nil {
+		log.Fatal(err)
+	}
+	return client
+}
+
+func postgresConnection(database string) *sql.DB {
+	db, err := sql.Open("postgres", database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return db
+}
@@ -8,12 +8,24 @@ import (
 	"hex-example/internal/database/psql"
 	redisdb "hex-example/internal/database/redis"
 	"hex-example/internal/env"
+	"hex-example/internal/graph"
+	"hex-example/internal/jwks"
+	"hex-example/internal/mail"
 	"hex-example/internal/middleware"
+	"hex-example/internal/openapi"
+	"hex-example/internal/rbac"
+	"hex-example/internal/requestid"
+	"hex-example/internal/rpc"
 	"hex-example/internal/ticket"
+	"hex-example/internal/user"
+	"hex-example/pkg/api/ticketpb"
+	"net"
 	"net/http"
+	"net/smtp"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/apex/gateway"
 	"github.com/go-redis/redis"
@@ -25,47 +37,103 @@ const (
 	DefaultRedisUrl      = "localhost:6379"
 	DefaultRedisPassword = ""
 	DefaultPostgresUrl   = "postgresql://postgres@localhost/ticket?sslmode=disable"
+	DefaultGrpcAddress   = ":9001"
+	DefaultJwksUrl       = "http://localhost:3000/.well-known/jwks.json"
+	DefaultJwksCacheTTL  = 5 * time.Minute
+	DefaultUserApiUrl    = "http://localhost:3000"
+	DefaultMailFrom      = "noreply@localhost"
 )
 
 var docker string
 
 func main() {
 
-	var server bool
-	var dbType, dbURL, redisPassword string
-	flag.StringVar(&dbType, "database", "redis", "database type [redis, psql]")
+	var server, grpcMode bool
+	var dbType, userDBType string
+	flag.StringVar(&dbType, "database", "redis", "ticket database type [redis, psql]")
+	flag.StringVar(&userDBType, "user-database", env.EnvString("USER_DATABASE", "redis"), "account database type [redis, psql], the one userAPI uses")
 	flag.BoolVar(&server, "server", false, "run in server mode")
+	flag.BoolVar(&grpcMode, "grpc", false, "also serve gRPC on "+DefaultGrpcAddress)
 	flag.Parse()
 
 	var ticketRepo ticket.TicketRepository
+	var userRepo user.UserRepo
+	var rconn *redis.Client
+	redisPassword := env.EnvString("REDIS_PASSWORD", DefaultRedisPassword)
 
 	switch dbType {
 	case "psql":
-		dbURL = env.EnvString("DATABASE_URL", DefaultPostgresUrl)
-		pconn := postgresConnection(dbURL)
+		pconn := postgresConnection(env.EnvString("DATABASE_URL", DefaultPostgresUrl))
 		defer pconn.Close()
 		ticketRepo = psql.NewPostgresTicketRepository(pconn)
+		rconn = redisConnect(env.EnvString("USER_DATABASE_URL", DefaultRedisUrl), redisPassword)
 	case "redis":
-		dbURL = env.EnvString("DATABASE_URL", DefaultRedisUrl)
-		redisPassword = env.EnvString("REDIS_PASSWORD", DefaultRedisPassword)
-		rconn := redisConnect(dbURL, redisPassword)
-		defer rconn.Close()
+		rconn = redisConnect(env.EnvString("DATABASE_URL", DefaultRedisUrl), redisPassword)
+		if err := redisdb.Migrate(rconn); err != nil {
+			logrus.Fatal(err)
+		}
 		ticketRepo = redisdb.NewRedisTicketRepository(rconn)
 	default:
 		panic("Unknown database")
 	}
+	defer rconn.Close()
+
+	// accounts are read from wherever userAPI keeps them, whatever the
+	// ticket database; tokens, keys and service accounts stay in redis
+	switch userDBType {
+	case "psql":
+		uconn := postgresConnection(env.EnvString("POSTGRES_URL", DefaultPostgresUrl))
+		defer uconn.Close()
+		userRepo = psql.NewPostgresUserRepository(uconn)
+	case "redis":
+		userRepo = redisdb.NewRedisUserRepository(rconn)
+	default:
+		panic("Unknown user database")
+	}
+	tokenRepo := redisdb.NewRedisTokenRepository(rconn)
+	serviceAccountRepo := redisdb.NewRedisServiceAccountRepository(rconn)
+	actionTokenRepo := redisdb.NewRedisActionTokenRepository(rconn)
 
 	ticketService := ticket.NewTicketService(ticketRepo)
 	ticketHandler := ticket.NewTicketHandler(ticketService)
+	// tokens and second factors are handled by userAPI; only its public keys
+	// are needed here. Accounts created over GraphQL are verified through
+	// userAPI links.
+	recoveryService := user.NewRecoveryService(userRepo, tokenRepo,
+		actionTokenRepo,
+		mailer(),
+		env.EnvString("USER_API_URL", DefaultUserApiUrl),
+	)
+	userService := user.WithVerification(user.NewUserService(userRepo, tokenRepo, nil, actionTokenRepo, nil), recoveryService)
+	keys := jwks.NewRemoteKeySet(env.EnvString("JWKS_URL", DefaultJwksUrl), env.EnvDuration("JWKS_CACHE_TTL", DefaultJwksCacheTTL))
+	validator := middleware.WithAPIKeys(
+		middleware.NewTokenValidator(keys, middleware.ConfigFromEnv()),
+		user.NewServiceAccountService(serviceAccountRepo),
+	)
 
 	router := mux.NewRouter().StrictSlash(true)
-	router.HandleFunc("/tickets", ticketHandler.Get).Methods("GET")
-	router.HandleFunc("/tickets/{id}", ticketHandler.GetById).Methods("GET")
-	router.HandleFunc("/tickets", ticketHandler.Create).Methods("POST")
-
-	http.Handle("/", accessControl(middleware.Authenticate(router)))
-
-	errs := make(chan error, 2)
+	router.Handle("/tickets", middleware.Require(rbac.TicketsRead, http.HandlerFunc(ticketHandler.Get))).Methods("GET")
+	router.Handle("/tickets/{id}", middleware.Require(rbac.TicketsRead, http.HandlerFunc(ticketHandler.GetById))).Methods("GET")
+	router.Handle("/tickets", middleware.Require(rbac.TicketsCreate, http.HandlerFunc(ticketHandler.Create))).Methods("POST")
+	// project roles grant their permissions on the tickets of their project
+	router.Handle("/projects/{project}/tickets", middleware.RequireInProject(rbac.TicketsRead, projectVar, http.HandlerFunc(ticketHandler.Get))).Methods("GET")
+	router.Handle("/projects/{project}/tickets/{id}", middleware.RequireInProject(rbac.TicketsRead, projectVar, http.HandlerFunc(ticketHandler.GetById))).Methods("GET")
+	router.Handle("/projects/{project}/tickets", middleware.RequireInProject(rbac.TicketsCreate, projectVar, http.HandlerFunc(ticketHandler.Create))).Methods("POST")
+	// resolvers check permissions per field
+	router.Handle("/graphql", graph.NewHandler(ticketService, userService)).Methods("POST")
+
+	doc := openapi.TicketAPI()
+	http.Handle("/openapi.json", accessControl(openapi.Handler(doc)))
+	http.Handle("/", requestid.Handler(accessControl(middleware.Authenticate(validator, openapi.Validate(doc, router)))))
+
+	errs := make(chan error, 3)
+	if grpcMode {
+		grpcServer := rpc.NewServer(validator)
+		ticketpb.RegisterTicketServiceServer(grpcServer, rpc.NewTicketServer(ticketService))
+		go func() {
+			errs <- rpc.ListenAndServe(env.EnvString("GRPC_ADDRESS", DefaultGrpcAddress), grpcServer)
+		}()
+	}
 	go func() {
 		if server || docker == "true" {
 			logrus.Info("Listening server mode on port :3001")
@@ -84,6 +152,23 @@ func main() {
 	logrus.Errorf("terminated %s", <-errs)
 
 }
+
+// mailer relays through SMTP_ADDR, or logs mail when it is unset.
+func mailer() mail.Mailer {
+	addr := env.EnvString("SMTP_ADDR", "")
+	if addr == "" {
+		logrus.Warn("SMTP_ADDR not set, logging mail instead of sending it")
+		return mail.NewLogMailer()
+	}
+
+	var auth smtp.Auth
+	if username := env.EnvString("SMTP_USERNAME", ""); username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		auth = smtp.PlainAuth("", username, env.EnvString("SMTP_PASSWORD", ""), host)
+	}
+	return mail.NewSMTPMailer(addr, env.EnvString("SMTP_FROM", DefaultMailFrom), auth)
+}
+
 func redisConnect(url string, password string) *redis.Client {
 
 	logrus.WithField("connection", url).Info("Connecting to Redis DB")
@@ -111,11 +196,17 @@ func postgresConnection(database string) *sql.DB {
 	return db
 }
 
+// projectVar returns the project of a /projects/{project} route.
+func projectVar(r *http.Request) string {
+	return mux.Vars(r)["project"]
+}
+
 func accessControl(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, "+requestid.Header)
+		w.Header().Set("Access-Control-Expose-Headers", requestid.Header)
 
 		if r.Method == "OPTIONS" {
 			return
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *Config {
+	return &Config{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  DefaultRedirectURL,
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.Nil(t, ioutil.WriteFile(path, []byte(`{"clientId": "file-id", "clientSecret": "file-secret", "scopes": ["email"]}`), 0600))
+	os.Setenv("FACEBOOK_CLIENT_SECRET", "env-secret")
+	defer os.Unsetenv("FACEBOOK_CLIENT_SECRET")
+
+	config, err := loadConfig(path)
+	require.Nil(t, err)
+	assert.Equal(t, "file-id", config.ClientID)
+	assert.Equal(t, "env-secret", config.ClientSecret, "the environment overrides the file")
+	assert.Equal(t, []string{"email"}, config.Scopes)
+	assert.Equal(t, DefaultAddress, config.Address)
+	assert.Equal(t, DefaultRedirectURL, config.RedirectURL)
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	_, err := loadConfig("")
+	assert.EqualError(t, err, "Missing Facebook Client ID")
+
+	_, err = loadConfig(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.Nil(t, ioutil.WriteFile(path, []byte(`{"clientId": "file-id"}`), 0600))
+	_, err = loadConfig(path)
+	assert.EqualError(t, err, "Missing Facebook Client Secret")
+}
+
+func TestHome(t *testing.T) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	New(testConfig()).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `href="/facebook/login"`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/FBLogin", nil)
+	New(testConfig()).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestLogin(t *testing.T) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/facebook/login", nil)
+	New(testConfig()).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.Nil(t, err)
+	assert.Equal(t, "www.facebook.com", location.Host)
+	query := location.Query()
+	assert.Equal(t, "client-id", query.Get("client_id"))
+	assert.NotEmpty(t, query.Get("state"))
+	assert.NotEmpty(t, query.Get("code_challenge"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	for _, cookie := range w.Result().Cookies() {
+		assert.True(t, cookie.Secure, cookie.Name)
+		assert.True(t, cookie.HttpOnly, cookie.Name)
+	}
+}
+
+func TestCallback(t *testing.T) {
+	handler := New(testConfig())
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/facebook/login", nil)
+	handler.ServeHTTP(w, req)
+	cookies := w.Result().Cookies()
+	location, _ := url.Parse(w.Header().Get("Location"))
+	state := location.Query().Get("state")
+	var verifier string
+	for _, cookie := range cookies {
+		if cookie.Name == "facebook-verifier" {
+			verifier = cookie.Value
+		}
+	}
+	require.NotEmpty(t, verifier)
+
+	client, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/v2.9/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostFormValue("code_verifier") != verifier {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error": "invalid_grant"}`)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "any-token", "token_type": "bearer"})
+	})
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id": "54638001", "name": "<script>alert(1)</script>", "picture": {"data": {"url": "javascript:alert(1)"}}}`)
+	})
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/facebook/callback?code=any-code&state="+url.QueryEscape(state), nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "54638001")
+	assert.Contains(t, body, "&lt;script&gt;alert(1)&lt;/script&gt;", "user data is escaped")
+	assert.NotContains(t, body, "<script>")
+	assert.NotContains(t, body, `src="javascript:`, "unsafe URLs are filtered")
+}
+
+func TestCallback_Failure(t *testing.T) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/facebook/callback?code=any-code&state=<b>forged</b>", nil)
+	req.AddCookie(&http.Cookie{Name: "gologin-temporary-cookie", Value: "state-value"})
+	New(testConfig()).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Login failed")
+	assert.NotContains(t, w.Body.String(), "<b>")
+}
@@ -1,42 +1,218 @@
 package main
 
 import (
+	"database/sql"
+	"flag"
 	"fmt"
 	"github.com/go-redis/redis"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/gorilla/mux"
+	_ "github.com/lib/pq"
 	"github.com/sirupsen/logrus"
+	"hex-example/internal/database/psql"
 	redisdb "hex-example/internal/database/redis"
 	"hex-example/internal/env"
+	"hex-example/internal/jwks"
+	"hex-example/internal/mail"
+	"hex-example/internal/middleware"
+	"hex-example/internal/openapi"
+	"hex-example/internal/rbac"
+	"hex-example/internal/requestid"
+	"hex-example/internal/rpc"
 	"hex-example/internal/user"
+	"hex-example/pkg/api/userpb"
+	"net"
 	"net/http"
+	"net/smtp"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 )
 
 const (
 	DefaultRedisUrl      = "localhost:6379"
 	DefaultRedisPassword = ""
+	DefaultPostgresUrl   = "postgresql://postgres@localhost/ticket?sslmode=disable"
+	DefaultGrpcAddress   = ":9000"
+	DefaultPublicUrl     = "http://localhost:3000"
+	DefaultMailFrom      = "noreply@localhost"
+	DefaultTotpIssuer    = "hex-example"
+	DefaultWebAuthnRPID  = "localhost"
+	DefaultSigningAlg    = jwks.ES256
+	DefaultKeyRotation   = 24 * time.Hour
+	// keys outlive their rotation by at least the lifetime of an access token
+	DefaultKeyRetention = time.Hour
+	keyRefreshInterval  = time.Minute
+	// new keys sign once every instance has refreshed and published them
+	keyPublishDelay = 2 * keyRefreshInterval
 )
 
 func main() {
 
-	dbURL := env.EnvString("DsATABASE_URL", DefaultRedisUrl)
-	redisPassword := env.EnvString("REsDIS_PASSWORD", DefaultRedisPassword)
+	var grpcMode bool
+	var admin, dbType string
+	flag.StringVar(&dbType, "database", env.EnvString("USER_DATABASE", "redis"), "account database type [redis, psql]")
+	flag.BoolVar(&grpcMode, "grpc", false, "also serve gRPC on "+DefaultGrpcAddress)
+	flag.StringVar(&admin, "admin", "", "grant the admin role to this existing account on startup")
+	flag.Parse()
+
+	dbURL := env.EnvString("DATABASE_URL", DefaultRedisUrl)
+	redisPassword := env.EnvString("REDIS_PASSWORD", DefaultRedisPassword)
 	rconn := redisConnect(dbURL, redisPassword)
 	defer rconn.Close()
 
-	userRepo := redisdb.NewRedisUserRepository(rconn)
-	userService := user.NewUserService(userRepo)
-	userHandler := user.NewUserHandler(userService)
+	var userRepo user.UserRepo
+	switch dbType {
+	case "psql":
+		// tokens, keys and service accounts stay in redis
+		pconn := postgresConnection(env.EnvString("POSTGRES_URL", DefaultPostgresUrl))
+		defer pconn.Close()
+		userRepo = psql.NewPostgresUserRepository(pconn)
+	case "redis":
+		if err := redisdb.Migrate(rconn); err != nil {
+			logrus.Fatal(err)
+		}
+		userRepo = redisdb.NewRedisUserRepository(rconn)
+	default:
+		panic("Unknown database")
+	}
+	tokenRepo := redisdb.NewRedisTokenRepository(rconn)
+
+	keys, err := jwks.NewKeyRing(
+		signingKeyRepository(rconn),
+		env.EnvString("JWT_SIGNING_ALG", DefaultSigningAlg),
+		env.EnvDuration("JWT_KEY_ROTATION", DefaultKeyRotation),
+		DefaultKeyRetention,
+		keyPublishDelay,
+	)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go keys.Run(keyRefreshInterval, stop)
+
+	actionTokenRepo := redisdb.NewRedisActionTokenRepository(rconn)
+	mfaRepo := mfaRepository(rconn)
+	loginAttemptRepo := redisdb.NewRedisLoginAttemptRepository(rconn)
+	recoveryService := user.NewRecoveryService(userRepo, tokenRepo,
+		actionTokenRepo,
+		mailer(),
+		env.EnvString("PUBLIC_URL", DefaultPublicUrl),
+	)
+	recoveryHandler := user.NewRecoveryHandler(recoveryService, user.NewPasswordResetGuard(recoveryService, loginAttemptRepo))
+	userService := user.WithVerification(user.NewUserService(userRepo, tokenRepo, mfaRepo, actionTokenRepo, keys), recoveryService)
+	mfaHandler := user.NewMFAHandler(user.NewMFAService(userRepo, mfaRepo, env.EnvString("TOTP_ISSUER", DefaultTotpIssuer)))
+	passkeyService, err := user.NewPasskeyService(userRepo, actionTokenRepo, userService, &webauthn.Config{
+		RPID:          env.EnvString("WEBAUTHN_RP_ID", DefaultWebAuthnRPID),
+		RPDisplayName: env.EnvString("TOTP_ISSUER", DefaultTotpIssuer),
+		RPOrigins:     strings.Split(env.EnvString("WEBAUTHN_ORIGINS", DefaultPublicUrl), ","),
+	})
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	passkeyHandler := user.NewPasskeyHandler(passkeyService)
+	loginGuard := user.NewLoginGuard(userService, loginAttemptRepo)
+	userHandler := user.NewUserHandler(userService, loginGuard)
+	serviceAccountService := user.NewServiceAccountService(redisdb.NewRedisServiceAccountRepository(rconn))
+	serviceAccountHandler := user.NewServiceAccountHandler(serviceAccountService)
+	tokenConfig := middleware.ConfigFromEnv()
+	validator := middleware.WithAPIKeys(middleware.NewTokenValidator(keys, tokenConfig), serviceAccountService)
+
+	// OpenID Connect access tokens are only good for the userinfo endpoint
+	userInfoConfig := tokenConfig
+	userInfoConfig.Audience = tokenConfig.Issuer
+	userInfoValidator := middleware.NewTokenValidator(keys, userInfoConfig)
+	oidcService := user.NewOIDCService(redisdb.NewRedisOAuthClientRepository(rconn), actionTokenRepo, userRepo, keys)
+	// the consent page belongs to the frontend, which prompts through the
+	// /oauth/consent routes; without one authorization can never complete
+	consentURL := env.EnvString("OIDC_CONSENT_URL", "")
+	oidcHandler := user.NewOIDCHandler(oidcService, consentURL)
+
+	if admin != "" {
+		if _, err := userService.SetRoles(admin, &user.Roles{Role: rbac.RoleAdmin}); err != nil {
+			logrus.Fatal(err)
+		}
+	}
 
 	router := mux.NewRouter().StrictSlash(true)
 	router.HandleFunc("/account", userHandler.CreateAccount).Methods("POST")
 	router.HandleFunc("/auth", userHandler.GetToken).Methods("GET")
+	router.HandleFunc("/auth/mfa", userHandler.VerifyMFA).Methods("POST")
+	router.HandleFunc("/auth/passkey", passkeyHandler.BeginLogin).Methods("POST")
+	router.HandleFunc("/auth/passkey/{ceremonyId}", passkeyHandler.FinishLogin).Methods("POST")
+	router.HandleFunc("/auth/refresh", userHandler.RefreshToken).Methods("POST")
+	router.HandleFunc("/auth/logout", userHandler.Logout).Methods("POST")
+	self := func(h http.HandlerFunc) http.Handler {
+		return middleware.Authenticate(validator, h)
+	}
+	router.Handle("/account/me", self(userHandler.GetMe)).Methods("GET")
+	router.Handle("/account/me", self(userHandler.UpdateMe)).Methods("PATCH")
+	router.Handle("/account/me", self(userHandler.DeleteMe)).Methods("DELETE")
+	router.Handle("/account/me/password", self(userHandler.ChangePassword)).Methods("PUT")
+	router.Handle("/account/me/deactivate", self(userHandler.DeactivateMe)).Methods("POST")
+	router.Handle("/account/me/mfa", self(mfaHandler.Disable)).Methods("DELETE")
+	router.Handle("/account/me/mfa/totp", self(mfaHandler.Enroll)).Methods("POST")
+	router.Handle("/account/me/mfa/totp/qr.png", self(mfaHandler.QRCode)).Methods("GET")
+	router.Handle("/account/me/mfa/totp/confirm", self(mfaHandler.Confirm)).Methods("POST")
+	router.Handle("/account/me/mfa/recovery-codes", self(mfaHandler.RegenerateRecoveryCodes)).Methods("POST")
+	router.Handle("/account/me/passkeys", self(passkeyHandler.List)).Methods("GET")
+	router.Handle("/account/me/passkeys/registration", self(passkeyHandler.BeginRegistration)).Methods("POST")
+	router.Handle("/account/me/passkeys/registration/{ceremonyId}", self(passkeyHandler.FinishRegistration)).Methods("POST")
+	router.Handle("/account/me/passkeys/{id}", self(passkeyHandler.Delete)).Methods("DELETE")
+	router.Handle("/account/me/verification", self(recoveryHandler.ResendVerification)).Methods("POST")
+	router.Handle("/account/me/consents", self(oidcHandler.ListConsents)).Methods("GET")
+	router.Handle("/account/me/consents/{clientId}", self(oidcHandler.RevokeConsent)).Methods("DELETE")
+	router.HandleFunc("/account/verify", recoveryHandler.VerifyEmail).Methods("GET")
+	router.HandleFunc("/password-reset", recoveryHandler.RequestPasswordReset).Methods("POST")
+	router.HandleFunc("/password-reset/confirm", recoveryHandler.ResetPassword).Methods("POST")
+	router.Handle("/accounts", middleware.Authenticate(validator,
+		middleware.Require(rbac.AccountsList, http.HandlerFunc(userHandler.ListAccounts)))).Methods("GET")
+	router.Handle("/accounts/{username}/roles", middleware.Authenticate(validator,
+		middleware.Require(rbac.AccountsManage, http.HandlerFunc(userHandler.SetRoles)))).Methods("PUT")
+
+	manage := func(h http.HandlerFunc) http.Handler {
+		return middleware.Authenticate(validator, middleware.Require(rbac.AccountsManage, h))
+	}
+	router.Handle("/service-accounts", manage(serviceAccountHandler.List)).Methods("GET")
+	router.Handle("/service-accounts", manage(serviceAccountHandler.Create)).Methods("POST")
+	router.Handle("/service-accounts/{id}", manage(serviceAccountHandler.Delete)).Methods("DELETE")
+	router.Handle("/service-accounts/{id}/keys", manage(serviceAccountHandler.ListKeys)).Methods("GET")
+	router.Handle("/service-accounts/{id}/keys", manage(serviceAccountHandler.CreateKey)).Methods("POST")
+	router.Handle("/service-accounts/{id}/keys/{keyId}", manage(serviceAccountHandler.RevokeKey)).Methods("DELETE")
+	router.Handle("/oauth/clients", manage(oidcHandler.ListClients)).Methods("GET")
+	router.Handle("/oauth/clients", manage(oidcHandler.RegisterClient)).Methods("POST")
+	router.Handle("/oauth/clients/{id}", manage(oidcHandler.DeleteClient)).Methods("DELETE")
+
+	// OpenID Connect provider; the protocol endpoints are described by the
+	// discovery document rather than the OpenAPI document
+	if consentURL != "" {
+		router.HandleFunc(user.OIDCDiscoveryPath, oidcHandler.Discovery).Methods("GET")
+		router.HandleFunc(user.OIDCAuthorizePath, oidcHandler.Authorize).Methods("GET")
+		router.HandleFunc(user.OIDCTokenPath, oidcHandler.Token).Methods("POST")
+		router.Handle(user.OIDCUserInfoPath, middleware.Authenticate(userInfoValidator, http.HandlerFunc(oidcHandler.UserInfo))).Methods("GET", "POST")
+		router.Handle("/oauth/consent", self(oidcHandler.Prompt)).Methods("GET")
+		router.Handle("/oauth/consent", self(oidcHandler.Decide)).Methods("POST")
+	} else {
+		logrus.Warn("OIDC_CONSENT_URL is not set, the OpenID Connect provider is disabled")
+	}
+
+	doc := openapi.UserAPI()
+	router.Handle("/openapi.json", openapi.Handler(doc)).Methods("GET")
+	router.Handle(jwks.Path, jwks.Handler(keys)).Methods("GET")
 
-	http.Handle("/", accessControl(router))
+	http.Handle("/", requestid.Handler(accessControl(openapi.Validate(doc, router))))
 
-	errs := make(chan error, 2)
+	errs := make(chan error, 3)
+	if grpcMode {
+		grpcServer := rpc.NewServer(validator, rpc.UserPublicMethods...)
+		userpb.RegisterUserServiceServer(grpcServer, rpc.NewUserServer(userService, loginGuard))
+		go func() {
+			errs <- rpc.ListenAndServe(env.EnvString("GRPC_ADDRESS", DefaultGrpcAddress), grpcServer)
+		}()
+	}
 	go func() {
 		logrus.Info("Listening server mode on port :3000")
 		errs <- http.ListenAndServe(":3000", nil)
@@ -69,11 +245,66 @@ func redisConnect(url string, password string) *redis.Client {
 
 }
 
+// mfaRepository stores second factors sealed with MFA_SECRET_KEY, a base64
+// AES key shared by every service reading them.
+func mfaRepository(rconn *redis.Client) user.MFARepo {
+	key, err := env.EnvKey("MFA_SECRET_KEY")
+	if err != nil || key == nil {
+		logrus.Fatal("MFA_SECRET_KEY must be set to a base64 AES key")
+	}
+	repo, err := redisdb.NewRedisMFARepository(rconn, key)
+	if err != nil {
+		logrus.Fatal("Invalid MFA_SECRET_KEY: ", err)
+	}
+	return repo
+}
+
+// signingKeyRepository stores the JWT signing keys sealed with
+// SIGNING_SECRET_KEY, a base64 AES key shared by every service signing with
+// them.
+func signingKeyRepository(rconn *redis.Client) jwks.KeyStore {
+	key, err := env.EnvKey("SIGNING_SECRET_KEY")
+	if err != nil || key == nil {
+		logrus.Fatal("SIGNING_SECRET_KEY must be set to a base64 AES key")
+	}
+	repo, err := redisdb.NewRedisSigningKeyRepository(rconn, key)
+	if err != nil {
+		logrus.Fatal("Invalid SIGNING_SECRET_KEY: ", err)
+	}
+	return repo
+}
+
+// mailer relays through SMTP_ADDR, or logs mail when it is unset.
+func mailer() mail.Mailer {
+	addr := env.EnvString("SMTP_ADDR", "")
+	if addr == "" {
+		logrus.Warn("SMTP_ADDR not set, logging mail instead of sending it")
+		return mail.NewLogMailer()
+	}
+
+	var auth smtp.Auth
+	if username := env.EnvString("SMTP_USERNAME", ""); username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		auth = smtp.PlainAuth("", username, env.EnvString("SMTP_PASSWORD", ""), host)
+	}
+	return mail.NewSMTPMailer(addr, env.EnvString("SMTP_FROM", DefaultMailFrom), auth)
+}
+
+func postgresConnection(database string) *sql.DB {
+	logrus.Info("Connecting to PostgreSQL DB")
+	db, err := sql.Open("postgres", database)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	return db
+}
+
 func accessControl(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, "+requestid.Header)
+		w.Header().Set("Access-Control-Expose-Headers", requestid.Header+", Retry-After")
 
 		if r.Method == "OPTIONS" {
 			return
@@ -0,0 +1,71 @@
+package psql
+
+import (
+	"database/sql"
+	"hex-example/internal/user"
+)
+
+func (r *userRepository) GetUsersByEmail(email string) ([]*user.Account, error) {
+	rows, err := r.db.Query("SELECT "+accountColumns+" FROM accounts WHERE lower(email)=lower($1)", email)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []*user.Account{}
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+func (r *userRepository) GetUserByIdentity(provider, subject string) (*user.Account, error) {
+	account, err := scanAccount(r.db.QueryRow("SELECT "+accountColumns+" FROM accounts "+
+		"WHERE id=(SELECT account_id FROM identities WHERE provider=$1 AND subject=$2)", provider, subject))
+	if err == sql.ErrNoRows {
+		return nil, &user.NotFoundError{ID: provider + ":" + subject}
+	}
+	return account, err
+}
+
+func (r *userRepository) GetIdentity(provider, subject string) (*user.Identity, error) {
+	identity := &user.Identity{Provider: provider, Subject: subject}
+	err := r.db.QueryRow("SELECT account_id, linked, created FROM identities WHERE provider=$1 AND subject=$2", provider, subject).
+		Scan(&identity.AccountID, &identity.Linked, &identity.Created)
+	if err == sql.ErrNoRows {
+		return nil, &user.NotFoundError{ID: provider + ":" + subject}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+func (r *userRepository) LinkIdentity(identity *user.Identity) (bool, error) {
+	result, err := r.db.Exec("INSERT INTO identities(provider, subject, account_id, linked, created) VALUES ($1, $2, $3, $4, $5) "+
+		"ON CONFLICT (provider, subject) DO NOTHING",
+		identity.Provider, identity.Subject, identity.AccountID, identity.Linked, identity.Created)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *userRepository) UnlinkIdentity(provider, subject string) error {
+	result, err := r.db.Exec("DELETE FROM identities WHERE provider=$1 AND subject=$2", provider, subject)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return &user.NotFoundError{ID: provider + ":" + subject}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package psql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hex-example/internal/user"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const passkeyColumns = "id, account_id, name, created, last_used, credential"
+
+func (r *userRepository) SavePasskey(passkey *user.Passkey) error {
+	credential, err := json.Marshal(passkey.Credential)
+	if err != nil {
+		return err
+	}
+	lastUsed := sql.NullTime{Time: passkey.LastUsed, Valid: !passkey.LastUsed.IsZero()}
+	_, err = r.db.Exec("INSERT INTO passkeys("+passkeyColumns+") VALUES ($1, $2, $3, $4, $5, $6) "+
+		"ON CONFLICT (id) DO UPDATE SET name=$3, last_used=$5, credential=$6",
+		passkey.ID, passkey.AccountID, passkey.Name, passkey.Created, lastUsed, credential)
+	return err
+}
+
+func (r *userRepository) GetPasskeys(accountID string) ([]*user.Passkey, error) {
+	rows, err := r.db.Query("SELECT "+passkeyColumns+" FROM passkeys WHERE account_id=$1 ORDER BY created", accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passkeys := []*user.Passkey{}
+	for rows.Next() {
+		p := &user.Passkey{Credential: new(webauthn.Credential)}
+		var lastUsed sql.NullTime
+		var credential []byte
+		if err := rows.Scan(&p.ID, &p.AccountID, &p.Name, &p.Created, &lastUsed, &credential); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(credential, p.Credential); err != nil {
+			return nil, err
+		}
+		p.LastUsed = lastUsed.Time
+		passkeys = append(passkeys, p)
+	}
+	return passkeys, rows.Err()
+}
+
+func (r *userRepository) DeletePasskey(accountID, id string) error {
+	result, err := r.db.Exec("DELETE FROM passkeys WHERE account_id=$1 AND id=$2", accountID, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return &user.PasskeyNotFoundError{ID: id}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package psql
+
+import (
+	"database/sql"
+	"hex-example/internal/sessions"
+	"time"
+)
+
+type sessionRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresSessionRepository stores server-side sessions in the sessions
+// table. Expired rows are purged whenever a session is saved.
+func NewPostgresSessionRepository(db *sql.DB) sessions.Backend {
+	return &sessionRepository{
+		db,
+	}
+}
+
+func (r *sessionRepository) SaveSession(id, userID string, values []byte, ttl time.Duration) error {
+	if _, err := r.db.Exec("DELETE FROM sessions WHERE expires < now()"); err != nil {
+		return err
+	}
+	_, err := r.db.Exec("INSERT INTO sessions(id, user_id, data, expires) VALUES ($1, $2, $3, now() + $4 * interval '1 second') "+
+		"ON CONFLICT (id) DO UPDATE SET user_id=$2, data=$3, expires=now() + $4 * interval '1 second'",
+		id, userID, values, int64(ttl/time.Second))
+	return err
+}
+
+func (r *sessionRepository) GetSession(id string) ([]byte, error) {
+	var values []byte
+	err := r.db.QueryRow("SELECT data FROM sessions WHERE id=$1 AND expires > now()", id).Scan(&values)
+	if err == sql.ErrNoRows {
+		return nil, sessions.ErrSessionNotFound
+	}
+	return values, err
+}
+
+func (r *sessionRepository) TouchSession(id string, ttl time.Duration) error {
+	_, err := r.db.Exec("UPDATE sessions SET expires=now() + $2 * interval '1 second' WHERE id=$1", id, int64(ttl/time.Second))
+	return err
+}
+
+func (r *sessionRepository) DeleteSession(id string) error {
+	_, err := r.db.Exec("DELETE FROM sessions WHERE id=$1", id)
+	return err
+}
+
+func (r *sessionRepository) DeleteUserSessions(userID string) error {
+	_, err := r.db.Exec("DELETE FROM sessions WHERE user_id=$1", userID)
+	return err
+}
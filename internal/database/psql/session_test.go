@@ -0,0 +1,67 @@
+package psql
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"hex-example/internal/sessions"
+)
+
+func newTestSessionRepository(t *testing.T) (sqlmock.Sqlmock, sessions.Backend) {
+	db, mock, err := sqlmock.New()
+	require.Nil(t, err)
+	t.Cleanup(func() {
+		assert.Nil(t, mock.ExpectationsWereMet())
+		db.Close()
+	})
+	return mock, NewPostgresSessionRepository(db)
+}
+
+func TestSessionRepository_SaveSession(t *testing.T) {
+	mock, repo := newTestSessionRepository(t)
+	mock.ExpectExec(`DELETE FROM sessions WHERE expires < now\(\)`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO sessions\(id, user_id, data, expires\) .* ON CONFLICT \(id\) DO UPDATE`).
+		WithArgs("s1", "u1", []byte("values"), int64(3600)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.Nil(t, repo.SaveSession("s1", "u1", []byte("values"), time.Hour))
+}
+
+func TestSessionRepository_GetSession(t *testing.T) {
+	mock, repo := newTestSessionRepository(t)
+	mock.ExpectQuery(`SELECT data FROM sessions WHERE id=\$1 AND expires > now\(\)`).
+		WithArgs("s1").
+		WillReturnRows(sqlmock.NewRows([]string{"data"}).AddRow([]byte("values")))
+	mock.ExpectQuery(`SELECT data FROM sessions`).
+		WithArgs("expired").
+		WillReturnError(sql.ErrNoRows)
+
+	values, err := repo.GetSession("s1")
+	require.Nil(t, err)
+	assert.Equal(t, []byte("values"), values)
+	_, err = repo.GetSession("expired")
+	assert.Equal(t, sessions.ErrSessionNotFound, err)
+}
+
+func TestSessionRepository_TouchSession(t *testing.T) {
+	mock, repo := newTestSessionRepository(t)
+	mock.ExpectExec(`UPDATE sessions SET expires=now\(\) \+ \$2 \* interval '1 second' WHERE id=\$1`).
+		WithArgs("s1", int64(1800)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.Nil(t, repo.TouchSession("s1", 30*time.Minute))
+}
+
+func TestSessionRepository_DeleteUserSessions(t *testing.T) {
+	mock, repo := newTestSessionRepository(t)
+	mock.ExpectExec(`DELETE FROM sessions WHERE user_id=\$1`).
+		WithArgs("u1").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	assert.Nil(t, repo.DeleteUserSessions("u1"))
+}
@@ -5,7 +5,14 @@ import (
 	"hex-example/internal/ticket"
 	"log"
 
-	_ "github.com/lib/pq"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Postgres error codes translated into domain errors
+const (
+	uniqueViolation      = "23505"
+	invalidTextRepresent = "22P02" // e.g. an id that is not a uuid
 )
 
 type ticketRepository struct {
@@ -18,35 +25,85 @@ func NewPostgresTicketRepository(db *sql.DB) ticket.TicketRepository {
 	}
 }
 
-func (r *ticketRepository) Create(ticket *ticket.Ticket) error {
-	r.db.QueryRow("INSERT INTO tickets(creator, assigned, title, description, status, points, created, updated) "+
-		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
-		ticket.Creator, ticket.Assigned, ticket.Title, ticket.Description, ticket.Status, ticket.Points, ticket.Created, ticket.Updated).Scan(&ticket.ID)
-	return nil
+func (r *ticketRepository) Create(t *ticket.Ticket) error {
+	err := r.db.QueryRow("INSERT INTO tickets(project, creator, assigned, title, description, status, points, created, updated) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id",
+		t.Project, t.Creator, t.Assigned, t.Title, t.Description, t.Status, t.Points, t.Created, t.Updated).Scan(&t.ID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+		return &ticket.ConflictError{ID: t.ID}
+	}
+	return err
 }
 
 func (r *ticketRepository) FindById(id string) (*ticket.Ticket, error) {
-	ticket := new(ticket.Ticket)
-	err := r.db.QueryRow("SELECT id, creator, assigned, title, description, status, points, created, updated FROM tickets where id=$1", id).Scan(&ticket.ID, &ticket.Creator, &ticket.Assigned, &ticket.Title, &ticket.Description, &ticket.Status, &ticket.Points, &ticket.Created, &ticket.Updated)
+	t := new(ticket.Ticket)
+	err := r.db.QueryRow("SELECT id, project, creator, assigned, title, description, status, points, created, updated FROM tickets where id=$1", id).Scan(&t.ID, &t.Project, &t.Creator, &t.Assigned, &t.Title, &t.Description, &t.Status, &t.Points, &t.Created, &t.Updated)
+	if err == sql.ErrNoRows {
+		return nil, &ticket.NotFoundError{ID: id}
+	}
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == invalidTextRepresent {
+		return nil, &ticket.NotFoundError{ID: id}
+	}
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return ticket, nil
+	return t, nil
 }
 
 func (r *ticketRepository) FindAll() (tickets []*ticket.Ticket, err error) {
-	rows, err := r.db.Query("SELECT id, creator, assigned, title, description, status, points, created, updated FROM tickets")
+	rows, err := r.db.Query("SELECT id, project, creator, assigned, title, description, status, points, created, updated FROM tickets")
+	if err != nil {
+		return nil, err
+	}
+	return scanTickets(rows)
+}
+
+func (r *ticketRepository) FindByProject(project string) ([]*ticket.Ticket, error) {
+	rows, err := r.db.Query("SELECT id, project, creator, assigned, title, description, status, points, created, updated FROM tickets WHERE project=$1", project)
+	if err != nil {
+		return nil, err
+	}
+	return scanTickets(rows)
+}
+
+func (r *ticketRepository) FindByIDs(ids []string) ([]*ticket.Ticket, error) {
+	// ids that are not uuids cannot match and would fail the whole query
+	valid := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err == nil {
+			valid = append(valid, id)
+		}
+	}
+	if len(valid) == 0 {
+		return nil, nil
+	}
+	rows, err := r.db.Query("SELECT id, project, creator, assigned, title, description, status, points, created, updated FROM tickets WHERE id = ANY($1)", pq.Array(valid))
+	if err != nil {
+		return nil, err
+	}
+	return scanTickets(rows)
+}
+
+func (r *ticketRepository) FindByUsernames(usernames []string) ([]*ticket.Ticket, error) {
+	rows, err := r.db.Query("SELECT id, project, creator, assigned, title, description, status, points, created, updated FROM tickets WHERE creator = ANY($1) OR assigned = ANY($1)", pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	return scanTickets(rows)
+}
+
+func scanTickets(rows *sql.Rows) (tickets []*ticket.Ticket, err error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		ticket := new(ticket.Ticket)
-		if err = rows.Scan(&ticket.ID, &ticket.Creator, &ticket.Assigned, &ticket.Title, &ticket.Description, &ticket.Status, &ticket.Points, &ticket.Created, &ticket.Updated); err != nil {
+		t := new(ticket.Ticket)
+		if err = rows.Scan(&t.ID, &t.Project, &t.Creator, &t.Assigned, &t.Title, &t.Description, &t.Status, &t.Points, &t.Created, &t.Updated); err != nil {
 			log.Print(err)
 			return nil, err
 		}
 
-		tickets = append(tickets, ticket)
+		tickets = append(tickets, t)
 
 	}
-	return tickets, nil
+	return tickets, rows.Err()
 }
@@ -0,0 +1,149 @@
+package psql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hex-example/internal/user"
+
+	"github.com/lib/pq"
+)
+
+const accountColumns = "id, username, first_name, last_name, password, email, email_verified, role, projects, deactivated"
+
+type userRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresUserRepository(db *sql.DB) user.UserRepo {
+	return &userRepository{
+		db,
+	}
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAccount(row scanner) (*user.Account, error) {
+	a := new(user.Account)
+	var projects []byte
+	if err := row.Scan(&a.ID, &a.Username, &a.FirstName, &a.LastName, &a.Password, &a.Email, &a.EmailVerified, &a.Role, &projects, &a.Deactivated); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(projects, &a.Projects); err != nil {
+		return nil, err
+	}
+	if len(a.Projects) == 0 {
+		a.Projects = nil
+	}
+	return a, nil
+}
+
+func encodeProjects(projects map[string]string) ([]byte, error) {
+	if projects == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(projects)
+}
+
+func (r *userRepository) CreateAccount(account *user.Account) error {
+	projects, err := encodeProjects(account.Projects)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec("INSERT INTO accounts("+accountColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		account.ID, account.Username, account.FirstName, account.LastName, account.Password, account.Email, account.EmailVerified, account.Role, projects, account.Deactivated)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+		return &user.ConflictError{Username: account.Username}
+	}
+	return err
+}
+
+func (r *userRepository) GetUser(username string) (*user.Account, error) {
+	account, err := scanAccount(r.db.QueryRow("SELECT "+accountColumns+" FROM accounts WHERE username=$1", username))
+	if err == sql.ErrNoRows {
+		return nil, &user.NotFoundError{Username: username}
+	}
+	return account, err
+}
+
+func (r *userRepository) GetUserByID(id string) (*user.Account, error) {
+	account, err := scanAccount(r.db.QueryRow("SELECT "+accountColumns+" FROM accounts WHERE id=$1", id))
+	if err == sql.ErrNoRows {
+		return nil, &user.NotFoundError{ID: id}
+	}
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == invalidTextRepresent {
+		return nil, &user.NotFoundError{ID: id}
+	}
+	return account, err
+}
+
+func (r *userRepository) GetUsers(usernames []string) ([]*user.Account, error) {
+	accounts := make([]*user.Account, len(usernames))
+	if len(usernames) == 0 {
+		return accounts, nil
+	}
+
+	rows, err := r.db.Query("SELECT "+accountColumns+" FROM accounts WHERE username = ANY($1)", pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[string]*user.Account, len(usernames))
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		found[account.Username] = account
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, username := range usernames {
+		accounts[i] = found[username]
+	}
+	return accounts, nil
+}
+
+func (r *userRepository) ListUsers() ([]*user.Account, error) {
+	rows, err := r.db.Query("SELECT " + accountColumns + " FROM accounts ORDER BY username")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*user.Account
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+func (r *userRepository) UpdateAccount(account *user.Account) error {
+	projects, err := encodeProjects(account.Projects)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec("UPDATE accounts SET first_name=$2, last_name=$3, password=$4, email=$5, email_verified=$6, role=$7, projects=$8, deactivated=$9 WHERE username=$1",
+		account.Username, account.FirstName, account.LastName, account.Password, account.Email, account.EmailVerified, account.Role, projects, account.Deactivated)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return &user.NotFoundError{Username: account.Username}
+	}
+	return nil
+}
+
+func (r *userRepository) DeleteAccount(account *user.Account) error {
+	_, err := r.db.Exec("DELETE FROM accounts WHERE id=$1", account.ID)
+	return err
+}
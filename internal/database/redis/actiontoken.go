@@ -0,0 +1,65 @@
+package redis
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/user"
+)
+
+const actionTokenPrefix = "action_tokens:"
+
+type actionTokenRepository struct {
+	connection *redis.Client
+}
+
+func NewRedisActionTokenRepository(connection *redis.Client) user.ActionTokenRepo {
+	return &actionTokenRepository{
+		connection,
+	}
+}
+
+func actionTokenKey(purpose, hash string) string {
+	return actionTokenPrefix + purpose + ":" + hash
+}
+
+func (r *actionTokenRepository) SaveActionToken(token *user.ActionToken) error {
+	encoded, err := json.Marshal(token)
+	if err != nil {
+		logrus.Error("Unable to marshal action token")
+		return err
+	}
+
+	if err := r.connection.Set(actionTokenKey(token.Purpose, token.Hash), encoded, time.Until(token.Expires)).Err(); err != nil {
+		logrus.WithField("error", err).Error("Unable to save action token")
+		return err
+	}
+	return nil
+}
+
+func (r *actionTokenRepository) TakeActionToken(purpose, hash string) (*user.ActionToken, error) {
+	key := actionTokenKey(purpose, hash)
+	var get *redis.StringCmd
+	// GET and DEL in one transaction so a token can only be taken once
+	_, err := r.connection.TxPipelined(func(pipe redis.Pipeliner) error {
+		get = pipe.Get(key)
+		pipe.Del(key)
+		return nil
+	})
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch action token")
+		return nil, err
+	}
+
+	t := new(user.ActionToken)
+	if err := json.Unmarshal([]byte(get.Val()), t); err != nil {
+		logrus.Error("Unable to unmarshal action token")
+		return nil, err
+	}
+	return t, nil
+}
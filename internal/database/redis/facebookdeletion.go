@@ -0,0 +1,58 @@
+package redis
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/facebook"
+)
+
+const (
+	facebookDeletionPrefix = "facebook_deletions:" // confirmation code -> deletion request
+	// facebookDeletionTTL is how long users can check on a deletion request
+	facebookDeletionTTL = 90 * 24 * time.Hour
+)
+
+type facebookDeletionRepository struct {
+	connection *redis.Client
+}
+
+// NewRedisFacebookDeletionRepository keeps Facebook data deletion requests
+// for 90 days.
+func NewRedisFacebookDeletionRepository(connection *redis.Client) facebook.DeletionStore {
+	return &facebookDeletionRepository{
+		connection,
+	}
+}
+
+func (r *facebookDeletionRepository) SaveDeletion(deletion *facebook.Deletion) error {
+	encoded, err := json.Marshal(deletion)
+	if err != nil {
+		logrus.Error("Unable to marshal deletion request")
+		return err
+	}
+	if err := r.connection.Set(facebookDeletionPrefix+deletion.ConfirmationCode, encoded, facebookDeletionTTL).Err(); err != nil {
+		logrus.WithField("error", err).Error("Unable to save deletion request")
+		return err
+	}
+	return nil
+}
+
+func (r *facebookDeletionRepository) GetDeletion(code string) (*facebook.Deletion, error) {
+	b, err := r.connection.Get(facebookDeletionPrefix + code).Bytes()
+	if err == redis.Nil {
+		return nil, facebook.ErrDeletionNotFound
+	}
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch deletion request")
+		return nil, err
+	}
+	deletion := new(facebook.Deletion)
+	if err := json.Unmarshal(b, deletion); err != nil {
+		logrus.Error("Unable to unmarshal deletion request")
+		return nil, err
+	}
+	return deletion, nil
+}
@@ -0,0 +1,81 @@
+package redis
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/facebook"
+)
+
+const (
+	facebookTokensKey      = "facebook_tokens"       // user id -> sealed token
+	facebookTokenExpiryKey = "facebook_token_expiry" // sorted set of user ids by expiry
+)
+
+type facebookTokenRepository struct {
+	connection *redis.Client
+}
+
+// NewRedisFacebookTokenRepository stores sealed Facebook tokens, indexed by
+// expiry so they can be refreshed in time.
+func NewRedisFacebookTokenRepository(connection *redis.Client) facebook.TokenStore {
+	return &facebookTokenRepository{
+		connection,
+	}
+}
+
+func (r *facebookTokenRepository) SaveToken(userID string, sealed []byte, expiresAt time.Time) error {
+	_, err := r.connection.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.HSet(facebookTokensKey, userID, sealed)
+		if expiresAt.IsZero() {
+			pipe.ZRem(facebookTokenExpiryKey, userID)
+		} else {
+			pipe.ZAdd(facebookTokenExpiryKey, redis.Z{Score: float64(expiresAt.Unix()), Member: userID})
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"userId": userID, "error": err}).Error("Unable to save Facebook token")
+		return err
+	}
+	return nil
+}
+
+func (r *facebookTokenRepository) GetToken(userID string) ([]byte, error) {
+	sealed, err := r.connection.HGet(facebookTokensKey, userID).Bytes()
+	if err == redis.Nil {
+		return nil, facebook.ErrTokenNotFound
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"userId": userID, "error": err}).Error("Unable to fetch Facebook token")
+		return nil, err
+	}
+	return sealed, nil
+}
+
+func (r *facebookTokenRepository) DeleteToken(userID string) error {
+	_, err := r.connection.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.HDel(facebookTokensKey, userID)
+		pipe.ZRem(facebookTokenExpiryKey, userID)
+		return nil
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"userId": userID, "error": err}).Error("Unable to delete Facebook token")
+		return err
+	}
+	return nil
+}
+
+func (r *facebookTokenRepository) ExpiringTokens(before time.Time) ([]string, error) {
+	userIDs, err := r.connection.ZRangeByScore(facebookTokenExpiryKey, redis.ZRangeBy{
+		Min: "-inf",
+		Max: "(" + strconv.FormatInt(before.Unix(), 10),
+	}).Result()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch expiring Facebook tokens")
+		return nil, err
+	}
+	return userIDs, nil
+}
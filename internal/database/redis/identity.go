@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/user"
+)
+
+const (
+	identityPrefix        = "identities:"         // provider:subject -> identity
+	accountIdentityPrefix = "account_identities:" // account id -> identity keys
+)
+
+// GetUsersByEmail scans every account since there is no index on email. It
+// is only used on the first login of an external identity.
+func (r *userRepository) GetUsersByEmail(email string) ([]*user.Account, error) {
+	accounts, err := r.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	matching := []*user.Account{}
+	for _, account := range accounts {
+		if strings.EqualFold(account.Email, email) {
+			matching = append(matching, account)
+		}
+	}
+	return matching, nil
+}
+
+func (r *userRepository) GetUserByIdentity(provider, subject string) (*user.Account, error) {
+	identity, err := r.GetIdentity(provider, subject)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetUserByID(identity.AccountID)
+}
+
+func (r *userRepository) GetIdentity(provider, subject string) (*user.Identity, error) {
+	b, err := r.connection.Get(identityPrefix + provider + ":" + subject).Bytes()
+	if err == redis.Nil {
+		return nil, &user.NotFoundError{ID: provider + ":" + subject}
+	}
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch identity")
+		return nil, err
+	}
+
+	identity := new(user.Identity)
+	if err := json.Unmarshal(b, identity); err != nil {
+		logrus.Error("Unable to unmarshal identity")
+		return nil, err
+	}
+	return identity, nil
+}
+
+// linkScript sets the identity and indexes it under its account, unless
+// the identity is set already.
+var linkScript = redis.NewScript(`
+if redis.call("SETNX", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call("SADD", KEYS[2], KEYS[1])
+return 1
+`)
+
+func (r *userRepository) LinkIdentity(identity *user.Identity) (bool, error) {
+	encoded, err := json.Marshal(identity)
+	if err != nil {
+		logrus.Error("Unable to marshal identity")
+		return false, err
+	}
+
+	key := identityPrefix + identity.Provider + ":" + identity.Subject
+	linked, err := linkScript.Run(r.connection, []string{key, accountIdentityPrefix + identity.AccountID}, encoded).Int()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to link identity")
+		return false, err
+	}
+	return linked == 1, nil
+}
+
+func (r *userRepository) UnlinkIdentity(provider, subject string) error {
+	identity, err := r.GetIdentity(provider, subject)
+	if err != nil {
+		return err
+	}
+	key := identityPrefix + provider + ":" + subject
+	pipe := r.connection.TxPipeline()
+	pipe.Del(key)
+	pipe.SRem(accountIdentityPrefix+identity.AccountID, key)
+	if _, err := pipe.Exec(); err != nil {
+		logrus.WithField("error", err).Error("Unable to unlink identity")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/user"
+)
+
+const (
+	loginFailurePrefix = "login_failures:"
+	loginBlockPrefix   = "login_blocks:"
+)
+
+type loginAttemptRepository struct {
+	connection *redis.Client
+}
+
+func NewRedisLoginAttemptRepository(connection *redis.Client) user.LoginAttemptRepo {
+	return &loginAttemptRepository{
+		connection,
+	}
+}
+
+func (r *loginAttemptRepository) Reserve(key string, window time.Duration) (int, error) {
+	pipe := r.connection.TxPipeline()
+	incr := pipe.Incr(loginFailurePrefix + key)
+	pipe.Expire(loginFailurePrefix+key, window)
+	if _, err := pipe.Exec(); err != nil {
+		logrus.WithField("error", err).Error("Unable to count login attempt")
+		return 0, err
+	}
+	return int(incr.Val()), nil
+}
+
+// releaseScript decrements an attempt count, deleting it rather than
+// recreating it without an expiry if it expired meanwhile.
+var releaseScript = redis.NewScript(`
+if redis.call("DECR", KEYS[1]) <= 0 then
+	redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func (r *loginAttemptRepository) Release(key string) error {
+	if err := releaseScript.Run(r.connection, []string{loginFailurePrefix + key}).Err(); err != nil {
+		logrus.WithField("error", err).Error("Unable to release login attempt")
+		return err
+	}
+	return nil
+}
+
+func (r *loginAttemptRepository) Block(key string, until time.Time) (bool, error) {
+	blocked, err := r.connection.SetNX(loginBlockPrefix+key, until.UnixNano(), time.Until(until)).Result()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to block login")
+		return false, err
+	}
+	return blocked, nil
+}
+
+func (r *loginAttemptRepository) BlockedUntil(key string) (time.Time, error) {
+	nanos, err := r.connection.Get(loginBlockPrefix + key).Int64()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch login block")
+		return time.Time{}, err
+	}
+	return time.Unix(0, nanos), nil
+}
+
+func (r *loginAttemptRepository) Reset(key string) error {
+	if err := r.connection.Del(loginFailurePrefix+key, loginBlockPrefix+key).Err(); err != nil {
+		logrus.WithField("error", err).Error("Unable to reset login failures")
+		return err
+	}
+	return nil
+}
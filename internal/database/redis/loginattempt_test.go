@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginAttemptRepository(t *testing.T) {
+	server := miniredis.RunT(t)
+	connection := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { connection.Close() })
+	repo := NewRedisLoginAttemptRepository(connection)
+
+	for want := 1; want <= 2; want++ {
+		count, err := repo.Reserve("ip:1", time.Minute)
+		require.Nil(t, err)
+		assert.Equal(t, want, count)
+	}
+	require.Nil(t, repo.Release("ip:1"))
+	count, err := repo.Reserve("ip:1", time.Minute)
+	require.Nil(t, err)
+	assert.Equal(t, 2, count, "released attempts are not counted")
+
+	server.FastForward(time.Minute)
+	require.Nil(t, repo.Release("ip:1"))
+	assert.False(t, server.Exists(loginFailurePrefix+"ip:1"), "an expired count is not recreated")
+
+	until := time.Now().Add(time.Minute)
+	blocked, err := repo.Block("ip:1", until)
+	require.Nil(t, err)
+	assert.True(t, blocked)
+	blocked, err = repo.Block("ip:1", until.Add(time.Minute))
+	require.Nil(t, err)
+	assert.False(t, blocked, "only one attempt sets the block")
+
+	blockedUntil, err := repo.BlockedUntil("ip:1")
+	require.Nil(t, err)
+	assert.Equal(t, until.UnixNano(), blockedUntil.UnixNano())
+}
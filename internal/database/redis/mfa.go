@@ -0,0 +1,120 @@
+package redis
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/user"
+)
+
+const mfaTable = "mfa" // account id -> second factor
+
+// storedMFA is a second factor with its TOTP secret sealed.
+type storedMFA struct {
+	user.MFA
+	SealedSecret []byte `json:"sealedSecret,omitempty"`
+}
+
+type mfaRepository struct {
+	connection *redis.Client
+	aead       cipher.AEAD
+}
+
+// NewRedisMFARepository stores second factors with their TOTP secrets
+// sealed by AES-GCM with key and bound to the account ID, so that a secret
+// cannot be read from redis or moved to another account.
+func NewRedisMFARepository(connection *redis.Client, key []byte) (user.MFARepo, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &mfaRepository{
+		connection,
+		aead,
+	}, nil
+}
+
+func (r *mfaRepository) GetMFA(accountID string) (*user.MFA, error) {
+	b, err := r.connection.HGet(mfaTable, accountID).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch second factor")
+		return nil, err
+	}
+
+	stored := new(storedMFA)
+	if err := json.Unmarshal(b, stored); err != nil {
+		logrus.Error("Unable to unmarshal second factor")
+		return nil, err
+	}
+	// secrets saved before they were sealed are sealed on the next save
+	if stored.SealedSecret != nil {
+		secret, err := r.open(accountID, stored.SealedSecret)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"id": accountID, "error": err}).Error("Unable to open second factor secret")
+			return nil, err
+		}
+		stored.Secret = secret
+	}
+	return &stored.MFA, nil
+}
+
+func (r *mfaRepository) SaveMFA(mfa *user.MFA) error {
+	sealed, err := r.seal(mfa.AccountID, mfa.Secret)
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to seal second factor secret")
+		return err
+	}
+	stored := &storedMFA{MFA: *mfa, SealedSecret: sealed}
+	stored.Secret = ""
+	encoded, err := json.Marshal(stored)
+	if err != nil {
+		logrus.Error("Unable to marshal second factor")
+		return err
+	}
+	if err := r.connection.HSet(mfaTable, mfa.AccountID, encoded).Err(); err != nil {
+		logrus.WithField("error", err).Error("Unable to save second factor")
+		return err
+	}
+	return nil
+}
+
+func (r *mfaRepository) DeleteMFA(accountID string) error {
+	if err := r.connection.HDel(mfaTable, accountID).Err(); err != nil {
+		logrus.WithField("error", err).Error("Unable to delete second factor")
+		return err
+	}
+	return nil
+}
+
+// seal encrypts secret with the account ID as additional data.
+func (r *mfaRepository) seal(accountID, secret string) ([]byte, error) {
+	nonce := make([]byte, r.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return r.aead.Seal(nonce, nonce, []byte(secret), []byte(accountID)), nil
+}
+
+func (r *mfaRepository) open(accountID string, sealed []byte) (string, error) {
+	size := r.aead.NonceSize()
+	if len(sealed) < size {
+		return "", errors.New("sealed secret too short")
+	}
+	secret, err := r.aead.Open(nil, sealed[:size], sealed[size:], []byte(accountID))
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
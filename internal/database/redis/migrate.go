@@ -0,0 +1,121 @@
+package redis
+
+import (
+	"encoding/json"
+
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/rbac"
+	"hex-example/internal/ticket"
+	"hex-example/internal/user"
+)
+
+const migrationsKey = "migrations" // names of the applied migrations
+
+// migration changes existing data once for a new version of a repository.
+type migration struct {
+	name  string
+	apply func(connection *redis.Client) error
+}
+
+var migrations = []migration{
+	{"user_ids", indexUserIDs},
+	{"user_tickets", indexUserTickets},
+	{"member_role", assignMigratedRole},
+}
+
+// Migrate applies the migrations that were not applied to the database yet.
+// Migrations are idempotent, so instances racing at startup do no harm.
+func Migrate(connection *redis.Client) error {
+	for _, m := range migrations {
+		applied, err := connection.SIsMember(migrationsKey, m.name).Result()
+		if err != nil {
+			logrus.WithField("error", err).Error("Unable to fetch migrations")
+			return err
+		}
+		if applied {
+			continue
+		}
+		if err := m.apply(connection); err != nil {
+			logrus.WithFields(logrus.Fields{"migration": m.name, "error": err}).Error("Unable to migrate")
+			return err
+		}
+		if err := connection.SAdd(migrationsKey, m.name).Err(); err != nil {
+			logrus.WithField("error", err).Error("Unable to record migration")
+			return err
+		}
+		logrus.WithField("migration", m.name).Info("Migrated")
+	}
+	return nil
+}
+
+// indexUserIDs indexes the accounts created before the id index existed.
+func indexUserIDs(connection *redis.Client) error {
+	values, err := connection.HGetAll(userTable).Result()
+	if err != nil {
+		return err
+	}
+	index := make(map[string]interface{}, len(values))
+	for username, encoded := range values {
+		account := new(user.Account)
+		if err := json.Unmarshal([]byte(encoded), account); err != nil {
+			return err
+		}
+		if account.ID != "" {
+			index[account.ID] = username
+		}
+	}
+	if len(index) == 0 {
+		return nil
+	}
+	return connection.HMSet(userIDIndex, index).Err()
+}
+
+// assignMigratedRole keeps the access of the accounts created while an
+// empty role meant member. Accounts created later start without a role.
+func assignMigratedRole(connection *redis.Client) error {
+	values, err := connection.HGetAll(userTable).Result()
+	if err != nil {
+		return err
+	}
+	updated := make(map[string]interface{})
+	for username, encoded := range values {
+		account := new(user.Account)
+		if err := json.Unmarshal([]byte(encoded), account); err != nil {
+			return err
+		}
+		if account.Role != "" {
+			continue
+		}
+		account.Role = rbac.MigratedRole
+		b, err := json.Marshal(account)
+		if err != nil {
+			return err
+		}
+		updated[username] = b
+	}
+	if len(updated) == 0 {
+		return nil
+	}
+	return connection.HMSet(userTable, updated).Err()
+}
+
+// indexUserTickets indexes the tickets created before the user index
+// existed.
+func indexUserTickets(connection *redis.Client) error {
+	values, err := connection.HGetAll(ticketTable).Result()
+	if err != nil || len(values) == 0 {
+		return err
+	}
+	pipe := connection.Pipeline()
+	for id, encoded := range values {
+		t := new(ticket.Ticket)
+		if err := json.Unmarshal([]byte(encoded), t); err != nil {
+			return err
+		}
+		t.ID = id
+		indexTicketUsers(pipe, t)
+	}
+	_, err = pipe.Exec()
+	return err
+}
@@ -0,0 +1,35 @@
+package redis
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"hex-example/internal/rbac"
+	"hex-example/internal/user"
+)
+
+func TestMigrate_AssignsMigratedRole(t *testing.T) {
+	server := miniredis.RunT(t)
+	connection := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { connection.Close() })
+	repo := NewRedisUserRepository(connection)
+	// accounts saved while an empty role meant member
+	for _, account := range []*user.Account{{Username: "joel"}, {Username: "ivy", Role: rbac.RoleViewer}} {
+		encoded, _ := json.Marshal(account)
+		require.Nil(t, connection.HSet(userTable, account.Username, encoded).Err())
+	}
+
+	require.Nil(t, Migrate(connection))
+	require.Nil(t, connection.HSet(userTable, "new", `{"username":"new"}`).Err())
+	require.Nil(t, Migrate(connection))
+
+	for username, role := range map[string]string{"joel": rbac.MigratedRole, "ivy": rbac.RoleViewer, "new": ""} {
+		account, err := repo.GetUser(username)
+		require.Nil(t, err)
+		assert.Equal(t, role, account.Role, username)
+	}
+}
@@ -0,0 +1,144 @@
+package redis
+
+import (
+	"encoding/json"
+
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/user"
+)
+
+const (
+	oauthClientTable   = "oauth_clients"
+	oauthConsentPrefix = "oauth_consents:"
+)
+
+type oauthClientRepository struct {
+	connection *redis.Client
+}
+
+// NewRedisOAuthClientRepository stores clients in one hash and the consents
+// of each account in a hash keyed by client ID.
+func NewRedisOAuthClientRepository(connection *redis.Client) user.OAuthClientRepo {
+	return &oauthClientRepository{
+		connection,
+	}
+}
+
+func (r *oauthClientRepository) CreateClient(client *user.OAuthClient) error {
+	encoded, err := json.Marshal(client)
+	if err != nil {
+		logrus.Error("Unable to marshal OAuth client")
+		return err
+	}
+	if err := r.connection.HSet(oauthClientTable, client.ID, encoded).Err(); err != nil {
+		logrus.WithField("error", err).Error("Unable to save OAuth client")
+		return err
+	}
+	return nil
+}
+
+func (r *oauthClientRepository) GetClient(id string) (*user.OAuthClient, error) {
+	b, err := r.connection.HGet(oauthClientTable, id).Bytes()
+
+	if err == redis.Nil {
+		return nil, &user.OAuthClientNotFoundError{ID: id}
+	}
+	if err != nil {
+		logrus.WithField("clientId", id).Error("Unable to fetch OAuth client")
+		return nil, err
+	}
+
+	client := new(user.OAuthClient)
+	if err := json.Unmarshal(b, client); err != nil {
+		logrus.WithField("clientId", id).Error("Unable to unmarshal OAuth client")
+		return nil, err
+	}
+	return client, nil
+}
+
+func (r *oauthClientRepository) ListClients() ([]*user.OAuthClient, error) {
+	values, err := r.connection.HVals(oauthClientTable).Result()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to list OAuth clients")
+		return nil, err
+	}
+
+	clients := make([]*user.OAuthClient, 0, len(values))
+	for _, encoded := range values {
+		client := new(user.OAuthClient)
+		if err := json.Unmarshal([]byte(encoded), client); err != nil {
+			logrus.Error("Unable to unmarshal OAuth client")
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+func (r *oauthClientRepository) DeleteClient(id string) error {
+	if err := r.connection.HDel(oauthClientTable, id).Err(); err != nil {
+		logrus.WithField("error", err).Error("Unable to delete OAuth client")
+		return err
+	}
+	return nil
+}
+
+func (r *oauthClientRepository) SaveConsent(consent *user.Consent) error {
+	encoded, err := json.Marshal(consent)
+	if err != nil {
+		logrus.Error("Unable to marshal consent")
+		return err
+	}
+	if err := r.connection.HSet(oauthConsentPrefix+consent.AccountID, consent.ClientID, encoded).Err(); err != nil {
+		logrus.WithField("error", err).Error("Unable to save consent")
+		return err
+	}
+	return nil
+}
+
+func (r *oauthClientRepository) GetConsent(accountID, clientID string) (*user.Consent, error) {
+	b, err := r.connection.HGet(oauthConsentPrefix+accountID, clientID).Bytes()
+
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch consent")
+		return nil, err
+	}
+
+	consent := new(user.Consent)
+	if err := json.Unmarshal(b, consent); err != nil {
+		logrus.Error("Unable to unmarshal consent")
+		return nil, err
+	}
+	return consent, nil
+}
+
+func (r *oauthClientRepository) ListConsents(accountID string) ([]*user.Consent, error) {
+	values, err := r.connection.HVals(oauthConsentPrefix + accountID).Result()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to list consents")
+		return nil, err
+	}
+
+	consents := make([]*user.Consent, 0, len(values))
+	for _, encoded := range values {
+		consent := new(user.Consent)
+		if err := json.Unmarshal([]byte(encoded), consent); err != nil {
+			logrus.Error("Unable to unmarshal consent")
+			return nil, err
+		}
+		consents = append(consents, consent)
+	}
+	return consents, nil
+}
+
+func (r *oauthClientRepository) DeleteConsent(accountID, clientID string) error {
+	if err := r.connection.HDel(oauthConsentPrefix+accountID, clientID).Err(); err != nil {
+		logrus.WithField("error", err).Error("Unable to delete consent")
+		return err
+	}
+	return nil
+}
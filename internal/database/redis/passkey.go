@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/user"
+)
+
+const passkeyPrefix = "passkeys:" // account id -> credential id -> passkey
+
+func (r *userRepository) SavePasskey(passkey *user.Passkey) error {
+	encoded, err := json.Marshal(passkey)
+	if err != nil {
+		logrus.Error("Unable to marshal passkey")
+		return err
+	}
+	if err := r.connection.HSet(passkeyPrefix+passkey.AccountID, passkey.ID, encoded).Err(); err != nil {
+		logrus.WithField("error", err).Error("Unable to save passkey")
+		return err
+	}
+	return nil
+}
+
+func (r *userRepository) GetPasskeys(accountID string) ([]*user.Passkey, error) {
+	values, err := r.connection.HVals(passkeyPrefix + accountID).Result()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch passkeys")
+		return nil, err
+	}
+
+	passkeys := make([]*user.Passkey, 0, len(values))
+	for _, encoded := range values {
+		p := new(user.Passkey)
+		if err := json.Unmarshal([]byte(encoded), p); err != nil {
+			logrus.Error("Unable to unmarshal passkey")
+			return nil, err
+		}
+		passkeys = append(passkeys, p)
+	}
+	sort.Slice(passkeys, func(i, j int) bool { return passkeys[i].Created.Before(passkeys[j].Created) })
+	return passkeys, nil
+}
+
+func (r *userRepository) DeletePasskey(accountID, id string) error {
+	deleted, err := r.connection.HDel(passkeyPrefix+accountID, id).Result()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to delete passkey")
+		return err
+	}
+	if deleted == 0 {
+		return &user.PasskeyNotFoundError{ID: id}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package redis
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/user"
+)
+
+const (
+	serviceAccountTable      = "service_accounts"
+	apiKeyTable              = "api_keys"
+	serviceAccountKeysPrefix = "service_account_keys:"
+)
+
+type serviceAccountRepository struct {
+	connection *redis.Client
+}
+
+func NewRedisServiceAccountRepository(connection *redis.Client) user.ServiceAccountRepo {
+	return &serviceAccountRepository{
+		connection,
+	}
+}
+
+func (r *serviceAccountRepository) CreateServiceAccount(account *user.ServiceAccount) error {
+	encoded, err := json.Marshal(account)
+	if err != nil {
+		logrus.Error("Unable to marshal service account")
+		return err
+	}
+	if err := r.connection.HSet(serviceAccountTable, account.ID, encoded).Err(); err != nil {
+		logrus.WithField("error", err).Error("Unable to save service account")
+		return err
+	}
+	return nil
+}
+
+func (r *serviceAccountRepository) GetServiceAccount(id string) (*user.ServiceAccount, error) {
+	b, err := r.connection.HGet(serviceAccountTable, id).Bytes()
+
+	if err == redis.Nil {
+		return nil, &user.ServiceAccountNotFoundError{ID: id}
+	}
+	if err != nil {
+		logrus.WithField("id", id).Error("Unable to fetch service account")
+		return nil, err
+	}
+
+	account := new(user.ServiceAccount)
+	if err := json.Unmarshal(b, account); err != nil {
+		logrus.WithField("id", id).Error("Unable to unmarshal service account")
+		return nil, err
+	}
+	return account, nil
+}
+
+func (r *serviceAccountRepository) ListServiceAccounts() ([]*user.ServiceAccount, error) {
+	values, err := r.connection.HVals(serviceAccountTable).Result()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to list service accounts")
+		return nil, err
+	}
+
+	accounts := make([]*user.ServiceAccount, 0, len(values))
+	for _, encoded := range values {
+		account := new(user.ServiceAccount)
+		if err := json.Unmarshal([]byte(encoded), account); err != nil {
+			logrus.Error("Unable to unmarshal service account")
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+func (r *serviceAccountRepository) DeleteServiceAccount(id string) error {
+	keysKey := serviceAccountKeysPrefix + id
+	keyIDs, err := r.connection.SMembers(keysKey).Result()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch service account keys")
+		return err
+	}
+
+	pipe := r.connection.TxPipeline()
+	pipe.HDel(serviceAccountTable, id)
+	if len(keyIDs) > 0 {
+		pipe.HDel(apiKeyTable, keyIDs...)
+	}
+	pipe.Del(keysKey)
+	if _, err := pipe.Exec(); err != nil {
+		logrus.WithField("error", err).Error("Unable to delete service account")
+		return err
+	}
+	return nil
+}
+
+func (r *serviceAccountRepository) SaveAPIKey(key *user.APIKey) error {
+	encoded, err := json.Marshal(key)
+	if err != nil {
+		logrus.Error("Unable to marshal API key")
+		return err
+	}
+
+	pipe := r.connection.TxPipeline()
+	pipe.HSet(apiKeyTable, key.ID, encoded)
+	pipe.SAdd(serviceAccountKeysPrefix+key.ServiceAccountID, key.ID)
+	if _, err := pipe.Exec(); err != nil {
+		logrus.WithField("error", err).Error("Unable to save API key")
+		return err
+	}
+	return nil
+}
+
+func (r *serviceAccountRepository) GetAPIKey(id string) (*user.APIKey, error) {
+	b, err := r.connection.HGet(apiKeyTable, id).Bytes()
+
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch API key")
+		return nil, err
+	}
+
+	key := new(user.APIKey)
+	if err := json.Unmarshal(b, key); err != nil {
+		logrus.Error("Unable to unmarshal API key")
+		return nil, err
+	}
+	return key, nil
+}
+
+func (r *serviceAccountRepository) ListAPIKeys(serviceAccountID string) ([]*user.APIKey, error) {
+	ids, err := r.connection.SMembers(serviceAccountKeysPrefix + serviceAccountID).Result()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch service account keys")
+		return nil, err
+	}
+	keys := make([]*user.APIKey, 0, len(ids))
+	if len(ids) == 0 {
+		return keys, nil
+	}
+
+	values, err := r.connection.HMGet(apiKeyTable, ids...).Result()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch API keys")
+		return nil, err
+	}
+	for _, value := range values {
+		encoded, ok := value.(string)
+		if !ok {
+			continue
+		}
+		key := new(user.APIKey)
+		if err := json.Unmarshal([]byte(encoded), key); err != nil {
+			logrus.Error("Unable to unmarshal API key")
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (r *serviceAccountRepository) DeleteAPIKey(key *user.APIKey) error {
+	pipe := r.connection.TxPipeline()
+	pipe.HDel(apiKeyTable, key.ID)
+	pipe.SRem(serviceAccountKeysPrefix+key.ServiceAccountID, key.ID)
+	if _, err := pipe.Exec(); err != nil {
+		logrus.WithField("error", err).Error("Unable to delete API key")
+		return err
+	}
+	return nil
+}
+
+func (r *serviceAccountRepository) TouchAPIKey(key *user.APIKey, at time.Time) error {
+	touched := *key
+	touched.LastUsed = at
+	encoded, err := json.Marshal(&touched)
+	if err != nil {
+		return err
+	}
+
+	// a revoked key must not be resurrected by a concurrent request
+	err = r.connection.Watch(func(tx *redis.Tx) error {
+		exists, err := tx.HExists(apiKeyTable, key.ID).Result()
+		if err != nil || !exists {
+			return err
+		}
+		_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
+			pipe.HSet(apiKeyTable, key.ID, encoded)
+			return nil
+		})
+		return err
+	}, apiKeyTable)
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to record API key use")
+		return err
+	}
+	return nil
+}
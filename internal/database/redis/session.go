@@ -0,0 +1,120 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/sessions"
+)
+
+const (
+	sessionPrefix     = "sessions:"      // session id -> user and values
+	userSessionPrefix = "user_sessions:" // user id -> session ids
+)
+
+type sessionRepository struct {
+	connection *redis.Client
+}
+
+// NewRedisSessionRepository stores server-side sessions, each expiring on
+// its own, with an index of the sessions of every user.
+func NewRedisSessionRepository(connection *redis.Client) sessions.Backend {
+	return &sessionRepository{
+		connection,
+	}
+}
+
+func (r *sessionRepository) SaveSession(id, userID string, values []byte, ttl time.Duration) error {
+	_, err := r.connection.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.HMSet(sessionPrefix+id, map[string]interface{}{"user": userID, "values": values})
+		pipe.Expire(sessionPrefix+id, ttl)
+		if userID != "" {
+			// the index outlives its newest session, ids of expired
+			// sessions in it are skipped
+			pipe.SAdd(userSessionPrefix+userID, id)
+			pipe.Expire(userSessionPrefix+userID, ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to save session")
+		return err
+	}
+	return nil
+}
+
+func (r *sessionRepository) GetSession(id string) ([]byte, error) {
+	values, err := r.connection.HGet(sessionPrefix+id, "values").Bytes()
+	if err == redis.Nil {
+		return nil, sessions.ErrSessionNotFound
+	}
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch session")
+		return nil, err
+	}
+	return values, nil
+}
+
+// TouchSession extends the user index along with the session, so that the
+// index lasts as long as the sessions in it.
+func (r *sessionRepository) TouchSession(id string, ttl time.Duration) error {
+	userID, err := r.connection.HGet(sessionPrefix+id, "user").Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch session")
+		return err
+	}
+
+	_, err = r.connection.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Expire(sessionPrefix+id, ttl)
+		if userID != "" {
+			pipe.Expire(userSessionPrefix+userID, ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to extend session")
+		return err
+	}
+	return nil
+}
+
+func (r *sessionRepository) DeleteSession(id string) error {
+	userID, err := r.connection.HGet(sessionPrefix+id, "user").Result()
+	if err != nil && err != redis.Nil {
+		logrus.WithField("error", err).Error("Unable to fetch session")
+		return err
+	}
+
+	pipe := r.connection.TxPipeline()
+	pipe.Del(sessionPrefix + id)
+	if userID != "" {
+		pipe.SRem(userSessionPrefix+userID, id)
+	}
+	if _, err := pipe.Exec(); err != nil {
+		logrus.WithField("error", err).Error("Unable to delete session")
+		return err
+	}
+	return nil
+}
+
+func (r *sessionRepository) DeleteUserSessions(userID string) error {
+	ids, err := r.connection.SMembers(userSessionPrefix + userID).Result()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"userId": userID, "error": err}).Error("Unable to fetch user sessions")
+		return err
+	}
+
+	keys := []string{userSessionPrefix + userID}
+	for _, id := range ids {
+		keys = append(keys, sessionPrefix+id)
+	}
+	if err := r.connection.Del(keys...).Err(); err != nil {
+		logrus.WithFields(logrus.Fields{"userId": userID, "error": err}).Error("Unable to delete user sessions")
+		return err
+	}
+	return nil
+}
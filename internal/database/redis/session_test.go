@@ -0,0 +1,76 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"hex-example/internal/sessions"
+)
+
+func newTestSessionRepository(t *testing.T) (*miniredis.Miniredis, sessions.Backend) {
+	server := miniredis.RunT(t)
+	connection := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { connection.Close() })
+	return server, NewRedisSessionRepository(connection)
+}
+
+func TestSessionRepository(t *testing.T) {
+	server, repo := newTestSessionRepository(t)
+	require.Nil(t, repo.SaveSession("s1", "u1", []byte("values"), time.Hour))
+
+	values, err := repo.GetSession("s1")
+	require.Nil(t, err)
+	assert.Equal(t, []byte("values"), values)
+	assert.True(t, server.Exists(userSessionPrefix+"u1"))
+
+	require.Nil(t, repo.DeleteSession("s1"))
+	_, err = repo.GetSession("s1")
+	assert.Equal(t, sessions.ErrSessionNotFound, err)
+	assert.False(t, server.Exists(userSessionPrefix+"u1"), "the empty index goes with the session")
+}
+
+func TestSessionRepository_Expiry(t *testing.T) {
+	server, repo := newTestSessionRepository(t)
+	require.Nil(t, repo.SaveSession("s1", "u1", []byte("values"), time.Hour))
+
+	server.FastForward(time.Hour)
+	_, err := repo.GetSession("s1")
+	assert.Equal(t, sessions.ErrSessionNotFound, err)
+	assert.Nil(t, repo.TouchSession("s1", time.Hour), "expired sessions are not revived")
+	assert.False(t, server.Exists(sessionPrefix+"s1"))
+}
+
+func TestSessionRepository_TouchExtendsUserIndex(t *testing.T) {
+	server, repo := newTestSessionRepository(t)
+	require.Nil(t, repo.SaveSession("s1", "u1", []byte("values"), time.Hour))
+
+	// read within the TTL twice, outliving the TTL of the first save
+	server.FastForward(50 * time.Minute)
+	require.Nil(t, repo.TouchSession("s1", time.Hour))
+	server.FastForward(50 * time.Minute)
+	_, err := repo.GetSession("s1")
+	require.Nil(t, err)
+
+	require.Nil(t, repo.DeleteUserSessions("u1"))
+	_, err = repo.GetSession("s1")
+	assert.Equal(t, sessions.ErrSessionNotFound, err)
+}
+
+func TestSessionRepository_DeleteUserSessions(t *testing.T) {
+	_, repo := newTestSessionRepository(t)
+	require.Nil(t, repo.SaveSession("s1", "u1", []byte("one"), time.Hour))
+	require.Nil(t, repo.SaveSession("s2", "u1", []byte("two"), time.Hour))
+	require.Nil(t, repo.SaveSession("s3", "u2", []byte("three"), time.Hour))
+
+	require.Nil(t, repo.DeleteUserSessions("u1"))
+	for _, id := range []string{"s1", "s2"} {
+		_, err := repo.GetSession(id)
+		assert.Equal(t, sessions.ErrSessionNotFound, err, id)
+	}
+	_, err := repo.GetSession("s3")
+	assert.Nil(t, err, "other users keep their sessions")
+}
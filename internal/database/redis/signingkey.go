@@ -0,0 +1,128 @@
+package redis
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/jwks"
+)
+
+const signingKeysKey = "signing_keys"
+
+// storedSigningKey is a signing key sealed whole, private half included.
+type storedSigningKey struct {
+	SealedKey []byte `json:"sealedKey"`
+}
+
+type signingKeyRepository struct {
+	connection *redis.Client
+	aead       cipher.AEAD
+}
+
+// NewRedisSigningKeyRepository stores JWT signing keys, private halves
+// included, so every userAPI instance signs with the same key ring. Keys are
+// sealed by AES-GCM with key and bound to their kid, so that they cannot be
+// read from redis or swapped for one another.
+func NewRedisSigningKeyRepository(connection *redis.Client, key []byte) (jwks.KeyStore, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &signingKeyRepository{
+		connection,
+		aead,
+	}, nil
+}
+
+func (r *signingKeyRepository) SaveKey(key *jwks.Key) error {
+	marshaled, err := jwks.MarshalKey(key)
+	if err != nil {
+		logrus.Error("Unable to marshal signing key")
+		return err
+	}
+	sealed, err := r.seal(key.ID, marshaled)
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to seal signing key")
+		return err
+	}
+	encoded, err := json.Marshal(&storedSigningKey{sealed})
+	if err != nil {
+		logrus.Error("Unable to marshal signing key")
+		return err
+	}
+	if err := r.connection.HSet(signingKeysKey, key.ID, encoded).Err(); err != nil {
+		logrus.WithField("error", err).Error("Unable to save signing key")
+		return err
+	}
+	return nil
+}
+
+func (r *signingKeyRepository) ListKeys() ([]*jwks.Key, error) {
+	encoded, err := r.connection.HGetAll(signingKeysKey).Result()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch signing keys")
+		return nil, err
+	}
+
+	keys := make([]*jwks.Key, 0, len(encoded))
+	for id, v := range encoded {
+		key, err := r.decode(id, []byte(v))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"kid": id, "error": err}).Error("Unable to unmarshal signing key")
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (r *signingKeyRepository) DeleteKey(id string) error {
+	if err := r.connection.HDel(signingKeysKey, id).Err(); err != nil {
+		logrus.WithField("error", err).Error("Unable to delete signing key")
+		return err
+	}
+	return nil
+}
+
+// decode opens the key stored under id. Keys saved before they were sealed
+// are read as they are until they are retired.
+func (r *signingKeyRepository) decode(id string, b []byte) (*jwks.Key, error) {
+	stored := new(storedSigningKey)
+	if err := json.Unmarshal(b, stored); err != nil {
+		return nil, err
+	}
+	if stored.SealedKey == nil {
+		return jwks.UnmarshalKey(b)
+	}
+	marshaled, err := r.open(id, stored.SealedKey)
+	if err != nil {
+		return nil, err
+	}
+	return jwks.UnmarshalKey(marshaled)
+}
+
+// seal encrypts a marshaled key with its kid as additional data.
+func (r *signingKeyRepository) seal(id string, marshaled []byte) ([]byte, error) {
+	nonce := make([]byte, r.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return r.aead.Seal(nonce, nonce, marshaled, []byte(id)), nil
+}
+
+func (r *signingKeyRepository) open(id string, sealed []byte) ([]byte, error) {
+	size := r.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, errors.New("sealed signing key too short")
+	}
+	return r.aead.Open(nil, sealed[:size], sealed[size:], []byte(id))
+}
@@ -0,0 +1,43 @@
+package redis
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"hex-example/internal/jwks"
+)
+
+func TestSigningKeyRepository_SealsKeys(t *testing.T) {
+	server := miniredis.RunT(t)
+	connection := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { connection.Close() })
+	repo, err := NewRedisSigningKeyRepository(connection, bytes.Repeat([]byte{7}, 32))
+	require.Nil(t, err)
+
+	key, _ := jwks.GenerateKey(jwks.ES256)
+	require.Nil(t, repo.SaveKey(key))
+	// a key saved before keys were sealed
+	legacy, _ := jwks.GenerateKey(jwks.ES256)
+	marshaled, _ := jwks.MarshalKey(legacy)
+	require.Nil(t, connection.HSet(signingKeysKey, legacy.ID, marshaled).Err())
+
+	stored := server.HGet(signingKeysKey, key.ID)
+	assert.NotContains(t, stored, `"pkcs8"`, "private keys are not stored in the clear")
+
+	keys, err := repo.ListKeys()
+	require.Nil(t, err)
+	ids := make([]string, len(keys))
+	for i, k := range keys {
+		ids[i] = k.ID
+		assert.NotNil(t, k.Private, k.ID)
+	}
+	assert.ElementsMatch(t, []string{key.ID, legacy.ID}, ids)
+
+	other, _ := NewRedisSigningKeyRepository(connection, bytes.Repeat([]byte{8}, 32))
+	_, err = other.ListKeys()
+	assert.Error(t, err, "keys only open with the key they were sealed with")
+}
@@ -7,7 +7,12 @@ import (
 	"hex-example/internal/ticket"
 )
 
-const ticketTable = "tickets"
+const (
+	ticketTable         = "tickets"
+	projectTicketPrefix = "project_tickets:"
+	// tickets created by or assigned to a username
+	userTicketPrefix = "user_tickets:"
+)
 
 type ticketRepository struct {
 	connection *redis.Client
@@ -27,13 +32,27 @@ func (r *ticketRepository) Create(ticket *ticket.Ticket) error {
 		return err
 	}
 
-	r.connection.HSet(ticketTable, ticket.ID, encoded) //Don't expire
+	// the sets of tickets per project and per user are kept in the same
+	// transaction
+	pipe := r.connection.TxPipeline()
+	pipe.HSet(ticketTable, ticket.ID, encoded) //Don't expire
+	if ticket.Project != "" {
+		pipe.SAdd(projectTicketPrefix+ticket.Project, ticket.ID)
+	}
+	indexTicketUsers(pipe, ticket)
+	if _, err := pipe.Exec(); err != nil {
+		logrus.WithField("error", err).Error("Unable to save ticket")
+		return err
+	}
 	return nil
 }
 
 func (r *ticketRepository) FindById(id string) (*ticket.Ticket, error) {
 	b, err := r.connection.HGet(ticketTable, id).Bytes()
 
+	if err == redis.Nil {
+		return nil, &ticket.NotFoundError{ID: id}
+	}
 	if err != nil {
 		logrus.WithField("id", id).Error("Unable to fetch ticket")
 		return nil, err
@@ -51,7 +70,11 @@ func (r *ticketRepository) FindById(id string) (*ticket.Ticket, error) {
 }
 
 func (r *ticketRepository) FindAll() (tickets []*ticket.Ticket, err error) {
-	ts := r.connection.HGetAll(ticketTable).Val()
+	ts, err := r.connection.HGetAll(ticketTable).Result()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch tickets")
+		return nil, err
+	}
 	for key, value := range ts {
 		t := new(ticket.Ticket)
 		err = json.Unmarshal([]byte(value), t)
@@ -66,3 +89,64 @@ func (r *ticketRepository) FindAll() (tickets []*ticket.Ticket, err error) {
 	}
 	return tickets, nil
 }
+
+func (r *ticketRepository) FindByProject(project string) ([]*ticket.Ticket, error) {
+	ids, err := r.connection.SMembers(projectTicketPrefix + project).Result()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"project": project, "error": err}).Error("Unable to fetch project tickets")
+		return nil, err
+	}
+	return r.FindByIDs(ids)
+}
+
+func (r *ticketRepository) FindByUsernames(usernames []string) ([]*ticket.Ticket, error) {
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(usernames))
+	for i, username := range usernames {
+		keys[i] = userTicketPrefix + username
+	}
+	ids, err := r.connection.SUnion(keys...).Result()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"usernames": usernames, "error": err}).Error("Unable to fetch user tickets")
+		return nil, err
+	}
+	return r.FindByIDs(ids)
+}
+
+func (r *ticketRepository) FindByIDs(ids []string) ([]*ticket.Ticket, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	values, err := r.connection.HMGet(ticketTable, ids...).Result()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch tickets")
+		return nil, err
+	}
+	var tickets []*ticket.Ticket
+	for i, value := range values {
+		encoded, ok := value.(string)
+		if !ok {
+			continue
+		}
+		t := new(ticket.Ticket)
+		if err := json.Unmarshal([]byte(encoded), t); err != nil {
+			logrus.WithField("id", ids[i]).Error("Unable to unmarshal ticket")
+			return nil, err
+		}
+		t.ID = ids[i]
+		tickets = append(tickets, t)
+	}
+	return tickets, nil
+}
+
+// indexTicketUsers adds t to the sets of tickets of its creator and
+// assignee.
+func indexTicketUsers(pipe redis.Pipeliner, t *ticket.Ticket) {
+	pipe.SAdd(userTicketPrefix+t.Creator, t.ID)
+	if t.Assigned != "" {
+		pipe.SAdd(userTicketPrefix+t.Assigned, t.ID)
+	}
+}
@@ -0,0 +1,59 @@
+package redis
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"hex-example/internal/ticket"
+)
+
+func newTestTicketRepository(t *testing.T) (*redis.Client, ticket.TicketRepository) {
+	server := miniredis.RunT(t)
+	connection := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { connection.Close() })
+	return connection, NewRedisTicketRepository(connection)
+}
+
+func ticketIDs(tickets []*ticket.Ticket) []string {
+	ids := make([]string, len(tickets))
+	for i, t := range tickets {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+func TestTicketRepository_Batches(t *testing.T) {
+	_, repo := newTestTicketRepository(t)
+	require.Nil(t, repo.Create(&ticket.Ticket{ID: "1", Project: "infra", Creator: "joel"}))
+	require.Nil(t, repo.Create(&ticket.Ticket{ID: "2", Creator: "ivy", Assigned: "joel"}))
+	require.Nil(t, repo.Create(&ticket.Ticket{ID: "3", Creator: "ivy"}))
+
+	found, err := repo.FindByIDs([]string{"3", "missing", "1"})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"3", "1"}, ticketIDs(found))
+
+	found, err = repo.FindByUsernames([]string{"joel"})
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []string{"1", "2"}, ticketIDs(found), "created and assigned tickets")
+
+	found, err = repo.FindByProject("infra")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"1"}, ticketIDs(found))
+}
+
+func TestMigrate_IndexesUserTickets(t *testing.T) {
+	connection, repo := newTestTicketRepository(t)
+	// a ticket saved before the user index existed
+	encoded, _ := json.Marshal(&ticket.Ticket{Creator: "joel"})
+	require.Nil(t, connection.HSet(ticketTable, "1", encoded).Err())
+
+	require.Nil(t, Migrate(connection))
+
+	found, err := repo.FindByUsernames([]string{"joel"})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"1"}, ticketIDs(found))
+}
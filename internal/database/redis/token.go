@@ -0,0 +1,117 @@
+package redis
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/user"
+)
+
+const (
+	refreshTokenPrefix   = "refresh_tokens:"
+	refreshUsedPrefix    = "refresh_used:"
+	refreshFamilyPrefix  = "refresh_families:"
+	refreshAccountPrefix = "refresh_accounts:"
+)
+
+type tokenRepository struct {
+	connection *redis.Client
+}
+
+func NewRedisTokenRepository(connection *redis.Client) user.TokenRepo {
+	return &tokenRepository{
+		connection,
+	}
+}
+
+func (r *tokenRepository) SaveRefreshToken(token *user.RefreshToken) error {
+	encoded, err := json.Marshal(token)
+	if err != nil {
+		logrus.Error("Unable to marshal refresh token")
+		return err
+	}
+
+	ttl := time.Until(token.Expires)
+	familyKey := refreshFamilyPrefix + token.Family
+	accountKey := refreshAccountPrefix + token.AccountID
+	pipe := r.connection.TxPipeline()
+	pipe.Set(refreshTokenPrefix+token.Hash, encoded, ttl)
+	pipe.SAdd(familyKey, token.Hash)
+	pipe.Expire(familyKey, ttl)
+	pipe.SAdd(accountKey, token.Family)
+	pipe.Expire(accountKey, ttl)
+	if _, err := pipe.Exec(); err != nil {
+		logrus.WithField("error", err).Error("Unable to save refresh token")
+		return err
+	}
+	return nil
+}
+
+func (r *tokenRepository) GetRefreshToken(hash string) (*user.RefreshToken, error) {
+	b, err := r.connection.Get(refreshTokenPrefix + hash).Bytes()
+
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch refresh token")
+		return nil, err
+	}
+
+	t := new(user.RefreshToken)
+	if err := json.Unmarshal(b, t); err != nil {
+		logrus.Error("Unable to unmarshal refresh token")
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *tokenRepository) MarkRefreshTokenUsed(token *user.RefreshToken) (bool, error) {
+	set, err := r.connection.SetNX(refreshUsedPrefix+token.Hash, true, time.Until(token.Expires)).Result()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to mark refresh token used")
+		return false, err
+	}
+	return !set, nil
+}
+
+func (r *tokenRepository) RevokeFamily(family string) error {
+	familyKey := refreshFamilyPrefix + family
+	hashes, err := r.connection.SMembers(familyKey).Result()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch refresh token family")
+		return err
+	}
+
+	keys := []string{familyKey}
+	for _, hash := range hashes {
+		keys = append(keys, refreshTokenPrefix+hash, refreshUsedPrefix+hash)
+	}
+	if err := r.connection.Del(keys...).Err(); err != nil {
+		logrus.WithField("error", err).Error("Unable to revoke refresh token family")
+		return err
+	}
+	return nil
+}
+
+func (r *tokenRepository) RevokeAccount(accountID string) error {
+	accountKey := refreshAccountPrefix + accountID
+	families, err := r.connection.SMembers(accountKey).Result()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch refresh token families")
+		return err
+	}
+
+	for _, family := range families {
+		if err := r.RevokeFamily(family); err != nil {
+			return err
+		}
+	}
+	if err := r.connection.Del(accountKey).Err(); err != nil {
+		logrus.WithField("error", err).Error("Unable to revoke account refresh tokens")
+		return err
+	}
+	return nil
+}
@@ -7,7 +7,10 @@ import (
 	"hex-example/internal/user"
 )
 
-const userTable = "users"
+const (
+	userTable   = "users"
+	userIDIndex = "user_ids" // account id -> username
+)
 
 type userRepository struct {
 	connection *redis.Client
@@ -29,17 +32,64 @@ func (r *userRepository) CreateAccount(account *user.Account) error{
 		return err
 	}
 
-	cmd := r.connection.HSet(userTable, account.Username, encoded) //Don't expire
+	cmd := r.connection.HSetNX(userTable, account.Username, encoded) //Don't expire
 	if cmd.Err() != nil {
-		logrus.WithField("error", err).Error("Unable to save user account")
+		logrus.WithField("error", cmd.Err()).Error("Unable to save user account")
 		return cmd.Err()
 	}
+	if !cmd.Val() {
+		return &user.ConflictError{Username: account.Username}
+	}
+	if err := r.connection.HSet(userIDIndex, account.ID, account.Username).Err(); err != nil {
+		logrus.WithField("error", err).Error("Unable to index user account")
+		return err
+	}
+	return nil
+}
+
+func (r *userRepository) GetUserByID(id string) (*user.Account, error) {
+	username, err := r.connection.HGet(userIDIndex, id).Result()
+	if err == redis.Nil {
+		return nil, &user.NotFoundError{ID: id}
+	}
+	if err != nil {
+		logrus.WithField("id", id).Error("Unable to fetch account")
+		return nil, err
+	}
+
+	account, err := r.GetUser(username)
+	if _, ok := err.(*user.NotFoundError); ok {
+		return nil, &user.NotFoundError{ID: id}
+	}
+	return account, err
+}
+
+func (r *userRepository) DeleteAccount(account *user.Account) error {
+	identities, err := r.connection.SMembers(accountIdentityPrefix + account.ID).Result()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to fetch linked identities")
+		return err
+	}
+
+	pipe := r.connection.TxPipeline()
+	pipe.HDel(userTable, account.Username)
+	pipe.HDel(userIDIndex, account.ID)
+	pipe.Del(passkeyPrefix + account.ID)
+	pipe.Del(oauthConsentPrefix + account.ID)
+	pipe.Del(append(identities, accountIdentityPrefix+account.ID)...)
+	if _, err := pipe.Exec(); err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to delete account")
+		return err
+	}
 	return nil
 }
 
 func (r *userRepository) GetUser(username string) (*user.Account, error){
 	b, err := r.connection.HGet(userTable, username).Bytes()
 
+	if err == redis.Nil {
+		return nil, &user.NotFoundError{Username: username}
+	}
 	if err != nil {
 		logrus.WithField("username", username).Error("Unable to fetch account")
 		return nil, err
@@ -54,4 +104,85 @@ func (r *userRepository) GetUser(username string) (*user.Account, error){
 	}
 
 	return t, nil
-}
\ No newline at end of file
+}
+
+func (r *userRepository) GetUsers(usernames []string) ([]*user.Account, error) {
+	accounts := make([]*user.Account, len(usernames))
+	if len(usernames) == 0 {
+		return accounts, nil
+	}
+
+	values, err := r.connection.HMGet(userTable, usernames...).Result()
+	if err != nil {
+		logrus.WithField("usernames", usernames).Error("Unable to fetch accounts")
+		return nil, err
+	}
+
+	for i, value := range values {
+		encoded, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		t := new(user.Account)
+		if err := json.Unmarshal([]byte(encoded), t); err != nil {
+			logrus.WithField("username", usernames[i]).Error("Unable to unmarshal account")
+			return nil, err
+		}
+		accounts[i] = t
+	}
+
+	return accounts, nil
+}
+
+func (r *userRepository) ListUsers() ([]*user.Account, error) {
+	values, err := r.connection.HVals(userTable).Result()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to list accounts")
+		return nil, err
+	}
+
+	accounts := make([]*user.Account, 0, len(values))
+	for _, encoded := range values {
+		t := new(user.Account)
+		if err := json.Unmarshal([]byte(encoded), t); err != nil {
+			logrus.Error("Unable to unmarshal account")
+			return nil, err
+		}
+		accounts = append(accounts, t)
+	}
+	return accounts, nil
+}
+
+func (r *userRepository) UpdateAccount(account *user.Account) error {
+	encoded, err := json.Marshal(account)
+	if err != nil {
+		logrus.Error("Unable to marshal account")
+		return err
+	}
+
+	// only replace the account while it exists, atomically
+	err = r.connection.Watch(func(tx *redis.Tx) error {
+		exists, err := tx.HExists(userTable, account.Username).Result()
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return &user.NotFoundError{Username: account.Username}
+		}
+		_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
+			pipe.HSet(userTable, account.Username, encoded)
+			return nil
+		})
+		return err
+	}, userTable)
+
+	if _, ok := err.(*user.NotFoundError); ok {
+		return err
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to update account")
+		return err
+	}
+	return nil
+}
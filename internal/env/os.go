@@ -1,6 +1,10 @@
 package env
 
-import "os"
+import (
+	"encoding/base64"
+	"os"
+	"time"
+)
 
 func EnvString(env, fallback string) string {
 	e := os.Getenv(env)
@@ -9,3 +13,22 @@ func EnvString(env, fallback string) string {
 	}
 	return e
 }
+
+// EnvDuration parses env with time.ParseDuration, returning fallback when it
+// is unset or malformed.
+func EnvDuration(env string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(env))
+	if err != nil {
+		return fallback
+	}
+	return d
+}
+
+// EnvKey decodes the base64 key in env, returning nil if it is unset.
+func EnvKey(env string) ([]byte, error) {
+	e := os.Getenv(env)
+	if e == "" {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(e)
+}
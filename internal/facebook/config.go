@@ -0,0 +1,83 @@
+package facebook
+
+import (
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// Graph API defaults
+const (
+	DefaultGraphURL = "https://graph.facebook.com/"
+	DefaultVersion  = "v2.9"
+	dialogURL       = "https://www.facebook.com/"
+)
+
+// DefaultFields are the User fields requested when Config.Fields is empty.
+var DefaultFields = []string{"id", "name", "email", "first_name", "last_name", "locale", "picture"}
+
+// DefaultScopes are the permissions requested when Config.Scopes is empty.
+var DefaultScopes = []string{"public_profile", "email"}
+
+// Config configures Facebook login and the Graph API requests made for it.
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes are the permissions requested at login, DefaultScopes if empty.
+	Scopes []string
+	// Fields are the User fields requested, DefaultFields if empty.
+	Fields []string
+	// GraphURL is the Graph API base URL, DefaultGraphURL if empty.
+	GraphURL string
+	// Version is the Graph API version, e.g. "v19.0". DefaultVersion if
+	// empty.
+	Version string
+}
+
+// OAuth2 returns the OAuth2 client configuration for the login dialog and
+// token endpoint of the configured Graph API version.
+func (c *Config) OAuth2() *oauth2.Config {
+	scopes := c.Scopes
+	if len(scopes) == 0 {
+		scopes = DefaultScopes
+	}
+	return &oauth2.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		RedirectURL:  c.RedirectURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  dialogURL + c.version() + "/dialog/oauth",
+			TokenURL: c.baseURL() + "oauth/access_token",
+		},
+		Scopes: scopes,
+	}
+}
+
+// AppAccessToken returns the app access token used to debug user tokens.
+func (c *Config) AppAccessToken() string {
+	return c.ClientID + "|" + c.ClientSecret
+}
+
+// baseURL returns the versioned Graph API base URL, with a trailing slash.
+func (c *Config) baseURL() string {
+	graphURL := c.GraphURL
+	if graphURL == "" {
+		graphURL = DefaultGraphURL
+	}
+	return strings.TrimSuffix(graphURL, "/") + "/" + c.version() + "/"
+}
+
+func (c *Config) version() string {
+	if c.Version == "" {
+		return DefaultVersion
+	}
+	return c.Version
+}
+
+func (c *Config) fields() string {
+	if len(c.Fields) == 0 {
+		return strings.Join(DefaultFields, ",")
+	}
+	return strings.Join(c.Fields, ",")
+}
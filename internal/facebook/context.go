@@ -10,6 +10,9 @@ type key int
 
 const (
 	userKey key = iota
+	verifierKey
+	nonceKey
+	signedRequestKey
 )
 
 // WithUser returns a copy of ctx that stores the Facebook User.
@@ -25,3 +28,45 @@ func UserFromContext(ctx context.Context) (*User, error) {
 	}
 	return user, nil
 }
+
+// WithVerifier returns a copy of ctx that stores the PKCE code verifier.
+func WithVerifier(ctx context.Context, verifier string) context.Context {
+	return context.WithValue(ctx, verifierKey, verifier)
+}
+
+// VerifierFromContext returns the PKCE code verifier from the ctx.
+func VerifierFromContext(ctx context.Context) (string, error) {
+	verifier, ok := ctx.Value(verifierKey).(string)
+	if !ok {
+		return "", fmt.Errorf("facebook: Context missing code verifier")
+	}
+	return verifier, nil
+}
+
+// WithNonce returns a copy of ctx that stores the OpenID Connect nonce.
+func WithNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, nonceKey, nonce)
+}
+
+// NonceFromContext returns the OpenID Connect nonce from the ctx.
+func NonceFromContext(ctx context.Context) (string, error) {
+	nonce, ok := ctx.Value(nonceKey).(string)
+	if !ok {
+		return "", fmt.Errorf("facebook: Context missing nonce")
+	}
+	return nonce, nil
+}
+
+// WithSignedRequest returns a copy of ctx that stores the SignedRequest.
+func WithSignedRequest(ctx context.Context, request *SignedRequest) context.Context {
+	return context.WithValue(ctx, signedRequestKey, request)
+}
+
+// SignedRequestFromContext returns the SignedRequest from the ctx.
+func SignedRequestFromContext(ctx context.Context) (*SignedRequest, error) {
+	request, ok := ctx.Value(signedRequestKey).(*SignedRequest)
+	if !ok {
+		return nil, fmt.Errorf("facebook: Context missing SignedRequest")
+	}
+	return request, nil
+}
@@ -22,3 +22,39 @@ func TestContextUser_Error(t *testing.T) {
 		assert.Equal(t, "facebook: Context missing Facebook User", err.Error())
 	}
 }
+
+func TestContextVerifier(t *testing.T) {
+	ctx := WithVerifier(context.Background(), "verifier")
+	verifier, err := VerifierFromContext(ctx)
+	assert.Equal(t, "verifier", verifier)
+	assert.Nil(t, err)
+
+	_, err = VerifierFromContext(context.Background())
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "facebook: Context missing code verifier", err.Error())
+	}
+}
+
+func TestContextNonce(t *testing.T) {
+	ctx := WithNonce(context.Background(), "nonce")
+	nonce, err := NonceFromContext(ctx)
+	assert.Equal(t, "nonce", nonce)
+	assert.Nil(t, err)
+
+	_, err = NonceFromContext(context.Background())
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "facebook: Context missing nonce", err.Error())
+	}
+}
+
+func TestContextSignedRequest(t *testing.T) {
+	expected := &SignedRequest{UserID: "54638001"}
+	request, err := SignedRequestFromContext(WithSignedRequest(context.Background(), expected))
+	assert.Equal(t, expected, request)
+	assert.Nil(t, err)
+
+	_, err = SignedRequestFromContext(context.Background())
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "facebook: Context missing SignedRequest", err.Error())
+	}
+}
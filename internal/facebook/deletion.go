@@ -0,0 +1,46 @@
+package facebook
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrDeletionNotFound is returned for unknown confirmation codes.
+var ErrDeletionNotFound = errors.New("facebook: no deletion request with confirmation code")
+
+// Deletion request statuses
+const (
+	// DeletionCompleted means the data of the user was deleted.
+	DeletionCompleted = "completed"
+	// DeletionNoData means no data was stored for the user.
+	DeletionNoData = "no_data"
+)
+
+// Deletion records a data deletion request so the user can check on it with
+// the confirmation code. It deliberately does not name the user.
+type Deletion struct {
+	ConfirmationCode string    `json:"confirmationCode"`
+	Status           string    `json:"status"`
+	Requested        time.Time `json:"requested"`
+}
+
+// DeletionResponse is the response Facebook expects from the data deletion
+// callback.
+type DeletionResponse struct {
+	// URL is where the user can check the status of the request.
+	URL              string `json:"url"`
+	ConfirmationCode string `json:"confirmation_code"`
+}
+
+// DeletionStore keeps data deletion requests by confirmation code.
+type DeletionStore interface {
+	SaveDeletion(deletion *Deletion) error
+	// GetDeletion returns ErrDeletionNotFound for unknown codes.
+	GetDeletion(code string) (*Deletion, error)
+}
+
+// NewConfirmationCode returns a non-guessable confirmation code for a data
+// deletion request.
+func NewConfirmationCode() string {
+	return randomValue()
+}
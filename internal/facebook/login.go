@@ -29,24 +29,99 @@ func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handle
 
 // LoginHandler handles Facebook login requests by reading the state value
 // from the ctx and redirecting requests to the AuthURL with that state value.
-func LoginHandler(config *oauth2.Config, failure http.Handler) http.Handler {
-	return oauth2Login.LoginHandler(config, failure)
+// The code challenge and nonce are sent as well when PKCEHandler and
+// NonceHandler added them to the ctx.
+func LoginHandler(config *Config, failure http.Handler) http.Handler {
+	oauth2Config := config.OAuth2()
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		state, err := oauth2Login.StateFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		authURL := oauth2Config.AuthCodeURL(state, authCodeOptions(req)...)
+		http.Redirect(w, req, authURL, http.StatusFound)
+	}
+	return http.HandlerFunc(fn)
 }
 
 // CallbackHandler handles Facebook redirection URI requests and adds the
 // Facebook access token and User to the ctx. If authentication succeeds,
 // handling delegates to the success handler, otherwise to the failure
 // handler.
-func CallbackHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+//
+// The code is exchanged with the code verifier when PKCEHandler added one to
+// the ctx, and the ID token must carry the nonce when NonceHandler added one.
+func CallbackHandler(config *Config, success, failure http.Handler) http.Handler {
 	success = facebookHandler(config, success, failure)
-	return oauth2Login.CallbackHandler(config, success, failure)
+	success = nonceHandler(config, success, failure)
+	return exchangeHandler(config.OAuth2(), success, failure)
+}
+
+// exchangeHandler is a http.Handler that checks the callback state against
+// the state in the ctx and exchanges the authorization code for a Token. If
+// successful, the token is added to the ctx and the success handler is
+// called. Otherwise, the failure handler is called.
+func exchangeHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		authCode, state, err := parseCallback(req)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ownerState, err := oauth2Login.StateFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if state != ownerState {
+			ctx = gologin.WithError(ctx, oauth2Login.ErrInvalidState)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		token, err := config.Exchange(ctx, authCode, exchangeOptions(req)...)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = oauth2Login.WithToken(ctx, token)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// parseCallback returns the authorization code and state of a redirection
+// URI request.
+func parseCallback(req *http.Request) (authCode, state string, err error) {
+	if err := req.ParseForm(); err != nil {
+		return "", "", err
+	}
+	authCode = req.Form.Get("code")
+	state = req.Form.Get("state")
+	if authCode == "" || state == "" {
+		return "", "", errors.New("facebook: Request missing code or state")
+	}
+	return authCode, state, nil
 }
 
 // facebookHandler is a http.Handler that gets the OAuth2 Token from the ctx
 // to get the corresponding Facebook User. If successful, the user is added to
 // the ctx and the success handler is called. Otherwise, the failure handler
 // is called.
-func facebookHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+func facebookHandler(config *Config, success, failure http.Handler) http.Handler {
+	oauth2Config := config.OAuth2()
 	if failure == nil {
 		failure = gologin.DefaultFailureHandler
 	}
@@ -58,8 +133,8 @@ func facebookHandler(config *oauth2.Config, success, failure http.Handler) http.
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
-		httpClient := config.Client(ctx, token)
-		facebookService := newClient(httpClient)
+		httpClient := oauth2Config.Client(ctx, token)
+		facebookService := NewClient(httpClient, config)
 		user, resp, err := facebookService.Me()
 		err = validateResponse(user, resp, err)
 		if err != nil {
@@ -24,7 +24,7 @@ func TestFacebookHandler(t *testing.T) {
 	anyToken := &oauth2.Token{AccessToken: "any-token"}
 	ctx = oauth2Login.WithToken(ctx, anyToken)
 
-	config := &oauth2.Config{}
+	config := &Config{}
 	success := func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
 		facebookUser, err := UserFromContext(ctx)
@@ -47,7 +47,7 @@ func TestFacebookHandler(t *testing.T) {
 }
 
 func TestFacebookHandler_MissingCtxToken(t *testing.T) {
-	config := &oauth2.Config{}
+	config := &Config{}
 	success := testutils.AssertSuccessNotCalled(t)
 	failure := func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
@@ -76,7 +76,7 @@ func TestFacebookHandler_ErrorGettingUser(t *testing.T) {
 	anyToken := &oauth2.Token{AccessToken: "any-token"}
 	ctx = oauth2Login.WithToken(ctx, anyToken)
 
-	config := &oauth2.Config{}
+	config := &Config{}
 	success := testutils.AssertSuccessNotCalled(t)
 	failure := func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
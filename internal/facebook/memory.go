@@ -0,0 +1,87 @@
+package facebook
+
+import (
+	"sync"
+	"time"
+)
+
+type memoryToken struct {
+	sealed    []byte
+	expiresAt time.Time
+}
+
+type memoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]memoryToken
+}
+
+// NewMemoryTokenStore returns a TokenStore that keeps tokens in process.
+// Tokens do not survive a restart and are not shared between instances, so
+// it is only suitable for tests and single-instance development.
+func NewMemoryTokenStore() TokenStore {
+	return &memoryTokenStore{tokens: make(map[string]memoryToken)}
+}
+
+func (s *memoryTokenStore) SaveToken(userID string, sealed []byte, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[userID] = memoryToken{sealed, expiresAt}
+	return nil
+}
+
+func (s *memoryTokenStore) GetToken(userID string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[userID]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	return token.sealed, nil
+}
+
+func (s *memoryTokenStore) DeleteToken(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, userID)
+	return nil
+}
+
+func (s *memoryTokenStore) ExpiringTokens(before time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var userIDs []string
+	for userID, token := range s.tokens {
+		if !token.expiresAt.IsZero() && token.expiresAt.Before(before) {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs, nil
+}
+
+type memoryDeletionStore struct {
+	mu        sync.Mutex
+	deletions map[string]Deletion
+}
+
+// NewMemoryDeletionStore returns a DeletionStore that keeps deletion
+// requests in process, for tests and single-instance development.
+func NewMemoryDeletionStore() DeletionStore {
+	return &memoryDeletionStore{deletions: make(map[string]Deletion)}
+}
+
+func (s *memoryDeletionStore) SaveDeletion(deletion *Deletion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deletions[deletion.ConfirmationCode] = *deletion
+	return nil
+}
+
+func (s *memoryDeletionStore) GetDeletion(code string) (*Deletion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deletion, ok := s.deletions[code]
+	if !ok {
+		return nil, ErrDeletionNotFound
+	}
+	return &deletion, nil
+}
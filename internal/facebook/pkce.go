@@ -0,0 +1,180 @@
+package facebook
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dgrijalva/jwt-go"
+	"golang.org/x/oauth2"
+)
+
+// PKCE and nonce errors
+var (
+	ErrMissingIDToken  = errors.New("facebook: Token response missing id_token")
+	ErrInvalidAudience = errors.New("facebook: ID token issued to another app")
+	ErrInvalidNonce    = errors.New("facebook: Invalid ID token nonce")
+)
+
+// PKCEHandler checks for a code verifier cookie. If found, the verifier is
+// read and added to the ctx. Otherwise, a new verifier is added to the ctx
+// and to a (short-lived) cookie issued to the requester.
+//
+// With a verifier in the ctx, LoginHandler sends the S256 code challenge and
+// CallbackHandler proves possession of the verifier when exchanging the
+// code, as in OAuth 2 RFC 7636. Public clients, which cannot keep a client
+// secret, must use it. The cookie name must differ from the state cookie.
+func PKCEHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		cookie, err := req.Cookie(config.Name)
+		if err == nil && validVerifier(cookie.Value) {
+			ctx = WithVerifier(ctx, cookie.Value)
+		} else {
+			verifier := randomValue()
+			http.SetCookie(w, gologin.NewCookie(config, verifier))
+			ctx = WithVerifier(ctx, verifier)
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// NonceHandler checks for a nonce cookie. If found, the nonce is read and
+// added to the ctx. Otherwise, a non-guessable nonce is added to the ctx and
+// to a (short-lived) cookie issued to the requester.
+//
+// With a nonce in the ctx, LoginHandler asks Facebook to include it in the
+// ID token and CallbackHandler rejects ID tokens without it, binding the
+// token to the browser that started the login. The config scopes must
+// include "openid". The cookie name must differ from the state cookie.
+func NonceHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		cookie, err := req.Cookie(config.Name)
+		if err == nil && cookie.Value != "" {
+			ctx = WithNonce(ctx, cookie.Value)
+		} else {
+			nonce := randomValue()
+			http.SetCookie(w, gologin.NewCookie(config, nonce))
+			ctx = WithNonce(ctx, nonce)
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// authCodeOptions returns the authorization request parameters for the
+// verifier and nonce in the ctx, if any.
+func authCodeOptions(req *http.Request) []oauth2.AuthCodeOption {
+	var opts []oauth2.AuthCodeOption
+	if verifier, err := VerifierFromContext(req.Context()); err == nil {
+		opts = append(opts,
+			oauth2.SetAuthURLParam("code_challenge", codeChallenge(verifier)),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		)
+	}
+	if nonce, err := NonceFromContext(req.Context()); err == nil {
+		opts = append(opts, oauth2.SetAuthURLParam("nonce", nonce))
+	}
+	return opts
+}
+
+// exchangeOptions returns the token request parameters for the verifier in
+// the ctx, if any.
+func exchangeOptions(req *http.Request) []oauth2.AuthCodeOption {
+	verifier, err := VerifierFromContext(req.Context())
+	if err != nil {
+		return nil
+	}
+	return []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("code_verifier", verifier)}
+}
+
+// nonceHandler is a http.Handler that checks the ID token of the OAuth2 Token
+// in the ctx carries the nonce in the ctx. Without a nonce in the ctx the
+// token is not checked.
+//
+// The ID token signature is not verified: the token came straight from the
+// Facebook token endpoint over TLS, which OpenID Connect Core 3.1.3.7
+// accepts in its place.
+func nonceHandler(config *Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		nonce, err := NonceFromContext(ctx)
+		if err != nil {
+			success.ServeHTTP(w, req)
+			return
+		}
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err == nil {
+			err = validateIDToken(token, config.ClientID, nonce)
+		}
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		success.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateIDToken returns an error unless the ID token of token was issued
+// to clientID with nonce.
+func validateIDToken(token *oauth2.Token, clientID, nonce string) error {
+	raw, _ := token.Extra("id_token").(string)
+	if raw == "" {
+		return ErrMissingIDToken
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(raw, claims); err != nil {
+		return err
+	}
+	if !claims.VerifyAudience(clientID, true) {
+		return ErrInvalidAudience
+	}
+	got, _ := claims["nonce"].(string)
+	if subtle.ConstantTimeCompare([]byte(got), []byte(nonce)) != 1 {
+		return ErrInvalidNonce
+	}
+	return nil
+}
+
+// codeChallenge returns the S256 code challenge for verifier.
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// validVerifier reports whether verifier is a code verifier allowed by
+// RFC 7636 4.1.
+func validVerifier(verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, c := range verifier {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+		case c == '-', c == '.', c == '_', c == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// randomValue returns a non-guessable value usable as a code verifier or
+// nonce.
+func randomValue() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,265 @@
+package facebook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const testVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r7wW1gFWFOEjXk"
+
+var testCookieConfig = gologin.CookieConfig{Name: "facebook-verifier", Path: "/", MaxAge: 60, HTTPOnly: true}
+
+func testConfig() *Config {
+	return &Config{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "https://app.example/facebook/callback",
+		Scopes:       []string{"openid", "email"},
+	}
+}
+
+func testIDToken(t *testing.T, claims jwt.MapClaims) string {
+	idToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("any-key"))
+	assert.Nil(t, err)
+	return idToken
+}
+
+func TestPKCEHandler(t *testing.T) {
+	var verifier string
+	success := func(w http.ResponseWriter, req *http.Request) {
+		var err error
+		verifier, err = VerifierFromContext(req.Context())
+		assert.Nil(t, err)
+	}
+	handler := PKCEHandler(testCookieConfig, http.HandlerFunc(success))
+
+	// without a cookie, a new verifier is issued
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.True(t, validVerifier(verifier))
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "facebook-verifier", cookies[0].Name)
+		assert.Equal(t, verifier, cookies[0].Value)
+		assert.True(t, cookies[0].HttpOnly)
+	}
+
+	// a valid cookie is read back
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "facebook-verifier", Value: testVerifier})
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, testVerifier, verifier)
+	assert.Empty(t, w.Result().Cookies())
+
+	// an invalid cookie is replaced
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "facebook-verifier", Value: "short"})
+	handler.ServeHTTP(w, req)
+	assert.NotEqual(t, "short", verifier)
+	assert.Len(t, w.Result().Cookies(), 1)
+}
+
+func TestNonceHandler(t *testing.T) {
+	var nonce string
+	success := func(w http.ResponseWriter, req *http.Request) {
+		var err error
+		nonce, err = NonceFromContext(req.Context())
+		assert.Nil(t, err)
+	}
+	config := gologin.CookieConfig{Name: "facebook-nonce", Path: "/", MaxAge: 60}
+	handler := NonceHandler(config, http.HandlerFunc(success))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.NotEmpty(t, nonce)
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, nonce, cookies[0].Value)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "facebook-nonce", Value: "n-0S6_WzA2Mj"})
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "n-0S6_WzA2Mj", nonce)
+}
+
+func TestLoginHandler(t *testing.T) {
+	ctx := oauth2Login.WithState(context.Background(), "state-value")
+	ctx = WithVerifier(ctx, testVerifier)
+	ctx = WithNonce(ctx, "n-0S6_WzA2Mj")
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler assert that:
+	// - redirects to the AuthURL with the state
+	// - the S256 code challenge of the verifier is sent
+	// - the nonce is sent
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	LoginHandler(testConfig(), failure).ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		query := location.Query()
+		assert.Equal(t, "state-value", query.Get("state"))
+		assert.Equal(t, "bwWFMyPfdG9qreDhH2lmftFx_dFeLDalzcT1gb_j68g", query.Get("code_challenge"))
+		assert.Equal(t, "S256", query.Get("code_challenge_method"))
+		assert.Equal(t, "n-0S6_WzA2Mj", query.Get("nonce"))
+	}
+}
+
+func TestLoginHandler_WithoutPKCE(t *testing.T) {
+	ctx := oauth2Login.WithState(context.Background(), "state-value")
+	failure := testutils.AssertFailureNotCalled(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	LoginHandler(testConfig(), failure).ServeHTTP(w, req.WithContext(ctx))
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		query := location.Query()
+		assert.Equal(t, "state-value", query.Get("state"))
+		assert.Empty(t, query.Get("code_challenge"))
+		assert.Empty(t, query.Get("nonce"))
+	}
+}
+
+func TestLoginHandler_MissingCtxState(t *testing.T) {
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing state value", err.Error())
+		}
+		fmt.Fprint(w, "failure handler called")
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	LoginHandler(testConfig(), http.HandlerFunc(failure)).ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestCallbackHandler(t *testing.T) {
+	idToken := testIDToken(t, jwt.MapClaims{"aud": "client-id", "nonce": "n-0S6_WzA2Mj"})
+	jsonData := `{"id": "54638001", "name": "Ivy Crimson", "email": "ivy@harvard.edu"}`
+	proxyClient, server := newFacebookLoginServer(testVerifier, idToken, jsonData)
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "state-value")
+	ctx = WithVerifier(ctx, testVerifier)
+	ctx = WithNonce(ctx, "n-0S6_WzA2Mj")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		token, err := oauth2Login.TokenFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "any-token", token.AccessToken)
+		user, err := UserFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "54638001", user.ID)
+		fmt.Fprint(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the code is exchanged with the code verifier
+	// - the ID token nonce matches the ctx nonce
+	// - the Facebook User is added to the ctx of the success handler
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any-code&state=state-value", nil)
+	CallbackHandler(testConfig(), http.HandlerFunc(success), failure).ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_Rejected(t *testing.T) {
+	cases := map[string]struct {
+		state    string
+		verifier string
+		idToken  jwt.MapClaims
+		err      error
+	}{
+		"wrong state": {
+			state:    "other-state",
+			verifier: testVerifier,
+			idToken:  jwt.MapClaims{"aud": "client-id", "nonce": "n-0S6_WzA2Mj"},
+			err:      oauth2Login.ErrInvalidState,
+		},
+		"wrong nonce": {
+			state:    "state-value",
+			verifier: testVerifier,
+			idToken:  jwt.MapClaims{"aud": "client-id", "nonce": "other-nonce"},
+			err:      ErrInvalidNonce,
+		},
+		"wrong audience": {
+			state:    "state-value",
+			verifier: testVerifier,
+			idToken:  jwt.MapClaims{"aud": "other-client", "nonce": "n-0S6_WzA2Mj"},
+			err:      ErrInvalidAudience,
+		},
+		"missing id token": {
+			state:    "state-value",
+			verifier: testVerifier,
+			err:      ErrMissingIDToken,
+		},
+	}
+	jsonData := `{"id": "54638001", "name": "Ivy Crimson"}`
+	for name, c := range cases {
+		var idToken string
+		if c.idToken != nil {
+			idToken = testIDToken(t, c.idToken)
+		}
+		proxyClient, server := newFacebookLoginServer(c.verifier, idToken, jsonData)
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithState(ctx, "state-value")
+		ctx = WithVerifier(ctx, testVerifier)
+		ctx = WithNonce(ctx, "n-0S6_WzA2Mj")
+
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()), name)
+			fmt.Fprint(w, "failure handler called")
+		}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?code=any-code&state="+c.state, nil)
+		CallbackHandler(testConfig(), testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure)).ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String(), name)
+		server.Close()
+	}
+}
+
+func TestCallbackHandler_WrongVerifier(t *testing.T) {
+	proxyClient, server := newFacebookLoginServer(testVerifier, "", `{"id": "54638001"}`)
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "state-value")
+	ctx = WithVerifier(ctx, "Xr8kTxWJ4u1lPZ8aQ4n9dC2eVbN0mHsYq3fG7jKzL6w")
+
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Error(t, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprint(w, "failure handler called")
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any-code&state=state-value", nil)
+	CallbackHandler(testConfig(), testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure)).ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidVerifier(t *testing.T) {
+	assert.True(t, validVerifier(testVerifier))
+	assert.True(t, validVerifier(randomValue()))
+	assert.False(t, validVerifier("too-short"))
+	assert.False(t, validVerifier(testVerifier+"+/="))
+}
@@ -1,6 +1,7 @@
 package facebook
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -15,7 +16,34 @@ func newFacebookTestServer(jsonData string) (*http.Client, *httptest.Server) {
 	client, mux, server := testutils.TestServer()
 	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		fmt.Fprintf(w, jsonData)
+		fmt.Fprint(w, jsonData)
+	})
+	return client, server
+}
+
+// newFacebookLoginServer returns a new httptest.Server which mocks the
+// Facebook token and user endpoints and a client which proxies requests to
+// the server. The token endpoint requires the given code verifier and
+// responds with the given ID token, if any. The caller must close the server.
+func newFacebookLoginServer(verifier, idToken, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/v2.9/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != "any-code" || r.PostFormValue("code_verifier") != verifier {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error": "invalid_grant"}`)
+			return
+		}
+		token := map[string]string{"access_token": "any-token", "token_type": "bearer"}
+		if idToken != "" {
+			token["id_token"] = idToken
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(token)
+	})
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, jsonData)
 	})
 	return client, server
 }
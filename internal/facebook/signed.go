@@ -0,0 +1,108 @@
+package facebook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin"
+)
+
+// signed_request errors
+var (
+	ErrMissingSignedRequest = errors.New("facebook: Request missing signed_request")
+	ErrInvalidSignedRequest = errors.New("facebook: Invalid signed_request")
+	ErrExpiredSignedRequest = errors.New("facebook: Expired signed_request")
+)
+
+// MaxSignedRequestAge is how long after it was issued a signed_request is
+// accepted, so that a captured one cannot be replayed later on.
+const MaxSignedRequestAge = 5 * time.Minute
+
+// SignedRequest is the payload Facebook signs with the app secret when it
+// calls the app, e.g. when a user removes the app.
+type SignedRequest struct {
+	Algorithm string `json:"algorithm"`
+	IssuedAt  int64  `json:"issued_at"`
+	// UserID is the app-scoped ID of the user, as in User.ID.
+	UserID string `json:"user_id"`
+	// Expires and OAuthToken are only set when the user is logged in.
+	Expires    int64  `json:"expires,omitempty"`
+	OAuthToken string `json:"oauth_token,omitempty"`
+}
+
+// ParseSignedRequest verifies the HMAC-SHA256 signature of signedRequest
+// with appSecret and returns its payload. Requests issued more than
+// MaxSignedRequestAge ago, or as far in the future, are rejected with
+// ErrExpiredSignedRequest.
+func ParseSignedRequest(signedRequest, appSecret string) (*SignedRequest, error) {
+	parts := strings.SplitN(signedRequest, ".", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidSignedRequest
+	}
+	signature, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, ErrInvalidSignedRequest
+	}
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write([]byte(parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidSignedRequest
+	}
+
+	payload, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, ErrInvalidSignedRequest
+	}
+	request := new(SignedRequest)
+	if err := json.Unmarshal(payload, request); err != nil {
+		return nil, ErrInvalidSignedRequest
+	}
+	if !strings.EqualFold(request.Algorithm, "HMAC-SHA256") || request.UserID == "" || request.IssuedAt == 0 {
+		return nil, ErrInvalidSignedRequest
+	}
+	age := time.Since(time.Unix(request.IssuedAt, 0))
+	if age > MaxSignedRequestAge || age < -MaxSignedRequestAge {
+		return nil, ErrExpiredSignedRequest
+	}
+	return request, nil
+}
+
+// decodeSegment decodes base64url with or without padding.
+func decodeSegment(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+}
+
+// SignedRequestHandler reads the signed_request form value of requests made
+// by Facebook, such as the deauthorize and data deletion callbacks, and adds
+// the verified SignedRequest to the ctx. If the signed_request is missing or
+// invalid, handling delegates to the failure handler, otherwise to the
+// success handler.
+func SignedRequestHandler(config *Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		signedRequest := req.PostFormValue("signed_request")
+		if signedRequest == "" {
+			ctx = gologin.WithError(ctx, ErrMissingSignedRequest)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		request, err := ParseSignedRequest(signedRequest, config.ClientSecret)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithSignedRequest(ctx, request)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
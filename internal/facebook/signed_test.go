@@ -0,0 +1,124 @@
+package facebook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+// signRequest returns payload signed with secret the way Facebook does.
+func signRequest(payload, secret string) string {
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)) + "." + encoded
+}
+
+// deletionPayload is a data deletion payload for 54638001 issued at.
+func deletionPayload(issuedAt time.Time) string {
+	return fmt.Sprintf(`{"algorithm": "HMAC-SHA256", "issued_at": %d, "user_id": "54638001"}`, issuedAt.Unix())
+}
+
+func TestParseSignedRequest(t *testing.T) {
+	issuedAt := time.Now()
+	signed := signRequest(deletionPayload(issuedAt), "client-secret")
+	request, err := ParseSignedRequest(signed, "client-secret")
+	if assert.Nil(t, err) {
+		assert.Equal(t, &SignedRequest{Algorithm: "HMAC-SHA256", IssuedAt: issuedAt.Unix(), UserID: "54638001"}, request)
+	}
+}
+
+func TestParseSignedRequest_Expired(t *testing.T) {
+	for name, issuedAt := range map[string]time.Time{
+		"replayed":  time.Now().Add(-MaxSignedRequestAge - time.Minute),
+		"in future": time.Now().Add(MaxSignedRequestAge + time.Minute),
+	} {
+		_, err := ParseSignedRequest(signRequest(deletionPayload(issuedAt), "client-secret"), "client-secret")
+		assert.Equal(t, ErrExpiredSignedRequest, err, name)
+	}
+}
+
+func TestParseSignedRequest_Invalid(t *testing.T) {
+	valid := signRequest(deletionPayload(time.Now()), "client-secret")
+	cases := map[string]string{
+		"wrong secret":     signRequest(deletionPayload(time.Now()), "other-secret"),
+		"missing issued":   signRequest(`{"algorithm": "HMAC-SHA256", "user_id": "54638001"}`, "client-secret"),
+		"tampered payload": strings.SplitN(valid, ".", 2)[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"algorithm": "HMAC-SHA256", "user_id": "1"}`)),
+		"other algorithm":  signRequest(`{"algorithm": "none", "user_id": "54638001"}`, "client-secret"),
+		"missing user":     signRequest(`{"algorithm": "HMAC-SHA256"}`, "client-secret"),
+		"not json":         signRequest(`user_id=54638001`, "client-secret"),
+		"single segment":   "c2lnbmF0dXJl",
+		"bad base64":       "!!!." + strings.SplitN(valid, ".", 2)[1],
+		"empty":            "",
+	}
+	for name, signed := range cases {
+		_, err := ParseSignedRequest(signed, "client-secret")
+		assert.Equal(t, ErrInvalidSignedRequest, err, name)
+	}
+}
+
+func TestSignedRequestHandler(t *testing.T) {
+	config := &Config{ClientSecret: "client-secret"}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		request, err := SignedRequestFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "54638001", request.UserID)
+		fmt.Fprint(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	form := url.Values{"signed_request": {signRequest(deletionPayload(time.Now()), "client-secret")}}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	SignedRequestHandler(config, http.HandlerFunc(success), failure).ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestSignedRequestHandler_Rejected(t *testing.T) {
+	config := &Config{ClientSecret: "client-secret"}
+	cases := map[string]struct {
+		form url.Values
+		err  error
+	}{
+		"missing": {url.Values{}, ErrMissingSignedRequest},
+		"invalid": {url.Values{"signed_request": {signRequest(deletionPayload(time.Now()), "other-secret")}}, ErrInvalidSignedRequest},
+		"expired": {url.Values{"signed_request": {signRequest(deletionPayload(time.Now().Add(-time.Hour)), "client-secret")}}, ErrExpiredSignedRequest},
+	}
+	for name, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()), name)
+			fmt.Fprint(w, "failure handler called")
+		}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(c.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		SignedRequestHandler(config, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure)).ServeHTTP(w, req)
+		assert.Equal(t, "failure handler called", w.Body.String(), name)
+	}
+}
+
+func TestMemoryDeletionStore(t *testing.T) {
+	store := NewMemoryDeletionStore()
+	code := NewConfirmationCode()
+	assert.NotEqual(t, code, NewConfirmationCode())
+
+	_, err := store.GetDeletion(code)
+	assert.Equal(t, ErrDeletionNotFound, err)
+	assert.Nil(t, store.SaveDeletion(&Deletion{ConfirmationCode: code, Status: DeletionCompleted}))
+	deletion, err := store.GetDeletion(code)
+	if assert.Nil(t, err) {
+		assert.Equal(t, DeletionCompleted, deletion.Status)
+	}
+}
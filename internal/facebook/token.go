@@ -0,0 +1,260 @@
+package facebook
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dghubble/sling"
+	"github.com/sirupsen/logrus"
+)
+
+// Token manager errors
+var (
+	ErrTokenNotFound = errors.New("facebook: no token stored for user")
+	ErrTokenExpired  = errors.New("facebook: stored token expired")
+)
+
+// Token is a long-lived user access token.
+type Token struct {
+	AccessToken string `json:"accessToken"`
+	// ExpiresAt is zero for tokens that do not expire.
+	ExpiresAt time.Time `json:"expiresAt"`
+	// NeedsLogin is set once the token could not be refreshed. The user has
+	// to log in again before ExpiresAt to keep access.
+	NeedsLogin bool `json:"needsLogin,omitempty"`
+}
+
+// Expired reports whether the token expired at now.
+func (t *Token) Expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+// GraphError is an error response of the Graph API.
+type GraphError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    int    `json:"code"`
+}
+
+func (e *GraphError) Error() string {
+	return fmt.Sprintf("facebook: %s (%s %d)", e.Message, e.Type, e.Code)
+}
+
+// TokenStore persists sealed tokens per user. It never sees a token in the
+// clear.
+type TokenStore interface {
+	// SaveToken stores sealed for userID, replacing any previous token. A
+	// zero expiresAt never expires.
+	SaveToken(userID string, sealed []byte, expiresAt time.Time) error
+	// GetToken returns ErrTokenNotFound when userID has no token.
+	GetToken(userID string) ([]byte, error)
+	DeleteToken(userID string) error
+	// ExpiringTokens returns the users whose tokens expire before t.
+	ExpiringTokens(before time.Time) ([]string, error)
+}
+
+// TokenManager exchanges the short-lived tokens issued at login for
+// long-lived ones and keeps them, encrypted, until they are needed.
+type TokenManager interface {
+	// Exchange swaps shortLived for a long-lived token and stores it for
+	// userID.
+	Exchange(userID, shortLived string) (*Token, error)
+	// Token returns the stored token of userID.
+	Token(userID string) (*Token, error)
+	DeleteToken(userID string) error
+	// RefreshExpiring exchanges tokens expiring within window for fresh ones,
+	// flagging those Facebook refuses to refresh with NeedsLogin, and drops
+	// expired tokens.
+	RefreshExpiring(window time.Duration) error
+	// Run calls RefreshExpiring every tick until stop is closed.
+	Run(tick, window time.Duration, stop <-chan struct{})
+}
+
+type tokenManager struct {
+	sling  *sling.Sling
+	config *Config
+	store  TokenStore
+	aead   cipher.AEAD
+}
+
+// NewTokenManager returns a TokenManager calling the Graph API configured
+// by config with httpClient, or http.DefaultClient if nil. Tokens are sealed
+// with AES-GCM under key, which must be 16, 24 or 32 bytes long.
+func NewTokenManager(httpClient *http.Client, config *Config, store TokenStore, key []byte) (TokenManager, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &tokenManager{
+		sling:  sling.New().Client(httpClient).Base(config.baseURL()).Set("Accept", "application/json"),
+		config: config,
+		store:  store,
+		aead:   aead,
+	}, nil
+}
+
+type exchangeParams struct {
+	GrantType       string `url:"grant_type"`
+	ClientID        string `url:"client_id"`
+	ClientSecret    string `url:"client_secret"`
+	FBExchangeToken string `url:"fb_exchange_token"`
+}
+
+type exchangeResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type graphErrorResponse struct {
+	Error *GraphError `json:"error"`
+}
+
+// exchange swaps token for a long-lived token at the token endpoint. The
+// app secret and token go in the form body, not the URL, so that they are
+// not part of the *url.Error of a failed request.
+func (m *tokenManager) exchange(token string) (*Token, error) {
+	params := &exchangeParams{"fb_exchange_token", m.config.ClientID, m.config.ClientSecret, token}
+	success := new(exchangeResponse)
+	failure := new(graphErrorResponse)
+	resp, err := m.sling.New().Post("oauth/access_token").BodyForm(params).Receive(success, failure)
+	if err != nil {
+		return nil, err
+	}
+	if failure.Error != nil {
+		return nil, failure.Error
+	}
+	if resp.StatusCode != http.StatusOK || success.AccessToken == "" {
+		return nil, fmt.Errorf("facebook: unable to exchange token: %s", resp.Status)
+	}
+	long := &Token{AccessToken: success.AccessToken}
+	if success.ExpiresIn > 0 {
+		long.ExpiresAt = time.Now().Add(time.Duration(success.ExpiresIn) * time.Second)
+	}
+	return long, nil
+}
+
+func (m *tokenManager) Exchange(userID, shortLived string) (*Token, error) {
+	token, err := m.exchange(shortLived)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"userId": userID, "error": err}).Error("Unable to exchange Facebook token")
+		return nil, err
+	}
+	if err := m.save(userID, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (m *tokenManager) Token(userID string) (*Token, error) {
+	token, err := m.load(userID)
+	if err != nil {
+		return nil, err
+	}
+	if token.Expired(time.Now()) {
+		return nil, ErrTokenExpired
+	}
+	return token, nil
+}
+
+func (m *tokenManager) DeleteToken(userID string) error {
+	return m.store.DeleteToken(userID)
+}
+
+func (m *tokenManager) RefreshExpiring(window time.Duration) error {
+	now := time.Now()
+	userIDs, err := m.store.ExpiringTokens(now.Add(window))
+	if err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		token, err := m.load(userID)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"userId": userID, "error": err}).Error("Unable to read Facebook token")
+			continue
+		}
+		if token.Expired(now) {
+			if err := m.store.DeleteToken(userID); err != nil {
+				return err
+			}
+			continue
+		}
+		if token.NeedsLogin {
+			continue
+		}
+
+		fresh, err := m.exchange(token.AccessToken)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"userId": userID, "error": err}).Warn("Facebook token needs a new login")
+			token.NeedsLogin = true
+			fresh = token
+		}
+		if err := m.save(userID, fresh); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *tokenManager) Run(tick, window time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.RefreshExpiring(window); err != nil {
+				logrus.WithError(err).Error("Refreshing Facebook tokens")
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// save seals token for userID and stores it. The user ID is authenticated
+// with the token so a sealed token cannot be moved to another user.
+func (m *tokenManager) save(userID string, token *Token) error {
+	plaintext, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, m.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := m.aead.Seal(nonce, nonce, plaintext, []byte(userID))
+	return m.store.SaveToken(userID, sealed, token.ExpiresAt)
+}
+
+// load returns the stored token of userID, expired or not.
+func (m *tokenManager) load(userID string) (*Token, error) {
+	sealed, err := m.store.GetToken(userID)
+	if err != nil {
+		return nil, err
+	}
+	size := m.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, errors.New("facebook: sealed token too short")
+	}
+	plaintext, err := m.aead.Open(nil, sealed[:size], sealed[size:], []byte(userID))
+	if err != nil {
+		return nil, err
+	}
+	token := new(Token)
+	if err := json.Unmarshal(plaintext, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
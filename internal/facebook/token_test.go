@@ -0,0 +1,164 @@
+package facebook
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testTokenKey = bytes.Repeat([]byte{7}, 32)
+
+// newExchangeServer mocks the token endpoint, exchanging any token but
+// "revoked-token" for "long-token-<n>" valid for expiresIn seconds.
+func newExchangeServer(t *testing.T, expiresIn int) (*http.Client, func()) {
+	client, mux, server := testutils.TestServer()
+	var n int32
+	mux.HandleFunc("/v2.9/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Empty(t, r.URL.RawQuery, "secrets stay out of the URL")
+		assert.Equal(t, "fb_exchange_token", r.PostFormValue("grant_type"))
+		assert.Equal(t, "client-id", r.PostFormValue("client_id"))
+		assert.Equal(t, "client-secret", r.PostFormValue("client_secret"))
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostFormValue("fb_exchange_token") == "revoked-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error": {"message": "Error validating access token", "type": "OAuthException", "code": 190}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token": "long-token-%d", "token_type": "bearer", "expires_in": %d}`, atomic.AddInt32(&n, 1), expiresIn)
+	})
+	return client, server.Close
+}
+
+func newTestTokenManager(t *testing.T, client *http.Client, store TokenStore) TokenManager {
+	config := &Config{ClientID: "client-id", ClientSecret: "client-secret"}
+	manager, err := NewTokenManager(client, config, store, testTokenKey)
+	require.Nil(t, err)
+	return manager
+}
+
+func TestTokenManager_Exchange(t *testing.T) {
+	client, close := newExchangeServer(t, 5184000)
+	defer close()
+	store := NewMemoryTokenStore()
+	manager := newTestTokenManager(t, client, store)
+
+	token, err := manager.Exchange("54638001", "short-token")
+	require.Nil(t, err)
+	assert.Equal(t, "long-token-1", token.AccessToken)
+	assert.WithinDuration(t, time.Now().Add(60*24*time.Hour), token.ExpiresAt, time.Minute)
+
+	sealed, err := store.GetToken("54638001")
+	require.Nil(t, err)
+	assert.False(t, bytes.Contains(sealed, []byte("long-token-1")), "tokens are stored encrypted")
+
+	stored, err := manager.Token("54638001")
+	require.Nil(t, err)
+	assert.Equal(t, token.AccessToken, stored.AccessToken)
+	assert.False(t, stored.NeedsLogin)
+
+	_, err = manager.Token("other-user")
+	assert.Equal(t, ErrTokenNotFound, err)
+
+	require.Nil(t, manager.DeleteToken("54638001"))
+	_, err = manager.Token("54638001")
+	assert.Equal(t, ErrTokenNotFound, err)
+}
+
+func TestTokenManager_ExchangeRejected(t *testing.T) {
+	client, close := newExchangeServer(t, 5184000)
+	defer close()
+	manager := newTestTokenManager(t, client, NewMemoryTokenStore())
+
+	_, err := manager.Exchange("54638001", "revoked-token")
+	if assert.IsType(t, &GraphError{}, err) {
+		assert.Equal(t, 190, err.(*GraphError).Code)
+	}
+	_, err = manager.Token("54638001")
+	assert.Equal(t, ErrTokenNotFound, err)
+}
+
+func TestTokenManager_ExchangeErrorHidesSecrets(t *testing.T) {
+	client, close := newExchangeServer(t, 5184000)
+	close()
+	manager := newTestTokenManager(t, client, NewMemoryTokenStore())
+
+	_, err := manager.Exchange("54638001", "short-token")
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "client-secret")
+	assert.NotContains(t, err.Error(), "short-token")
+}
+
+func TestTokenManager_SealedToUser(t *testing.T) {
+	client, close := newExchangeServer(t, 5184000)
+	defer close()
+	store := NewMemoryTokenStore()
+	manager := newTestTokenManager(t, client, store)
+
+	_, err := manager.Exchange("54638001", "short-token")
+	require.Nil(t, err)
+	sealed, _ := store.GetToken("54638001")
+	require.Nil(t, store.SaveToken("other-user", sealed, time.Time{}))
+	_, err = manager.Token("other-user")
+	assert.Error(t, err, "a sealed token cannot be moved to another user")
+
+	other, err := NewTokenManager(client, &Config{}, store, bytes.Repeat([]byte{8}, 32))
+	require.Nil(t, err)
+	_, err = other.Token("54638001")
+	assert.Error(t, err, "tokens cannot be read without the key")
+}
+
+func TestTokenManager_RefreshExpiring(t *testing.T) {
+	client, close := newExchangeServer(t, 5184000)
+	defer close()
+	store := NewMemoryTokenStore()
+	manager := newTestTokenManager(t, client, store).(*tokenManager)
+
+	soon := time.Now().Add(24 * time.Hour)
+	require.Nil(t, manager.save("expiring", &Token{AccessToken: "old-token", ExpiresAt: soon}))
+	require.Nil(t, manager.save("revoked", &Token{AccessToken: "revoked-token", ExpiresAt: soon}))
+	require.Nil(t, manager.save("expired", &Token{AccessToken: "old-token", ExpiresAt: time.Now().Add(-time.Hour)}))
+	require.Nil(t, manager.save("fresh", &Token{AccessToken: "fresh-token", ExpiresAt: time.Now().Add(30 * 24 * time.Hour)}))
+	require.Nil(t, manager.save("forever", &Token{AccessToken: "page-token"}))
+
+	require.Nil(t, manager.RefreshExpiring(7*24*time.Hour))
+
+	token, err := manager.Token("expiring")
+	require.Nil(t, err)
+	assert.Equal(t, "long-token-1", token.AccessToken)
+	assert.True(t, token.ExpiresAt.After(soon))
+
+	token, err = manager.Token("revoked")
+	require.Nil(t, err)
+	assert.Equal(t, "revoked-token", token.AccessToken)
+	assert.True(t, token.NeedsLogin, "tokens that cannot be refreshed are flagged")
+
+	_, err = manager.Token("expired")
+	assert.Equal(t, ErrTokenNotFound, err, "expired tokens are dropped")
+
+	token, err = manager.Token("fresh")
+	require.Nil(t, err)
+	assert.Equal(t, "fresh-token", token.AccessToken)
+	token, err = manager.Token("forever")
+	require.Nil(t, err)
+	assert.Equal(t, "page-token", token.AccessToken)
+}
+
+func TestTokenManager_Expired(t *testing.T) {
+	manager := newTestTokenManager(t, nil, NewMemoryTokenStore()).(*tokenManager)
+	require.Nil(t, manager.save("54638001", &Token{AccessToken: "old-token", ExpiresAt: time.Now().Add(-time.Second)}))
+	_, err := manager.Token("54638001")
+	assert.Equal(t, ErrTokenExpired, err)
+}
+
+func TestNewTokenManager_InvalidKey(t *testing.T) {
+	_, err := NewTokenManager(nil, &Config{}, NewMemoryTokenStore(), []byte("short"))
+	assert.Error(t, err)
+}
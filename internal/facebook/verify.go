@@ -6,36 +6,138 @@ import (
 	"github.com/dghubble/sling"
 )
 
-const facebookAPI = "https://graph.facebook.com/v2.9/"
-
-// User is a Facebook user.
+// User is a Facebook user. Only the requested fields are set.
 //
 // Note that user ids are unique to each app.
 type User struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Email     string   `json:"email"`
+	FirstName string   `json:"first_name,omitempty"`
+	LastName  string   `json:"last_name,omitempty"`
+	Locale    string   `json:"locale,omitempty"`
+	Picture   *Picture `json:"picture,omitempty"`
+}
+
+// Picture is the profile picture of a User.
+type Picture struct {
+	Data PictureData `json:"data"`
+}
+
+// PictureData describes a profile picture image.
+type PictureData struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	// IsSilhouette is set while the user has no picture of their own.
+	IsSilhouette bool `json:"is_silhouette"`
+}
+
+// Permission is a permission the user granted or declined the app.
+type Permission struct {
+	Permission string `json:"permission"`
+	// Status is "granted", "declined" or "expired".
+	Status string `json:"status"`
 }
 
-// client is a Facebook client for obtaining the current User.
-type client struct {
-	c     *http.Client
-	sling *sling.Sling
+// TokenInfo describes an access token, as returned by debug_token.
+type TokenInfo struct {
+	AppID     string   `json:"app_id"`
+	Type      string   `json:"type"`
+	UserID    string   `json:"user_id"`
+	IsValid   bool     `json:"is_valid"`
+	IssuedAt  int64    `json:"issued_at"`
+	ExpiresAt int64    `json:"expires_at"`
+	Scopes    []string `json:"scopes"`
+	// DataAccessExpiresAt is when the app loses access to user data unless
+	// the user logs in again.
+	DataAccessExpiresAt int64 `json:"data_access_expires_at"`
 }
 
-func newClient(httpClient *http.Client) *client {
-	base := sling.New().Client(httpClient).Base(facebookAPI)
-	return &client{
-		c:     httpClient,
-		sling: base,
+// Granted returns the granted permissions of perms.
+func Granted(perms []Permission) []string {
+	var scopes []string
+	for _, p := range perms {
+		if p.Status == "granted" {
+			scopes = append(scopes, p.Permission)
+		}
 	}
+	return scopes
 }
 
-func (c *client) Me() (*User, *http.Response, error) {
-	user := new(User)
+// HasScopes reports whether all of scopes were granted to the token.
+func (t *TokenInfo) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		found := false
+		for _, granted := range t.Scopes {
+			if granted == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Client is a Facebook Graph API client.
+type Client struct {
+	c      *http.Client
+	sling  *sling.Sling
+	fields string
+}
+
+// NewClient returns a Client calling the Graph API configured by config.
+// Calls on behalf of a user need an httpClient authorized with their access
+// token, as returned by oauth2.Config.Client.
+func NewClient(httpClient *http.Client, config *Config) *Client {
 	// Facebook returns JSON as Content-Type text/javascript :(
 	// Set Accept header to receive proper Content-Type application/json
 	// so Sling will decode into the struct
-	resp, err := c.sling.New().Set("Accept", "application/json").Get("me?fields=name,email").ReceiveSuccess(user)
+	base := sling.New().Client(httpClient).Base(config.baseURL()).Set("Accept", "application/json")
+	return &Client{
+		c:      httpClient,
+		sling:  base,
+		fields: config.fields(),
+	}
+}
+
+type fieldsParams struct {
+	Fields string `url:"fields"`
+}
+
+// Me returns the User the access token was issued to.
+func (c *Client) Me() (*User, *http.Response, error) {
+	user := new(User)
+	resp, err := c.sling.New().Get("me").QueryStruct(&fieldsParams{c.fields}).ReceiveSuccess(user)
 	return user, resp, err
 }
+
+// Permissions returns the permissions the user granted or declined the app.
+func (c *Client) Permissions() ([]Permission, *http.Response, error) {
+	var body struct {
+		Data []Permission `json:"data"`
+	}
+	resp, err := c.sling.New().Get("me/permissions").ReceiveSuccess(&body)
+	return body.Data, resp, err
+}
+
+type debugTokenParams struct {
+	InputToken  string `url:"input_token"`
+	AccessToken string `url:"access_token"`
+}
+
+// DebugToken returns what Facebook knows of inputToken, such as the scopes
+// granted to it. appToken is the app access token, see
+// Config.AppAccessToken, so the Client must not be authorized with a user
+// access token.
+func (c *Client) DebugToken(inputToken, appToken string) (*TokenInfo, *http.Response, error) {
+	var body struct {
+		Data *TokenInfo `json:"data"`
+	}
+	body.Data = new(TokenInfo)
+	resp, err := c.sling.New().Get("debug_token").QueryStruct(&debugTokenParams{inputToken, appToken}).ReceiveSuccess(&body)
+	return body.Data, resp, err
+}
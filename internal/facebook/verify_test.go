@@ -0,0 +1,91 @@
+package facebook
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_OAuth2(t *testing.T) {
+	config := &Config{ClientID: "client-id", ClientSecret: "client-secret", RedirectURL: "https://app.example/callback"}
+	oauth2Config := config.OAuth2()
+	assert.Equal(t, "https://www.facebook.com/v2.9/dialog/oauth", oauth2Config.Endpoint.AuthURL)
+	assert.Equal(t, "https://graph.facebook.com/v2.9/oauth/access_token", oauth2Config.Endpoint.TokenURL)
+	assert.Equal(t, DefaultScopes, oauth2Config.Scopes)
+	assert.Equal(t, "client-id|client-secret", config.AppAccessToken())
+
+	config = &Config{Scopes: []string{"email"}, GraphURL: "https://graph.example/", Version: "v19.0"}
+	oauth2Config = config.OAuth2()
+	assert.Equal(t, "https://www.facebook.com/v19.0/dialog/oauth", oauth2Config.Endpoint.AuthURL)
+	assert.Equal(t, "https://graph.example/v19.0/oauth/access_token", oauth2Config.Endpoint.TokenURL)
+	assert.Equal(t, []string{"email"}, oauth2Config.Scopes)
+}
+
+func TestClient_Me(t *testing.T) {
+	client, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/v19.0/me", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "id,first_name,picture", r.URL.Query().Get("fields"))
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id": "54638001", "first_name": "Ivy", "picture": {"data": {"url": "https://pic.example/ivy.jpg", "width": 50, "height": 50, "is_silhouette": false}}}`)
+	})
+
+	config := &Config{Version: "v19.0", Fields: []string{"id", "first_name", "picture"}}
+	user, resp, err := NewClient(client, config).Me()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "54638001", user.ID)
+	assert.Equal(t, "Ivy", user.FirstName)
+	if assert.NotNil(t, user.Picture) {
+		assert.Equal(t, "https://pic.example/ivy.jpg", user.Picture.Data.URL)
+		assert.Equal(t, 50, user.Picture.Data.Width)
+	}
+}
+
+func TestClient_Permissions(t *testing.T) {
+	client, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/v2.9/me/permissions", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"data": [{"permission": "public_profile", "status": "granted"}, {"permission": "email", "status": "declined"}]}`)
+	})
+
+	perms, _, err := NewClient(client, &Config{}).Permissions()
+	assert.Nil(t, err)
+	assert.Equal(t, []Permission{{"public_profile", "granted"}, {"email", "declined"}}, perms)
+	assert.Equal(t, []string{"public_profile"}, Granted(perms))
+}
+
+func TestClient_DebugToken(t *testing.T) {
+	client, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/v2.9/debug_token", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "user-token", r.URL.Query().Get("input_token"))
+		assert.Equal(t, "client-id|client-secret", r.URL.Query().Get("access_token"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"data": {"app_id": "client-id", "type": "USER", "user_id": "54638001", "is_valid": true, "expires_at": 1700000000, "scopes": ["public_profile", "email"]}}`)
+	})
+
+	config := &Config{ClientID: "client-id", ClientSecret: "client-secret"}
+	info, _, err := NewClient(client, config).DebugToken("user-token", config.AppAccessToken())
+	assert.Nil(t, err)
+	assert.True(t, info.IsValid)
+	assert.Equal(t, "54638001", info.UserID)
+	assert.Equal(t, int64(1700000000), info.ExpiresAt)
+	assert.True(t, info.HasScopes("email"))
+	assert.True(t, info.HasScopes())
+	assert.False(t, info.HasScopes("email", "user_friends"))
+}
+
+func TestClient_ErrorResponse(t *testing.T) {
+	client, server := testutils.NewErrorServer("Facebook Service Down", http.StatusInternalServerError)
+	defer server.Close()
+
+	_, resp, err := NewClient(client, &Config{}).Permissions()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
@@ -0,0 +1,44 @@
+package graph
+
+import (
+	"context"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"hex-example/internal/user"
+)
+
+type accountResolver struct {
+	a *user.Account
+}
+
+func (r *accountResolver) ID() graphql.ID {
+	return graphql.ID(r.a.ID)
+}
+
+func (r *accountResolver) Username() string {
+	return r.a.Username
+}
+
+func (r *accountResolver) FirstName() string {
+	return r.a.FirstName
+}
+
+func (r *accountResolver) LastName() string {
+	return r.a.LastName
+}
+
+func (r *accountResolver) CreatedTickets(ctx context.Context) ([]*ticketResolver, error) {
+	l, err := loadersFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return loadTickets(ctx, l.ticketsByCreator, r.a.Username)
+}
+
+func (r *accountResolver) AssignedTickets(ctx context.Context) ([]*ticketResolver, error) {
+	l, err := loadersFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return loadTickets(ctx, l.ticketsByAssigned, r.a.Username)
+}
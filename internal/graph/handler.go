@@ -0,0 +1,23 @@
+// Package graph serves a GraphQL API over the ticket and user services.
+package graph
+
+import (
+	"net/http"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"hex-example/internal/ticket"
+	"hex-example/internal/user"
+)
+
+// NewHandler returns a http.Handler which executes GraphQL requests against
+// the given services. Every request gets its own set of batching loaders, so
+// cached results never leak between requests.
+func NewHandler(tickets ticket.TicketService, users user.UserService) http.Handler {
+	h := &relay.Handler{Schema: graphql.MustParseSchema(schema, NewResolver(tickets, users))}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := withLoaders(r.Context(), newLoaders(tickets, users))
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,203 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"hex-example/internal/middleware"
+	"hex-example/internal/mocks"
+	"hex-example/internal/rbac"
+	"hex-example/internal/ticket"
+	"hex-example/internal/user"
+)
+
+// fakeUserService is a user.UserService backed by a map of accounts which
+// records the batches it was asked for.
+type fakeUserService struct {
+	user.UserService
+	accounts map[string]*user.Account
+	batches  [][]string
+}
+
+func (s *fakeUserService) GetAccount(id string) (*user.Account, error) {
+	for _, account := range s.accounts {
+		if account.ID == id {
+			return account, nil
+		}
+	}
+	return nil, &user.NotFoundError{}
+}
+
+func (s *fakeUserService) FindAccounts(usernames []string) ([]*user.Account, error) {
+	s.batches = append(s.batches, usernames)
+	accounts := make([]*user.Account, len(usernames))
+	for i, username := range usernames {
+		accounts[i] = s.accounts[username]
+	}
+	return accounts, nil
+}
+
+// asRole returns r as sent by an authenticated caller holding role.
+func asRole(r *http.Request, role string) *http.Request {
+	return r.WithContext(middleware.WithClaims(r.Context(), &middleware.Claims{Subject: "test", Type: middleware.PrincipalUser, Role: role}))
+}
+
+// inProject returns r as sent by account a1, holding role in project only.
+func inProject(r *http.Request, project, role string) *http.Request {
+	claims := &middleware.Claims{Subject: "a1", Type: middleware.PrincipalUser, Projects: map[string]string{project: role}}
+	return r.WithContext(middleware.WithClaims(r.Context(), claims))
+}
+
+// query posts the GraphQL query to a handler over tickets and users.
+func query(tickets ticket.TicketService, users user.UserService, q string, auth func(*http.Request) *http.Request) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]string{"query": q})
+	r, _ := http.NewRequest("POST", "/graphql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	NewHandler(tickets, users).ServeHTTP(w, auth(r))
+	return w
+}
+
+func TestHandler_TicketsWithCreators(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	tickets := mocks.NewMockTicketService(mockCtrl)
+	tickets.EXPECT().FindAllTickets().Return([]*ticket.Ticket{
+		{ID: "1", Title: "First", Creator: "joel"},
+		{ID: "2", Title: "Second", Creator: "ivy"},
+		{ID: "3", Title: "Third", Creator: "joel"},
+	}, nil)
+	users := &fakeUserService{accounts: map[string]*user.Account{
+		"joel": {ID: "a1", Username: "joel", FirstName: "Joel"},
+		"ivy":  {ID: "a2", Username: "ivy", FirstName: "Ivy"},
+	}}
+
+	body, _ := json.Marshal(map[string]string{
+		"query": `{ tickets { id creatorAccount { username firstName } } }`,
+	})
+	r, _ := http.NewRequest("POST", "/graphql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	NewHandler(tickets, users).ServeHTTP(w, asRole(r, rbac.RoleViewer))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var result struct {
+		Data struct {
+			Tickets []struct {
+				ID             string
+				CreatorAccount struct {
+					Username  string
+					FirstName string
+				}
+			}
+		}
+	}
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&result))
+	if assert.Len(t, result.Data.Tickets, 3) {
+		assert.Equal(t, "Joel", result.Data.Tickets[0].CreatorAccount.FirstName)
+		assert.Equal(t, "Ivy", result.Data.Tickets[1].CreatorAccount.FirstName)
+	}
+	// Both creators are resolved with a single batched lookup
+	if assert.Len(t, users.batches, 1) {
+		assert.ElementsMatch(t, []string{"joel", "ivy"}, users.batches[0])
+	}
+}
+
+func TestHandler_AccountWithTickets(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	tickets := mocks.NewMockTicketService(mockCtrl)
+	tickets.EXPECT().FindUserTickets([]string{"joel"}).Return([]*ticket.Ticket{
+		{ID: "1", Creator: "joel"},
+		{ID: "2", Creator: "ivy", Assigned: "joel"},
+	}, nil)
+	users := &fakeUserService{accounts: map[string]*user.Account{
+		"joel": {ID: "a1", Username: "joel"},
+	}}
+
+	body, _ := json.Marshal(map[string]string{
+		"query": `{ account(username: "joel") { id createdTickets { id } } }`,
+	})
+	r, _ := http.NewRequest("POST", "/graphql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	NewHandler(tickets, users).ServeHTTP(w, asRole(r, rbac.RoleViewer))
+
+	assert.JSONEq(t, `{"data":{"account":{"id":"a1","createdTickets":[{"id":"1"}]}}}`, w.Body.String())
+}
+
+func TestHandler_TicketsByIDAreBatched(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	tickets := mocks.NewMockTicketService(mockCtrl)
+	tickets.EXPECT().FindTicketsByIDs(gomock.Any()).DoAndReturn(func(ids []string) ([]*ticket.Ticket, error) {
+		assert.ElementsMatch(t, []string{"1", "2", "3"}, ids)
+		return []*ticket.Ticket{{ID: "1"}, {ID: "2"}}, nil
+	})
+
+	body, _ := json.Marshal(map[string]string{
+		"query": `{ a: ticket(id: "1") { id } b: ticket(id: "2") { id } c: ticket(id: "3") { id } }`,
+	})
+	r, _ := http.NewRequest("POST", "/graphql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	NewHandler(tickets, &fakeUserService{}).ServeHTTP(w, asRole(r, rbac.RoleViewer))
+
+	assert.JSONEq(t, `{"data":{"a":{"id":"1"},"b":{"id":"2"},"c":null}}`, w.Body.String())
+}
+
+func TestHandler_CreateTicketForbiddenForViewer(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	body, _ := json.Marshal(map[string]string{
+		"query": `mutation { createTicket(input: {title: "Test"}) { id } }`,
+	})
+	r, _ := http.NewRequest("POST", "/graphql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	NewHandler(mocks.NewMockTicketService(mockCtrl), &fakeUserService{}).ServeHTTP(w, asRole(r, rbac.RoleViewer))
+
+	var result struct {
+		Errors []struct{ Message string }
+	}
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&result))
+	if assert.Len(t, result.Errors, 1) {
+		assert.Equal(t, "Permission tickets:create required", result.Errors[0].Message)
+	}
+}
+
+func TestHandler_ProjectRoles(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	tickets := mocks.NewMockTicketService(mockCtrl)
+	tickets.EXPECT().FindProjectTickets("infra").Return([]*ticket.Ticket{{ID: "1", Project: "infra"}}, nil)
+	tickets.EXPECT().FindTicketsByIDs(gomock.Any()).Return([]*ticket.Ticket{{ID: "2", Project: "web"}}, nil)
+	tickets.EXPECT().CreateTicket(gomock.Any()).DoAndReturn(func(t *ticket.Ticket) error {
+		t.ID = "3"
+		return nil
+	})
+	users := &fakeUserService{accounts: map[string]*user.Account{"joel": {ID: "a1", Username: "joel"}}}
+	auth := func(r *http.Request) *http.Request { return inProject(r, "infra", rbac.RoleMember) }
+
+	w := query(tickets, users, `{ tickets(project: "infra") { id project } }`, auth)
+	assert.JSONEq(t, `{"data":{"tickets":[{"id":"1","project":"infra"}]}}`, w.Body.String())
+
+	for q, message := range map[string]string{
+		`{ tickets { id } }`:                 "Permission tickets:read required",
+		`{ tickets(project: "web") { id } }`: "Permission tickets:read required in project web",
+		`{ ticket(id: "2") { id } }`:         "Permission tickets:read required",
+	} {
+		w = query(tickets, users, q, auth)
+		assert.Contains(t, w.Body.String(), `"message":"`+message+`"`, q)
+	}
+
+	w = query(tickets, users, `mutation { createTicket(input: {project: "infra", title: "Test"}) { id project creator } }`, auth)
+	assert.JSONEq(t, `{"data":{"createTicket":{"id":"3","project":"infra","creator":"joel"}}}`, w.Body.String(), "the caller is the creator")
+	w = query(tickets, users, `mutation { createTicket(input: {project: "web", title: "Test"}) { id } }`, auth)
+	assert.Contains(t, w.Body.String(), "Permission tickets:create required in project web")
+}
@@ -0,0 +1,115 @@
+package graph
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWait     = 2 * time.Millisecond
+	defaultMaxBatch = 100
+)
+
+// batchFunc loads the values for keys in a single round trip. It must return
+// one value and one error per key, in key order.
+type batchFunc func(ctx context.Context, keys []string) ([]interface{}, []error)
+
+// loader collects the keys requested by concurrently running resolvers for a
+// short window and loads them with one call to its batchFunc, DataLoader
+// style. Results are cached for the lifetime of the loader, which is a single
+// GraphQL request.
+type loader struct {
+	fetch    batchFunc
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	cache   map[string]*result
+	current *batch
+}
+
+type result struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+type batch struct {
+	keys    []string
+	results []*result
+	closing bool
+}
+
+func newLoader(fetch batchFunc) *loader {
+	return &loader{
+		fetch:    fetch,
+		wait:     defaultWait,
+		maxBatch: defaultMaxBatch,
+		cache:    make(map[string]*result),
+	}
+}
+
+// Load returns the value for key, waiting for the batch it is part of.
+func (l *loader) Load(ctx context.Context, key string) (interface{}, error) {
+	res := l.enqueue(ctx, key)
+	<-res.done
+	return res.value, res.err
+}
+
+func (l *loader) enqueue(ctx context.Context, key string) *result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if res, ok := l.cache[key]; ok {
+		return res
+	}
+
+	res := &result{done: make(chan struct{})}
+	l.cache[key] = res
+
+	if l.current == nil {
+		l.current = &batch{}
+		go l.dispatchAfter(ctx, l.current)
+	}
+	b := l.current
+	b.keys = append(b.keys, key)
+	b.results = append(b.results, res)
+
+	if len(b.keys) >= l.maxBatch {
+		b.closing = true
+		l.current = nil
+		go l.dispatch(ctx, b)
+	}
+	return res
+}
+
+func (l *loader) dispatchAfter(ctx context.Context, b *batch) {
+	time.Sleep(l.wait)
+
+	l.mu.Lock()
+	if b.closing {
+		l.mu.Unlock()
+		return
+	}
+	b.closing = true
+	if l.current == b {
+		l.current = nil
+	}
+	l.mu.Unlock()
+
+	l.dispatch(ctx, b)
+}
+
+func (l *loader) dispatch(ctx context.Context, b *batch) {
+	values, errs := l.fetch(ctx, b.keys)
+	for i, res := range b.results {
+		if i < len(values) {
+			res.value = values[i]
+		}
+		if i < len(errs) {
+			res.err = errs[i]
+		}
+		close(res.done)
+	}
+}
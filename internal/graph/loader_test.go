@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoader_BatchesConcurrentLoads(t *testing.T) {
+	var mu sync.Mutex
+	var calls [][]string
+	l := newLoader(func(ctx context.Context, keys []string) ([]interface{}, []error) {
+		mu.Lock()
+		calls = append(calls, keys)
+		mu.Unlock()
+		values := make([]interface{}, len(keys))
+		for i, k := range keys {
+			values[i] = "value-" + k
+		}
+		return values, make([]error, len(keys))
+	})
+
+	var wg sync.WaitGroup
+	for _, k := range []string{"a", "b", "c", "a"} {
+		wg.Add(1)
+		go func(k string) {
+			defer wg.Done()
+			value, err := l.Load(context.Background(), k)
+			assert.Nil(t, err)
+			assert.Equal(t, "value-"+k, value)
+		}(k)
+	}
+	wg.Wait()
+
+	if assert.Len(t, calls, 1) {
+		assert.ElementsMatch(t, []string{"a", "b", "c"}, calls[0])
+	}
+}
+
+func TestLoader_CachesResults(t *testing.T) {
+	count := 0
+	l := newLoader(func(ctx context.Context, keys []string) ([]interface{}, []error) {
+		count++
+		return make([]interface{}, len(keys)), make([]error, len(keys))
+	})
+
+	l.Load(context.Background(), "a")
+	l.Load(context.Background(), "a")
+	assert.Equal(t, 1, count)
+}
+
+func TestLoader_MaxBatch(t *testing.T) {
+	var mu sync.Mutex
+	sizes := []int{}
+	l := newLoader(func(ctx context.Context, keys []string) ([]interface{}, []error) {
+		mu.Lock()
+		sizes = append(sizes, len(keys))
+		mu.Unlock()
+		return make([]interface{}, len(keys)), make([]error, len(keys))
+	})
+	l.maxBatch = 2
+
+	var wg sync.WaitGroup
+	for _, k := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func(k string) {
+			defer wg.Done()
+			l.Load(context.Background(), k)
+		}(k)
+	}
+	wg.Wait()
+
+	assert.ElementsMatch(t, []int{2, 1}, sizes)
+}
+
+func TestLoader_Error(t *testing.T) {
+	expected := errors.New("backend down")
+	l := newLoader(func(ctx context.Context, keys []string) ([]interface{}, []error) {
+		errs := make([]error, len(keys))
+		for i := range errs {
+			errs[i] = expected
+		}
+		return make([]interface{}, len(keys)), errs
+	})
+
+	value, err := l.Load(context.Background(), "a")
+	assert.Nil(t, value)
+	assert.Equal(t, expected, err)
+}
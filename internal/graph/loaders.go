@@ -0,0 +1,125 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"hex-example/internal/ticket"
+	"hex-example/internal/user"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	loadersKey key = iota
+)
+
+// loaders holds the per-request loaders used by the resolvers.
+type loaders struct {
+	accounts          *loader
+	tickets           *loader
+	ticketsByCreator  *loader
+	ticketsByAssigned *loader
+}
+
+func newLoaders(tickets ticket.TicketService, users user.UserService) *loaders {
+	return &loaders{
+		accounts:          newLoader(accountBatch(users)),
+		tickets:           newLoader(ticketIDBatch(tickets)),
+		ticketsByCreator:  newLoader(ticketBatch(tickets, func(t *ticket.Ticket) string { return t.Creator })),
+		ticketsByAssigned: newLoader(ticketBatch(tickets, func(t *ticket.Ticket) string { return t.Assigned })),
+	}
+}
+
+// withLoaders returns a copy of ctx that stores the request loaders.
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey, l)
+}
+
+// loadersFromContext returns the request loaders from the ctx.
+func loadersFromContext(ctx context.Context) (*loaders, error) {
+	l, ok := ctx.Value(loadersKey).(*loaders)
+	if !ok {
+		return nil, fmt.Errorf("graph: Context missing loaders")
+	}
+	return l, nil
+}
+
+// accountBatch loads a batch of accounts by username with a single
+// UserService call.
+func accountBatch(users user.UserService) batchFunc {
+	return func(ctx context.Context, usernames []string) ([]interface{}, []error) {
+		values := make([]interface{}, len(usernames))
+		errs := make([]error, len(usernames))
+
+		accounts, err := users.FindAccounts(usernames)
+		if err != nil {
+			for i := range errs {
+				errs[i] = err
+			}
+			return values, errs
+		}
+
+		for i := range usernames {
+			if i < len(accounts) && accounts[i] != nil {
+				values[i] = accounts[i]
+			}
+		}
+		return values, errs
+	}
+}
+
+// ticketIDBatch loads a batch of tickets by id with a single TicketService
+// call.
+func ticketIDBatch(tickets ticket.TicketService) batchFunc {
+	return func(ctx context.Context, ids []string) ([]interface{}, []error) {
+		values := make([]interface{}, len(ids))
+		errs := make([]error, len(ids))
+
+		found, err := tickets.FindTicketsByIDs(ids)
+		if err != nil {
+			for i := range errs {
+				errs[i] = err
+			}
+			return values, errs
+		}
+
+		byID := make(map[string]*ticket.Ticket, len(found))
+		for _, t := range found {
+			byID[t.ID] = t
+		}
+		for i, id := range ids {
+			if t, ok := byID[id]; ok {
+				values[i] = t
+			}
+		}
+		return values, errs
+	}
+}
+
+// ticketBatch loads the tickets belonging to a batch of usernames with a
+// single TicketService call, grouping them with by.
+func ticketBatch(tickets ticket.TicketService, by func(*ticket.Ticket) string) batchFunc {
+	return func(ctx context.Context, usernames []string) ([]interface{}, []error) {
+		values := make([]interface{}, len(usernames))
+		errs := make([]error, len(usernames))
+
+		all, err := tickets.FindUserTickets(usernames)
+		if err != nil {
+			for i := range errs {
+				errs[i] = err
+			}
+			return values, errs
+		}
+
+		grouped := make(map[string][]*ticket.Ticket)
+		for _, t := range all {
+			grouped[by(t)] = append(grouped[by(t)], t)
+		}
+		for i, username := range usernames {
+			values[i] = grouped[username]
+		}
+		return values, errs
+	}
+}
@@ -0,0 +1,208 @@
+package graph
+
+import (
+	"context"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/middleware"
+	"hex-example/internal/rbac"
+	"hex-example/internal/ticket"
+	"hex-example/internal/user"
+)
+
+// Resolver is the root resolver for queries and mutations.
+type Resolver struct {
+	tickets ticket.TicketService
+	users   user.UserService
+}
+
+func NewResolver(tickets ticket.TicketService, users user.UserService) *Resolver {
+	return &Resolver{
+		tickets,
+		users,
+	}
+}
+
+// Tickets lists the tickets of project, which project roles grant access
+// to, or every ticket, which only global roles do.
+func (r *Resolver) Tickets(ctx context.Context, args struct{ Project *string }) ([]*ticketResolver, error) {
+	project := ""
+	if args.Project != nil {
+		project = *args.Project
+	}
+	if err := middleware.Authorize(ctx, rbac.TicketsRead, project); err != nil {
+		return nil, err
+	}
+	var tickets []*ticket.Ticket
+	var err error
+	if project != "" {
+		tickets, err = r.tickets.FindProjectTickets(project)
+	} else {
+		tickets, err = r.tickets.FindAllTickets()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ticketResolvers(tickets), nil
+}
+
+// Ticket returns the ticket with id if the caller may read tickets in its
+// project. Missing tickets need the global permission, and the error does
+// not name the project, so that a project role cannot tell them from
+// tickets of other projects.
+func (r *Resolver) Ticket(ctx context.Context, args struct{ ID graphql.ID }) (*ticketResolver, error) {
+	l, err := loadersFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	value, err := l.tickets.Load(ctx, string(args.ID))
+	if err != nil {
+		return nil, err
+	}
+	t, ok := value.(*ticket.Ticket)
+	project := ""
+	if ok {
+		project = t.Project
+	}
+	if err := middleware.Authorize(ctx, rbac.TicketsRead, project); err != nil {
+		return nil, &rbac.ForbiddenError{Permission: rbac.TicketsRead}
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &ticketResolver{t}, nil
+}
+
+func (r *Resolver) Account(ctx context.Context, args struct{ Username string }) (*accountResolver, error) {
+	if err := middleware.Authorize(ctx, rbac.TicketsRead, ""); err != nil {
+		return nil, err
+	}
+	return loadAccount(ctx, args.Username)
+}
+
+type ticketInput struct {
+	Project     *string
+	Assigned    *string
+	Title       string
+	Description *string
+	Points      *int32
+}
+
+// CreateTicket creates a ticket in the input project, recorded as created
+// by the caller.
+func (r *Resolver) CreateTicket(ctx context.Context, args struct{ Input ticketInput }) (*ticketResolver, error) {
+	t := &ticket.Ticket{Title: args.Input.Title}
+	if args.Input.Project != nil {
+		t.Project = *args.Input.Project
+	}
+	if err := middleware.Authorize(ctx, rbac.TicketsCreate, t.Project); err != nil {
+		return nil, err
+	}
+	creator, err := r.creator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t.Creator = creator
+	if args.Input.Assigned != nil {
+		t.Assigned = *args.Input.Assigned
+	}
+	if args.Input.Description != nil {
+		t.Description = *args.Input.Description
+	}
+	if args.Input.Points != nil {
+		t.Points = int(*args.Input.Points)
+	}
+
+	if err := r.tickets.CreateTicket(t); err != nil {
+		logrus.WithField("error", err).Error("Unable to create ticket")
+		return nil, err
+	}
+	return &ticketResolver{t}, nil
+}
+
+// creator returns the name tickets created by the caller are recorded
+// under: the username of an account, or the ID of any other principal.
+func (r *Resolver) creator(ctx context.Context) (string, error) {
+	claims, _ := middleware.ClaimsFromContext(ctx)
+	if claims.Type != middleware.PrincipalUser {
+		return claims.Subject, nil
+	}
+	account, err := r.users.GetAccount(claims.Subject)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"id": claims.Subject, "error": err}).Error("Unable to fetch creator")
+		return "", err
+	}
+	return account.Username, nil
+}
+
+type accountInput struct {
+	Username  string
+	FirstName *string
+	LastName  *string
+	Email     *string
+	Password  string
+}
+
+func (r *Resolver) CreateAccount(ctx context.Context, args struct{ Input accountInput }) (*accountResolver, error) {
+	if err := middleware.Authorize(ctx, rbac.AccountsManage, ""); err != nil {
+		return nil, err
+	}
+	account := &user.Account{
+		Username: args.Input.Username,
+		Password: args.Input.Password,
+	}
+	if args.Input.FirstName != nil {
+		account.FirstName = *args.Input.FirstName
+	}
+	if args.Input.LastName != nil {
+		account.LastName = *args.Input.LastName
+	}
+	if args.Input.Email != nil {
+		account.Email = *args.Input.Email
+	}
+
+	if err := r.users.CreateAccount(account); err != nil {
+		logrus.WithField("error", err).Error("Unable to create account")
+		return nil, err
+	}
+	return &accountResolver{account}, nil
+}
+
+// loadAccount resolves the account for username through the request's
+// account loader. Returns nil if there is no such account.
+func loadAccount(ctx context.Context, username string) (*accountResolver, error) {
+	if username == "" {
+		return nil, nil
+	}
+	l, err := loadersFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	value, err := l.accounts.Load(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	account, ok := value.(*user.Account)
+	if !ok {
+		return nil, nil
+	}
+	return &accountResolver{account}, nil
+}
+
+// loadTickets resolves the tickets for username through the given loader,
+// leaving out those in projects the caller may not read.
+func loadTickets(ctx context.Context, l *loader, username string) ([]*ticketResolver, error) {
+	value, err := l.Load(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	tickets, _ := value.([]*ticket.Ticket)
+	visible := make([]*ticket.Ticket, 0, len(tickets))
+	for _, t := range tickets {
+		if middleware.Authorize(ctx, rbac.TicketsRead, t.Project) == nil {
+			visible = append(visible, t)
+		}
+	}
+	return ticketResolvers(visible), nil
+}
@@ -0,0 +1,62 @@
+package graph
+
+// schema is the GraphQL schema served at /graphql.
+const schema = `
+schema {
+	query: Query
+	mutation: Mutation
+}
+
+scalar Time
+
+type Query {
+	tickets(project: String): [Ticket!]!
+	ticket(id: ID!): Ticket
+	account(username: String!): Account
+}
+
+type Mutation {
+	createTicket(input: TicketInput!): Ticket!
+	createAccount(input: AccountInput!): Account!
+}
+
+type Ticket {
+	id: ID!
+	project: String!
+	title: String!
+	description: String!
+	status: String!
+	points: Int!
+	created: Time!
+	updated: Time!
+	creator: String!
+	assigned: String!
+	creatorAccount: Account
+	assignedAccount: Account
+}
+
+type Account {
+	id: ID!
+	username: String!
+	firstName: String!
+	lastName: String!
+	createdTickets: [Ticket!]!
+	assignedTickets: [Ticket!]!
+}
+
+input TicketInput {
+	project: String
+	assigned: String
+	title: String!
+	description: String
+	points: Int
+}
+
+input AccountInput {
+	username: String!
+	firstName: String
+	lastName: String
+	email: String
+	password: String!
+}
+`
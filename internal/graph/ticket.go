@@ -0,0 +1,68 @@
+package graph
+
+import (
+	"context"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"hex-example/internal/ticket"
+)
+
+type ticketResolver struct {
+	t *ticket.Ticket
+}
+
+func ticketResolvers(tickets []*ticket.Ticket) []*ticketResolver {
+	resolvers := make([]*ticketResolver, len(tickets))
+	for i, t := range tickets {
+		resolvers[i] = &ticketResolver{t}
+	}
+	return resolvers
+}
+
+func (r *ticketResolver) ID() graphql.ID {
+	return graphql.ID(r.t.ID)
+}
+
+func (r *ticketResolver) Project() string {
+	return r.t.Project
+}
+
+func (r *ticketResolver) Title() string {
+	return r.t.Title
+}
+
+func (r *ticketResolver) Description() string {
+	return r.t.Description
+}
+
+func (r *ticketResolver) Status() string {
+	return r.t.Status
+}
+
+func (r *ticketResolver) Points() int32 {
+	return int32(r.t.Points)
+}
+
+func (r *ticketResolver) Created() graphql.Time {
+	return graphql.Time{Time: r.t.Created}
+}
+
+func (r *ticketResolver) Updated() graphql.Time {
+	return graphql.Time{Time: r.t.Updated}
+}
+
+func (r *ticketResolver) Creator() string {
+	return r.t.Creator
+}
+
+func (r *ticketResolver) Assigned() string {
+	return r.t.Assigned
+}
+
+func (r *ticketResolver) CreatorAccount(ctx context.Context) (*accountResolver, error) {
+	return loadAccount(ctx, r.t.Creator)
+}
+
+func (r *ticketResolver) AssignedAccount(ctx context.Context) (*accountResolver, error) {
+	return loadAccount(ctx, r.t.Assigned)
+}
@@ -0,0 +1,28 @@
+package jwks
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/problem"
+)
+
+// Path is where issuers publish their key set.
+const Path = "/.well-known/jwks.json"
+
+// Handler serves the public keys of ring as a JWKS document.
+func Handler(ring *KeyRing) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		set, err := ring.Set()
+		if err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		if err := json.NewEncoder(w).Encode(set); err != nil {
+			logrus.WithField("error", err).Error("Error writing response")
+		}
+	})
+}
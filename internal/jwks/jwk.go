@@ -0,0 +1,107 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+)
+
+// JSONWebKey is the public RFC 7517 representation of an RSA or P-256 key.
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// Set is a JSON Web Key Set as served at /.well-known/jwks.json.
+type Set struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// NewJSONWebKey returns the JWK for the public half of k.
+func NewJSONWebKey(k *Key) (JSONWebKey, error) {
+	jwk := JSONWebKey{KeyID: k.ID, Use: "sig", Algorithm: k.Algorithm}
+	switch public := k.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = b64.EncodeToString(public.N.Bytes())
+		jwk.E = b64.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case *ecdsa.PublicKey:
+		if public.Curve != elliptic.P256() {
+			return jwk, fmt.Errorf("jwks: unsupported curve %s", public.Curve.Params().Name)
+		}
+		jwk.KeyType = "EC"
+		jwk.Curve = "P-256"
+		jwk.X = b64.EncodeToString(padded(public.X.Bytes(), 32))
+		jwk.Y = b64.EncodeToString(padded(public.Y.Bytes(), 32))
+	default:
+		return jwk, fmt.Errorf("jwks: unsupported key type %T", public)
+	}
+	return jwk, nil
+}
+
+// PublicKey decodes the public key described by the JWK.
+func (jwk JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := b64.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		if jwk.Curve != "P-256" {
+			return nil, fmt.Errorf("jwks: unsupported curve %s", jwk.Curve)
+		}
+		x, err := b64.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		public := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !public.Curve.IsOnCurve(public.X, public.Y) {
+			return nil, fmt.Errorf("jwks: key %s is not on its curve", jwk.KeyID)
+		}
+		return public, nil
+	}
+	return nil, fmt.Errorf("jwks: unsupported key type %q", jwk.KeyType)
+}
+
+// padded left-pads b with zeroes to n bytes, as RFC 7518 requires for EC
+// coordinates.
+func padded(b []byte, n int) []byte {
+	if len(b) >= n {
+		return b
+	}
+	p := make([]byte, n)
+	copy(p[n-len(b):], b)
+	return p
+}
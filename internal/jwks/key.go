@@ -0,0 +1,110 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+// Supported signing algorithms.
+const (
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+const rsaKeyBits = 2048
+
+// Key is a private signing key identified by the kid header of the tokens
+// it signs.
+type Key struct {
+	ID        string
+	Algorithm string
+	Created   time.Time
+	Private   crypto.Signer
+}
+
+// GenerateKey returns a new key for alg.
+func GenerateKey(alg string) (*Key, error) {
+	var private crypto.Signer
+	var err error
+	switch alg {
+	case RS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case ES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("jwks: unsupported algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Key{
+		ID:        uuid.New().String(),
+		Algorithm: alg,
+		Created:   time.Now(),
+		Private:   private,
+	}, nil
+}
+
+// Public returns the public half of the key.
+func (k *Key) Public() crypto.PublicKey {
+	return k.Private.Public()
+}
+
+// SigningMethod returns the jwt signing method for the key's algorithm.
+func (k *Key) SigningMethod() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// storedKey is the serialised form of a Key used by KeyStore implementations.
+type storedKey struct {
+	ID        string    `json:"id"`
+	Algorithm string    `json:"alg"`
+	Created   time.Time `json:"created"`
+	PKCS8     []byte    `json:"pkcs8"`
+}
+
+// MarshalKey serialises k, including its private key, for storage.
+func MarshalKey(k *Key) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&storedKey{
+		ID:        k.ID,
+		Algorithm: k.Algorithm,
+		Created:   k.Created,
+		PKCS8:     der,
+	})
+}
+
+// UnmarshalKey parses a key serialised by MarshalKey.
+func UnmarshalKey(b []byte) (*Key, error) {
+	stored := new(storedKey)
+	if err := json.Unmarshal(b, stored); err != nil {
+		return nil, err
+	}
+	private, err := x509.ParsePKCS8PrivateKey(stored.PKCS8)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("jwks: key %s is not a signing key", stored.ID)
+	}
+	return &Key{
+		ID:        stored.ID,
+		Algorithm: stored.Algorithm,
+		Created:   stored.Created,
+		Private:   signer,
+	}, nil
+}
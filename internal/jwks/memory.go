@@ -0,0 +1,39 @@
+package jwks
+
+import "sync"
+
+type memoryStore struct {
+	mu   sync.Mutex
+	keys map[string]*Key
+}
+
+// NewMemoryStore returns a KeyStore that keeps keys in process. Keys do not
+// survive a restart and are not shared between instances, so it is only
+// suitable for tests and single-instance development.
+func NewMemoryStore() KeyStore {
+	return &memoryStore{keys: make(map[string]*Key)}
+}
+
+func (s *memoryStore) SaveKey(key *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.ID] = key
+	return nil
+}
+
+func (s *memoryStore) ListKeys() ([]*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]*Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func (s *memoryStore) DeleteKey(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, id)
+	return nil
+}
@@ -0,0 +1,115 @@
+package jwks
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// minRefetch bounds how often an unknown kid can trigger a fetch, so a flood
+// of forged tokens cannot be turned into a flood of requests to the issuer.
+const minRefetch = 10 * time.Second
+
+// RemoteKeySet is a KeySet backed by a JWKS document served over HTTP. Keys
+// are cached for ttl and refetched early when a token names an unknown kid,
+// which is how a rotation on the issuer is picked up.
+type RemoteKeySet struct {
+	url    string
+	ttl    time.Duration
+	client *http.Client
+	// fetches shares one fetch between the lookups that need it
+	fetches singleflight.Group
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// NewRemoteKeySet returns a KeySet for the JWKS document at url.
+func NewRemoteKeySet(url string, ttl time.Duration) *RemoteKeySet {
+	return &RemoteKeySet{
+		url:    url,
+		ttl:    ttl,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Key implements KeySet. The document is fetched without holding the lock,
+// so lookups of cached keys never wait for the issuer.
+func (s *RemoteKeySet) Key(kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	key, ok := s.keys[kid]
+	age := time.Since(s.fetched)
+	cached := s.keys != nil
+	s.mu.Unlock()
+
+	if ok && age < s.ttl {
+		return key, nil
+	}
+	if !ok && cached && age < minRefetch {
+		return nil, ErrKeyNotFound
+	}
+	if _, err, _ := s.fetches.Do(s.url, func() (interface{}, error) { return nil, s.refresh() }); err != nil {
+		if ok {
+			// Keep verifying with a known key while the issuer is unreachable.
+			logrus.WithError(err).Warn("Refreshing JWKS")
+			return key, nil
+		}
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok = s.keys[kid]; !ok {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// refresh replaces the cached keys with those of a new fetch.
+func (s *RemoteKeySet) refresh() error {
+	s.mu.Lock()
+	s.fetched = time.Now()
+	s.mu.Unlock()
+
+	keys, err := s.fetch()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *RemoteKeySet) fetch() (map[string]crypto.PublicKey, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: fetching %s: %s", s.url, resp.Status)
+	}
+
+	set := new(Set)
+	if err := json.NewDecoder(resp.Body).Decode(set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		key, err := jwk.PublicKey()
+		if err != nil {
+			logrus.WithError(err).WithField("kid", jwk.KeyID).Warn("Skipping JWKS key")
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+	return keys, nil
+}
@@ -0,0 +1,118 @@
+package jwks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONWebKey_RoundTrip(t *testing.T) {
+	for _, alg := range []string{RS256, ES256} {
+		key, err := GenerateKey(alg)
+		require.NoError(t, err)
+
+		jwk, err := NewJSONWebKey(key)
+		require.NoError(t, err)
+		public, err := jwk.PublicKey()
+		require.NoError(t, err)
+
+		assert.Equal(t, key.Public(), public)
+		assert.Equal(t, key.ID, jwk.KeyID)
+	}
+}
+
+func newJWKSTestServer(ring *KeyRing) (*httptest.Server, *int32) {
+	var fetches int32
+	handler := Handler(ring)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		handler.ServeHTTP(w, r)
+	}))
+	return server, &fetches
+}
+
+func TestRemoteKeySet_VerifiesIssuerTokens(t *testing.T) {
+	ring, err := NewKeyRing(NewMemoryStore(), ES256, time.Hour, time.Hour, 0)
+	require.NoError(t, err)
+	server, fetches := newJWKSTestServer(ring)
+	defer server.Close()
+	keys := NewRemoteKeySet(server.URL+Path, time.Hour)
+
+	signed, _ := ring.Sign(jwt.MapClaims{"sub": "test"})
+	_, err = parse(t, keys, signed)
+	require.NoError(t, err)
+	_, err = parse(t, keys, signed)
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(fetches), "keys are cached")
+}
+
+func TestRemoteKeySet_UnknownKidRefetchIsRateLimited(t *testing.T) {
+	ring, err := NewKeyRing(NewMemoryStore(), ES256, time.Hour, time.Hour, 0)
+	require.NoError(t, err)
+	server, fetches := newJWKSTestServer(ring)
+	defer server.Close()
+	keys := NewRemoteKeySet(server.URL+Path, time.Hour)
+
+	_, err = keys.Key("unknown")
+	assert.Equal(t, ErrKeyNotFound, err)
+	_, err = keys.Key("unknown")
+	assert.Equal(t, ErrKeyNotFound, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(fetches))
+
+	// a rotation is picked up once the refetch window has passed
+	require.NoError(t, ring.Rotate())
+	keys.fetched = keys.fetched.Add(-minRefetch)
+	signed, _ := ring.Sign(jwt.MapClaims{"sub": "test"})
+	_, err = parse(t, keys, signed)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(fetches))
+}
+
+func TestRemoteKeySet_FetchDoesNotBlockCachedKeys(t *testing.T) {
+	ring, err := NewKeyRing(NewMemoryStore(), ES256, time.Hour, time.Hour, 0)
+	require.NoError(t, err)
+	var fetches int32
+	release := make(chan struct{})
+	handler := Handler(ring)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			<-release
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	defer close(release)
+	keys := NewRemoteKeySet(server.URL+Path, time.Hour)
+	kid := ring.current().ID
+	_, err = keys.Key(kid)
+	require.NoError(t, err)
+
+	// lookups of an unknown kid wait for a single slow fetch
+	keys.fetched = keys.fetched.Add(-minRefetch)
+	done := make(chan error, 2)
+	for i := 0; i < cap(done); i++ {
+		go func() {
+			_, err := keys.Key("unknown")
+			done <- err
+		}()
+	}
+	for atomic.LoadInt32(&fetches) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err = keys.Key(kid)
+	assert.NoError(t, err, "cached keys are served during the fetch")
+
+	release <- struct{}{}
+	for i := 0; i < cap(done); i++ {
+		assert.Equal(t, ErrKeyNotFound, <-done)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches), "concurrent lookups share the fetch")
+}
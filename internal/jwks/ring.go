@@ -0,0 +1,185 @@
+package jwks
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrKeyNotFound is returned when no key matches a token's kid header.
+var ErrKeyNotFound = errors.New("jwks: key not found")
+
+// KeySet resolves the public key that verifies tokens with a given kid.
+type KeySet interface {
+	Key(kid string) (crypto.PublicKey, error)
+}
+
+// KeyStore persists signing keys so that every instance of a service signs
+// with, and publishes, the same keys.
+type KeyStore interface {
+	SaveKey(key *Key) error
+	ListKeys() ([]*Key, error)
+	DeleteKey(id string) error
+}
+
+// KeyRing signs tokens with the newest published key in its store, rotating
+// to a fresh key every interval and retiring old keys once no token they
+// signed can still be valid. A new key is published, in Set and through Key,
+// as soon as it is generated, but only signs once it is older than the
+// publish delay, so that every ring sharing the store has loaded it first.
+type KeyRing struct {
+	store     KeyStore
+	alg       string
+	interval  time.Duration
+	retention time.Duration
+	publish   time.Duration
+
+	mu   sync.RWMutex
+	keys []*Key // newest first
+}
+
+// NewKeyRing loads the keys in store, generating a first alg key if the
+// newest one is older than interval. Keys start signing publish after they
+// were generated, which must exceed the refresh interval of every ring on
+// store, and are kept for verification for interval plus publish plus
+// retention, which must cover the lifetime of issued tokens.
+func NewKeyRing(store KeyStore, alg string, interval, retention, publish time.Duration) (*KeyRing, error) {
+	if jwt.GetSigningMethod(alg) == nil || (alg != RS256 && alg != ES256) {
+		return nil, fmt.Errorf("jwks: unsupported algorithm %q", alg)
+	}
+	r := &KeyRing{store: store, alg: alg, interval: interval, retention: retention, publish: publish}
+	if err := r.Refresh(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Refresh reloads the keys from the store, rotates if the signing key is
+// due and deletes keys past their retention.
+func (r *KeyRing) Refresh() error {
+	keys, err := r.store.ListKeys()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	live := keys[:0]
+	for _, k := range keys {
+		if now.Sub(k.Created) > r.interval+r.publish+r.retention {
+			if err := r.store.DeleteKey(k.ID); err != nil {
+				return err
+			}
+			logrus.WithField("kid", k.ID).Info("Retired signing key")
+			continue
+		}
+		live = append(live, k)
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].Created.After(live[j].Created) })
+	r.set(live)
+
+	if len(live) == 0 || now.Sub(live[0].Created) >= r.interval {
+		return r.Rotate()
+	}
+	return nil
+}
+
+// Rotate generates a new signing key, which signs once the publish delay
+// has passed. Tokens signed by earlier keys remain verifiable until those
+// keys are retired.
+func (r *KeyRing) Rotate() error {
+	key, err := GenerateKey(r.alg)
+	if err != nil {
+		return err
+	}
+	if err := r.store.SaveKey(key); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.keys = append([]*Key{key}, r.keys...)
+	r.mu.Unlock()
+	logrus.WithField("kid", key.ID).Info("Rotated signing key")
+	return nil
+}
+
+// Run refreshes the ring every tick until stop is closed, picking up keys
+// rotated by other instances and rotating on schedule.
+func (r *KeyRing) Run(tick time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Refresh(); err != nil {
+				logrus.WithError(err).Error("Refreshing signing keys")
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Sign returns claims signed by the current key with its kid header set.
+func (r *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	key := r.current()
+	if key == nil {
+		return "", ErrKeyNotFound
+	}
+	token := jwt.NewWithClaims(key.SigningMethod(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// Key implements KeySet.
+func (r *KeyRing) Key(kid string) (crypto.PublicKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, k := range r.keys {
+		if k.ID == kid {
+			return k.Public(), nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+// Set returns the public keys of the ring.
+func (r *KeyRing) Set() (*Set, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	set := &Set{Keys: make([]JSONWebKey, 0, len(r.keys))}
+	for _, k := range r.keys {
+		jwk, err := NewJSONWebKey(k)
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
+
+// current returns the newest published key, or the oldest key while none
+// is published yet, as when the first key of a store was just generated.
+func (r *KeyRing) current() *Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.keys) == 0 {
+		return nil
+	}
+	published := time.Now().Add(-r.publish)
+	for _, k := range r.keys {
+		if !k.Created.After(published) {
+			return k
+		}
+	}
+	return r.keys[len(r.keys)-1]
+}
+
+func (r *KeyRing) set(keys []*Key) {
+	r.mu.Lock()
+	r.keys = keys
+	r.mu.Unlock()
+}
@@ -0,0 +1,129 @@
+package jwks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, keys KeySet, signed string) (*jwt.Token, error) {
+	t.Helper()
+	return jwt.Parse(signed, func(token *jwt.Token) (interface{}, error) {
+		return keys.Key(token.Header["kid"].(string))
+	})
+}
+
+func TestKeyRing_SignAndVerify(t *testing.T) {
+	for _, alg := range []string{RS256, ES256} {
+		t.Run(alg, func(t *testing.T) {
+			ring, err := NewKeyRing(NewMemoryStore(), alg, time.Hour, time.Hour, 0)
+			require.NoError(t, err)
+
+			signed, err := ring.Sign(jwt.MapClaims{"sub": "test"})
+			require.NoError(t, err)
+
+			token, err := parse(t, ring, signed)
+			require.NoError(t, err)
+			assert.Equal(t, alg, token.Method.Alg())
+			assert.Equal(t, ring.current().ID, token.Header["kid"])
+		})
+	}
+}
+
+func TestKeyRing_UnsupportedAlgorithm(t *testing.T) {
+	_, err := NewKeyRing(NewMemoryStore(), "HS256", time.Hour, time.Hour, 0)
+	assert.Error(t, err)
+}
+
+func TestKeyRing_RotationKeepsOldKeysVerifiable(t *testing.T) {
+	ring, err := NewKeyRing(NewMemoryStore(), ES256, time.Hour, time.Hour, 0)
+	require.NoError(t, err)
+	before, _ := ring.Sign(jwt.MapClaims{"sub": "test"})
+
+	require.NoError(t, ring.Rotate())
+	after, _ := ring.Sign(jwt.MapClaims{"sub": "test"})
+
+	old, err := parse(t, ring, before)
+	require.NoError(t, err)
+	current, err := parse(t, ring, after)
+	require.NoError(t, err)
+	assert.NotEqual(t, old.Header["kid"], current.Header["kid"])
+
+	set, err := ring.Set()
+	require.NoError(t, err)
+	assert.Len(t, set.Keys, 2)
+}
+
+func TestKeyRing_NewKeysArePublishedBeforeSigning(t *testing.T) {
+	store := NewMemoryStore()
+	signer, err := NewKeyRing(store, ES256, time.Hour, time.Hour, time.Minute)
+	require.NoError(t, err)
+	other, err := NewKeyRing(store, ES256, time.Hour, time.Hour, time.Minute)
+	require.NoError(t, err)
+	first := signer.current()
+
+	require.NoError(t, signer.Rotate())
+	rotated := signer.keys[0]
+	signed, _ := signer.Sign(jwt.MapClaims{"sub": "test"})
+	token, err := parse(t, other, signed)
+	require.NoError(t, err, "tokens verify on rings that have not refreshed")
+	assert.Equal(t, first.ID, token.Header["kid"], "the first key signs until the new one is published")
+	set, _ := signer.Set()
+	assert.Len(t, set.Keys, 2, "the new key is published straight away")
+
+	rotated.Created = rotated.Created.Add(-time.Minute)
+	assert.Equal(t, rotated.ID, signer.current().ID, "the new key signs after the publish delay")
+}
+
+func TestKeyRing_RefreshRotatesAndRetires(t *testing.T) {
+	store := NewMemoryStore()
+	stale, _ := GenerateKey(ES256)
+	stale.Created = time.Now().Add(-3 * time.Hour)
+	due, _ := GenerateKey(ES256)
+	due.Created = time.Now().Add(-90 * time.Minute)
+	store.SaveKey(stale)
+	store.SaveKey(due)
+
+	ring, err := NewKeyRing(store, ES256, time.Hour, time.Hour, 0)
+	require.NoError(t, err)
+
+	_, err = ring.Key(stale.ID)
+	assert.Equal(t, ErrKeyNotFound, err, "keys past retention are retired")
+	_, err = ring.Key(due.ID)
+	assert.NoError(t, err, "rotated keys still verify")
+	assert.NotEqual(t, due.ID, ring.current().ID, "a key older than the interval is rotated")
+
+	keys, _ := store.ListKeys()
+	assert.Len(t, keys, 2)
+}
+
+func TestKeyRing_SharesKeysThroughStore(t *testing.T) {
+	store := NewMemoryStore()
+	signer, err := NewKeyRing(store, RS256, time.Hour, time.Hour, 0)
+	require.NoError(t, err)
+	other, err := NewKeyRing(store, RS256, time.Hour, time.Hour, 0)
+	require.NoError(t, err)
+
+	signed, _ := signer.Sign(jwt.MapClaims{"sub": "test"})
+	_, err = parse(t, other, signed)
+	assert.NoError(t, err)
+}
+
+func TestMarshalKey(t *testing.T) {
+	for _, alg := range []string{RS256, ES256} {
+		key, err := GenerateKey(alg)
+		require.NoError(t, err)
+
+		b, err := MarshalKey(key)
+		require.NoError(t, err)
+		decoded, err := UnmarshalKey(b)
+		require.NoError(t, err)
+
+		assert.Equal(t, key.ID, decoded.ID)
+		assert.Equal(t, alg, decoded.Algorithm)
+		assert.Equal(t, key.Public(), decoded.Public())
+	}
+}
@@ -0,0 +1,4 @@
+// Package login provides OAuth2 and OpenID Connect login and callback
+// handlers for any Provider, such as Google, GitHub, GitLab or an OpenID
+// Connect issuer found through discovery.
+package login
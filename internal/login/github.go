@@ -0,0 +1,68 @@
+package login
+
+import (
+	"context"
+	"strconv"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+const githubAPI = "https://api.github.com/"
+
+type githubUser struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+type githubProvider struct {
+	config *oauth2.Config
+}
+
+// GitHub returns the Provider for github.com. The client configuration is
+// copied and its endpoint set; scopes default to read:user and user:email.
+func GitHub(config *oauth2.Config) Provider {
+	return &githubProvider{withEndpoint(config, github.Endpoint, "read:user", "user:email")}
+}
+
+func (p *githubProvider) Name() string { return "github" }
+
+func (p *githubProvider) OAuth2() *oauth2.Config { return p.config }
+
+// User returns the GitHub user with their primary email address. The
+// address is only marked verified when GitHub lists it as such, which takes
+// the user:email scope; without it the public profile address is used.
+func (p *githubProvider) User(ctx context.Context, token *oauth2.Token) (*User, error) {
+	profile := new(githubUser)
+	if err := get(ctx, p.config, token, githubAPI, "user", profile); err != nil {
+		return nil, err
+	}
+	user := &User{
+		Username: profile.Login,
+		Name:     profile.Name,
+		Email:    profile.Email,
+	}
+	if profile.ID != 0 {
+		user.ID = strconv.FormatInt(profile.ID, 10)
+	}
+
+	var emails []githubEmail
+	if err := get(ctx, p.config, token, githubAPI, "user/emails", &emails); err != nil {
+		return user, nil
+	}
+	for _, email := range emails {
+		if email.Primary {
+			user.Email = email.Email
+			user.EmailVerified = email.Verified
+		}
+	}
+	return user, nil
+}
@@ -0,0 +1,18 @@
+package login
+
+import "golang.org/x/oauth2"
+
+// googleMetadata is Google's discovery document, which is stable enough to
+// spare a request at startup.
+var googleMetadata = Metadata{
+	Issuer:                "https://accounts.google.com",
+	AuthorizationEndpoint: "https://accounts.google.com/o/oauth2/v2/auth",
+	TokenEndpoint:         "https://oauth2.googleapis.com/token",
+	UserInfoEndpoint:      "https://openidconnect.googleapis.com/v1/userinfo",
+	JWKSURI:               "https://www.googleapis.com/oauth2/v3/certs",
+}
+
+// Google returns the OpenID Connect Provider for Google accounts.
+func Google(config *oauth2.Config) Provider {
+	return OIDC("google", &googleMetadata, config)
+}
@@ -0,0 +1,125 @@
+package login
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/sling"
+	"golang.org/x/oauth2"
+)
+
+// Login errors
+var (
+	ErrUnableToGetUser = errors.New("login: unable to get provider User")
+)
+
+// User is an account holder at a provider.
+type User struct {
+	// Provider is the Name of the Provider the user logged in with.
+	Provider string `json:"provider"`
+	// ID is unique to the user at the provider and never reassigned.
+	ID       string `json:"id"`
+	Username string `json:"username,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Email    string `json:"email,omitempty"`
+	// EmailVerified is set when the provider vouches that the user controls
+	// Email.
+	EmailVerified bool `json:"emailVerified"`
+}
+
+// Provider is an OAuth2 authorization server that can tell whose access
+// token it issued.
+type Provider interface {
+	// Name identifies the provider, e.g. "github".
+	Name() string
+	// OAuth2 returns the client configuration for the provider endpoint.
+	OAuth2() *oauth2.Config
+	// User returns the user token was issued to. An http.Client set as
+	// oauth2.HTTPClient in ctx is used for requests to the provider.
+	User(ctx context.Context, token *oauth2.Token) (*User, error)
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles login requests by reading the state value from the
+// ctx and redirecting requests to the provider AuthURL with that state value.
+func LoginHandler(provider Provider, failure http.Handler) http.Handler {
+	return oauth2Login.LoginHandler(provider.OAuth2(), failure)
+}
+
+// CallbackHandler handles provider redirection URI requests and adds the
+// access token and provider User to the ctx. If authentication succeeds,
+// handling delegates to the success handler, otherwise to the failure
+// handler.
+func CallbackHandler(provider Provider, success, failure http.Handler) http.Handler {
+	success = userHandler(provider, success, failure)
+	return oauth2Login.CallbackHandler(provider.OAuth2(), success, failure)
+}
+
+// userHandler is a http.Handler that gets the OAuth2 Token from the ctx to
+// get the corresponding provider User. If successful, the user is added to
+// the ctx and the success handler is called. Otherwise, the failure handler
+// is called.
+func userHandler(provider Provider, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		user, err := provider.User(ctx, token)
+		if err == nil && (user == nil || user.ID == "") {
+			err = ErrUnableToGetUser
+		}
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		user.Provider = provider.Name()
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// withEndpoint returns a copy of config for endpoint, using scopes unless
+// config asks for its own.
+func withEndpoint(config *oauth2.Config, endpoint oauth2.Endpoint, scopes ...string) *oauth2.Config {
+	c := *config
+	c.Endpoint = endpoint
+	if len(c.Scopes) == 0 {
+		c.Scopes = scopes
+	}
+	return &c
+}
+
+// get decodes the JSON resource at path of the API at base into v,
+// authenticating with token.
+func get(ctx context.Context, config *oauth2.Config, token *oauth2.Token, base, path string, v interface{}) error {
+	resp, err := sling.New().Client(config.Client(ctx, token)).Base(base).
+		Set("Accept", "application/json").Get(path).ReceiveSuccess(v)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("login: unable to get %s: %s", path, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package login
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const (
+	githubUserJSON   = `{"id": 583231, "login": "octocat", "name": "The Octocat", "email": "octocat@github.com"}`
+	githubEmailsJSON = `[{"email": "octocat@example.com", "primary": false, "verified": true},
+		{"email": "octo@users.noreply.github.com", "primary": true, "verified": true}]`
+)
+
+// serveUser runs userHandler for provider with a token in the ctx and
+// returns the User passed to the success handler.
+func serveUser(t *testing.T, provider Provider, client *http.Client) *User {
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+	var user *User
+	success := func(w http.ResponseWriter, req *http.Request) {
+		var err error
+		user, err = UserFromContext(req.Context())
+		assert.Nil(t, err)
+	}
+	handler := userHandler(provider, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+	return user
+}
+
+func TestUserHandler_GitHub(t *testing.T) {
+	proxyClient, server := newProviderTestServer(map[string]string{
+		"/user":        githubUserJSON,
+		"/user/emails": githubEmailsJSON,
+	})
+	defer server.Close()
+
+	expectedUser := &User{
+		Provider:      "github",
+		ID:            "583231",
+		Username:      "octocat",
+		Name:          "The Octocat",
+		Email:         "octo@users.noreply.github.com",
+		EmailVerified: true,
+	}
+	assert.Equal(t, expectedUser, serveUser(t, GitHub(&oauth2.Config{}), proxyClient))
+}
+
+func TestUserHandler_GitHubWithoutEmailScope(t *testing.T) {
+	proxyClient, server := newProviderTestServer(map[string]string{"/user": githubUserJSON})
+	defer server.Close()
+
+	user := serveUser(t, GitHub(&oauth2.Config{}), proxyClient)
+	if assert.NotNil(t, user) {
+		assert.Equal(t, "octocat@github.com", user.Email)
+		assert.False(t, user.EmailVerified)
+	}
+}
+
+func TestUserHandler_GitLab(t *testing.T) {
+	cases := map[string]struct {
+		json     string
+		verified bool
+	}{
+		"confirmed":   {`{"id": 1, "username": "root", "name": "Administrator", "email": "admin@example.com", "confirmed_at": "2012-05-23T09:05:22Z"}`, true},
+		"unconfirmed": {`{"id": 1, "username": "root", "name": "Administrator", "email": "admin@example.com"}`, false},
+	}
+	for name, c := range cases {
+		proxyClient, server := newProviderTestServer(map[string]string{"/api/v4/user": c.json})
+		user := serveUser(t, GitLab(GitLabURL, &oauth2.Config{}), proxyClient)
+		server.Close()
+
+		if assert.NotNil(t, user, name) {
+			assert.Equal(t, &User{
+				Provider:      "gitlab",
+				ID:            "1",
+				Username:      "root",
+				Name:          "Administrator",
+				Email:         "admin@example.com",
+				EmailVerified: c.verified,
+			}, user, name)
+		}
+	}
+}
+
+func TestUserHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	handler := userHandler(GitHub(&oauth2.Config{}), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestUserHandler_ErrorGettingUser(t *testing.T) {
+	cases := map[string]Provider{
+		"github": GitHub(&oauth2.Config{}),
+		"gitlab": GitLab(GitLabURL, &oauth2.Config{}),
+	}
+	for name, provider := range cases {
+		proxyClient, server := testutils.NewErrorServer("Service Down", http.StatusInternalServerError)
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Error(t, gologin.ErrorFromContext(req.Context()), name)
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		handler := userHandler(provider, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		server.Close()
+		assert.Equal(t, "failure handler called", w.Body.String(), name)
+	}
+}
+
+func TestUserHandler_MissingID(t *testing.T) {
+	proxyClient, server := newProviderTestServer(map[string]string{"/user": `{}`})
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrUnableToGetUser, gologin.ErrorFromContext(req.Context()))
+	}
+
+	handler := userHandler(GitHub(&oauth2.Config{}), testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+}
+
+func TestCallbackHandler(t *testing.T) {
+	server := newOIDCTestServer(t)
+	defer server.Close()
+	server.claims["email"] = "jane@example.com"
+	server.claims["email_verified"] = true
+	provider := OIDC("example", server.metadata(), &oauth2.Config{ClientID: testClientID})
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		user, err := UserFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, &User{
+			Provider:      "example",
+			ID:            "248289761001",
+			Email:         "jane@example.com",
+			EmailVerified: true,
+		}, user)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// CallbackHandler asserts that:
+	// - the code is exchanged for a token with an ID token
+	// - the ID token is verified against the issuer keys
+	// - the User is added to the ctx of the success handler
+	handler := CallbackHandler(provider, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/callback?code=any-code&state=any-state", nil)
+	ctx := oauth2Login.WithState(req.Context(), "any-state")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestLoginHandler(t *testing.T) {
+	provider := Google(&oauth2.Config{ClientID: testClientID, RedirectURL: "http://localhost:8080/google/callback"})
+
+	handler := LoginHandler(provider, testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/google/login", nil)
+	handler.ServeHTTP(w, req.WithContext(oauth2Login.WithState(req.Context(), "any-state")))
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	location := w.Header().Get("Location")
+	assert.Contains(t, location, googleMetadata.AuthorizationEndpoint)
+	assert.Contains(t, location, "state=any-state")
+	assert.Contains(t, location, "scope=openid+email+profile")
+}
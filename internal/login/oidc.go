@@ -0,0 +1,213 @@
+package login
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"golang.org/x/oauth2"
+	"hex-example/internal/jwks"
+	"hex-example/internal/middleware"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	keySetTTL     = time.Hour
+	idTokenLeeway = 30 * time.Second
+)
+
+// OpenID Connect errors
+var (
+	ErrMissingIDToken = errors.New("login: token response missing id_token")
+	ErrInvalidIDToken = errors.New("login: invalid ID token")
+)
+
+// Metadata is the part of the OpenID Provider Metadata needed to log in.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint,omitempty"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// idTokenClaims are the ID token claims read to identify the user.
+type idTokenClaims struct {
+	Issuer            string              `json:"iss"`
+	Subject           string              `json:"sub"`
+	Audience          middleware.Audience `json:"aud"`
+	AuthorizedParty   string              `json:"azp,omitempty"`
+	ExpiresAt         int64               `json:"exp"`
+	IssuedAt          int64               `json:"iat"`
+	PreferredUsername string              `json:"preferred_username,omitempty"`
+	Name              string              `json:"name,omitempty"`
+	Email             string              `json:"email,omitempty"`
+	EmailVerified     bool                `json:"email_verified,omitempty"`
+}
+
+// Valid implements jwt.Claims. The claims are checked by verify instead,
+// against the issuer and client they were expected from.
+func (c *idTokenClaims) Valid() error { return nil }
+
+type oidcProvider struct {
+	name     string
+	config   *oauth2.Config
+	metadata Metadata
+	keys     jwks.KeySet
+	parser   *jwt.Parser
+}
+
+// OIDC returns the Provider for the OpenID Connect issuer described by
+// metadata. The client configuration is copied and its endpoint set; scopes
+// default to openid, email and profile, and openid is always requested.
+func OIDC(name string, metadata *Metadata, config *oauth2.Config) Provider {
+	endpoint := oauth2.Endpoint{
+		AuthURL:  metadata.AuthorizationEndpoint,
+		TokenURL: metadata.TokenEndpoint,
+	}
+	config = withEndpoint(config, endpoint, "openid", "email", "profile")
+	if !hasScope(config.Scopes, "openid") {
+		config.Scopes = append([]string{"openid"}, config.Scopes...)
+	}
+	return &oidcProvider{
+		name,
+		config,
+		*metadata,
+		jwks.NewRemoteKeySet(metadata.JWKSURI, keySetTTL),
+		&jwt.Parser{
+			ValidMethods:         []string{jwks.RS256, jwks.ES256},
+			SkipClaimsValidation: true,
+		},
+	}
+}
+
+// Discover fetches the metadata of issuer from its discovery document and
+// returns its Provider, as OIDC does.
+func Discover(ctx context.Context, name, issuer string, config *oauth2.Config) (Provider, error) {
+	client, ok := ctx.Value(oauth2.HTTPClient).(*http.Client)
+	if !ok {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(issuer, "/")+discoveryPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("login: discovering %s: %s", issuer, resp.Status)
+	}
+
+	metadata := new(Metadata)
+	if err := json.NewDecoder(resp.Body).Decode(metadata); err != nil {
+		return nil, err
+	}
+	// OpenID Connect Discovery 4.3: the issuer must be the one asked for
+	if metadata.Issuer != issuer {
+		return nil, fmt.Errorf("login: discovered issuer %q does not match %q", metadata.Issuer, issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("login: incomplete metadata for %s", issuer)
+	}
+	return OIDC(name, metadata, config), nil
+}
+
+func (p *oidcProvider) Name() string { return p.name }
+
+func (p *oidcProvider) OAuth2() *oauth2.Config { return p.config }
+
+// User returns the user named by the ID token issued along with token. When
+// the ID token does not carry an email address, it is looked up at the
+// userinfo endpoint.
+func (p *oidcProvider) User(ctx context.Context, token *oauth2.Token) (*User, error) {
+	raw, ok := token.Extra("id_token").(string)
+	if !ok || raw == "" {
+		return nil, ErrMissingIDToken
+	}
+	claims, err := p.verify(raw, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Email == "" && p.metadata.UserInfoEndpoint != "" {
+		info := new(idTokenClaims)
+		err := get(ctx, p.config, token, p.metadata.UserInfoEndpoint, "", info)
+		// OpenID Connect Core 5.3.2: userinfo for another subject is ignored
+		if err == nil && info.Subject == claims.Subject {
+			claims.Email = info.Email
+			claims.EmailVerified = info.EmailVerified
+			if claims.Name == "" {
+				claims.Name = info.Name
+			}
+		}
+	}
+	return &User{
+		ID:            claims.Subject,
+		Username:      claims.PreferredUsername,
+		Name:          claims.Name,
+		Email:         claims.Email,
+		EmailVerified: claims.Email != "" && claims.EmailVerified,
+	}, nil
+}
+
+// verify checks the signature of the ID token against the issuer keys and
+// that it was issued by the issuer to this client and is current at now.
+func (p *oidcProvider) verify(raw string, now time.Time) (*idTokenClaims, error) {
+	claims := new(idTokenClaims)
+	_, err := p.parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := p.keys.Key(kid)
+		if err != nil {
+			return nil, err
+		}
+
+		// The algorithm must match the key so that a public key can never be
+		// used as an HMAC secret.
+		switch token.Method.Alg() {
+		case jwks.RS256:
+			if _, ok := key.(*rsa.PublicKey); ok {
+				return key, nil
+			}
+		case jwks.ES256:
+			if _, ok := key.(*ecdsa.PublicKey); ok {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	})
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+
+	leeway := int64(idTokenLeeway / time.Second)
+	switch {
+	case claims.Subject == "" || claims.Issuer != p.metadata.Issuer:
+		return nil, ErrInvalidIDToken
+	case !claims.Audience.Contains(p.config.ClientID):
+		return nil, ErrInvalidIDToken
+	case claims.AuthorizedParty != "" && claims.AuthorizedParty != p.config.ClientID:
+		return nil, ErrInvalidIDToken
+	case now.Unix() > claims.ExpiresAt+leeway || now.Unix() < claims.IssuedAt-leeway:
+		return nil, ErrInvalidIDToken
+	}
+	return claims, nil
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
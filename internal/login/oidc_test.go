@@ -0,0 +1,111 @@
+package login
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestDiscover(t *testing.T) {
+	server := newOIDCTestServer(t)
+	defer server.Close()
+
+	provider, err := Discover(context.Background(), "example", server.URL, &oauth2.Config{ClientID: testClientID})
+	require.NoError(t, err)
+	assert.Equal(t, "example", provider.Name())
+	assert.Equal(t, server.URL+"/token", provider.OAuth2().Endpoint.TokenURL)
+	assert.Equal(t, []string{"openid", "email", "profile"}, provider.OAuth2().Scopes)
+}
+
+func TestDiscover_IssuerMismatch(t *testing.T) {
+	server := newOIDCTestServer(t)
+	defer server.Close()
+
+	_, err := Discover(context.Background(), "example", server.URL+"/other", &oauth2.Config{ClientID: testClientID})
+	assert.Error(t, err)
+}
+
+func TestOIDC_AlwaysRequestsOpenID(t *testing.T) {
+	provider := OIDC("example", &googleMetadata, &oauth2.Config{Scopes: []string{"email"}})
+	assert.Equal(t, []string{"openid", "email"}, provider.OAuth2().Scopes)
+}
+
+func TestOIDCUser(t *testing.T) {
+	now := time.Now().Unix()
+	cases := map[string]struct {
+		claims   map[string]interface{}
+		userinfo string
+		expected *User
+		err      error
+	}{
+		"valid": {
+			claims:   map[string]interface{}{"name": "Jane Doe", "preferred_username": "jane", "email": "jane@example.com", "email_verified": true},
+			expected: &User{ID: "248289761001", Username: "jane", Name: "Jane Doe", Email: "jane@example.com", EmailVerified: true},
+		},
+		"unverified email": {
+			claims:   map[string]interface{}{"email": "jane@example.com"},
+			expected: &User{ID: "248289761001", Email: "jane@example.com"},
+		},
+		"email from userinfo": {
+			userinfo: `{"sub": "248289761001", "name": "Jane Doe", "email": "jane@example.com", "email_verified": true}`,
+			expected: &User{ID: "248289761001", Name: "Jane Doe", Email: "jane@example.com", EmailVerified: true},
+		},
+		"userinfo for another subject": {
+			userinfo: `{"sub": "1", "email": "mallory@example.com", "email_verified": true}`,
+			expected: &User{ID: "248289761001"},
+		},
+		"multiple audiences": {
+			claims:   map[string]interface{}{"aud": []string{"other-client", testClientID}, "azp": testClientID},
+			expected: &User{ID: "248289761001"},
+		},
+		"other audience":         {claims: map[string]interface{}{"aud": "other-client"}, err: ErrInvalidIDToken},
+		"other authorized party": {claims: map[string]interface{}{"azp": "other-client"}, err: ErrInvalidIDToken},
+		"other issuer":           {claims: map[string]interface{}{"iss": "https://evil.example"}, err: ErrInvalidIDToken},
+		"expired":                {claims: map[string]interface{}{"exp": now - 60}, err: ErrInvalidIDToken},
+		"issued in the future":   {claims: map[string]interface{}{"iat": now + 60}, err: ErrInvalidIDToken},
+		"missing subject":        {claims: map[string]interface{}{"sub": ""}, err: ErrInvalidIDToken},
+	}
+	for name, c := range cases {
+		server := newOIDCTestServer(t)
+		for claim, value := range c.claims {
+			server.claims[claim] = value
+		}
+		server.userinfo = c.userinfo
+		provider := OIDC("example", server.metadata(), &oauth2.Config{ClientID: testClientID})
+
+		ctx := context.Background()
+		token, err := provider.OAuth2().Exchange(ctx, "any-code")
+		require.NoError(t, err, name)
+		user, err := provider.User(ctx, token)
+		server.Close()
+
+		assert.Equal(t, c.err, err, name)
+		assert.Equal(t, c.expected, user, name)
+	}
+}
+
+func TestOIDCUser_MissingIDToken(t *testing.T) {
+	provider := OIDC("example", &googleMetadata, &oauth2.Config{ClientID: testClientID})
+	_, err := provider.User(context.Background(), &oauth2.Token{AccessToken: "any-token"})
+	assert.Equal(t, ErrMissingIDToken, err)
+}
+
+func TestOIDCUser_ForgedSignature(t *testing.T) {
+	server := newOIDCTestServer(t)
+	defer server.Close()
+	forger := newOIDCTestServer(t)
+	defer forger.Close()
+	forger.claims["iss"] = server.URL
+	provider := OIDC("example", server.metadata(), &oauth2.Config{ClientID: testClientID})
+
+	// the forger signs with its own keys, unknown to the issuer
+	forged := OIDC("example", forger.metadata(), &oauth2.Config{ClientID: testClientID})
+	token, err := forged.OAuth2().Exchange(context.Background(), "any-code")
+	require.NoError(t, err)
+	_, err = provider.User(context.Background(), token)
+	assert.Equal(t, ErrInvalidIDToken, err)
+}
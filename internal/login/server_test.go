@@ -0,0 +1,98 @@
+package login
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin/testutils"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+	"hex-example/internal/jwks"
+)
+
+const testClientID = "test-client"
+
+// newProviderTestServer returns a new httptest.Server which mocks provider
+// API endpoints and a client which proxies requests to the server. Each
+// route responds with its json data, other paths with 404 Not Found. The
+// caller must close the server.
+func newProviderTestServer(routes map[string]string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	for path, jsonData := range routes {
+		jsonData := jsonData
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, jsonData)
+		})
+	}
+	return client, server
+}
+
+// oidcTestServer mocks an OpenID Connect issuer at its URL. The token
+// endpoint signs claims as the ID token of every response.
+type oidcTestServer struct {
+	*httptest.Server
+	claims   jwt.MapClaims
+	userinfo string
+}
+
+// newOIDCTestServer starts an issuer whose ID tokens identify subject
+// "248289761001" to testClientID until the test changes claims. The caller
+// must close the server.
+func newOIDCTestServer(t *testing.T) *oidcTestServer {
+	keys, err := jwks.NewKeyRing(jwks.NewMemoryStore(), jwks.ES256, time.Hour, time.Hour, 0)
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	server := &oidcTestServer{Server: httptest.NewServer(mux)}
+	now := time.Now().Unix()
+	server.claims = jwt.MapClaims{
+		"iss": server.URL,
+		"sub": "248289761001",
+		"aud": testClientID,
+		"iat": now,
+		"exp": now + 300,
+	}
+
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(server.metadata())
+	})
+	mux.Handle(jwks.Path, jwks.Handler(keys))
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		idToken, err := keys.Sign(server.claims)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "any-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if server.userinfo == "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, server.userinfo)
+	})
+	return server
+}
+
+func (s *oidcTestServer) metadata() *Metadata {
+	return &Metadata{
+		Issuer:                s.URL,
+		AuthorizationEndpoint: s.URL + "/authorize",
+		TokenEndpoint:         s.URL + "/token",
+		UserInfoEndpoint:      s.URL + "/userinfo",
+		JWKSURI:               s.URL + jwks.Path,
+	}
+}
@@ -0,0 +1,76 @@
+// Package mail delivers transactional email such as account verification
+// and password reset messages.
+package mail
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Message is a plain text email to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+type smtpMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer returns a Mailer that relays through the SMTP server at addr
+// (host:port), sending from the given address. auth may be nil for servers
+// that accept unauthenticated mail, such as a local relay; otherwise the
+// server must offer STARTTLS.
+func NewSMTPMailer(addr, from string, auth smtp.Auth) Mailer {
+	return &smtpMailer{addr, from, auth}
+}
+
+func (m *smtpMailer) Send(msg *Message) error {
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return fmt.Errorf("mail: header contains a line break")
+	}
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, m.format(msg)); err != nil {
+		logrus.WithFields(logrus.Fields{"to": msg.To, "error": err}).Error("Unable to send mail")
+		return err
+	}
+	return nil
+}
+
+func (m *smtpMailer) format(msg *Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+type logMailer struct{}
+
+// NewLogMailer returns a Mailer that logs messages instead of sending them,
+// for development without an SMTP server.
+func NewLogMailer() Mailer {
+	return logMailer{}
+}
+
+func (logMailer) Send(msg *Message) error {
+	logrus.WithFields(logrus.Fields{"to": msg.To, "subject": msg.Subject}).Info(msg.Body)
+	return nil
+}
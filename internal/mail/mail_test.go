@@ -0,0 +1,34 @@
+package mail_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"hex-example/internal/mail"
+	"hex-example/internal/mail/mailtest"
+)
+
+func TestSMTPMailer_Send(t *testing.T) {
+	server, err := mailtest.NewServer()
+	require.NoError(t, err)
+	defer server.Close()
+
+	mailer := mail.NewSMTPMailer(server.Addr, "noreply@example.com", nil)
+	err = mailer.Send(&mail.Message{To: "joel@example.com", Subject: "Hello", Body: "line one\n.line two"})
+
+	require.NoError(t, err)
+	messages := server.Messages()
+	if assert.Len(t, messages, 1) {
+		assert.Equal(t, "noreply@example.com", messages[0].From)
+		assert.Equal(t, []string{"joel@example.com"}, messages[0].To)
+		assert.Equal(t, "Hello", messages[0].Subject)
+		assert.Equal(t, "line one\n.line two\n", messages[0].Body)
+	}
+}
+
+func TestSMTPMailer_RejectsHeaderInjection(t *testing.T) {
+	mailer := mail.NewSMTPMailer("127.0.0.1:1", "noreply@example.com", nil)
+	err := mailer.Send(&mail.Message{To: "joel@example.com\r\nBcc: eve@example.com", Subject: "Hello"})
+	assert.Error(t, err)
+}
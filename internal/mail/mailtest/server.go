@@ -0,0 +1,151 @@
+// Package mailtest provides a local SMTP server that records the messages
+// it receives, as a stand-in for a real relay in tests.
+package mailtest
+
+import (
+	"bufio"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+)
+
+// Message is a message received by a Server.
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Server is an SMTP server listening on a random local port. It accepts
+// every message without authentication.
+type Server struct {
+	// Addr is the host:port the server listens on.
+	Addr string
+
+	listener net.Listener
+	mu       sync.Mutex
+	messages []*Message
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a Server. Callers must Close it.
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{Addr: l.Addr().String(), listener: l}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Messages returns the messages received so far.
+func (s *Server) Messages() []*Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Message(nil), s.messages...)
+}
+
+// Close stops the server and waits for open sessions to end.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.session(conn)
+		}()
+	}
+}
+
+func (s *Server) session(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	reply := func(line string) bool {
+		_, err := conn.Write([]byte(line + "\r\n"))
+		return err == nil
+	}
+
+	reply("220 mailtest ESMTP")
+	msg := new(Message)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(verb, "EHLO"), strings.HasPrefix(verb, "HELO"):
+			reply("250 mailtest")
+		case strings.HasPrefix(verb, "MAIL FROM:"):
+			msg = &Message{From: address(line[len("MAIL FROM:"):])}
+			reply("250 OK")
+		case strings.HasPrefix(verb, "RCPT TO:"):
+			msg.To = append(msg.To, address(line[len("RCPT TO:"):]))
+			reply("250 OK")
+		case verb == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := readData(r)
+			if err != nil {
+				return
+			}
+			if parsed, err := mail.ReadMessage(strings.NewReader(data)); err == nil {
+				msg.Subject = parsed.Header.Get("Subject")
+				body := new(strings.Builder)
+				bufio.NewReader(parsed.Body).WriteTo(body)
+				msg.Body = strings.ReplaceAll(body.String(), "\r\n", "\n")
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 OK")
+		case verb == "RSET", verb == "NOOP":
+			reply("250 OK")
+		case verb == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// readData reads a DATA payload up to the terminating dot line, undoing
+// dot-stuffing.
+func readData(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return b.String(), nil
+		}
+		b.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
+
+// address strips the angle brackets and parameters of a MAIL or RCPT
+// argument.
+func address(arg string) string {
+	arg = strings.TrimSpace(arg)
+	if i := strings.IndexByte(arg, ' '); i >= 0 {
+		arg = arg[:i]
+	}
+	return strings.Trim(arg, "<>")
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import "strings"
+
+// APIKeyPrefix starts every API key so that keys can be told apart from
+// JWTs and found by secret scanners.
+const APIKeyPrefix = "hxk_"
+
+// APIKeyVerifier resolves an API key to the claims of the service principal
+// it belongs to.
+type APIKeyVerifier interface {
+	VerifyAPIKey(key string) (*Claims, error)
+}
+
+type apiKeyValidator struct {
+	tokens TokenValidator
+	keys   APIKeyVerifier
+}
+
+// WithAPIKeys returns a TokenValidator that accepts API keys, verified by
+// keys, as well as the bearer JWTs accepted by tokens.
+func WithAPIKeys(tokens TokenValidator, keys APIKeyVerifier) TokenValidator {
+	return &apiKeyValidator{
+		tokens,
+		keys,
+	}
+}
+
+func (v *apiKeyValidator) Validate(tokenString string) (*Claims, error) {
+	if strings.HasPrefix(tokenString, APIKeyPrefix) {
+		return v.keys.VerifyAPIKey(tokenString)
+	}
+	return v.tokens.Validate(tokenString)
+}
@@ -1,51 +1,160 @@
 package middleware
 
 import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
-	"github.com/gorilla/context"
 	"github.com/sirupsen/logrus"
 	"hex-example/internal/env"
+	"hex-example/internal/jwks"
+	"hex-example/internal/problem"
 	"net/http"
 	"strings"
+	"time"
 )
 
-func Authenticate(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		key := env.EnvString("SECRET", "secret")
+// Reasons a token is rejected.
+var (
+	ErrInvalidToken     = errors.New("Invalid Token")
+	ErrTokenExpired     = errors.New("Token is expired")
+	ErrTokenNotYetValid = errors.New("Token is not valid yet")
+	ErrInvalidIssuer    = errors.New("Token issuer is not accepted")
+	ErrInvalidAudience  = errors.New("Token audience is not accepted")
+)
 
-		tokenString := r.Header.Get("Authorization")
-		tokenString = strings.Replace(tokenString, "Bearer ", "", -1)
-		if tokenString == "" {
-			http.Error(w, "Authorization Header Required", http.StatusUnauthorized)
-			return
-		}
+// Defaults shared by the issuer and the services accepting its tokens.
+const (
+	DefaultIssuer   = "http://localhost:3000"
+	DefaultAudience = "hex-example"
+	DefaultLeeway   = 30 * time.Second
+)
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+// Config is what a service requires of the tokens it accepts.
+type Config struct {
+	// Issuer, if set, must equal the iss claim.
+	Issuer string
+	// Audience, if set, must be one of the aud claim values.
+	Audience string
+	// Leeway absorbs clock skew when checking exp, nbf and iat.
+	Leeway time.Duration
+}
 
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-			}
+// ConfigFromEnv reads the Config from JWT_ISSUER, JWT_AUDIENCE and
+// JWT_LEEWAY.
+func ConfigFromEnv() Config {
+	return Config{
+		Issuer:   env.EnvString("JWT_ISSUER", DefaultIssuer),
+		Audience: env.EnvString("JWT_AUDIENCE", DefaultAudience),
+		Leeway:   env.EnvDuration("JWT_LEEWAY", DefaultLeeway),
+	}
+}
 
-			return []byte(key), nil
-		})
+func (c Config) validate(claims *Claims, now time.Time) error {
+	if claims.Subject == "" || claims.Type == "" || claims.ExpiresAt == 0 {
+		return ErrInvalidToken
+	}
+	leeway := int64(c.Leeway / time.Second)
+	if now.Unix() > claims.ExpiresAt+leeway {
+		return ErrTokenExpired
+	}
+	if now.Unix() < claims.NotBefore-leeway || now.Unix() < claims.IssuedAt-leeway {
+		return ErrTokenNotYetValid
+	}
+	if c.Issuer != "" && claims.Issuer != c.Issuer {
+		return ErrInvalidIssuer
+	}
+	if c.Audience != "" && !claims.Audience.Contains(c.Audience) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
 
+// TokenValidator verifies bearer tokens. It is shared by every transport that
+// accepts them.
+type TokenValidator interface {
+	Validate(tokenString string) (*Claims, error)
+}
+
+type tokenValidator struct {
+	keys   jwks.KeySet
+	config Config
+	parser *jwt.Parser
+}
+
+// NewTokenValidator returns a TokenValidator accepting tokens signed by one
+// of keys whose claims satisfy config.
+func NewTokenValidator(keys jwks.KeySet, config Config) TokenValidator {
+	return &tokenValidator{
+		keys,
+		config,
+		&jwt.Parser{
+			ValidMethods:         []string{jwks.RS256, jwks.ES256},
+			SkipClaimsValidation: true,
+		},
+	}
+}
+
+// Validate verifies the signature of tokenString against the public key named
+// by its kid header and returns its claims once they satisfy the Config.
+func (v *tokenValidator) Validate(tokenString string) (*Claims, error) {
+	claims := new(Claims)
+	_, err := v.parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+		key, err := v.keys.Key(kid)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
-			return
+			return nil, err
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid  {
-			userId := claims["sub"].(string)
-			context.Set(r, "id", userId)
-			context.Set(r, "authType", claims["type"].(string))
-			logrus.WithField("UserId", userId).Info("Validated user")
-			next.ServeHTTP(w, r) // call original
-		} else {
-			http.Error(w, "Invalid Token", http.StatusUnauthorized)
-			return
+		// The algorithm must match the key so that a public key can never be
+		// used as an HMAC secret.
+		switch token.Method.Alg() {
+		case jwks.RS256:
+			if _, ok := key.(*rsa.PublicKey); ok {
+				return key, nil
+			}
+		case jwks.ES256:
+			if _, ok := key.(*ecdsa.PublicKey); ok {
+				return key, nil
+			}
 		}
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
 	})
+	if err != nil {
+		if ve, ok := err.(*jwt.ValidationError); ok && ve.Inner != nil {
+			return nil, ve.Inner
+		}
+		return nil, err
+	}
+
+	if err := v.config.validate(claims, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
 }
 
+// Authenticate rejects requests without a valid bearer token before calling
+// next with the token's claims in the request context.
+func Authenticate(validator TokenValidator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenString := r.Header.Get("Authorization")
+		tokenString = strings.Replace(tokenString, "Bearer ", "", -1)
+		if tokenString == "" {
+			problem.Write(w, r, problem.New(http.StatusUnauthorized, "Authorization Header Required"))
+			return
+		}
+
+		claims, err := validator.Validate(tokenString)
+		if err != nil {
+			problem.Write(w, r, problem.New(http.StatusUnauthorized, err.Error()))
+			return
+		}
 
+		logrus.WithField("UserId", claims.Subject).Info("Validated user")
+		next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims))) // call original
+	})
+}
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"hex-example/internal/jwks"
+	"hex-example/internal/rbac"
+)
+
+var testConfig = Config{Issuer: "issuer", Audience: "tickets", Leeway: time.Minute}
+
+func newTestKeys(t *testing.T) *jwks.KeyRing {
+	keys, err := jwks.NewKeyRing(jwks.NewMemoryStore(), jwks.ES256, time.Hour, time.Hour, 0)
+	require.NoError(t, err)
+	return keys
+}
+
+func validClaims() *Claims {
+	return &Claims{
+		Subject:   "test",
+		Type:      PrincipalUser,
+		Issuer:    "issuer",
+		Audience:  Audience{"users", "tickets"},
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestValidate(t *testing.T) {
+	keys := newTestKeys(t)
+	validator := NewTokenValidator(keys, testConfig)
+
+	tests := []struct {
+		name   string
+		mutate func(c *Claims)
+		err    error
+	}{
+		{"valid", func(c *Claims) {}, nil},
+		{"expired within leeway", func(c *Claims) { c.ExpiresAt = time.Now().Add(-30 * time.Second).Unix() }, nil},
+		{"expired", func(c *Claims) { c.ExpiresAt = time.Now().Add(-2 * time.Minute).Unix() }, ErrTokenExpired},
+		{"not yet valid", func(c *Claims) { c.NotBefore = time.Now().Add(2 * time.Minute).Unix() }, ErrTokenNotYetValid},
+		{"wrong issuer", func(c *Claims) { c.Issuer = "other" }, ErrInvalidIssuer},
+		{"wrong audience", func(c *Claims) { c.Audience = Audience{"users"} }, ErrInvalidAudience},
+		{"missing type", func(c *Claims) { c.Type = "" }, ErrInvalidToken},
+		{"missing subject", func(c *Claims) { c.Subject = "" }, ErrInvalidToken},
+		{"missing expiry", func(c *Claims) { c.ExpiresAt = 0 }, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.mutate(claims)
+			signed, err := keys.Sign(claims)
+			require.NoError(t, err)
+
+			got, err := validator.Validate(signed)
+
+			assert.Equal(t, tt.err, err)
+			if tt.err == nil {
+				assert.Equal(t, claims, got)
+			}
+		})
+	}
+}
+
+func TestValidate_RejectsUnknownKeyAndHMAC(t *testing.T) {
+	validator := NewTokenValidator(newTestKeys(t), testConfig)
+
+	signed, _ := newTestKeys(t).Sign(validClaims())
+	_, err := validator.Validate(signed)
+	assert.Equal(t, jwks.ErrKeyNotFound, err)
+
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+	hmac.Header["kid"] = "secret"
+	signed, _ = hmac.SignedString([]byte("secret"))
+	_, err = validator.Validate(signed)
+	assert.Error(t, err)
+}
+
+func TestAudience_JSON(t *testing.T) {
+	var aud Audience
+	require.NoError(t, aud.UnmarshalJSON([]byte(`"tickets"`)))
+	assert.Equal(t, Audience{"tickets"}, aud)
+	require.NoError(t, aud.UnmarshalJSON([]byte(`["users","tickets"]`)))
+	assert.Equal(t, Audience{"users", "tickets"}, aud)
+	assert.Error(t, aud.UnmarshalJSON([]byte(`1`)))
+}
+
+func TestAuthenticate(t *testing.T) {
+	keys := newTestKeys(t)
+	var principal Principal
+	handler := Authenticate(NewTokenValidator(keys, testConfig), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = PrincipalFromContext(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/tickets", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	signed, _ := keys.Sign(validClaims())
+	r := httptest.NewRequest("GET", "/tickets", nil)
+	r.Header.Set("Authorization", "Bearer "+signed)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, Principal{ID: "test", Type: PrincipalUser}, principal)
+}
+
+func TestRequire(t *testing.T) {
+	handler := RequireInProject(rbac.TicketsDelete, func(r *http.Request) string {
+		return r.URL.Query().Get("project")
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name   string
+		claims *Claims
+		url    string
+		status int
+	}{
+		{"no claims", nil, "/tickets/1", http.StatusForbidden},
+		{"member", &Claims{Role: rbac.RoleMember}, "/tickets/1", http.StatusForbidden},
+		{"admin", &Claims{Role: rbac.RoleAdmin}, "/tickets/1", http.StatusOK},
+		{"project admin", &Claims{Role: rbac.RoleViewer, Projects: map[string]string{"infra": rbac.RoleAdmin}}, "/tickets/1?project=infra", http.StatusOK},
+		{"other project", &Claims{Role: rbac.RoleViewer, Projects: map[string]string{"infra": rbac.RoleAdmin}}, "/tickets/1?project=web", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("DELETE", tt.url, nil)
+			if tt.claims != nil {
+				r = r.WithContext(WithClaims(r.Context(), tt.claims))
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+type fakeAPIKeys map[string]*Claims
+
+func (k fakeAPIKeys) VerifyAPIKey(key string) (*Claims, error) {
+	claims, ok := k[key]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func TestWithAPIKeys(t *testing.T) {
+	keys := newTestKeys(t)
+	service := &Claims{Subject: "ci", Type: PrincipalService, Scopes: []string{string(rbac.TicketsRead)}}
+	validator := WithAPIKeys(NewTokenValidator(keys, testConfig), fakeAPIKeys{APIKeyPrefix + "key": service})
+
+	claims, err := validator.Validate(APIKeyPrefix + "key")
+	assert.NoError(t, err)
+	assert.Equal(t, service, claims)
+
+	signed, _ := keys.Sign(validClaims())
+	claims, err = validator.Validate(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, PrincipalUser, claims.Type)
+
+	_, err = validator.Validate(APIKeyPrefix + "unknown")
+	assert.Error(t, err)
+
+	ctx := WithClaims(httptest.NewRequest("GET", "/", nil).Context(), service)
+	assert.NoError(t, Authorize(ctx, rbac.TicketsRead, ""))
+	assert.Error(t, Authorize(ctx, rbac.TicketsCreate, ""), "service principals hold only their scopes")
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"hex-example/internal/problem"
+	"hex-example/internal/rbac"
+)
+
+// Authorize returns an rbac.ForbiddenError unless the caller in ctx holds
+// permission, either globally or in project. Service principals hold only
+// their scopes; callers without claims hold no permissions.
+func Authorize(ctx context.Context, permission rbac.Permission, project string) error {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok || !allowed(claims, permission, project) {
+		return &rbac.ForbiddenError{Permission: permission, Project: project}
+	}
+	return nil
+}
+
+func allowed(claims *Claims, permission rbac.Permission, project string) bool {
+	if claims.Type == PrincipalService {
+		return rbac.InScopes(claims.Scopes, permission)
+	}
+	return rbac.Allowed(claims.Role, claims.Projects, permission, project)
+}
+
+// Require responds 403 unless the authenticated caller holds permission
+// globally. It must run after Authenticate.
+func Require(permission rbac.Permission, next http.Handler) http.Handler {
+	return RequireInProject(permission, nil, next)
+}
+
+// RequireInProject responds 403 unless the authenticated caller holds
+// permission in the project project returns for the request. A nil project
+// function checks the global role only.
+func RequireInProject(permission rbac.Permission, project func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var id string
+		if project != nil {
+			id = project(r)
+		}
+		if err := Authorize(r.Context(), permission, id); err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Principal types carried in the type claim.
+const (
+	PrincipalUser    = "user"
+	PrincipalService = "service"
+)
+
+// Claims are the claims of an access token issued by userAPI.
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Type      string   `json:"type"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	// AuthTime is when the account holder authenticated. Unlike IssuedAt it
+	// is kept when the token is refreshed.
+	AuthTime int64 `json:"auth_time,omitempty"`
+	// Role is the global rbac role of the principal.
+	Role string `json:"role,omitempty"`
+	// Projects maps project IDs to the rbac role held in that project.
+	Projects map[string]string `json:"projects,omitempty"`
+	// Scopes are the only permissions of a service principal.
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// Valid implements jwt.Claims with no leeway and no issuer or audience
+// requirements. Authenticate validates with a Config instead.
+func (c *Claims) Valid() error {
+	return Config{}.validate(c, time.Now())
+}
+
+// Principal returns the caller the claims authenticate.
+func (c *Claims) Principal() Principal {
+	return Principal{ID: c.Subject, Type: c.Type}
+}
+
+// Audience is the aud claim, which RFC 7519 allows to be a single string or
+// an array of strings.
+type Audience []string
+
+// Contains reports whether aud is one of the audiences.
+func (a Audience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return errors.New("aud must be a string or an array of strings")
+	}
+	*a = many
+	return nil
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	ID   string
+	Type string
+}
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	claimsKey key = iota
+)
+
+// WithClaims returns a copy of ctx that stores the claims of the caller.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+// ClaimsFromContext returns the claims stored by Authenticate, if any.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*Claims)
+	return claims, ok
+}
+
+// PrincipalFromContext returns the authenticated caller, if any.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return Principal{}, false
+	}
+	return claims.Principal(), true
+}
@@ -59,6 +59,19 @@ func (mr *MockTicketRepositoryMockRecorder) FindAll() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAll", reflect.TypeOf((*MockTicketRepository)(nil).FindAll))
 }
 
+// FindByIDs mocks base method
+func (m *MockTicketRepository) FindByIDs(arg0 []string) ([]*ticket.Ticket, error) {
+	ret := m.ctrl.Call(m, "FindByIDs", arg0)
+	ret0, _ := ret[0].([]*ticket.Ticket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByIDs indicates an expected call of FindByIDs
+func (mr *MockTicketRepositoryMockRecorder) FindByIDs(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByIDs", reflect.TypeOf((*MockTicketRepository)(nil).FindByIDs), arg0)
+}
+
 // FindById mocks base method
 func (m *MockTicketRepository) FindById(arg0 string) (*ticket.Ticket, error) {
 	ret := m.ctrl.Call(m, "FindById", arg0)
@@ -72,6 +85,32 @@ func (mr *MockTicketRepositoryMockRecorder) FindById(arg0 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindById", reflect.TypeOf((*MockTicketRepository)(nil).FindById), arg0)
 }
 
+// FindByProject mocks base method
+func (m *MockTicketRepository) FindByProject(arg0 string) ([]*ticket.Ticket, error) {
+	ret := m.ctrl.Call(m, "FindByProject", arg0)
+	ret0, _ := ret[0].([]*ticket.Ticket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByProject indicates an expected call of FindByProject
+func (mr *MockTicketRepositoryMockRecorder) FindByProject(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByProject", reflect.TypeOf((*MockTicketRepository)(nil).FindByProject), arg0)
+}
+
+// FindByUsernames mocks base method
+func (m *MockTicketRepository) FindByUsernames(arg0 []string) ([]*ticket.Ticket, error) {
+	ret := m.ctrl.Call(m, "FindByUsernames", arg0)
+	ret0, _ := ret[0].([]*ticket.Ticket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByUsernames indicates an expected call of FindByUsernames
+func (mr *MockTicketRepositoryMockRecorder) FindByUsernames(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUsernames", reflect.TypeOf((*MockTicketRepository)(nil).FindByUsernames), arg0)
+}
+
 // MockTicketService is a mock of TicketService interface
 type MockTicketService struct {
 	ctrl     *gomock.Controller
@@ -120,6 +159,19 @@ func (mr *MockTicketServiceMockRecorder) FindAllTickets() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAllTickets", reflect.TypeOf((*MockTicketService)(nil).FindAllTickets))
 }
 
+// FindProjectTickets mocks base method
+func (m *MockTicketService) FindProjectTickets(arg0 string) ([]*ticket.Ticket, error) {
+	ret := m.ctrl.Call(m, "FindProjectTickets", arg0)
+	ret0, _ := ret[0].([]*ticket.Ticket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindProjectTickets indicates an expected call of FindProjectTickets
+func (mr *MockTicketServiceMockRecorder) FindProjectTickets(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindProjectTickets", reflect.TypeOf((*MockTicketService)(nil).FindProjectTickets), arg0)
+}
+
 // FindTicketById mocks base method
 func (m *MockTicketService) FindTicketById(arg0 string) (*ticket.Ticket, error) {
 	ret := m.ctrl.Call(m, "FindTicketById", arg0)
@@ -133,6 +185,32 @@ func (mr *MockTicketServiceMockRecorder) FindTicketById(arg0 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindTicketById", reflect.TypeOf((*MockTicketService)(nil).FindTicketById), arg0)
 }
 
+// FindTicketsByIDs mocks base method
+func (m *MockTicketService) FindTicketsByIDs(arg0 []string) ([]*ticket.Ticket, error) {
+	ret := m.ctrl.Call(m, "FindTicketsByIDs", arg0)
+	ret0, _ := ret[0].([]*ticket.Ticket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindTicketsByIDs indicates an expected call of FindTicketsByIDs
+func (mr *MockTicketServiceMockRecorder) FindTicketsByIDs(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindTicketsByIDs", reflect.TypeOf((*MockTicketService)(nil).FindTicketsByIDs), arg0)
+}
+
+// FindUserTickets mocks base method
+func (m *MockTicketService) FindUserTickets(arg0 []string) ([]*ticket.Ticket, error) {
+	ret := m.ctrl.Call(m, "FindUserTickets", arg0)
+	ret0, _ := ret[0].([]*ticket.Ticket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindUserTickets indicates an expected call of FindUserTickets
+func (mr *MockTicketServiceMockRecorder) FindUserTickets(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUserTickets", reflect.TypeOf((*MockTicketService)(nil).FindUserTickets), arg0)
+}
+
 // MockTicketHandler is a mock of TicketHandler interface
 type MockTicketHandler struct {
 	ctrl     *gomock.Controller
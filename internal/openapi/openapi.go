@@ -0,0 +1,138 @@
+// Package openapi holds the OpenAPI 3 documents for the HTTP APIs, serves
+// them and validates incoming requests against them.
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/problem"
+)
+
+// TicketAPI returns the document describing cmd/ticketAPI.
+func TicketAPI() *openapi3.T {
+	return mustLoad(ticketSpec)
+}
+
+// UserAPI returns the document describing cmd/userAPI.
+func UserAPI() *openapi3.T {
+	return mustLoad(userSpec)
+}
+
+func mustLoad(spec string) *openapi3.T {
+	doc, err := openapi3.NewLoader().LoadFromData([]byte(spec))
+	if err != nil {
+		panic(err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		panic(err)
+	}
+	return doc
+}
+
+// Handler serves doc as JSON.
+func Handler(doc *openapi3.T) http.Handler {
+	response, err := json.Marshal(doc)
+	if err != nil {
+		panic(err)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(response); err != nil {
+			logrus.WithField("error", err).Error("Error writing response")
+		}
+	})
+}
+
+// Validate checks every request routed by doc against its parameters and
+// request body before calling next, and responds 400 with a problem listing
+// every invalid field otherwise. Requests doc does not describe are passed
+// through so the router can answer them. Authentication is left to
+// middleware.Authenticate.
+func Validate(doc *openapi3.T, next http.Handler) http.Handler {
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		panic(err)
+	}
+	options := &openapi3filter.Options{
+		MultiError:         true,
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, pathParams, err := router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    options,
+		}
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			logrus.WithField("error", err).Info("Invalid request")
+			p := problem.New(http.StatusBadRequest, "Request does not match the API document")
+			p.InvalidParams = invalidParams(err)
+			problem.Write(w, r, p)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// invalidParams flattens the errors returned by ValidateRequest into one
+// entry per invalid field.
+func invalidParams(err error) []problem.InvalidParam {
+	var params []problem.InvalidParam
+
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		for _, nested := range e {
+			params = append(params, invalidParams(nested)...)
+		}
+	case *openapi3filter.RequestError:
+		name := "body"
+		if e.Parameter != nil {
+			name = e.Parameter.Name
+		}
+		switch nested := e.Err.(type) {
+		case nil:
+			params = append(params, problem.InvalidParam{Name: name, Reason: e.Reason})
+		case openapi3.MultiError:
+			for _, n := range nested {
+				params = append(params, fieldParam(name, n))
+			}
+		default:
+			params = append(params, fieldParam(name, nested))
+		}
+	case *openapi3filter.SecurityRequirementsError:
+		params = append(params, problem.InvalidParam{Name: "security", Reason: e.Error()})
+	default:
+		params = append(params, problem.InvalidParam{Name: "request", Reason: err.Error()})
+	}
+	return params
+}
+
+// fieldParam names a schema error after the JSON pointer of the field that
+// failed, prefixed with where the field came from.
+func fieldParam(name string, err error) problem.InvalidParam {
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		if pointer := schemaErr.JSONPointer(); len(pointer) > 0 {
+			name = name + "." + strings.Join(pointer, ".")
+		}
+		return problem.InvalidParam{Name: name, Reason: schemaErr.Reason}
+	}
+	return problem.InvalidParam{Name: name, Reason: err.Error()}
+}
@@ -0,0 +1,95 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"hex-example/internal/problem"
+)
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "next handler called")
+	})
+}
+
+func TestValidate_MissingRequiredFields(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/tickets", bytes.NewBufferString(`{"description": "no title"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	Validate(TicketAPI(), okHandler()).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+
+	result := new(problem.Problem)
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(result))
+	names := []string{}
+	for _, p := range result.InvalidParams {
+		names = append(names, p.Name)
+	}
+	assert.ElementsMatch(t, []string{"body.creator", "body.title"}, names)
+}
+
+func TestValidate_WrongType(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/tickets", bytes.NewBufferString(`{"creator": "Joel", "title": "t", "points": "three"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	Validate(TicketAPI(), okHandler()).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	result := new(problem.Problem)
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(result))
+	if assert.Len(t, result.InvalidParams, 1) {
+		assert.Equal(t, "body.points", result.InvalidParams[0].Name)
+	}
+}
+
+func TestValidate_ValidRequestKeepsBody(t *testing.T) {
+	body := `{"username": "joel", "password": "hunter22"}`
+	next := func(w http.ResponseWriter, r *http.Request) {
+		b := new(bytes.Buffer)
+		b.ReadFrom(r.Body)
+		assert.Equal(t, body, b.String())
+		fmt.Fprintf(w, "next handler called")
+	}
+	r, _ := http.NewRequest("POST", "/account", bytes.NewBufferString(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	Validate(UserAPI(), http.HandlerFunc(next)).ServeHTTP(w, r)
+
+	assert.Equal(t, "next handler called", w.Body.String())
+}
+
+func TestValidate_ProjectRoute(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/projects/infra/tickets", bytes.NewBufferString(`{"creator": "Joel", "title": "t"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	Validate(TicketAPI(), okHandler()).ServeHTTP(w, r)
+
+	assert.Equal(t, "next handler called", w.Body.String())
+}
+
+func TestValidate_UnknownRoutePassesThrough(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/unknown", nil)
+	w := httptest.NewRecorder()
+	Validate(UserAPI(), okHandler()).ServeHTTP(w, r)
+
+	assert.Equal(t, "next handler called", w.Body.String())
+}
+
+func TestHandler(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	Handler(TicketAPI()).ServeHTTP(w, r)
+
+	var doc map[string]interface{}
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&doc))
+	assert.Equal(t, "3.0.3", doc["openapi"])
+	assert.Contains(t, doc["paths"], "/tickets/{id}")
+}
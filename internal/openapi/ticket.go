@@ -0,0 +1,227 @@
+package openapi
+
+// ticketSpec describes every route registered by cmd/ticketAPI.
+const ticketSpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Ticket API",
+    "version": "1.0.0"
+  },
+  "security": [{"bearerAuth": []}],
+  "paths": {
+    "/tickets": {
+      "get": {
+        "operationId": "findAllTickets",
+        "responses": {
+          "200": {
+            "description": "All tickets",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Ticket"}}}}
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      },
+      "post": {
+        "operationId": "createTicket",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/TicketInput"}}}
+        },
+        "responses": {
+          "201": {
+            "description": "The created ticket",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Ticket"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/tickets/{id}": {
+      "get": {
+        "operationId": "findTicketById",
+        "parameters": [
+          {"name": "id", "in": "path", "required": true, "schema": {"type": "string", "minLength": 1}}
+        ],
+        "responses": {
+          "200": {
+            "description": "The ticket",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Ticket"}}}
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"}
+        }
+      }
+    },
+    "/projects/{project}/tickets": {
+      "parameters": [
+        {"name": "project", "in": "path", "required": true, "schema": {"type": "string", "minLength": 1, "maxLength": 64}}
+      ],
+      "get": {
+        "operationId": "findProjectTickets",
+        "responses": {
+          "200": {
+            "description": "The tickets of the project",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Ticket"}}}}
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      },
+      "post": {
+        "operationId": "createProjectTicket",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/TicketInput"}}}
+        },
+        "responses": {
+          "201": {
+            "description": "The created ticket, which belongs to the project",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Ticket"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/projects/{project}/tickets/{id}": {
+      "get": {
+        "operationId": "findProjectTicketById",
+        "parameters": [
+          {"name": "project", "in": "path", "required": true, "schema": {"type": "string", "minLength": 1, "maxLength": 64}},
+          {"name": "id", "in": "path", "required": true, "schema": {"type": "string", "minLength": 1}}
+        ],
+        "responses": {
+          "200": {
+            "description": "The ticket",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Ticket"}}}
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"}
+        }
+      }
+    },
+    "/graphql": {
+      "post": {
+        "operationId": "graphql",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/GraphQLRequest"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "GraphQL response",
+            "content": {"application/json": {"schema": {"type": "object"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "operationId": "openapi",
+        "security": [],
+        "responses": {
+          "200": {
+            "description": "This document",
+            "content": {"application/json": {"schema": {"type": "object"}}}
+          }
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearerAuth": {
+        "type": "http",
+        "scheme": "bearer",
+        "description": "An access token issued by userAPI or a service account API key starting with hxk_"
+      }
+    },
+    "responses": {
+      "BadRequest": {
+        "description": "The request does not match this document",
+        "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+      },
+      "Unauthorized": {
+        "description": "Missing or invalid bearer token",
+        "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+      },
+      "Forbidden": {
+        "description": "The caller's role, or their role in the project, lacks the required permission",
+        "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+      },
+      "NotFound": {
+        "description": "No such resource",
+        "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+      }
+    },
+    "schemas": {
+      "Ticket": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string"},
+          "project": {"type": "string"},
+          "creator": {"type": "string"},
+          "assigned": {"type": "string"},
+          "title": {"type": "string"},
+          "description": {"type": "string"},
+          "status": {"type": "string"},
+          "points": {"type": "integer"},
+          "created": {"type": "string", "format": "date-time"},
+          "updated": {"type": "string", "format": "date-time"},
+          "deleted": {"type": "string", "format": "date-time"}
+        }
+      },
+      "TicketInput": {
+        "type": "object",
+        "required": ["creator", "title"],
+        "properties": {
+          "project": {"type": "string", "maxLength": 64},
+          "creator": {"type": "string", "minLength": 1, "maxLength": 255},
+          "assigned": {"type": "string", "maxLength": 255},
+          "title": {"type": "string", "minLength": 1, "maxLength": 255},
+          "description": {"type": "string", "maxLength": 255},
+          "status": {"type": "string", "enum": ["OPEN", "IN_PROGRESS", "BLOCKED", "DONE"]},
+          "points": {"type": "integer", "minimum": 0, "maximum": 100}
+        }
+      },
+      "GraphQLRequest": {
+        "type": "object",
+        "required": ["query"],
+        "properties": {
+          "query": {"type": "string"},
+          "operationName": {"type": "string"},
+          "variables": {"type": "object"}
+        }
+      },
+      "Problem": {
+        "type": "object",
+        "properties": {
+          "type": {"type": "string"},
+          "title": {"type": "string"},
+          "status": {"type": "integer"},
+          "detail": {"type": "string"},
+          "instance": {"type": "string"},
+          "correlationId": {"type": "string"},
+          "invalid-params": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "name": {"type": "string"},
+                "reason": {"type": "string"}
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
@@ -0,0 +1,1053 @@
+package openapi
+
+// userSpec describes every route registered by cmd/userAPI.
+const userSpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "User API",
+    "version": "1.0.0"
+  },
+  "paths": {
+    "/account": {
+      "post": {
+        "operationId": "createAccount",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AccountInput"}}}
+        },
+        "responses": {
+          "201": {
+            "description": "The created account",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Account"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "409": {
+            "description": "The username is already taken",
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          }
+        }
+      }
+    },
+    "/auth": {
+      "get": {
+        "operationId": "getToken",
+        "security": [{"basicAuth": []}],
+        "responses": {
+          "200": {
+            "description": "A bearer token for the account",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Login"}}}
+          },
+          "401": {
+            "description": "Missing or invalid credentials",
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          },
+          "429": {
+            "description": "Too many failed logins for the username or client; wait for Retry-After seconds",
+            "headers": {"Retry-After": {"schema": {"type": "integer"}}},
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          }
+        }
+      }
+    },
+    "/auth/refresh": {
+      "post": {
+        "operationId": "refreshToken",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RefreshRequest"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "A new access token and a rotated refresh token",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Login"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {
+            "description": "Unknown, expired, reused or revoked refresh token",
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          }
+        }
+      }
+    },
+    "/auth/logout": {
+      "post": {
+        "operationId": "logout",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RefreshRequest"}}}
+        },
+        "responses": {
+          "204": {"description": "The refresh token family is revoked"},
+          "400": {"$ref": "#/components/responses/BadRequest"}
+        }
+      }
+    },
+    "/account/me": {
+      "get": {
+        "operationId": "getMe",
+        "security": [{"bearerAuth": []}],
+        "responses": {
+          "200": {
+            "description": "The caller's account",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Account"}}}
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      },
+      "patch": {
+        "operationId": "updateMe",
+        "security": [{"bearerAuth": []}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AccountUpdate"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "The updated account",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Account"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      },
+      "delete": {
+        "operationId": "deleteMe",
+        "security": [{"bearerAuth": []}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PasswordConfirmation"}}}
+        },
+        "responses": {
+          "204": {"description": "The account and its sessions are erased"},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/account/me/password": {
+      "put": {
+        "operationId": "changePassword",
+        "security": [{"bearerAuth": []}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PasswordChange"}}}
+        },
+        "responses": {
+          "204": {"description": "The password is changed and every session is logged out"},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/auth/mfa": {
+      "post": {
+        "operationId": "verifyMfa",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/MFAVerification"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "An access token and a refresh token",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Login"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {
+            "description": "Invalid code, or an unknown, expired or exhausted challenge",
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          },
+          "429": {
+            "description": "Too many wrong codes from this client; wait for Retry-After seconds",
+            "headers": {"Retry-After": {"schema": {"type": "integer"}}},
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          }
+        }
+      }
+    },
+    "/account/me/mfa": {
+      "delete": {
+        "operationId": "disableMfa",
+        "security": [{"bearerAuth": []}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PasswordConfirmation"}}}
+        },
+        "responses": {
+          "204": {"description": "Two-factor authentication is disabled"},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/account/me/mfa/totp": {
+      "post": {
+        "operationId": "enrollTotp",
+        "security": [{"bearerAuth": []}],
+        "responses": {
+          "201": {
+            "description": "A new TOTP secret, pending confirmation",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/MFAEnrollment"}}}
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "409": {"$ref": "#/components/responses/MFAState"}
+        }
+      }
+    },
+    "/account/me/mfa/totp/qr.png": {
+      "get": {
+        "operationId": "totpQrCode",
+        "security": [{"bearerAuth": []}],
+        "responses": {
+          "200": {
+            "description": "The pending otpauth URI as a QR code",
+            "content": {"image/png": {"schema": {"type": "string", "format": "binary"}}}
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "409": {"$ref": "#/components/responses/MFAState"}
+        }
+      }
+    },
+    "/account/me/mfa/totp/confirm": {
+      "post": {
+        "operationId": "confirmTotp",
+        "security": [{"bearerAuth": []}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/MFACode"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "Two-factor authentication is enabled; the recovery codes are shown only once",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RecoveryCodes"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "409": {"$ref": "#/components/responses/MFAState"}
+        }
+      }
+    },
+    "/account/me/mfa/recovery-codes": {
+      "post": {
+        "operationId": "regenerateRecoveryCodes",
+        "security": [{"bearerAuth": []}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/MFACode"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "New recovery codes replacing every earlier one",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RecoveryCodes"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "409": {"$ref": "#/components/responses/MFAState"}
+        }
+      }
+    },
+    "/auth/passkey": {
+      "post": {
+        "operationId": "beginPasskeyLogin",
+        "responses": {
+          "200": {
+            "description": "Options for navigator.credentials.get and the ceremony to finish",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PasskeyCeremony"}}}
+          }
+        }
+      }
+    },
+    "/auth/passkey/{ceremonyId}": {
+      "post": {
+        "operationId": "finishPasskeyLogin",
+        "parameters": [
+          {"name": "ceremonyId", "in": "path", "required": true, "schema": {"type": "string"}}
+        ],
+        "requestBody": {
+          "required": true,
+          "description": "The PublicKeyCredential returned by navigator.credentials.get, as JSON",
+          "content": {"application/json": {"schema": {"type": "object"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "An access token and a refresh token",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Login"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {
+            "description": "Unknown or expired ceremony, or an assertion that fails verification",
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          }
+        }
+      }
+    },
+    "/account/me/passkeys": {
+      "get": {
+        "operationId": "listPasskeys",
+        "security": [{"bearerAuth": []}],
+        "responses": {
+          "200": {
+            "description": "The passkeys of the account, oldest first",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Passkey"}}}}
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/account/me/passkeys/registration": {
+      "post": {
+        "operationId": "beginPasskeyRegistration",
+        "security": [{"bearerAuth": []}],
+        "responses": {
+          "200": {
+            "description": "Options for navigator.credentials.create and the ceremony to finish",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PasskeyCeremony"}}}
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/account/me/passkeys/registration/{ceremonyId}": {
+      "post": {
+        "operationId": "finishPasskeyRegistration",
+        "security": [{"bearerAuth": []}],
+        "parameters": [
+          {"name": "ceremonyId", "in": "path", "required": true, "schema": {"type": "string"}},
+          {"name": "name", "in": "query", "schema": {"type": "string", "maxLength": 64}}
+        ],
+        "requestBody": {
+          "required": true,
+          "description": "The PublicKeyCredential returned by navigator.credentials.create, as JSON",
+          "content": {"application/json": {"schema": {"type": "object"}}}
+        },
+        "responses": {
+          "201": {
+            "description": "The registered passkey",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Passkey"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/account/me/passkeys/{id}": {
+      "delete": {
+        "operationId": "deletePasskey",
+        "security": [{"bearerAuth": []}],
+        "parameters": [
+          {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "204": {"description": "The passkey is deleted"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {
+            "description": "The account has no such passkey",
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          }
+        }
+      }
+    },
+    "/account/me/consents": {
+      "get": {
+        "operationId": "listConsents",
+        "security": [{"bearerAuth": []}],
+        "responses": {
+          "200": {
+            "description": "The OpenID Connect clients the account consented to",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Consent"}}}}
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/account/me/consents/{clientId}": {
+      "delete": {
+        "operationId": "revokeConsent",
+        "security": [{"bearerAuth": []}],
+        "parameters": [
+          {"name": "clientId", "in": "path", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "204": {"description": "The consent is revoked; the client has to ask again"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {
+            "description": "The account gave no consent to the client",
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          }
+        }
+      }
+    },
+    "/account/me/verification": {
+      "post": {
+        "operationId": "resendVerification",
+        "security": [{"bearerAuth": []}],
+        "responses": {
+          "202": {"description": "A verification link is mailed unless the address is already verified"},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/account/verify": {
+      "get": {
+        "operationId": "verifyEmail",
+        "parameters": [
+          {"name": "token", "in": "query", "required": true, "schema": {"type": "string", "minLength": 1}}
+        ],
+        "responses": {
+          "204": {"description": "The email address is verified"},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {
+            "description": "Unknown, expired or already used token",
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          }
+        }
+      }
+    },
+    "/password-reset": {
+      "post": {
+        "operationId": "requestPasswordReset",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PasswordResetRequest"}}}
+        },
+        "responses": {
+          "202": {"description": "A reset token is mailed if the account has a verified email address"},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "429": {
+            "description": "Too many reset requests for this username or from this client; wait for Retry-After seconds",
+            "headers": {"Retry-After": {"schema": {"type": "integer"}}},
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          }
+        }
+      }
+    },
+    "/password-reset/confirm": {
+      "post": {
+        "operationId": "resetPassword",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PasswordReset"}}}
+        },
+        "responses": {
+          "204": {"description": "The password is reset and every session is logged out"},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {
+            "description": "Unknown, expired or already used token",
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          }
+        }
+      }
+    },
+    "/account/me/deactivate": {
+      "post": {
+        "operationId": "deactivateMe",
+        "security": [{"bearerAuth": []}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PasswordConfirmation"}}}
+        },
+        "responses": {
+          "204": {"description": "The account can no longer log in"},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/accounts": {
+      "get": {
+        "operationId": "listAccounts",
+        "security": [{"bearerAuth": []}],
+        "responses": {
+          "200": {
+            "description": "Every account; requires accounts:list",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Account"}}}}
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/accounts/{username}/roles": {
+      "put": {
+        "operationId": "setRoles",
+        "security": [{"bearerAuth": []}],
+        "parameters": [
+          {"name": "username", "in": "path", "required": true, "schema": {"type": "string", "minLength": 1}}
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Roles"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "The account with its new roles; requires accounts:manage",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Account"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {
+            "description": "No such account",
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          }
+        }
+      }
+    },
+    "/service-accounts": {
+      "get": {
+        "operationId": "listServiceAccounts",
+        "security": [{"bearerAuth": []}],
+        "responses": {
+          "200": {
+            "description": "Every service account; requires accounts:manage",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/ServiceAccount"}}}}
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      },
+      "post": {
+        "operationId": "createServiceAccount",
+        "security": [{"bearerAuth": []}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ServiceAccountInput"}}}
+        },
+        "responses": {
+          "201": {
+            "description": "The created service account",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ServiceAccount"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/service-accounts/{id}": {
+      "delete": {
+        "operationId": "deleteServiceAccount",
+        "security": [{"bearerAuth": []}],
+        "parameters": [
+          {"name": "id", "in": "path", "required": true, "schema": {"type": "string", "minLength": 1}}
+        ],
+        "responses": {
+          "204": {"description": "The service account and all its keys are deleted"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {
+            "description": "No such service account or key",
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          }
+        }
+      }
+    },
+    "/service-accounts/{id}/keys": {
+      "get": {
+        "operationId": "listAPIKeys",
+        "security": [{"bearerAuth": []}],
+        "parameters": [
+          {"name": "id", "in": "path", "required": true, "schema": {"type": "string", "minLength": 1}}
+        ],
+        "responses": {
+          "200": {
+            "description": "The keys of the service account, without secrets",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/APIKey"}}}}
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {
+            "description": "No such service account or key",
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          }
+        }
+      },
+      "post": {
+        "operationId": "createAPIKey",
+        "security": [{"bearerAuth": []}],
+        "parameters": [
+          {"name": "id", "in": "path", "required": true, "schema": {"type": "string", "minLength": 1}}
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/APIKeyRequest"}}}
+        },
+        "responses": {
+          "201": {
+            "description": "The new key; the key value is only returned here",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/IssuedAPIKey"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {
+            "description": "No such service account or key",
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          }
+        }
+      }
+    },
+    "/service-accounts/{id}/keys/{keyId}": {
+      "delete": {
+        "operationId": "revokeAPIKey",
+        "security": [{"bearerAuth": []}],
+        "parameters": [
+          {"name": "id", "in": "path", "required": true, "schema": {"type": "string", "minLength": 1}},
+          {"name": "keyId", "in": "path", "required": true, "schema": {"type": "string", "minLength": 1}}
+        ],
+        "responses": {
+          "204": {"description": "The key is revoked"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {
+            "description": "No such service account or key",
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          }
+        }
+      }
+    },
+    "/oauth/clients": {
+      "get": {
+        "operationId": "listOAuthClients",
+        "security": [{"bearerAuth": []}],
+        "responses": {
+          "200": {
+            "description": "Every OpenID Connect client, without secrets; requires accounts:manage",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/OAuthClient"}}}}
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      },
+      "post": {
+        "operationId": "registerOAuthClient",
+        "security": [{"bearerAuth": []}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/OAuthClientInput"}}}
+        },
+        "responses": {
+          "201": {
+            "description": "The registered client; its secret is only returned here",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/IssuedOAuthClient"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/oauth/clients/{id}": {
+      "delete": {
+        "operationId": "deleteOAuthClient",
+        "security": [{"bearerAuth": []}],
+        "parameters": [
+          {"name": "id", "in": "path", "required": true, "schema": {"type": "string", "minLength": 1}}
+        ],
+        "responses": {
+          "204": {"description": "The client is deleted"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {
+            "description": "No such client",
+            "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+          }
+        }
+      }
+    },
+    "/oauth/consent": {
+      "get": {
+        "operationId": "getConsentPrompt",
+        "description": "Called by the consent page with the query of the authorization request it was sent",
+        "security": [{"bearerAuth": []}],
+        "parameters": [
+          {"name": "response_type", "in": "query", "schema": {"type": "string"}},
+          {"name": "client_id", "in": "query", "required": true, "schema": {"type": "string"}},
+          {"name": "redirect_uri", "in": "query", "required": true, "schema": {"type": "string"}},
+          {"name": "scope", "in": "query", "schema": {"type": "string"}},
+          {"name": "state", "in": "query", "schema": {"type": "string"}},
+          {"name": "nonce", "in": "query", "schema": {"type": "string"}},
+          {"name": "code_challenge", "in": "query", "schema": {"type": "string"}},
+          {"name": "code_challenge_method", "in": "query", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "What the account holder is asked to consent to",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ConsentPrompt"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      },
+      "post": {
+        "operationId": "decideConsent",
+        "security": [{"bearerAuth": []}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ConsentDecision"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "Where to send the browser: back to the client with a code or an error",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AuthorizationResponse"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/.well-known/jwks.json": {
+      "get": {
+        "operationId": "jwks",
+        "responses": {
+          "200": {
+            "description": "The public keys that verify access tokens",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/JWKS"}}}
+          }
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "operationId": "openapi",
+        "responses": {
+          "200": {
+            "description": "This document",
+            "content": {"application/json": {"schema": {"type": "object"}}}
+          }
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "basicAuth": {"type": "http", "scheme": "basic"},
+      "bearerAuth": {"type": "http", "scheme": "bearer", "bearerFormat": "JWT"}
+    },
+    "responses": {
+      "BadRequest": {
+        "description": "The request does not match this document",
+        "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+      },
+      "Unauthorized": {
+        "description": "Missing or invalid bearer token",
+        "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+      },
+      "MFAState": {
+        "description": "Two-factor authentication is not in the state the operation requires",
+        "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+      },
+      "Forbidden": {
+        "description": "The caller's role lacks the required permission",
+        "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}
+      }
+    },
+    "schemas": {
+      "JWKS": {
+        "type": "object",
+        "required": ["keys"],
+        "properties": {
+          "keys": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["kty", "kid"],
+              "properties": {
+                "kty": {"type": "string", "enum": ["RSA", "EC"]},
+                "kid": {"type": "string"},
+                "use": {"type": "string"},
+                "alg": {"type": "string", "enum": ["RS256", "ES256"]},
+                "n": {"type": "string"},
+                "e": {"type": "string"},
+                "crv": {"type": "string"},
+                "x": {"type": "string"},
+                "y": {"type": "string"}
+              }
+            }
+          }
+        }
+      },
+      "Account": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string"},
+          "username": {"type": "string"},
+          "firstName": {"type": "string"},
+          "lastName": {"type": "string"},
+          "email": {"type": "string"},
+          "emailVerified": {"type": "boolean"},
+          "role": {"type": "string", "enum": ["admin", "member", "viewer"]},
+          "projects": {"type": "object", "additionalProperties": {"type": "string", "enum": ["admin", "member", "viewer"]}}
+        }
+      },
+      "AccountUpdate": {
+        "type": "object",
+        "properties": {
+          "firstName": {"type": "string", "maxLength": 64},
+          "lastName": {"type": "string", "maxLength": 64},
+          "email": {"type": "string", "format": "email", "maxLength": 254}
+        }
+      },
+      "PasswordChange": {
+        "type": "object",
+        "required": ["currentPassword", "newPassword"],
+        "properties": {
+          "currentPassword": {"type": "string", "minLength": 1},
+          "newPassword": {"type": "string", "minLength": 8, "maxLength": 72}
+        }
+      },
+      "PasswordResetRequest": {
+        "type": "object",
+        "required": ["username"],
+        "properties": {
+          "username": {"type": "string", "minLength": 1}
+        }
+      },
+      "PasswordReset": {
+        "type": "object",
+        "required": ["token", "newPassword"],
+        "properties": {
+          "token": {"type": "string", "minLength": 1},
+          "newPassword": {"type": "string", "minLength": 8, "maxLength": 72}
+        }
+      },
+      "MFAVerification": {
+        "type": "object",
+        "required": ["mfaToken", "code"],
+        "properties": {
+          "mfaToken": {"type": "string", "minLength": 1},
+          "code": {"type": "string", "minLength": 1, "description": "A TOTP code or an unused recovery code"}
+        }
+      },
+      "MFAEnrollment": {
+        "type": "object",
+        "properties": {
+          "secret": {"type": "string"},
+          "uri": {"type": "string"}
+        }
+      },
+      "MFACode": {
+        "type": "object",
+        "required": ["code"],
+        "properties": {
+          "code": {"type": "string", "minLength": 1}
+        }
+      },
+      "RecoveryCodes": {
+        "type": "object",
+        "properties": {
+          "recoveryCodes": {"type": "array", "items": {"type": "string"}}
+        }
+      },
+      "PasskeyCeremony": {
+        "type": "object",
+        "properties": {
+          "ceremonyId": {"type": "string"},
+          "options": {"type": "object", "description": "Pass options.publicKey to navigator.credentials.create or get"}
+        }
+      },
+      "Passkey": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string", "description": "The base64url encoded credential ID"},
+          "accountId": {"type": "string"},
+          "name": {"type": "string"},
+          "created": {"type": "string", "format": "date-time"},
+          "lastUsed": {"type": "string", "format": "date-time"}
+        }
+      },
+      "PasswordConfirmation": {
+        "type": "object",
+        "required": ["password"],
+        "properties": {
+          "password": {"type": "string", "minLength": 1}
+        }
+      },
+      "ServiceAccount": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string"},
+          "name": {"type": "string"},
+          "description": {"type": "string"},
+          "scopes": {"type": "array", "items": {"type": "string", "enum": ["tickets:read", "tickets:create", "tickets:update", "tickets:delete", "accounts:list", "accounts:manage"]}},
+          "createdBy": {"type": "string"},
+          "created": {"type": "string", "format": "date-time"}
+        }
+      },
+      "ServiceAccountInput": {
+        "type": "object",
+        "required": ["name", "scopes"],
+        "properties": {
+          "name": {"type": "string", "minLength": 1, "maxLength": 64},
+          "description": {"type": "string", "maxLength": 255},
+          "scopes": {"type": "array", "items": {"type": "string", "enum": ["tickets:read", "tickets:create", "tickets:update", "tickets:delete", "accounts:list", "accounts:manage"]}}
+        }
+      },
+      "APIKey": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string"},
+          "serviceAccountId": {"type": "string"},
+          "scopes": {"type": "array", "items": {"type": "string", "enum": ["tickets:read", "tickets:create", "tickets:update", "tickets:delete", "accounts:list", "accounts:manage"]}},
+          "created": {"type": "string", "format": "date-time"},
+          "expires": {"type": "string", "format": "date-time"},
+          "lastUsed": {"type": "string", "format": "date-time"}
+        }
+      },
+      "APIKeyRequest": {
+        "type": "object",
+        "properties": {
+          "scopes": {"type": "array", "items": {"type": "string", "enum": ["tickets:read", "tickets:create", "tickets:update", "tickets:delete", "accounts:list", "accounts:manage"]}},
+          "expiresIn": {"type": "integer", "minimum": 0, "maximum": 315360000}
+        }
+      },
+      "IssuedAPIKey": {
+        "allOf": [
+          {"$ref": "#/components/schemas/APIKey"},
+          {"type": "object", "required": ["key"], "properties": {"key": {"type": "string"}}}
+        ]
+      },
+      "OAuthClient": {
+        "type": "object",
+        "properties": {
+          "clientId": {"type": "string"},
+          "name": {"type": "string"},
+          "redirectUris": {"type": "array", "items": {"type": "string", "format": "uri"}},
+          "public": {"type": "boolean"},
+          "createdBy": {"type": "string"},
+          "created": {"type": "string", "format": "date-time"}
+        }
+      },
+      "OAuthClientInput": {
+        "type": "object",
+        "required": ["name", "redirectUris"],
+        "properties": {
+          "name": {"type": "string", "minLength": 1, "maxLength": 64},
+          "redirectUris": {"type": "array", "minItems": 1, "maxItems": 10, "items": {"type": "string", "format": "uri"}},
+          "public": {"type": "boolean", "description": "Public clients get no secret and authenticate with PKCE alone"}
+        }
+      },
+      "IssuedOAuthClient": {
+        "allOf": [
+          {"$ref": "#/components/schemas/OAuthClient"},
+          {"type": "object", "properties": {"clientSecret": {"type": "string"}}}
+        ]
+      },
+      "Consent": {
+        "type": "object",
+        "properties": {
+          "accountId": {"type": "string"},
+          "clientId": {"type": "string"},
+          "scopes": {"type": "array", "items": {"type": "string"}},
+          "granted": {"type": "string", "format": "date-time"}
+        }
+      },
+      "ConsentPrompt": {
+        "type": "object",
+        "properties": {
+          "clientId": {"type": "string"},
+          "clientName": {"type": "string"},
+          "scopes": {"type": "array", "items": {"type": "string"}},
+          "consented": {"type": "boolean", "description": "Every scope was allowed before"}
+        }
+      },
+      "ConsentDecision": {
+        "type": "object",
+        "required": ["clientId", "redirectUri", "approve"],
+        "properties": {
+          "responseType": {"type": "string"},
+          "clientId": {"type": "string"},
+          "redirectUri": {"type": "string"},
+          "scope": {"type": "string"},
+          "state": {"type": "string"},
+          "nonce": {"type": "string"},
+          "codeChallenge": {"type": "string"},
+          "codeChallengeMethod": {"type": "string"},
+          "approve": {"type": "boolean"}
+        }
+      },
+      "AuthorizationResponse": {
+        "type": "object",
+        "properties": {
+          "redirectTo": {"type": "string", "format": "uri"}
+        }
+      },
+      "Roles": {
+        "type": "object",
+        "properties": {
+          "role": {"type": "string", "enum": ["admin", "member", "viewer"]},
+          "projects": {"type": "object", "additionalProperties": {"type": "string", "enum": ["admin", "member", "viewer"]}}
+        }
+      },
+      "AccountInput": {
+        "type": "object",
+        "required": ["username", "password"],
+        "properties": {
+          "username": {"type": "string", "pattern": "^[a-zA-Z0-9][a-zA-Z0-9_.-]{2,31}$"},
+          "firstName": {"type": "string", "maxLength": 64},
+          "lastName": {"type": "string", "maxLength": 64},
+          "email": {"type": "string", "format": "email", "maxLength": 254},
+          "password": {"type": "string", "format": "password", "minLength": 8, "maxLength": 72}
+        }
+      },
+      "Login": {
+        "type": "object",
+        "properties": {
+          "username": {"type": "string"},
+          "accountId": {"type": "string"},
+          "token": {"type": "string"},
+          "expiresIn": {"type": "integer"},
+          "refreshToken": {"type": "string"},
+          "mfaToken": {"type": "string", "description": "Set instead of tokens when the account has two-factor authentication; exchange it at /auth/mfa"}
+        }
+      },
+      "RefreshRequest": {
+        "type": "object",
+        "required": ["refreshToken"],
+        "properties": {
+          "refreshToken": {"type": "string", "minLength": 1}
+        }
+      },
+      "Problem": {
+        "type": "object",
+        "properties": {
+          "type": {"type": "string"},
+          "title": {"type": "string"},
+          "status": {"type": "integer"},
+          "detail": {"type": "string"},
+          "instance": {"type": "string"},
+          "correlationId": {"type": "string"},
+          "invalid-params": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "name": {"type": "string"},
+                "reason": {"type": "string"}
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
@@ -0,0 +1,156 @@
+// Package problem writes RFC 7807 problem details responses and maps domain
+// errors onto them.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/requestid"
+	"hex-example/internal/validation"
+)
+
+// ContentType is the media type of a problem details response.
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. CorrelationID is an
+// extension member matching the X-Request-ID response header.
+type Problem struct {
+	Type          string         `json:"type,omitempty"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	CorrelationID string         `json:"correlationId,omitempty"`
+	InvalidParams []InvalidParam `json:"invalid-params,omitempty"`
+}
+
+// InvalidParam describes a single request field that failed validation.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Domain errors are classified by behaviour rather than by concrete type so
+// that each domain package can declare its own error types. An error that
+// reports true from one of these methods is mapped to the matching status.
+type (
+	notFound     interface{ NotFound() bool }
+	conflict     interface{ Conflict() bool }
+	unauthorized interface{ Unauthorized() bool }
+	forbidden    interface{ Forbidden() bool }
+	tooMany      interface{ TooManyRequests() bool }
+	// retryAfter errors also set the Retry-After header.
+	retryAfter interface{ RetryAfter() time.Duration }
+)
+
+// New returns a Problem for status with the standard status text as title.
+func New(status int, detail string) *Problem {
+	return &Problem{
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Invalid returns a 400 Problem listing every field in errs.
+func Invalid(errs validation.Errors) *Problem {
+	p := New(http.StatusBadRequest, "One or more fields are invalid")
+	for _, fe := range errs {
+		p.InvalidParams = append(p.InvalidParams, InvalidParam{Name: fe.Field, Reason: fe.Reason})
+	}
+	return p
+}
+
+// StatusOf returns the HTTP status a domain error maps to. Unclassified
+// errors are internal errors.
+func StatusOf(err error) int {
+	var (
+		invalid validation.Errors
+		nf      notFound
+		c       conflict
+		u       unauthorized
+		f       forbidden
+		t       tooMany
+	)
+	switch {
+	case errors.As(err, &invalid):
+		return http.StatusBadRequest
+	case errors.As(err, &nf) && nf.NotFound():
+		return http.StatusNotFound
+	case errors.As(err, &c) && c.Conflict():
+		return http.StatusConflict
+	case errors.As(err, &u) && u.Unauthorized():
+		return http.StatusUnauthorized
+	case errors.As(err, &f) && f.Forbidden():
+		return http.StatusForbidden
+	case errors.As(err, &t) && t.TooManyRequests():
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// FromError maps err onto a Problem. The error message is only exposed for
+// classified domain errors; internal errors get a generic detail.
+func FromError(err error) *Problem {
+	var invalid validation.Errors
+	if errors.As(err, &invalid) {
+		return Invalid(invalid)
+	}
+
+	status := StatusOf(err)
+	if status == http.StatusInternalServerError {
+		return New(status, "An unexpected error occurred")
+	}
+	return New(status, err.Error())
+}
+
+// Error logs err with the request's correlation ID and writes the Problem it
+// maps to.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	p := FromError(err)
+	entry := logrus.WithFields(logrus.Fields{
+		"error":         err,
+		"correlationId": requestid.FromContext(r.Context()),
+	})
+	if p.Status >= http.StatusInternalServerError {
+		entry.Error(p.Detail)
+	} else {
+		entry.Info(p.Detail)
+	}
+	var ra retryAfter
+	if errors.As(err, &ra) {
+		// round up so that clients never retry early
+		seconds := (ra.RetryAfter() + time.Second - 1) / time.Second
+		w.Header().Set("Retry-After", strconv.Itoa(int(seconds)))
+	}
+	Write(w, r, p)
+}
+
+// Write writes p to w as application/problem+json, filling in the request
+// path and correlation ID.
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	if p.CorrelationID == "" {
+		p.CorrelationID = requestid.FromContext(r.Context())
+	}
+
+	response, err := json.Marshal(p)
+	if err != nil {
+		logrus.WithField("error", err).Error("Error marshalling problem")
+		http.Error(w, p.Title, p.Status)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	if _, err := w.Write(response); err != nil {
+		logrus.WithField("error", err).Error("Error writing response")
+	}
+}
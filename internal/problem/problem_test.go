@@ -0,0 +1,81 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"hex-example/internal/problem"
+	"hex-example/internal/rbac"
+	"hex-example/internal/requestid"
+	"hex-example/internal/ticket"
+	"hex-example/internal/user"
+	"hex-example/internal/validation"
+)
+
+func TestStatusOf(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{&ticket.NotFoundError{ID: "1"}, http.StatusNotFound},
+		{&user.NotFoundError{Username: "joel"}, http.StatusNotFound},
+		{&ticket.ConflictError{ID: "1"}, http.StatusConflict},
+		{&user.ConflictError{Username: "joel"}, http.StatusConflict},
+		{user.ErrInvalidLogin, http.StatusUnauthorized},
+		{&rbac.ForbiddenError{Permission: rbac.TicketsCreate}, http.StatusForbidden},
+		{validation.Errors{{Field: "title", Reason: "is required"}}, http.StatusBadRequest},
+		{fmt.Errorf("wrapped: %w", &ticket.NotFoundError{ID: "1"}), http.StatusNotFound},
+		{errors.New("redis: connection refused"), http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.status, problem.StatusOf(c.err), c.err.Error())
+	}
+}
+
+func TestFromError_HidesInternalDetail(t *testing.T) {
+	p := problem.FromError(errors.New("pq: password authentication failed"))
+	assert.Equal(t, http.StatusInternalServerError, p.Status)
+	assert.NotContains(t, p.Detail, "pq")
+}
+
+func TestError_WritesCorrelatedProblem(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/tickets/1", nil)
+	r = r.WithContext(requestid.WithID(r.Context(), "abc-123"))
+	w := httptest.NewRecorder()
+
+	problem.Error(w, r, &ticket.NotFoundError{ID: "1"})
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	result := new(problem.Problem)
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(result))
+	assert.Equal(t, &problem.Problem{
+		Title:         "Not Found",
+		Status:        http.StatusNotFound,
+		Detail:        "ticket 1 not found",
+		Instance:      "/tickets/1",
+		CorrelationID: "abc-123",
+	}, result)
+}
+
+type retryLater struct{}
+
+func (retryLater) Error() string             { return "slow down" }
+func (retryLater) TooManyRequests() bool     { return true }
+func (retryLater) RetryAfter() time.Duration { return 1500 * time.Millisecond }
+
+func TestError_SetsRetryAfter(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/auth", nil)
+	w := httptest.NewRecorder()
+
+	problem.Error(w, r, retryLater{})
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+}
@@ -0,0 +1,120 @@
+// Package rbac defines the roles an account can hold, the permissions they
+// grant and the policy deciding whether a caller may perform an operation.
+package rbac
+
+import "fmt"
+
+// Roles, from most to least privileged.
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+	RoleViewer = "viewer"
+)
+
+// MigratedRole is given by a one-off migration to the accounts that existed
+// before roles did. Accounts created since hold no global role until one is
+// assigned, only their project roles.
+const MigratedRole = RoleMember
+
+// Roles lists every valid role.
+var Roles = []string{RoleAdmin, RoleMember, RoleViewer}
+
+// Permission names an operation routes and resolvers require.
+type Permission string
+
+// Permissions checked by the ticket and user APIs.
+const (
+	TicketsRead   Permission = "tickets:read"
+	TicketsCreate Permission = "tickets:create"
+	TicketsUpdate Permission = "tickets:update"
+	TicketsDelete Permission = "tickets:delete"
+	// AccountsList allows listing every account.
+	AccountsList Permission = "accounts:list"
+	// AccountsManage allows creating accounts for others and changing roles.
+	AccountsManage Permission = "accounts:manage"
+)
+
+// Permissions lists every permission, which are also the scopes an API key
+// can be restricted to.
+var Permissions = []Permission{TicketsRead, TicketsCreate, TicketsUpdate, TicketsDelete, AccountsList, AccountsManage}
+
+var grants = map[string][]Permission{
+	RoleViewer: {TicketsRead},
+	RoleMember: {TicketsRead, TicketsCreate, TicketsUpdate},
+	RoleAdmin:  {TicketsRead, TicketsCreate, TicketsUpdate, TicketsDelete, AccountsList, AccountsManage},
+}
+
+// projectScoped are the permissions a project role can grant. Account
+// administration is never scoped to a project.
+var projectScoped = map[Permission]bool{
+	TicketsRead:   true,
+	TicketsCreate: true,
+	TicketsUpdate: true,
+	TicketsDelete: true,
+}
+
+// ValidRole reports whether role is one of Roles.
+func ValidRole(role string) bool {
+	_, ok := grants[role]
+	return ok
+}
+
+// Grants reports whether role includes permission.
+func Grants(role string, permission Permission) bool {
+	for _, p := range grants[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidPermission reports whether p is one of Permissions.
+func ValidPermission(p string) bool {
+	for _, permission := range Permissions {
+		if string(permission) == p {
+			return true
+		}
+	}
+	return false
+}
+
+// InScopes reports whether permission is one of scopes. Scoped principals
+// such as API keys hold exactly their scopes and no role.
+func InScopes(scopes []string, permission Permission) bool {
+	for _, s := range scopes {
+		if s == string(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed reports whether a caller with the global role and the per-project
+// roles in projects holds permission in project. An empty project checks
+// the global role only.
+func Allowed(role string, projects map[string]string, permission Permission, project string) bool {
+	if Grants(role, permission) {
+		return true
+	}
+	if project == "" || !projectScoped[permission] {
+		return false
+	}
+	return Grants(projects[project], permission)
+}
+
+// ForbiddenError is returned when an authenticated caller lacks a
+// permission.
+type ForbiddenError struct {
+	Permission Permission
+	Project    string
+}
+
+func (e *ForbiddenError) Error() string {
+	if e.Project != "" {
+		return fmt.Sprintf("Permission %s required in project %s", e.Permission, e.Project)
+	}
+	return fmt.Sprintf("Permission %s required", e.Permission)
+}
+
+func (e *ForbiddenError) Forbidden() bool { return true }
@@ -0,0 +1,43 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrants(t *testing.T) {
+	assert.True(t, Grants(RoleViewer, TicketsRead))
+	assert.False(t, Grants(RoleViewer, TicketsCreate))
+	assert.True(t, Grants(RoleMember, TicketsUpdate))
+	assert.False(t, Grants(RoleMember, TicketsDelete))
+	assert.True(t, Grants(RoleAdmin, AccountsManage))
+	assert.False(t, Grants("", TicketsRead))
+	assert.False(t, Grants("root", TicketsRead))
+}
+
+func TestAllowed(t *testing.T) {
+	projects := map[string]string{"infra": RoleAdmin, "web": RoleViewer}
+
+	assert.True(t, Allowed(RoleViewer, projects, TicketsDelete, "infra"), "project role adds to the global role")
+	assert.False(t, Allowed(RoleViewer, projects, TicketsDelete, "web"))
+	assert.False(t, Allowed(RoleViewer, projects, TicketsDelete, ""), "project roles need a project")
+	assert.False(t, Allowed(RoleViewer, projects, AccountsManage, "infra"), "account administration is global only")
+	assert.True(t, Allowed(RoleMember, nil, TicketsCreate, "web"), "the global role applies in every project")
+}
+
+func TestValidRole(t *testing.T) {
+	for _, role := range Roles {
+		assert.True(t, ValidRole(role))
+	}
+	assert.False(t, ValidRole(""))
+	assert.False(t, ValidRole("owner"))
+}
+
+func TestInScopes(t *testing.T) {
+	assert.True(t, InScopes([]string{"tickets:read", "tickets:create"}, TicketsCreate))
+	assert.False(t, InScopes([]string{"tickets:read"}, TicketsCreate))
+	assert.False(t, InScopes(nil, TicketsRead))
+	assert.True(t, ValidPermission("accounts:list"))
+	assert.False(t, ValidPermission("tickets:*"))
+}
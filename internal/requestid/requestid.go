@@ -0,0 +1,48 @@
+// Package requestid assigns every request a correlation ID that is echoed
+// in the response, attached to error responses and available to handlers.
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+// Header carries the correlation ID on requests and responses.
+const Header = "X-Request-ID"
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	idKey key = iota
+)
+
+// callers may pass their own ID as long as it is reasonably shaped
+var validID = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,128}$`)
+
+// Handler reads the correlation ID from the request header, or generates a
+// new one, adds it to the ctx and echoes it in the response header.
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !validID.MatchString(id) {
+			id = uuid.New().String()
+		}
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(WithID(r.Context(), id)))
+	})
+}
+
+// WithID returns a copy of ctx that stores the correlation ID.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey, id)
+}
+
+// FromContext returns the correlation ID from the ctx, or "" if there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(idKey).(string)
+	return id
+}
@@ -0,0 +1,45 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_GeneratesID(t *testing.T) {
+	var seen string
+	next := func(w http.ResponseWriter, r *http.Request) {
+		seen = FromContext(r.Context())
+	}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	Handler(http.HandlerFunc(next)).ServeHTTP(w, r)
+
+	assert.NotEmpty(t, seen)
+	assert.Equal(t, seen, w.Header().Get(Header))
+}
+
+func TestHandler_KeepsCallerID(t *testing.T) {
+	var seen string
+	next := func(w http.ResponseWriter, r *http.Request) {
+		seen = FromContext(r.Context())
+	}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set(Header, "abc-123")
+	Handler(http.HandlerFunc(next)).ServeHTTP(w, r)
+
+	assert.Equal(t, "abc-123", seen)
+	assert.Equal(t, "abc-123", w.Header().Get(Header))
+}
+
+func TestHandler_ReplacesMalformedID(t *testing.T) {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set(Header, "not valid\nid")
+	Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+
+	assert.NotEqual(t, "not valid\nid", w.Header().Get(Header))
+}
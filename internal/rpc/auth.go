@@ -0,0 +1,83 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"hex-example/internal/middleware"
+)
+
+// UnaryAuthInterceptor validates the bearer token in the "authorization"
+// metadata of every unary call, except for the given public methods, with the
+// same rules as middleware.Authenticate, and passes its claims on in the
+// context.
+func UnaryAuthInterceptor(validator middleware.TokenValidator, public ...string) grpc.UnaryServerInterceptor {
+	skip := publicMethods(public)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !skip[info.FullMethod] {
+			claims, err := authenticate(validator, ctx)
+			if err != nil {
+				return nil, err
+			}
+			ctx = middleware.WithClaims(ctx, claims)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthInterceptor is the streaming counterpart of UnaryAuthInterceptor.
+func StreamAuthInterceptor(validator middleware.TokenValidator, public ...string) grpc.StreamServerInterceptor {
+	skip := publicMethods(public)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !skip[info.FullMethod] {
+			claims, err := authenticate(validator, ss.Context())
+			if err != nil {
+				return err
+			}
+			ss = &authenticatedStream{ss, middleware.WithClaims(ss.Context(), claims)}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// authenticatedStream overrides the context of a stream with one carrying
+// the caller's claims.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func publicMethods(methods []string) map[string]bool {
+	skip := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		skip[m] = true
+	}
+	return skip
+}
+
+// authenticate returns the claims of the bearer token in ctx, or an
+// Unauthenticated status unless it is valid.
+func authenticate(validator middleware.TokenValidator, ctx context.Context) (*middleware.Claims, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || values[0] == "" {
+		return nil, status.Error(codes.Unauthenticated, "Authorization Header Required")
+	}
+	tokenString := strings.Replace(values[0], "Bearer ", "", -1)
+
+	claims, err := validator.Validate(tokenString)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	logrus.WithField("UserId", claims.Subject).Info("Validated user")
+	return claims, nil
+}
@@ -0,0 +1,52 @@
+package rpc
+
+import (
+	"errors"
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"hex-example/internal/problem"
+	"hex-example/internal/validation"
+)
+
+// toStatus converts a service error into a gRPC status with the same
+// classification problem.StatusOf uses for HTTP. Validation failures become
+// InvalidArgument with a BadRequest detail per field; unclassified errors
+// become code with msg.
+func toStatus(err error, code codes.Code, msg string) error {
+	switch problem.StatusOf(err) {
+	case http.StatusBadRequest:
+		return invalidArgument(err)
+	case http.StatusNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case http.StatusConflict:
+		return status.Error(codes.AlreadyExists, err.Error())
+	case http.StatusUnauthorized:
+		return status.Error(codes.Unauthenticated, err.Error())
+	case http.StatusForbidden:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case http.StatusTooManyRequests:
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return status.Error(code, msg)
+}
+
+func invalidArgument(err error) error {
+	var invalid validation.Errors
+	errors.As(err, &invalid)
+
+	br := &errdetails.BadRequest{}
+	for _, fe := range invalid {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       fe.Field,
+			Description: fe.Reason,
+		})
+	}
+	st, detailErr := status.New(codes.InvalidArgument, "One or more fields are invalid").WithDetails(br)
+	if detailErr != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return st.Err()
+}
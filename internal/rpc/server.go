@@ -0,0 +1,30 @@
+// Package rpc serves the ticket and user services over gRPC.
+package rpc
+
+import (
+	"net"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"hex-example/internal/middleware"
+)
+
+// NewServer returns a gRPC server that authenticates every call except the
+// given public methods with validator.
+func NewServer(validator middleware.TokenValidator, public ...string) *grpc.Server {
+	return grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryAuthInterceptor(validator, public...)),
+		grpc.StreamInterceptor(StreamAuthInterceptor(validator, public...)),
+	)
+}
+
+// ListenAndServe listens on the TCP network address addr and serves gRPC
+// requests with server.
+func ListenAndServe(addr string, server *grpc.Server) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Listening grpc mode on port %s", addr)
+	return server.Serve(lis)
+}
@@ -0,0 +1,153 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"hex-example/internal/jwks"
+	"hex-example/internal/middleware"
+	"hex-example/internal/mocks"
+	"hex-example/internal/rbac"
+	"hex-example/internal/ticket"
+	"hex-example/pkg/api/ticketpb"
+)
+
+// testKeys signs the tokens presented by the test clients.
+var testKeys = func() *jwks.KeyRing {
+	keys, err := jwks.NewKeyRing(jwks.NewMemoryStore(), jwks.ES256, time.Hour, time.Hour, 0)
+	if err != nil {
+		panic(err)
+	}
+	return keys
+}()
+
+// newTicketTestClient serves service over an in-memory listener and returns
+// a generated client connected to it. The caller must call the returned
+// close function.
+func newTicketTestClient(t *testing.T, service ticket.TicketService) (ticketpb.TicketServiceClient, func()) {
+	lis := bufconn.Listen(1024 * 1024)
+	server := NewServer(middleware.NewTokenValidator(testKeys, middleware.Config{}))
+	ticketpb.RegisterTicketServiceServer(server, NewTicketServer(service))
+	go server.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ticketpb.NewTicketServiceClient(conn), func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func withToken(t *testing.T, ctx context.Context) context.Context {
+	return withRole(t, ctx, rbac.RoleMember)
+}
+
+func withRole(t *testing.T, ctx context.Context, role string) context.Context {
+	signed, err := testKeys.Sign(jwt.MapClaims{
+		"sub":  "test",
+		"type": "user",
+		"exp":  time.Now().Add(time.Hour).Unix(),
+		"role": role,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+signed)
+}
+
+func TestTicketServer_RequiresToken(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client, closeFn := newTicketTestClient(t, mocks.NewMockTicketService(mockCtrl))
+	defer closeFn()
+
+	_, err := client.FindTicketById(context.Background(), &ticketpb.FindTicketByIdRequest{Id: "test"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestTicketServer_RejectsHMACToken(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client, closeFn := newTicketTestClient(t, mocks.NewMockTicketService(mockCtrl))
+	defer closeFn()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":  "test",
+		"type": "user",
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	})
+	signed, _ := token.SignedString([]byte("secret"))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+signed)
+
+	_, err := client.FindTicketById(ctx, &ticketpb.FindTicketByIdRequest{Id: "test"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestTicketServer_CreateTicketForbiddenForViewer(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client, closeFn := newTicketTestClient(t, mocks.NewMockTicketService(mockCtrl))
+	defer closeFn()
+
+	_, err := client.CreateTicket(withRole(t, context.Background(), rbac.RoleViewer), &ticketpb.CreateTicketRequest{Creator: "joel", Title: "Test"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestTicketServer_FindTicketById(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	service := mocks.NewMockTicketService(mockCtrl)
+	service.EXPECT().FindTicketById("test").Return(&ticket.Ticket{ID: "test", Creator: "Joel"}, nil)
+	client, closeFn := newTicketTestClient(t, service)
+	defer closeFn()
+
+	result, err := client.FindTicketById(withToken(t, context.Background()), &ticketpb.FindTicketByIdRequest{Id: "test"})
+	assert.Nil(t, err)
+	assert.Equal(t, "Joel", result.Creator)
+}
+
+func TestTicketServer_FindAllTicketsStreams(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	service := mocks.NewMockTicketService(mockCtrl)
+	service.EXPECT().FindAllTickets().Return([]*ticket.Ticket{{ID: "test1"}, {ID: "test2"}}, nil)
+	client, closeFn := newTicketTestClient(t, service)
+	defer closeFn()
+
+	stream, err := client.FindAllTickets(withToken(t, context.Background()), &ticketpb.FindAllTicketsRequest{})
+	assert.Nil(t, err)
+
+	var ids []string
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if !assert.Nil(t, err) {
+			return
+		}
+		ids = append(ids, msg.Id)
+	}
+	assert.Equal(t, []string{"test1", "test2"}, ids)
+}
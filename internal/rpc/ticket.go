@@ -0,0 +1,85 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"hex-example/internal/middleware"
+	"hex-example/internal/rbac"
+	"hex-example/internal/ticket"
+	"hex-example/pkg/api/ticketpb"
+)
+
+type ticketServer struct {
+	ticketpb.UnimplementedTicketServiceServer
+	ticketService ticket.TicketService
+}
+
+func NewTicketServer(ticketService ticket.TicketService) ticketpb.TicketServiceServer {
+	return &ticketServer{
+		ticketService: ticketService,
+	}
+}
+
+func (s *ticketServer) CreateTicket(ctx context.Context, req *ticketpb.CreateTicketRequest) (*ticketpb.Ticket, error) {
+	if err := middleware.Authorize(ctx, rbac.TicketsCreate, ""); err != nil {
+		return nil, toStatus(err, codes.PermissionDenied, err.Error())
+	}
+	t := &ticket.Ticket{
+		Creator:     req.Creator,
+		Assigned:    req.Assigned,
+		Title:       req.Title,
+		Description: req.Description,
+		Points:      int(req.Points),
+	}
+	if err := s.ticketService.CreateTicket(t); err != nil {
+		logrus.WithField("error", err).Error("Unable to create ticket")
+		return nil, toStatus(err, codes.Internal, "Unable to create ticket")
+	}
+	return toTicketMessage(t), nil
+}
+
+func (s *ticketServer) FindTicketById(ctx context.Context, req *ticketpb.FindTicketByIdRequest) (*ticketpb.Ticket, error) {
+	if err := middleware.Authorize(ctx, rbac.TicketsRead, ""); err != nil {
+		return nil, toStatus(err, codes.PermissionDenied, err.Error())
+	}
+	t, err := s.ticketService.FindTicketById(req.Id)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"error": err, "id": req.Id}).Error("Unable to find ticket")
+		return nil, toStatus(err, codes.Internal, "Unable to find ticket")
+	}
+	return toTicketMessage(t), nil
+}
+
+func (s *ticketServer) FindAllTickets(req *ticketpb.FindAllTicketsRequest, stream ticketpb.TicketService_FindAllTicketsServer) error {
+	if err := middleware.Authorize(stream.Context(), rbac.TicketsRead, ""); err != nil {
+		return toStatus(err, codes.PermissionDenied, err.Error())
+	}
+	tickets, err := s.ticketService.FindAllTickets()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to find all tickets")
+		return toStatus(err, codes.Internal, "Unable to find all tickets")
+	}
+	for _, t := range tickets {
+		if err := stream.Send(toTicketMessage(t)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toTicketMessage(t *ticket.Ticket) *ticketpb.Ticket {
+	return &ticketpb.Ticket{
+		Id:          t.ID,
+		Creator:     t.Creator,
+		Assigned:    t.Assigned,
+		Title:       t.Title,
+		Description: t.Description,
+		Status:      t.Status,
+		Points:      int32(t.Points),
+		Created:     timestamppb.New(t.Created),
+		Updated:     timestamppb.New(t.Updated),
+	}
+}
@@ -0,0 +1,109 @@
+package rpc
+
+import (
+	"context"
+	"net"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"hex-example/internal/user"
+	"hex-example/pkg/api/userpb"
+)
+
+// UserPublicMethods are the UserService methods callable without a token.
+var UserPublicMethods = []string{
+	userpb.UserService_CreateAccount_FullMethodName,
+	userpb.UserService_Login_FullMethodName,
+}
+
+type userServer struct {
+	userpb.UnimplementedUserServiceServer
+	service user.UserService
+	guard   user.LoginGuard
+}
+
+// NewUserServer returns a UserServiceServer whose logins go through guard.
+func NewUserServer(service user.UserService, guard user.LoginGuard) userpb.UserServiceServer {
+	return &userServer{
+		service: service,
+		guard:   guard,
+	}
+}
+
+func (s *userServer) CreateAccount(ctx context.Context, req *userpb.CreateAccountRequest) (*userpb.Account, error) {
+	account := &user.Account{
+		Username:  req.Username,
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Password:  req.Password,
+	}
+	if err := s.service.CreateAccount(account); err != nil {
+		logrus.WithField("error", err).Error("Unable to create account")
+		return nil, toStatus(err, codes.Internal, "Unable to create account")
+	}
+	return toAccountMessage(account), nil
+}
+
+func (s *userServer) Login(ctx context.Context, req *userpb.LoginRequest) (*userpb.LoginResponse, error) {
+	if req.Username == "" || req.Password == "" {
+		return nil, status.Error(codes.Unauthenticated, "No credentials provided")
+	}
+
+	login, err := s.guard.Login(req.Username, req.Password, peerIP(ctx))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error":    err,
+			"username": req.Username,
+		}).Error("Error generating token")
+		return nil, toStatus(err, codes.Internal, "Unable to login")
+	}
+	if login.MFAToken != "" {
+		// the second step is only offered over HTTP
+		return nil, status.Error(codes.FailedPrecondition, "Two-factor authentication required, log in with POST /auth and /auth/mfa")
+	}
+	return &userpb.LoginResponse{
+		Username: login.Username,
+		Token:    login.Token,
+	}, nil
+}
+
+func (s *userServer) FindAccounts(req *userpb.FindAccountsRequest, stream userpb.UserService_FindAccountsServer) error {
+	accounts, err := s.service.FindAccounts(req.Usernames)
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to find accounts")
+		return toStatus(err, codes.Internal, "Unable to find accounts")
+	}
+	for _, account := range accounts {
+		if account == nil {
+			continue
+		}
+		if err := stream.Send(toAccountMessage(account)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toAccountMessage(account *user.Account) *userpb.Account {
+	return &userpb.Account{
+		Id:        account.ID,
+		Username:  account.Username,
+		FirstName: account.FirstName,
+		LastName:  account.LastName,
+	}
+}
+
+// peerIP returns the IP address of the calling client.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,77 @@
+package sessions
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gorilla/securecookie"
+)
+
+const (
+	hashKeyLen  = 64 // HMAC-SHA256 key
+	blockKeyLen = 32 // AES-256 key
+)
+
+// GenerateKeyPair returns a new random hash key, to sign cookies, and block
+// key, to encrypt them.
+func GenerateKeyPair() (hashKey, blockKey []byte, err error) {
+	hashKey = securecookie.GenerateRandomKey(hashKeyLen)
+	blockKey = securecookie.GenerateRandomKey(blockKeyLen)
+	if hashKey == nil || blockKey == nil {
+		return nil, nil, errors.New("sessions: unable to generate keys")
+	}
+	return hashKey, blockKey, nil
+}
+
+// EncodeKeyPairs formats hash and block key pairs for configuration as
+// comma separated "hash:block" pairs of base64 keys, in the order given.
+func EncodeKeyPairs(keyPairs ...[]byte) string {
+	pairs := make([]string, 0, (len(keyPairs)+1)/2)
+	for i := 0; i < len(keyPairs); i += 2 {
+		pair := base64.StdEncoding.EncodeToString(keyPairs[i]) + ":"
+		if i+1 < len(keyPairs) {
+			pair += base64.StdEncoding.EncodeToString(keyPairs[i+1])
+		}
+		pairs = append(pairs, pair)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ParseKeyPairs parses key pairs formatted by EncodeKeyPairs into the
+// keyPairs of NewCookieStore and NewServerStore. The first pair is the
+// current one, used to encode cookies, and the rest only decode cookies
+// encoded before the keys were rotated. Every pair needs a block key, so
+// that cookies are always encrypted.
+func ParseKeyPairs(s string) ([][]byte, error) {
+	var keyPairs [][]byte
+	for i, pair := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(pair), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("sessions: key pair %d is not hash:block", i+1)
+		}
+		hashKey, err := base64.StdEncoding.DecodeString(parts[0])
+		if err != nil || len(hashKey) < 32 {
+			return nil, fmt.Errorf("sessions: hash key %d must be at least 32 bytes of base64", i+1)
+		}
+		blockKey, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil || (len(blockKey) != 16 && len(blockKey) != 24 && len(blockKey) != 32) {
+			return nil, fmt.Errorf("sessions: block key %d must be 16, 24 or 32 bytes of base64", i+1)
+		}
+		keyPairs = append(keyPairs, hashKey, blockKey)
+	}
+	return keyPairs, nil
+}
+
+// decodeMulti decodes value like securecookie.DecodeMulti and reports
+// whether it was encoded with an old key, i.e. not by the first codec.
+func decodeMulti(name, value string, dst interface{}, codecs ...securecookie.Codec) (bool, error) {
+	if len(codecs) > 0 && codecs[0].Decode(name, value, dst) == nil {
+		return false, nil
+	}
+	if len(codecs) < 2 {
+		return false, securecookie.DecodeMulti(name, value, dst, codecs...)
+	}
+	return true, securecookie.DecodeMulti(name, value, dst, codecs[1:]...)
+}
@@ -0,0 +1,104 @@
+package sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKeyPairs(t *testing.T, n int) [][]byte {
+	var keyPairs [][]byte
+	for i := 0; i < n; i++ {
+		hashKey, blockKey, err := GenerateKeyPair()
+		require.Nil(t, err)
+		keyPairs = append(keyPairs, hashKey, blockKey)
+	}
+	return keyPairs
+}
+
+func TestParseKeyPairs(t *testing.T) {
+	keyPairs := newKeyPairs(t, 2)
+	parsed, err := ParseKeyPairs(EncodeKeyPairs(keyPairs...))
+	require.Nil(t, err)
+	assert.Equal(t, keyPairs, parsed)
+}
+
+func TestParseKeyPairs_Invalid(t *testing.T) {
+	keyPairs := newKeyPairs(t, 1)
+	cases := []string{
+		"",
+		"not base64:not base64",
+		EncodeKeyPairs(keyPairs[0]), // missing block key
+		EncodeKeyPairs(keyPairs[0], []byte("short")), // invalid AES key
+		EncodeKeyPairs([]byte("short"), keyPairs[1]),
+	}
+	for _, c := range cases {
+		_, err := ParseKeyPairs(c)
+		assert.Error(t, err, c)
+	}
+}
+
+func TestCookieStore_Encrypted(t *testing.T) {
+	store := NewCookieStore(newKeyPairs(t, 1)...)
+	session := store.New("app")
+	session.Values["user"] = "joel"
+	w := httptest.NewRecorder()
+	require.Nil(t, session.Save(w))
+
+	cookie := w.Result().Cookies()[0]
+	assert.NotContains(t, cookie.Value, "joel", "values are encrypted")
+}
+
+func TestCookieStore_Rotation(t *testing.T) {
+	oldKeys := newKeyPairs(t, 1)
+	newKeys := newKeyPairs(t, 1)
+	old := NewCookieStore(oldKeys...)
+	session := old.New("app")
+	session.Values["user"] = "joel"
+	w := httptest.NewRecorder()
+	require.Nil(t, session.Save(w))
+
+	store := NewCookieStore(append(newKeys, oldKeys...)...)
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(w.Result().Cookies()[0])
+	session, err := store.Get(req, "app")
+	require.Nil(t, err)
+	assert.Equal(t, "joel", session.Values["user"])
+	assert.True(t, session.Stale(), "encoded with the old key")
+
+	w = httptest.NewRecorder()
+	require.Nil(t, session.Save(w))
+	assert.False(t, session.Stale())
+
+	// the re-encoded cookie no longer needs the old key
+	current := NewCookieStore(newKeys...)
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.AddCookie(w.Result().Cookies()[0])
+	session, err = current.Get(req, "app")
+	require.Nil(t, err)
+	assert.Equal(t, "joel", session.Values["user"])
+	assert.False(t, session.Stale())
+}
+
+func TestServerStore_Rotation(t *testing.T) {
+	backend := newFakeBackend()
+	oldKeys := newKeyPairs(t, 1)
+	old := NewServerStore(backend, oldKeys...)
+	cookie := login(t, old, "joel")
+
+	store := NewServerStore(backend, append(newKeyPairs(t, 1), oldKeys...)...)
+	session, err := get(store, cookie)
+	require.Nil(t, err)
+	assert.True(t, session.Stale())
+	id := session.id
+
+	w := httptest.NewRecorder()
+	require.Nil(t, session.Save(w))
+	session, err = get(store, w.Result().Cookies()[0])
+	require.Nil(t, err)
+	assert.False(t, session.Stale())
+	assert.Equal(t, id, session.id, "rotating keys keeps the session")
+}
@@ -0,0 +1,169 @@
+package sessions
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/securecookie"
+)
+
+// ErrSessionNotFound is returned by Backends for unknown or expired
+// sessions.
+var ErrSessionNotFound = errors.New("sessions: session not found")
+
+// Backend persists the Values of server-side sessions.
+type Backend interface {
+	// SaveSession stores values under id until ttl passes, replacing any
+	// previous values. userID may be empty.
+	SaveSession(id, userID string, values []byte, ttl time.Duration) error
+	// GetSession returns ErrSessionNotFound unless a session with id is
+	// stored.
+	GetSession(id string) ([]byte, error)
+	// TouchSession resets the ttl of the session.
+	TouchSession(id string, ttl time.Duration) error
+	DeleteSession(id string) error
+	// DeleteUserSessions deletes every session of userID.
+	DeleteUserSessions(userID string) error
+}
+
+// ServerStore stores Sessions server-side in a Backend. The cookie only
+// carries the signed session ID, so sessions can be revoked and are not
+// limited in size.
+type ServerStore struct {
+	// encodes and decodes signed and optionally encrypted session IDs
+	Codecs []securecookie.Codec
+	// configures session cookie properties of new Sessions. MaxAge is the
+	// ttl of sessions, extended whenever a session is read.
+	Config *Config
+	// UserKey names the string Value holding the user of a session, for
+	// DestroyUser. Sessions are not tied to users when empty.
+	UserKey string
+	backend Backend
+}
+
+// NewServerStore returns a new ServerStore keeping sessions in backend and
+// signing, and optionally encrypting, session IDs in cookies.
+func NewServerStore(backend Backend, keyPairs ...[]byte) *ServerStore {
+	return &ServerStore{
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Config: &Config{
+			Path:     "/",
+			MaxAge:   defaultMaxAge,
+			HTTPOnly: true,
+			SameSite: http.SameSiteDefaultMode,
+		},
+		backend: backend,
+	}
+}
+
+// New returns a new Session with the requested name and the store's config
+// value. It gets a fresh ID when saved.
+func (s *ServerStore) New(name string) *Session {
+	session := NewSession(s, name)
+	config := *s.Config
+	session.Config = &config
+	return session
+}
+
+// Get returns the named Session from the Request and extends its ttl.
+// Returns an error if the session cookie cannot be found, the cookie
+// verification fails, or the session expired or was destroyed.
+func (s *ServerStore) Get(req *http.Request, name string) (*Session, error) {
+	cookie, err := req.Cookie(name)
+	if err != nil {
+		return nil, err
+	}
+	var id string
+	stale, err := decodeMulti(name, cookie.Value, &id, s.Codecs...)
+	if err != nil {
+		return nil, err
+	}
+	values, err := s.backend.GetSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	session := s.New(name)
+	session.id = id
+	session.stale = stale
+	if err := gob.NewDecoder(bytes.NewReader(values)).Decode(&session.Values); err != nil {
+		return nil, err
+	}
+	if err := s.backend.TouchSession(id, s.ttl()); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Save stores the Session Values in the backend and sets the cookie with
+// the signed session ID. The session Config sets cookie properties.
+func (s *ServerStore) Save(w http.ResponseWriter, session *Session) error {
+	if session.id == "" {
+		id, err := newSessionID()
+		if err != nil {
+			return err
+		}
+		session.id = id
+	}
+	var values bytes.Buffer
+	if err := gob.NewEncoder(&values).Encode(session.Values); err != nil {
+		return err
+	}
+	var userID string
+	if s.UserKey != "" {
+		userID, _ = session.Values[s.UserKey].(string)
+	}
+	if err := s.backend.SaveSession(session.id, userID, values.Bytes(), s.ttl()); err != nil {
+		return err
+	}
+
+	cookieValue, err := securecookie.EncodeMulti(session.Name(), session.id, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, newCookie(session.Name(), cookieValue, session.Config))
+	session.stale = false
+	return nil
+}
+
+// Destroy deletes the session cookie with the given name. The session
+// itself is only deleted by DestroySession, Session.Destroy or DestroyUser.
+func (s *ServerStore) Destroy(w http.ResponseWriter, name string) {
+	http.SetCookie(w, newCookie(name, "", &Config{MaxAge: -1, Path: s.Config.Path}))
+}
+
+// DestroySession deletes the Session server-side and its cookie.
+func (s *ServerStore) DestroySession(w http.ResponseWriter, session *Session) error {
+	s.Destroy(w, session.Name())
+	if session.id == "" {
+		return nil
+	}
+	return s.backend.DeleteSession(session.id)
+}
+
+// DestroyUser deletes every session of userID, logging them out everywhere.
+func (s *ServerStore) DestroyUser(userID string) error {
+	return s.backend.DeleteUserSessions(userID)
+}
+
+// ttl returns how long sessions live without being read.
+func (s *ServerStore) ttl() time.Duration {
+	if s.Config.MaxAge > 0 {
+		return time.Duration(s.Config.MaxAge) * time.Second
+	}
+	return defaultMaxAge * time.Second
+}
+
+// newSessionID returns a non-guessable session ID.
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
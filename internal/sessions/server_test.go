@@ -0,0 +1,183 @@
+package sessions
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackend is a Backend backed by maps. Sessions do not expire.
+type fakeBackend struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	users  map[string]string
+	ttls   map[string]time.Duration
+	// deleteErr fails deletes when set
+	deleteErr error
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		values: make(map[string][]byte),
+		users:  make(map[string]string),
+		ttls:   make(map[string]time.Duration),
+	}
+}
+
+func (b *fakeBackend) SaveSession(id, userID string, values []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.values[id] = values
+	b.users[id] = userID
+	b.ttls[id] = ttl
+	return nil
+}
+
+func (b *fakeBackend) GetSession(id string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	values, ok := b.values[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return values, nil
+}
+
+func (b *fakeBackend) TouchSession(id string, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ttls[id] = ttl
+	return nil
+}
+
+func (b *fakeBackend) DeleteSession(id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.deleteErr != nil {
+		return b.deleteErr
+	}
+	delete(b.values, id)
+	delete(b.users, id)
+	return nil
+}
+
+func (b *fakeBackend) DeleteUserSessions(userID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, user := range b.users {
+		if user == userID {
+			delete(b.values, id)
+			delete(b.users, id)
+		}
+	}
+	return nil
+}
+
+// login saves a new session of username and returns its cookie.
+func login(t *testing.T, store *ServerStore, username string) *http.Cookie {
+	session := store.New("app")
+	session.Values["user"] = username
+	w := httptest.NewRecorder()
+	require.Nil(t, session.Save(w))
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	return cookies[0]
+}
+
+func get(store *ServerStore, cookie *http.Cookie) (*Session, error) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	return store.Get(req, "app")
+}
+
+func newTestStore(backend Backend) *ServerStore {
+	store := NewServerStore(backend, []byte("signing secret"))
+	store.UserKey = "user"
+	return store
+}
+
+func TestServerStore(t *testing.T) {
+	backend := newFakeBackend()
+	store := newTestStore(backend)
+	cookie := login(t, store, "joel")
+	assert.True(t, cookie.HttpOnly)
+	assert.NotContains(t, cookie.Value, "joel", "values stay server-side")
+
+	session, err := get(store, cookie)
+	require.Nil(t, err)
+	assert.Equal(t, "joel", session.Values["user"])
+	assert.Equal(t, time.Duration(defaultMaxAge)*time.Second, backend.ttls[session.id])
+
+	// saving again keeps the session ID
+	session.Values["theme"] = "dark"
+	w := httptest.NewRecorder()
+	require.Nil(t, session.Save(w))
+	assert.Len(t, backend.values, 1)
+	session, err = get(store, w.Result().Cookies()[0])
+	require.Nil(t, err)
+	assert.Equal(t, "dark", session.Values["theme"])
+}
+
+func TestServerStore_InvalidCookie(t *testing.T) {
+	store := newTestStore(newFakeBackend())
+	cookie := login(t, store, "joel")
+
+	other := newTestStore(newFakeBackend())
+	other.Codecs = NewCookieStore([]byte("other secret")).Codecs
+	_, err := get(other, cookie)
+	assert.Error(t, err, "the session ID is signed")
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	_, err = store.Get(req, "app")
+	assert.Equal(t, http.ErrNoCookie, err)
+}
+
+func TestServerStore_Destroy(t *testing.T) {
+	store := newTestStore(newFakeBackend())
+	cookie := login(t, store, "joel")
+	session, err := get(store, cookie)
+	require.Nil(t, err)
+
+	w := httptest.NewRecorder()
+	require.Nil(t, session.Destroy(w))
+	expired := w.Result().Cookies()
+	if assert.Len(t, expired, 1) {
+		assert.Equal(t, -1, expired[0].MaxAge)
+	}
+	_, err = get(store, cookie)
+	assert.Equal(t, ErrSessionNotFound, err, "a copied cookie no longer works")
+}
+
+func TestServerStore_DestroyFailure(t *testing.T) {
+	backend := newFakeBackend()
+	store := newTestStore(backend)
+	cookie := login(t, store, "joel")
+	session, err := get(store, cookie)
+	require.Nil(t, err)
+
+	backend.deleteErr = errors.New("connection refused")
+	assert.Equal(t, backend.deleteErr, session.Destroy(httptest.NewRecorder()))
+	_, err = get(store, cookie)
+	assert.Nil(t, err, "the session is still alive")
+}
+
+func TestServerStore_DestroyUser(t *testing.T) {
+	store := newTestStore(newFakeBackend())
+	laptop := login(t, store, "joel")
+	phone := login(t, store, "joel")
+	other := login(t, store, "ivy")
+
+	require.Nil(t, store.DestroyUser("joel"))
+	_, err := get(store, laptop)
+	assert.Equal(t, ErrSessionNotFound, err)
+	_, err = get(store, phone)
+	assert.Equal(t, ErrSessionNotFound, err)
+	_, err = get(store, other)
+	assert.Nil(t, err)
+}
@@ -28,9 +28,11 @@ type Config struct {
 // Session represents Values state which  a named bundle of maintained web state
 // stores web session state
 type Session struct {
+	id     string  // server-side session ID, empty until saved to a ServerStore
 	name   string  // session cookie name
 	Config *Config // session cookie config
 	store  Store   // session store
+	stale  bool    // cookie encoded with an old key
 	Values map[string]interface{}
 }
 
@@ -48,6 +50,12 @@ func (s *Session) Name() string {
 	return s.name
 }
 
+// Stale reports whether the session cookie was encoded with an old key.
+// Saving the session re-encodes it with the current key.
+func (s *Session) Stale() bool {
+	return s.stale
+}
+
 // Save adds or updates the session. Identical to calling
 // store.Save(w, session).
 func (s *Session) Save(w http.ResponseWriter) error {
@@ -55,7 +63,12 @@ func (s *Session) Save(w http.ResponseWriter) error {
 }
 
 // Destroy destroys the session. Identical to calling
-// store.Destroy(w, session.name).
-func (s *Session) Destroy(w http.ResponseWriter) {
+// store.Destroy(w, session.name), except that sessions of a ServerStore are
+// deleted server-side as well, which may fail.
+func (s *Session) Destroy(w http.ResponseWriter) error {
+	if server, ok := s.store.(*ServerStore); ok {
+		return server.DestroySession(w, s)
+	}
 	s.store.Destroy(w, s.name)
+	return nil
 }
@@ -24,7 +24,9 @@ type CookieStore struct {
 }
 
 // NewCookieStore returns a new CookieStore which signs and optionally encrypts
-// session cookies.
+// session cookies. keyPairs are hash and block key pairs, as returned by
+// ParseKeyPairs: cookies are encoded with the first pair and decoded with
+// any, so keys can be rotated without logging everyone out.
 func NewCookieStore(keyPairs ...[]byte) *CookieStore {
 	return &CookieStore{
 		Codecs: securecookie.CodecsFromPairs(keyPairs...),
@@ -53,7 +55,7 @@ func (s *CookieStore) Get(req *http.Request, name string) (session *Session, err
 	cookie, err := req.Cookie(name)
 	if err == nil {
 		session = s.New(name)
-		err = securecookie.DecodeMulti(name, cookie.Value, &session.Values, s.Codecs...)
+		session.stale, err = decodeMulti(name, cookie.Value, &session.Values, s.Codecs...)
 	}
 	return session, err
 }
@@ -67,6 +69,7 @@ func (s *CookieStore) Save(w http.ResponseWriter, session *Session) error {
 		return err
 	}
 	http.SetCookie(w, newCookie(session.Name(), cookieValue, session.Config))
+	session.stale = false
 	return nil
 }
 
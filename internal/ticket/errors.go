@@ -0,0 +1,45 @@
+package ticket
+
+import (
+	"fmt"
+
+	"hex-example/internal/validation"
+)
+
+// NotFoundError is returned when no ticket has the requested ID.
+type NotFoundError struct {
+	ID string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("ticket %s not found", e.ID)
+}
+
+func (e *NotFoundError) NotFound() bool { return true }
+
+// ConflictError is returned when a ticket cannot be stored because it clashes
+// with an existing one.
+type ConflictError struct {
+	ID string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("ticket %s already exists", e.ID)
+}
+
+func (e *ConflictError) Conflict() bool { return true }
+
+// UnauthorizedError is returned when the caller may not act on a ticket.
+type UnauthorizedError struct {
+	Reason string
+}
+
+func (e *UnauthorizedError) Error() string {
+	return e.Reason
+}
+
+func (e *UnauthorizedError) Unauthorized() bool { return true }
+
+// ValidationError is returned when a ticket payload breaks one or more field
+// rules.
+type ValidationError = validation.Errors
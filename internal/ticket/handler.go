@@ -3,6 +3,8 @@ package ticket
 import (
 	"encoding/json"
 	"github.com/sirupsen/logrus"
+	"hex-example/internal/problem"
+	"hex-example/internal/validation"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -24,18 +26,24 @@ func NewTicketHandler(ticketService TicketService) TicketHandler {
 	}
 }
 
+// Get, GetById and Create are also served under /projects/{project}, where
+// they only see and create tickets of that project.
 func (h *ticketHandler) Get(w http.ResponseWriter, r *http.Request) {
-	tickets, err := h.ticketService.FindAllTickets()
+	var tickets []*Ticket
+	var err error
+	if project := mux.Vars(r)["project"]; project != "" {
+		tickets, err = h.ticketService.FindProjectTickets(project)
+	} else {
+		tickets, err = h.ticketService.FindAllTickets()
+	}
 	if err != nil {
-		logrus.WithField("error", err).Error("Unable to find all tickets")
-		http.Error(w, "Unable to find all tickets", http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 
 	response, err := json.Marshal(tickets)
 	if err != nil {
-		logrus.WithField("error", err).Error("Error unmarshalling response")
-		http.Error(w, "Unable to get ticket", http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 
@@ -51,15 +59,17 @@ func (h *ticketHandler) GetById(w http.ResponseWriter, r *http.Request) {
 	id := vars["id"]
 	ticket, err := h.ticketService.FindTicketById(id)
 	if err != nil {
-		logrus.WithFields(logrus.Fields{"error":err, "id": id}).Error("Unable to find ticket")
-		http.Error(w, "Unable to find ticket", http.StatusInternalServerError)
+		problem.Error(w, r, err)
+		return
+	}
+	if project := vars["project"]; project != "" && ticket.Project != project {
+		problem.Error(w, r, &NotFoundError{ID: id})
 		return
 	}
 
 	response, err := json.Marshal(ticket)
 	if err != nil {
-		logrus.WithField("error", err).Error("Error unmarshalling response")
-		http.Error(w, "Unable to fetch tickets", http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 
@@ -76,20 +86,25 @@ func (h *ticketHandler) Create(w http.ResponseWriter, r *http.Request) {
 	decoder := json.NewDecoder(r.Body)
 	if err := decoder.Decode(&ticket); err != nil{
 		logrus.Error("Unable to decode ticket")
-		http.Error(w, "Bad format for ticket", http.StatusBadRequest)
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for ticket"))
 		return
 	}
+	if project := mux.Vars(r)["project"]; project != "" {
+		if ticket.Project != "" && ticket.Project != project {
+			problem.Error(w, r, validation.Errors{{Field: "project", Reason: "must match the project of the route"}})
+			return
+		}
+		ticket.Project = project
+	}
 
 	if err := h.ticketService.CreateTicket(&ticket); err != nil {
-		logrus.WithField("error", err).Error("Unable to create ticket")
-		http.Error(w, "Unable to create ticket", http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 
 	response, err := json.Marshal(ticket)
 	if err != nil {
-		logrus.WithField("error", err).Error("Error unmarshalling response")
-		http.Error(w, "Unable to create ticket", http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 
@@ -3,8 +3,10 @@ package ticket_test
 import (
 	"bytes"
 	"encoding/json"
-	"hex-example/internal/mocksnal/mocks"
-	"hex-example/internal/ticketal/ticket"
+	"hex-example/internal/mocks"
+	"hex-example/internal/problem"
+	"hex-example/internal/ticket"
+	"hex-example/internal/validation"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -55,6 +57,27 @@ func (suite *TicketHandlerTestSuite) TestCreate() {
 	suite.Equal("Joel", result.Creator)
 }
 
+func (suite *TicketHandlerTestSuite) TestCreateInvalid() {
+	suite.ticketService.EXPECT().CreateTicket(gomock.Any()).Return(validation.Errors{
+		{Field: "title", Reason: "is required"},
+	})
+
+	r, _ := http.NewRequest("POST", "/tickets", bytes.NewBufferString(`{"creator": "Joel"}`))
+
+	w := httptest.NewRecorder()
+	suite.underTest.Create(w, r)
+
+	response := w.Result()
+	suite.Equal("400 Bad Request", response.Status)
+	suite.Equal(problem.ContentType, response.Header.Get("Content-Type"))
+
+	defer response.Body.Close()
+	result := new(problem.Problem)
+	json.NewDecoder(response.Body).Decode(result)
+
+	suite.Equal([]problem.InvalidParam{{Name: "title", Reason: "is required"}}, result.InvalidParams)
+}
+
 func (suite *TicketHandlerTestSuite) TestFindTicketById() {
 	t := &ticket.Ticket{
 		Creator: "Joel",
@@ -81,6 +104,20 @@ func (suite *TicketHandlerTestSuite) TestFindTicketById() {
 	suite.Equal("Joel", result.Creator)
 }
 
+func (suite *TicketHandlerTestSuite) TestFindTicketByIdNotFound() {
+	suite.ticketService.EXPECT().FindTicketById("missing").Return(nil, &ticket.NotFoundError{ID: "missing"})
+
+	r, _ := http.NewRequest("GET", "/tickets/missing", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": "missing"})
+
+	w := httptest.NewRecorder()
+	suite.underTest.GetById(w, r)
+
+	response := w.Result()
+	suite.Equal("404 Not Found", response.Status)
+	suite.Equal(problem.ContentType, response.Header.Get("Content-Type"))
+}
+
 func (suite *TicketHandlerTestSuite) TestFindAll() {
 	ts := []*ticket.Ticket{
 		&ticket.Ticket{
@@ -107,3 +144,55 @@ func (suite *TicketHandlerTestSuite) TestFindAll() {
 	json.NewDecoder(response.Body).Decode(result)
 	suite.Len(*result, 2, "Should get two results")
 }
+
+func (suite *TicketHandlerTestSuite) TestCreateInProject() {
+	suite.ticketService.EXPECT().CreateTicket(gomock.Eq(&ticket.Ticket{Project: "infra", Creator: "Joel"})).Return(nil)
+
+	r, _ := http.NewRequest("POST", "/projects/infra/tickets", bytes.NewBufferString(`{"creator": "Joel"}`))
+	r = mux.SetURLVars(r, map[string]string{"project": "infra"})
+
+	w := httptest.NewRecorder()
+	suite.underTest.Create(w, r)
+
+	suite.Equal("201 Created", w.Result().Status)
+}
+
+func (suite *TicketHandlerTestSuite) TestCreateInOtherProject() {
+	r, _ := http.NewRequest("POST", "/projects/infra/tickets", bytes.NewBufferString(`{"project": "web", "creator": "Joel"}`))
+	r = mux.SetURLVars(r, map[string]string{"project": "infra"})
+
+	w := httptest.NewRecorder()
+	suite.underTest.Create(w, r)
+
+	suite.Equal("400 Bad Request", w.Result().Status)
+}
+
+func (suite *TicketHandlerTestSuite) TestFindTicketByIdInOtherProject() {
+	suite.ticketService.EXPECT().FindTicketById("test").Return(&ticket.Ticket{ID: "test", Project: "web"}, nil)
+
+	r, _ := http.NewRequest("GET", "/projects/infra/tickets/test", nil)
+	r = mux.SetURLVars(r, map[string]string{"project": "infra", "id": "test"})
+
+	w := httptest.NewRecorder()
+	suite.underTest.GetById(w, r)
+
+	suite.Equal("404 Not Found", w.Result().Status, "tickets of other projects are hidden")
+}
+
+func (suite *TicketHandlerTestSuite) TestFindProjectTickets() {
+	suite.ticketService.EXPECT().FindProjectTickets("infra").Return([]*ticket.Ticket{{ID: "test", Project: "infra"}}, nil)
+
+	r, _ := http.NewRequest("GET", "/projects/infra/tickets", nil)
+	r = mux.SetURLVars(r, map[string]string{"project": "infra"})
+
+	w := httptest.NewRecorder()
+	suite.underTest.Get(w, r)
+
+	response := w.Result()
+	suite.Equal("200 OK", response.Status)
+
+	defer response.Body.Close()
+	result := new([]ticket.Ticket)
+	json.NewDecoder(response.Body).Decode(result)
+	suite.Len(*result, 1)
+}
@@ -4,6 +4,7 @@ import "time"
 
 type Ticket struct {
 	ID          string    `json:"id" db:"id"`
+	Project     string    `json:"project" db:"project"`
 	Creator     string    `json:"creator" db:"creator"`
 	Assigned    string    `json:"assigned" db:"assigned"`
 	Title       string    `json:"title" db:"title"`
@@ -4,4 +4,11 @@ type TicketRepository interface {
 	Create(ticket *Ticket) error
 	FindById(id string) (*Ticket, error)
 	FindAll() ([]*Ticket, error)
+	FindByProject(project string) ([]*Ticket, error)
+	// FindByIDs returns the tickets with the given ids, skipping unknown
+	// ones.
+	FindByIDs(ids []string) ([]*Ticket, error)
+	// FindByUsernames returns the tickets created by or assigned to any of
+	// usernames.
+	FindByUsernames(usernames []string) ([]*Ticket, error)
 }
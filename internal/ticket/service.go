@@ -11,6 +11,13 @@ type TicketService interface {
 	CreateTicket(ticket *Ticket) error
 	FindTicketById(id string) (*Ticket, error)
 	FindAllTickets() ([]*Ticket, error)
+	FindProjectTickets(project string) ([]*Ticket, error)
+	// FindTicketsByIDs returns the tickets with the given ids in a single
+	// repository call. Unknown ids are skipped.
+	FindTicketsByIDs(ids []string) ([]*Ticket, error)
+	// FindUserTickets returns the tickets created by or assigned to any of
+	// usernames in a single repository call.
+	FindUserTickets(usernames []string) ([]*Ticket, error)
 }
 
 type ticketService struct {
@@ -24,10 +31,17 @@ func NewTicketService(repo TicketRepository) TicketService {
 }
 
 func (s *ticketService) CreateTicket(ticket *Ticket) error {
+	if err := validateNew(ticket); err != nil {
+		logrus.WithField("error", err).Info("Invalid ticket")
+		return err
+	}
+
 	ticket.ID = uuid.New().String()
 	ticket.Created = time.Now()
 	ticket.Updated = time.Now()
-	ticket.Status = "OPEN"
+	if ticket.Status == "" {
+		ticket.Status = StatusOpen
+	}
 
 	if err := s.repo.Create(ticket); err != nil {
 		logrus.WithField("error", err).Error("Error creating ticket")
@@ -58,3 +72,31 @@ func (s *ticketService) FindAllTickets() ([]*Ticket, error) {
 	logrus.Info("Found all tickets")
 	return tickets, nil
 }
+
+func (s *ticketService) FindProjectTickets(project string) ([]*Ticket, error) {
+	tickets, err := s.repo.FindByProject(project)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"error": err, "project": project}).Error("Error finding project tickets")
+		return nil, err
+	}
+	logrus.WithField("project", project).Info("Found project tickets")
+	return tickets, nil
+}
+
+func (s *ticketService) FindTicketsByIDs(ids []string) ([]*Ticket, error) {
+	tickets, err := s.repo.FindByIDs(ids)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"error": err, "ids": ids}).Error("Error finding tickets")
+		return nil, err
+	}
+	return tickets, nil
+}
+
+func (s *ticketService) FindUserTickets(usernames []string) ([]*Ticket, error) {
+	tickets, err := s.repo.FindByUsernames(usernames)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"error": err, "usernames": usernames}).Error("Error finding user tickets")
+		return nil, err
+	}
+	return tickets, nil
+}
@@ -1,8 +1,9 @@
 package ticket_test
 
 import (
-	"hex-example/internal/mocksnal/mocks"
-	"hex-example/internal/ticketal/ticket"
+	"hex-example/internal/mocks"
+	"hex-example/internal/ticket"
+	"hex-example/internal/validation"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -31,6 +32,7 @@ func (suite *TicketServiceTestSuite) TestCreate() {
 	//Arrange
 	t := &ticket.Ticket{
 		Creator: "Joel",
+		Title:   "Test",
 	}
 	suite.ticketRepo.EXPECT().Create(gomock.Any()).Return(nil)
 
@@ -45,6 +47,24 @@ func (suite *TicketServiceTestSuite) TestCreate() {
 
 }
 
+func (suite *TicketServiceTestSuite) TestCreateInvalid() {
+	t := &ticket.Ticket{
+		ID:     "client-id",
+		Points: -1,
+		Status: "LOST",
+	}
+
+	err := suite.underTest.CreateTicket(t)
+
+	errs, ok := err.(validation.Errors)
+	suite.True(ok, "should be a validation error")
+	fields := []string{}
+	for _, fe := range errs {
+		fields = append(fields, fe.Field)
+	}
+	suite.ElementsMatch([]string{"id", "creator", "title", "points", "status"}, fields)
+}
+
 func (suite *TicketServiceTestSuite) TestFindTicketById() {
 	t := &ticket.Ticket{
 		ID:      "test",
@@ -0,0 +1,46 @@
+package ticket
+
+import "hex-example/internal/validation"
+
+// Ticket statuses.
+const (
+	StatusOpen       = "OPEN"
+	StatusInProgress = "IN_PROGRESS"
+	StatusBlocked    = "BLOCKED"
+	StatusDone       = "DONE"
+)
+
+// Statuses lists every valid ticket status.
+var Statuses = []string{StatusOpen, StatusInProgress, StatusBlocked, StatusDone}
+
+const (
+	maxFieldLength   = 255 // varchar(255) in schema.sql
+	maxProjectLength = 64  // varchar(64) in schema.sql
+	minPoints        = 0
+	maxPoints        = 100
+)
+
+// validateNew checks a ticket submitted for creation. Server-assigned fields
+// must be left unset.
+func validateNew(ticket *Ticket) error {
+	v := new(validation.Validator)
+	v.Empty("id", ticket.ID != "")
+	v.Empty("created", !ticket.Created.IsZero())
+	v.Empty("updated", !ticket.Updated.IsZero())
+	v.Empty("deleted", !ticket.Deleted.IsZero())
+
+	if v.Required("creator", ticket.Creator) {
+		v.Length("creator", ticket.Creator, 1, maxFieldLength)
+	}
+	if v.Required("title", ticket.Title) {
+		v.Length("title", ticket.Title, 1, maxFieldLength)
+	}
+	v.Length("project", ticket.Project, 0, maxProjectLength)
+	v.Length("assigned", ticket.Assigned, 0, maxFieldLength)
+	v.Length("description", ticket.Description, 0, maxFieldLength)
+	v.Range("points", ticket.Points, minPoints, maxPoints)
+	if ticket.Status != "" {
+		v.OneOf("status", ticket.Status, Statuses...)
+	}
+	return v.Err()
+}
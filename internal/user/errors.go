@@ -0,0 +1,183 @@
+package user
+
+import (
+	"fmt"
+	"time"
+
+	"hex-example/internal/validation"
+)
+
+// NotFoundError is returned when no account has the requested username, or
+// ID when looked up by ID.
+type NotFoundError struct {
+	Username string
+	ID       string
+}
+
+func (e *NotFoundError) Error() string {
+	if e.Username == "" {
+		return fmt.Sprintf("account with id %s not found", e.ID)
+	}
+	return fmt.Sprintf("account %s not found", e.Username)
+}
+
+func (e *NotFoundError) NotFound() bool { return true }
+
+// ServiceAccountNotFoundError is returned when no service account or API key
+// has the requested ID.
+type ServiceAccountNotFoundError struct {
+	ID string
+}
+
+func (e *ServiceAccountNotFoundError) Error() string {
+	return fmt.Sprintf("service account %s not found", e.ID)
+}
+
+func (e *ServiceAccountNotFoundError) NotFound() bool { return true }
+
+// PasskeyNotFoundError is returned when the account has no passkey with the
+// requested ID.
+type PasskeyNotFoundError struct {
+	ID string
+}
+
+func (e *PasskeyNotFoundError) Error() string {
+	return fmt.Sprintf("passkey %s not found", e.ID)
+}
+
+func (e *PasskeyNotFoundError) NotFound() bool { return true }
+
+// OAuthClientNotFoundError is returned when no OpenID Connect client, or no
+// consent given to one, has the requested ID.
+type OAuthClientNotFoundError struct {
+	ID string
+}
+
+func (e *OAuthClientNotFoundError) Error() string {
+	return fmt.Sprintf("client %s not found", e.ID)
+}
+
+func (e *OAuthClientNotFoundError) NotFound() bool { return true }
+
+// ConflictError is returned when the username is already taken.
+type ConflictError struct {
+	Username string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("username %s is already taken", e.Username)
+}
+
+func (e *ConflictError) Conflict() bool { return true }
+
+// UnauthorizedError is returned when credentials are missing or wrong. Its
+// message never says which part was wrong.
+type UnauthorizedError struct {
+	Reason string
+}
+
+func (e *UnauthorizedError) Error() string {
+	return e.Reason
+}
+
+func (e *UnauthorizedError) Unauthorized() bool { return true }
+
+// TooManyAttemptsError is returned by logins for a username or client that
+// failed too often. It is returned for unknown usernames too, so it does not
+// tell whether an account exists.
+type TooManyAttemptsError struct {
+	Wait time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return "Too many failed login attempts, try again later"
+}
+
+func (e *TooManyAttemptsError) TooManyRequests() bool { return true }
+
+// RetryAfter returns how long the caller has to wait before trying again.
+func (e *TooManyAttemptsError) RetryAfter() time.Duration { return e.Wait }
+
+// ValidationError is returned when an account payload breaks one or more
+// field rules.
+type ValidationError = validation.Errors
+
+// ErrInvalidLogin is returned by Login for an unknown username or a wrong
+// password alike.
+var ErrInvalidLogin = &UnauthorizedError{Reason: "Invalid login"}
+
+// ErrInvalidAPIKey is returned for malformed, unknown, expired and revoked
+// API keys alike.
+var ErrInvalidAPIKey = &UnauthorizedError{Reason: "Invalid API key"}
+
+// ErrAccountDeactivated is returned by Login for a deactivated account once
+// the password has been verified.
+var ErrAccountDeactivated = &UnauthorizedError{Reason: "Account is deactivated"}
+
+// ErrInvalidActionToken is returned for unknown, expired and already used
+// verification and password reset tokens alike.
+var ErrInvalidActionToken = &UnauthorizedError{Reason: "Invalid or expired token"}
+
+// ErrInvalidMFAToken is returned for unknown, expired and exhausted
+// two-factor login challenges.
+var ErrInvalidMFAToken = &UnauthorizedError{Reason: "Invalid or expired two-factor challenge"}
+
+// ErrInvalidMFACode is returned for wrong, reused and expired TOTP codes and
+// unknown recovery codes alike.
+var ErrInvalidMFACode = &UnauthorizedError{Reason: "Invalid two-factor code"}
+
+// MFAStateError is returned when two-factor authentication is not in the
+// state an operation requires.
+type MFAStateError struct {
+	Reason string
+}
+
+func (e *MFAStateError) Error() string {
+	return e.Reason
+}
+
+func (e *MFAStateError) Conflict() bool { return true }
+
+var (
+	// ErrMFAEnabled is returned when enrolling an account that already has
+	// two-factor authentication.
+	ErrMFAEnabled = &MFAStateError{Reason: "Two-factor authentication is already enabled"}
+	// ErrMFANotEnrolled is returned when confirming or changing two-factor
+	// authentication that was never set up.
+	ErrMFANotEnrolled = &MFAStateError{Reason: "Two-factor authentication is not set up"}
+)
+
+// ErrWrongPassword is returned when the current password confirming an
+// account change is wrong.
+var ErrWrongPassword = &UnauthorizedError{Reason: "Wrong password"}
+
+// ErrInvalidPasskey is returned for unknown and expired passkey ceremonies
+// and for assertions that fail verification alike.
+var ErrInvalidPasskey = &UnauthorizedError{Reason: "Invalid or expired passkey ceremony"}
+
+// OAuthError is an OAuth 2.0 error response, sent back to the client in the
+// redirect of an authorization request or in a token endpoint response.
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// Unauthorized reports whether the client failed to authenticate, which the
+// token endpoint answers with 401.
+func (e *OAuthError) Unauthorized() bool { return e.Code == "invalid_client" }
+
+var (
+	// ErrInvalidClient is returned by the token endpoint for unknown clients
+	// and wrong secrets alike.
+	ErrInvalidClient = &OAuthError{Code: "invalid_client", Description: "Client authentication failed"}
+	// ErrInvalidGrant is returned for unknown, expired and used
+	// authorization codes, and for codes presented by another client, with
+	// another redirect URI or with the wrong PKCE verifier alike.
+	ErrInvalidGrant = &OAuthError{Code: "invalid_grant", Description: "Invalid or expired authorization code"}
+	// ErrAccessDenied is sent to the client when the account holder declines.
+	ErrAccessDenied = &OAuthError{Code: "access_denied", Description: "The account holder denied the request"}
+)
@@ -0,0 +1,250 @@
+package user
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	maxUsernameAttempts = 5
+	maxUsernameBase     = 26 // leaves room for a "-1234" suffix
+)
+
+var usernameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// LoginExternal logs in the user of an identity provider, linking the
+// identity to an account on first login. The account is the one that
+// verified the same email address if exactly one did and the provider
+// vouches for the address, and a new account otherwise. Accounts with a
+// second factor still have to complete VerifyMFA.
+func (s *userService) LoginExternal(profile *ExternalProfile) (*Login, error) {
+	account, err := s.repo.GetUserByIdentity(profile.Provider, profile.Subject)
+	if _, ok := err.(*NotFoundError); ok {
+		account, err = s.link(profile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if account.Deactivated {
+		logrus.WithField("username", account.Username).Info("Login for deactivated account")
+		return nil, ErrAccountDeactivated
+	}
+
+	mfa, err := s.mfa.GetMFA(account.ID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to fetch second factor")
+		return nil, err
+	}
+	if mfa != nil && mfa.Enabled {
+		return s.challenge(account)
+	}
+	return s.issue(account, uuid.New().String(), time.Now())
+}
+
+// UnlinkExternal unlinks an identity whose holder removed the app at the
+// provider and ends the sessions of the linked account, which may have been
+// started through the provider. It returns the account the identity was
+// linked to.
+func (s *userService) UnlinkExternal(provider, subject string) (*Account, error) {
+	account, err := s.repo.GetUserByIdentity(provider, subject)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.UnlinkIdentity(provider, subject); err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to unlink identity")
+		return nil, err
+	}
+	if err := s.revokeSessions(account); err != nil {
+		return nil, err
+	}
+	logrus.WithFields(logrus.Fields{"username": account.Username, "provider": provider}).Info("Identity unlinked")
+	return account, nil
+}
+
+// DeleteExternal deletes what came from the provider when it asks for the
+// data of its user to be deleted. An account created for the identity is
+// deleted with everything stored with it, since the provider authenticated
+// the request no password is needed. An account that existed before the
+// identity was linked to it only loses the link and its sessions. It
+// returns the account the identity was linked to.
+func (s *userService) DeleteExternal(provider, subject string) (*Account, error) {
+	identity, err := s.repo.GetIdentity(provider, subject)
+	if err != nil {
+		return nil, err
+	}
+	if !identity.Created {
+		return s.UnlinkExternal(provider, subject)
+	}
+
+	account, err := s.repo.GetUserByID(identity.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.deleteAccount(account); err != nil {
+		return nil, err
+	}
+	logrus.WithFields(logrus.Fields{"id": account.ID, "provider": provider}).Info("Account deleted at the request of the provider")
+	return account, nil
+}
+
+// link finds or creates the account for an identity seen for the first time
+// and links the two. When a concurrent first login linked the identity in
+// the meantime, the account it linked is used and an account created here
+// is deleted again.
+func (s *userService) link(profile *ExternalProfile) (*Account, error) {
+	account, err := s.verifiedAccount(profile)
+	if err != nil {
+		return nil, err
+	}
+	created := account == nil
+	if created {
+		if account, err = s.createExternal(profile); err != nil {
+			return nil, err
+		}
+	}
+
+	identity := &Identity{
+		Provider:  profile.Provider,
+		Subject:   profile.Subject,
+		AccountID: account.ID,
+		Linked:    time.Now(),
+		Created:   created,
+	}
+	linked, err := s.repo.LinkIdentity(identity)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to link identity")
+		return nil, err
+	}
+	if !linked {
+		logrus.WithField("provider", profile.Provider).Info("Identity linked by a concurrent login")
+		if created {
+			if err := s.repo.DeleteAccount(account); err != nil {
+				logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to delete unlinked account")
+			}
+		}
+		return s.repo.GetUserByIdentity(profile.Provider, profile.Subject)
+	}
+	logrus.WithFields(logrus.Fields{"username": account.Username, "provider": profile.Provider}).Info("Identity linked")
+	return account, nil
+}
+
+// verifiedAccount returns the only account that verified the email address
+// of profile, or nil if there is no such account or the provider does not
+// vouch for the address.
+func (s *userService) verifiedAccount(profile *ExternalProfile) (*Account, error) {
+	if !profile.EmailVerified || profile.Email == "" {
+		return nil, nil
+	}
+	accounts, err := s.repo.GetUsersByEmail(profile.Email)
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch accounts by email")
+		return nil, err
+	}
+
+	var verified *Account
+	for _, account := range accounts {
+		if !account.EmailVerified {
+			continue
+		}
+		if verified != nil {
+			logrus.WithField("provider", profile.Provider).Info("Several accounts verified the email of an identity, not linking any")
+			return nil, nil
+		}
+		verified = account
+	}
+	return verified, nil
+}
+
+// createExternal creates an account for profile with a username derived from
+// its email address or name. The password is random, so the account holder
+// logs in through the provider until they reset it.
+func (s *userService) createExternal(profile *ExternalProfile) (*Account, error) {
+	password := make([]byte, actionTokenLen)
+	if _, err := rand.Read(password); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(base64.RawURLEncoding.EncodeToString(password)), bcrypt.DefaultCost)
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to hash password: ")
+		return nil, err
+	}
+
+	firstName, lastName := splitName(profile.Name)
+	account := &Account{
+		ID:            uuid.New().String(),
+		FirstName:     firstName,
+		LastName:      lastName,
+		Password:      string(hashedPassword),
+		Email:         profile.Email,
+		EmailVerified: profile.EmailVerified && profile.Email != "",
+	}
+	base := usernameBase(profile)
+	for attempt := 0; attempt < maxUsernameAttempts; attempt++ {
+		account.Username = base
+		if attempt > 0 {
+			n, err := rand.Int(rand.Reader, big.NewInt(10000))
+			if err != nil {
+				return nil, err
+			}
+			account.Username = fmt.Sprintf("%s-%04d", base, n)
+		}
+
+		err = s.repo.CreateAccount(account)
+		if _, ok := err.(*ConflictError); ok {
+			continue
+		}
+		if err != nil {
+			logrus.WithField("error", err).Error("Unable to save account")
+			return nil, err
+		}
+		logrus.WithFields(logrus.Fields{"username": account.Username, "provider": profile.Provider}).Info("Account created for identity")
+		return account, nil
+	}
+	return nil, &ConflictError{Username: account.Username}
+}
+
+// usernameBase returns a valid username resembling the email address or
+// name of profile.
+func usernameBase(profile *ExternalProfile) string {
+	base := profile.Name
+	if at := strings.LastIndex(profile.Email, "@"); at > 0 {
+		base = profile.Email[:at]
+	}
+	base = strings.Trim(usernameUnsafe.ReplaceAllString(strings.ToLower(base), "."), "._-")
+	if len(base) > maxUsernameBase {
+		base = base[:maxUsernameBase]
+	}
+	if len(base) < 3 {
+		base = "user" + base
+	}
+	return base
+}
+
+// splitName splits a display name into first and last name at its last
+// space.
+func splitName(name string) (string, string) {
+	name = strings.TrimSpace(name)
+	i := strings.LastIndex(name, " ")
+	if i < 0 {
+		return truncate(name, maxNameLength), ""
+	}
+	return truncate(name[:i], maxNameLength), truncate(name[i+1:], maxNameLength)
+}
+
+// truncate shortens s to at most n characters.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) > n {
+		return string([]rune(s)[:n])
+	}
+	return s
+}
@@ -0,0 +1,248 @@
+package user
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"hex-example/internal/jwks"
+)
+
+func TestExternalLoginSuite(t *testing.T) {
+	suite.Run(t, new(ExternalLoginTestSuite))
+}
+
+type ExternalLoginTestSuite struct {
+	suite.Suite
+	users     *fakeUserRepo
+	mfa       *fakeMFARepo
+	underTest UserService
+}
+
+func (suite *ExternalLoginTestSuite) SetupTest() {
+	keys, err := jwks.NewKeyRing(jwks.NewMemoryStore(), jwks.ES256, time.Hour, accessTokenTTL, 0)
+	suite.Require().NoError(err)
+	suite.users = newFakeUserRepo()
+	suite.mfa = newFakeMFARepo()
+	suite.underTest = NewUserService(suite.users, newFakeTokenRepo(), suite.mfa, newFakeActionTokenRepo(), keys)
+}
+
+func (suite *ExternalLoginTestSuite) profile() *ExternalProfile {
+	return &ExternalProfile{
+		Provider:      "facebook",
+		Subject:       "54638001",
+		Name:          "Ivy Crimson",
+		Email:         "Ivy@harvard.edu",
+		EmailVerified: true,
+	}
+}
+
+// verifiedAccount creates an account that verified email.
+func (suite *ExternalLoginTestSuite) verifiedAccount(username, email string) *Account {
+	account := &Account{Username: username, Password: "password1", Email: email}
+	suite.Require().NoError(suite.underTest.CreateAccount(account))
+	stored, err := suite.users.GetUser(username)
+	suite.Require().NoError(err)
+	stored.EmailVerified = true
+	suite.Require().NoError(suite.users.UpdateAccount(stored))
+	return stored
+}
+
+func (suite *ExternalLoginTestSuite) TestFirstLoginCreatesAccount() {
+	login, err := suite.underTest.LoginExternal(suite.profile())
+
+	suite.Require().NoError(err)
+	suite.Equal("ivy", login.Username)
+	suite.NotEmpty(login.Token)
+	suite.NotEmpty(login.RefreshToken)
+	account, err := suite.users.GetUser("ivy")
+	suite.Require().NoError(err)
+	suite.Equal("Ivy", account.FirstName)
+	suite.Equal("Crimson", account.LastName)
+	suite.True(account.EmailVerified)
+}
+
+func (suite *ExternalLoginTestSuite) TestLaterLoginsUseLinkedAccount() {
+	first, err := suite.underTest.LoginExternal(suite.profile())
+	suite.Require().NoError(err)
+
+	profile := suite.profile()
+	profile.Email = "ivy@example.com"
+	second, err := suite.underTest.LoginExternal(profile)
+
+	suite.Require().NoError(err)
+	suite.Equal(first.Username, second.Username)
+	accounts, _ := suite.users.ListUsers()
+	suite.Len(accounts, 1)
+}
+
+func (suite *ExternalLoginTestSuite) TestConcurrentFirstLoginsLinkOneAccount() {
+	const logins = 5
+	usernames := make(chan string, logins)
+	var wg sync.WaitGroup
+	for i := 0; i < logins; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			login, err := suite.underTest.LoginExternal(suite.profile())
+			suite.NoError(err)
+			if err == nil {
+				usernames <- login.Username
+			}
+		}()
+	}
+	wg.Wait()
+	close(usernames)
+
+	accounts, _ := suite.users.ListUsers()
+	suite.Require().Len(accounts, 1, "accounts created by losing logins are deleted")
+	for username := range usernames {
+		suite.Equal(accounts[0].Username, username)
+	}
+}
+
+func (suite *ExternalLoginTestSuite) TestLinksAccountByVerifiedEmail() {
+	suite.verifiedAccount("crimson", "ivy@harvard.edu")
+
+	login, err := suite.underTest.LoginExternal(suite.profile())
+
+	suite.Require().NoError(err)
+	suite.Equal("crimson", login.Username)
+}
+
+func (suite *ExternalLoginTestSuite) TestDoesNotLinkUnverifiedEmail() {
+	suite.Require().NoError(suite.underTest.CreateAccount(&Account{Username: "crimson", Password: "password1", Email: "ivy@harvard.edu"}))
+
+	login, err := suite.underTest.LoginExternal(suite.profile())
+
+	suite.Require().NoError(err)
+	suite.Equal("ivy", login.Username)
+}
+
+func (suite *ExternalLoginTestSuite) TestDoesNotLinkEmailTheProviderDoesNotVouchFor() {
+	suite.verifiedAccount("crimson", "ivy@harvard.edu")
+	profile := suite.profile()
+	profile.EmailVerified = false
+
+	login, err := suite.underTest.LoginExternal(profile)
+
+	suite.Require().NoError(err)
+	suite.Equal("ivy", login.Username)
+	account, _ := suite.users.GetUser("ivy")
+	suite.False(account.EmailVerified)
+}
+
+func (suite *ExternalLoginTestSuite) TestDoesNotLinkAmbiguousEmail() {
+	suite.verifiedAccount("crimson", "ivy@harvard.edu")
+	suite.verifiedAccount("ivy2", "ivy@harvard.edu")
+
+	login, err := suite.underTest.LoginExternal(suite.profile())
+
+	suite.Require().NoError(err)
+	suite.Equal("ivy", login.Username)
+}
+
+func (suite *ExternalLoginTestSuite) TestUsernameTaken() {
+	suite.Require().NoError(suite.underTest.CreateAccount(&Account{Username: "ivy", Password: "password1"}))
+
+	login, err := suite.underTest.LoginExternal(suite.profile())
+
+	suite.Require().NoError(err)
+	suite.Regexp(`^ivy-\d{4}$`, login.Username)
+}
+
+func (suite *ExternalLoginTestSuite) TestSecondFactor() {
+	first, err := suite.underTest.LoginExternal(suite.profile())
+	suite.Require().NoError(err)
+	account, _ := suite.users.GetUser(first.Username)
+	suite.Require().NoError(suite.mfa.SaveMFA(&MFA{AccountID: account.ID, Secret: "JBSWY3DPEHPK3PXP", Enabled: true}))
+
+	login, err := suite.underTest.LoginExternal(suite.profile())
+
+	suite.Require().NoError(err)
+	suite.Empty(login.Token)
+	suite.NotEmpty(login.MFAToken)
+}
+
+func (suite *ExternalLoginTestSuite) TestDeactivated() {
+	first, err := suite.underTest.LoginExternal(suite.profile())
+	suite.Require().NoError(err)
+	account, _ := suite.users.GetUser(first.Username)
+	account.Deactivated = true
+	suite.Require().NoError(suite.users.UpdateAccount(account))
+
+	_, err = suite.underTest.LoginExternal(suite.profile())
+	suite.Equal(ErrAccountDeactivated, err)
+}
+
+func (suite *ExternalLoginTestSuite) TestUnlinkExternal() {
+	first, err := suite.underTest.LoginExternal(suite.profile())
+	suite.Require().NoError(err)
+
+	unlinked, err := suite.underTest.UnlinkExternal("facebook", "54638001")
+	suite.Require().NoError(err)
+	suite.Equal(first.AccountID, unlinked.ID)
+	_, err = suite.users.GetUserByIdentity("facebook", "54638001")
+	suite.IsType(&NotFoundError{}, err)
+	_, err = suite.underTest.Refresh(first.RefreshToken)
+	suite.Equal(ErrInvalidRefreshToken, err, "sessions end with the link")
+	_, err = suite.users.GetUser(first.Username)
+	suite.NoError(err, "the account is kept")
+
+	_, err = suite.underTest.UnlinkExternal("facebook", "54638001")
+	suite.IsType(&NotFoundError{}, err)
+}
+
+func (suite *ExternalLoginTestSuite) TestDeleteExternal() {
+	first, err := suite.underTest.LoginExternal(suite.profile())
+	suite.Require().NoError(err)
+
+	deleted, err := suite.underTest.DeleteExternal("facebook", "54638001")
+	suite.Require().NoError(err)
+	suite.Equal(first.AccountID, deleted.ID)
+	_, err = suite.users.GetUser(first.Username)
+	suite.IsType(&NotFoundError{}, err)
+	_, err = suite.users.GetUserByIdentity("facebook", "54638001")
+	suite.IsType(&NotFoundError{}, err)
+	_, err = suite.underTest.Refresh(first.RefreshToken)
+	suite.Equal(ErrInvalidRefreshToken, err)
+
+	_, err = suite.underTest.DeleteExternal("facebook", "54638001")
+	suite.IsType(&NotFoundError{}, err)
+}
+
+func (suite *ExternalLoginTestSuite) TestDeleteExternalKeepsLinkedAccount() {
+	suite.verifiedAccount("crimson", "ivy@harvard.edu")
+	first, err := suite.underTest.LoginExternal(suite.profile())
+	suite.Require().NoError(err)
+
+	deleted, err := suite.underTest.DeleteExternal("facebook", "54638001")
+	suite.Require().NoError(err)
+	suite.Equal(first.AccountID, deleted.ID)
+	_, err = suite.users.GetUser("crimson")
+	suite.NoError(err, "the account existed before the identity was linked")
+	_, err = suite.users.GetUserByIdentity("facebook", "54638001")
+	suite.IsType(&NotFoundError{}, err)
+	_, err = suite.underTest.Refresh(first.RefreshToken)
+	suite.Equal(ErrInvalidRefreshToken, err)
+}
+
+func TestUsernameBase(t *testing.T) {
+	cases := map[string]struct {
+		profile  *ExternalProfile
+		expected string
+	}{
+		"email local part":  {&ExternalProfile{Name: "Ivy", Email: "Ivy.Crimson+fb@harvard.edu"}, "ivy.crimson.fb"},
+		"name without mail": {&ExternalProfile{Name: "Zoë O'Brien"}, "zo.o.brien"},
+		"no usable letters": {&ExternalProfile{Name: "李"}, "user"},
+		"too short":         {&ExternalProfile{Email: "a@b.c"}, "usera"},
+		"too long":          {&ExternalProfile{Email: "averyveryverylonglocalpartthatgoeson@b.c"}, "averyveryverylonglocalpart"},
+	}
+	for name, c := range cases {
+		username := usernameBase(c.profile)
+		assert.Equal(t, c.expected, username, name)
+		assert.Regexp(t, usernamePattern, username, name)
+	}
+}
@@ -0,0 +1,566 @@
+package user
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeUserRepo is an in-memory UserRepo keyed by username.
+type fakeUserRepo struct {
+	mu         sync.Mutex
+	accounts   map[string]*Account
+	passkeys   map[string]*Passkey
+	identities map[string]*Identity
+}
+
+func newFakeUserRepo() *fakeUserRepo {
+	return &fakeUserRepo{
+		accounts:   make(map[string]*Account),
+		passkeys:   make(map[string]*Passkey),
+		identities: make(map[string]*Identity),
+	}
+}
+
+func (r *fakeUserRepo) CreateAccount(account *Account) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.accounts[account.Username]; ok {
+		return &ConflictError{Username: account.Username}
+	}
+	stored := *account
+	r.accounts[account.Username] = &stored
+	return nil
+}
+
+func (r *fakeUserRepo) GetUser(username string) (*Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account, ok := r.accounts[username]
+	if !ok {
+		return nil, &NotFoundError{Username: username}
+	}
+	copied := *account
+	return &copied, nil
+}
+
+func (r *fakeUserRepo) GetUserByID(id string) (*Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, account := range r.accounts {
+		if account.ID == id {
+			copied := *account
+			return &copied, nil
+		}
+	}
+	return nil, &NotFoundError{ID: id}
+}
+
+func (r *fakeUserRepo) DeleteAccount(account *Account) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.accounts, account.Username)
+	for id, passkey := range r.passkeys {
+		if passkey.AccountID == account.ID {
+			delete(r.passkeys, id)
+		}
+	}
+	for key, identity := range r.identities {
+		if identity.AccountID == account.ID {
+			delete(r.identities, key)
+		}
+	}
+	return nil
+}
+
+func (r *fakeUserRepo) GetUsersByEmail(email string) ([]*Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	accounts := []*Account{}
+	for _, account := range r.accounts {
+		if strings.EqualFold(account.Email, email) {
+			copied := *account
+			accounts = append(accounts, &copied)
+		}
+	}
+	return accounts, nil
+}
+
+func (r *fakeUserRepo) GetUserByIdentity(provider, subject string) (*Account, error) {
+	r.mu.Lock()
+	identity, ok := r.identities[provider+":"+subject]
+	r.mu.Unlock()
+	if !ok {
+		return nil, &NotFoundError{ID: provider + ":" + subject}
+	}
+	return r.GetUserByID(identity.AccountID)
+}
+
+func (r *fakeUserRepo) GetIdentity(provider, subject string) (*Identity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	identity, ok := r.identities[provider+":"+subject]
+	if !ok {
+		return nil, &NotFoundError{ID: provider + ":" + subject}
+	}
+	stored := *identity
+	return &stored, nil
+}
+
+func (r *fakeUserRepo) LinkIdentity(identity *Identity) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := identity.Provider + ":" + identity.Subject
+	if _, ok := r.identities[key]; ok {
+		return false, nil
+	}
+	stored := *identity
+	r.identities[key] = &stored
+	return true, nil
+}
+
+func (r *fakeUserRepo) UnlinkIdentity(provider, subject string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.identities[provider+":"+subject]; !ok {
+		return &NotFoundError{ID: provider + ":" + subject}
+	}
+	delete(r.identities, provider+":"+subject)
+	return nil
+}
+
+func (r *fakeUserRepo) SavePasskey(passkey *Passkey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *passkey
+	r.passkeys[passkey.ID] = &stored
+	return nil
+}
+
+func (r *fakeUserRepo) GetPasskeys(accountID string) ([]*Passkey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	passkeys := []*Passkey{}
+	for _, passkey := range r.passkeys {
+		if passkey.AccountID == accountID {
+			copied := *passkey
+			passkeys = append(passkeys, &copied)
+		}
+	}
+	sort.Slice(passkeys, func(i, j int) bool { return passkeys[i].Created.Before(passkeys[j].Created) })
+	return passkeys, nil
+}
+
+func (r *fakeUserRepo) DeletePasskey(accountID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	passkey, ok := r.passkeys[id]
+	if !ok || passkey.AccountID != accountID {
+		return &PasskeyNotFoundError{ID: id}
+	}
+	delete(r.passkeys, id)
+	return nil
+}
+
+func (r *fakeUserRepo) GetUsers(usernames []string) ([]*Account, error) {
+	accounts := make([]*Account, len(usernames))
+	for i, username := range usernames {
+		accounts[i], _ = r.GetUser(username)
+	}
+	return accounts, nil
+}
+
+func (r *fakeUserRepo) ListUsers() ([]*Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	accounts := make([]*Account, 0, len(r.accounts))
+	for _, account := range r.accounts {
+		copied := *account
+		accounts = append(accounts, &copied)
+	}
+	return accounts, nil
+}
+
+func (r *fakeUserRepo) UpdateAccount(account *Account) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.accounts[account.Username]; !ok {
+		return &NotFoundError{Username: account.Username}
+	}
+	stored := *account
+	r.accounts[account.Username] = &stored
+	return nil
+}
+
+// fakeTokenRepo is an in-memory TokenRepo.
+type fakeTokenRepo struct {
+	mu     sync.Mutex
+	tokens map[string]*RefreshToken
+	used   map[string]bool
+}
+
+func newFakeTokenRepo() *fakeTokenRepo {
+	return &fakeTokenRepo{
+		tokens: make(map[string]*RefreshToken),
+		used:   make(map[string]bool),
+	}
+}
+
+func (r *fakeTokenRepo) SaveRefreshToken(token *RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[token.Hash] = token
+	return nil
+}
+
+func (r *fakeTokenRepo) GetRefreshToken(hash string) (*RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.tokens[hash], nil
+}
+
+func (r *fakeTokenRepo) MarkRefreshTokenUsed(token *RefreshToken) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	used := r.used[token.Hash]
+	r.used[token.Hash] = true
+	return used, nil
+}
+
+func (r *fakeTokenRepo) RevokeFamily(family string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for hash, token := range r.tokens {
+		if token.Family == family {
+			delete(r.tokens, hash)
+		}
+	}
+	return nil
+}
+
+func (r *fakeTokenRepo) RevokeAccount(accountID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for hash, token := range r.tokens {
+		if token.AccountID == accountID {
+			delete(r.tokens, hash)
+		}
+	}
+	return nil
+}
+
+// fakeServiceAccountRepo is an in-memory ServiceAccountRepo.
+type fakeServiceAccountRepo struct {
+	mu       sync.Mutex
+	accounts map[string]*ServiceAccount
+	keys     map[string]*APIKey
+	touches  int
+}
+
+func newFakeServiceAccountRepo() *fakeServiceAccountRepo {
+	return &fakeServiceAccountRepo{
+		accounts: make(map[string]*ServiceAccount),
+		keys:     make(map[string]*APIKey),
+	}
+}
+
+func (r *fakeServiceAccountRepo) CreateServiceAccount(account *ServiceAccount) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *account
+	r.accounts[account.ID] = &stored
+	return nil
+}
+
+func (r *fakeServiceAccountRepo) GetServiceAccount(id string) (*ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account, ok := r.accounts[id]
+	if !ok {
+		return nil, &ServiceAccountNotFoundError{ID: id}
+	}
+	copied := *account
+	return &copied, nil
+}
+
+func (r *fakeServiceAccountRepo) ListServiceAccounts() ([]*ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	accounts := make([]*ServiceAccount, 0, len(r.accounts))
+	for _, account := range r.accounts {
+		copied := *account
+		accounts = append(accounts, &copied)
+	}
+	return accounts, nil
+}
+
+func (r *fakeServiceAccountRepo) DeleteServiceAccount(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.accounts, id)
+	for keyID, key := range r.keys {
+		if key.ServiceAccountID == id {
+			delete(r.keys, keyID)
+		}
+	}
+	return nil
+}
+
+func (r *fakeServiceAccountRepo) SaveAPIKey(key *APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *key
+	r.keys[key.ID] = &stored
+	return nil
+}
+
+func (r *fakeServiceAccountRepo) GetAPIKey(id string) (*APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *key
+	return &copied, nil
+}
+
+func (r *fakeServiceAccountRepo) ListAPIKeys(serviceAccountID string) ([]*APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var keys []*APIKey
+	for _, key := range r.keys {
+		if key.ServiceAccountID == serviceAccountID {
+			copied := *key
+			keys = append(keys, &copied)
+		}
+	}
+	return keys, nil
+}
+
+func (r *fakeServiceAccountRepo) DeleteAPIKey(key *APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.keys, key.ID)
+	return nil
+}
+
+func (r *fakeServiceAccountRepo) TouchAPIKey(key *APIKey, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stored, ok := r.keys[key.ID]; ok {
+		stored.LastUsed = at
+		r.touches++
+	}
+	return nil
+}
+
+// fakeActionTokenRepo is an in-memory ActionTokenRepo.
+type fakeActionTokenRepo struct {
+	mu     sync.Mutex
+	tokens map[string]*ActionToken
+}
+
+func newFakeActionTokenRepo() *fakeActionTokenRepo {
+	return &fakeActionTokenRepo{tokens: make(map[string]*ActionToken)}
+}
+
+func (r *fakeActionTokenRepo) SaveActionToken(token *ActionToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *token
+	r.tokens[token.Purpose+":"+token.Hash] = &stored
+	return nil
+}
+
+func (r *fakeActionTokenRepo) TakeActionToken(purpose, hash string) (*ActionToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[purpose+":"+hash]
+	if !ok {
+		return nil, nil
+	}
+	delete(r.tokens, purpose+":"+hash)
+	return token, nil
+}
+
+// fakeLoginAttemptRepo is an in-memory LoginAttemptRepo whose attempt counts
+// never expire. Blocks end at now, which tests may replace with a clock.
+type fakeLoginAttemptRepo struct {
+	mu       sync.Mutex
+	failures map[string]int
+	blocks   map[string]time.Time
+	now      func() time.Time
+}
+
+func newFakeLoginAttemptRepo() *fakeLoginAttemptRepo {
+	return &fakeLoginAttemptRepo{failures: make(map[string]int), blocks: make(map[string]time.Time), now: time.Now}
+}
+
+func (r *fakeLoginAttemptRepo) Reserve(key string, window time.Duration) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures[key]++
+	return r.failures[key], nil
+}
+
+func (r *fakeLoginAttemptRepo) Release(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures[key]--; r.failures[key] <= 0 {
+		delete(r.failures, key)
+	}
+	return nil
+}
+
+func (r *fakeLoginAttemptRepo) Block(key string, until time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.blocks[key].After(r.now()) {
+		return false, nil
+	}
+	r.blocks[key] = until
+	return true, nil
+}
+
+func (r *fakeLoginAttemptRepo) BlockedUntil(key string) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.blocks[key], nil
+}
+
+func (r *fakeLoginAttemptRepo) Reset(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.failures, key)
+	delete(r.blocks, key)
+	return nil
+}
+
+// fakeMFARepo is an in-memory MFARepo.
+type fakeMFARepo struct {
+	mu  sync.Mutex
+	mfa map[string]*MFA
+}
+
+func newFakeMFARepo() *fakeMFARepo {
+	return &fakeMFARepo{mfa: make(map[string]*MFA)}
+}
+
+func (r *fakeMFARepo) GetMFA(accountID string) (*MFA, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.mfa[accountID]
+	if !ok {
+		return nil, nil
+	}
+	copied := *m
+	copied.RecoveryCodes = append([]string(nil), m.RecoveryCodes...)
+	return &copied, nil
+}
+
+func (r *fakeMFARepo) SaveMFA(mfa *MFA) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *mfa
+	r.mfa[mfa.AccountID] = &stored
+	return nil
+}
+
+func (r *fakeMFARepo) DeleteMFA(accountID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.mfa, accountID)
+	return nil
+}
+
+// fakeOAuthClientRepo is an in-memory OAuthClientRepo.
+type fakeOAuthClientRepo struct {
+	mu       sync.Mutex
+	clients  map[string]*OAuthClient
+	consents map[string]*Consent
+}
+
+func newFakeOAuthClientRepo() *fakeOAuthClientRepo {
+	return &fakeOAuthClientRepo{
+		clients:  make(map[string]*OAuthClient),
+		consents: make(map[string]*Consent),
+	}
+}
+
+func (r *fakeOAuthClientRepo) CreateClient(client *OAuthClient) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *client
+	r.clients[client.ID] = &stored
+	return nil
+}
+
+func (r *fakeOAuthClientRepo) GetClient(id string) (*OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client, ok := r.clients[id]
+	if !ok {
+		return nil, &OAuthClientNotFoundError{ID: id}
+	}
+	copied := *client
+	return &copied, nil
+}
+
+func (r *fakeOAuthClientRepo) ListClients() ([]*OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clients := make([]*OAuthClient, 0, len(r.clients))
+	for _, client := range r.clients {
+		copied := *client
+		clients = append(clients, &copied)
+	}
+	return clients, nil
+}
+
+func (r *fakeOAuthClientRepo) DeleteClient(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.clients, id)
+	return nil
+}
+
+func (r *fakeOAuthClientRepo) SaveConsent(consent *Consent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *consent
+	r.consents[consent.AccountID+":"+consent.ClientID] = &stored
+	return nil
+}
+
+func (r *fakeOAuthClientRepo) GetConsent(accountID, clientID string) (*Consent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	consent, ok := r.consents[accountID+":"+clientID]
+	if !ok {
+		return nil, nil
+	}
+	copied := *consent
+	return &copied, nil
+}
+
+func (r *fakeOAuthClientRepo) ListConsents(accountID string) ([]*Consent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var consents []*Consent
+	for _, consent := range r.consents {
+		if consent.AccountID == accountID {
+			copied := *consent
+			consents = append(consents, &copied)
+		}
+	}
+	return consents, nil
+}
+
+func (r *fakeOAuthClientRepo) DeleteConsent(accountID, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.consents, accountID+":"+clientID)
+	return nil
+}
@@ -0,0 +1,304 @@
+package user
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	failureWindow   = 15 * time.Minute
+	lockoutDuration = 15 * time.Minute
+	firstDelay      = time.Second
+	maxDelay        = time.Minute
+)
+
+// throttle is the policy for one kind of key. The first free failures
+// within failureWindow are not delayed; each further failure doubles the
+// wait before the next attempt, starting at firstDelay, until lockout
+// failures lock the key for lockoutDuration and audit lockEvent.
+type throttle struct {
+	prefix    string
+	free      int
+	lockout   int
+	lockEvent string
+}
+
+var (
+	usernameThrottle = throttle{prefix: "username:", free: 3, lockout: 10, lockEvent: "account_locked"}
+	// clients are allowed more failures as they may be shared, e.g. behind
+	// a NAT, and guess across many usernames
+	clientThrottle = throttle{prefix: "ip:", free: 10, lockout: 100, lockEvent: "client_locked"}
+	// wrong second factor codes count per account across challenges, as
+	// whoever makes them already knows the password
+	mfaThrottle = throttle{prefix: "mfa:", free: 3, lockout: 10, lockEvent: "mfa_locked"}
+	// every password reset request counts, as each may send a mail
+	resetThrottle       = throttle{prefix: "reset:", free: 3, lockout: 10, lockEvent: "reset_locked"}
+	resetClientThrottle = throttle{prefix: "reset_ip:", free: 10, lockout: 100, lockEvent: "reset_client_locked"}
+)
+
+// attempt is a login attempt counted against key before it is made.
+type attempt struct {
+	policy throttle
+	key    string
+	count  int
+}
+
+func (p throttle) attempt(value string) *attempt {
+	return &attempt{policy: p, key: p.prefix + value}
+}
+
+// LoginGuard protects password logins against guessing by throttling and
+// locking out usernames and client IPs that failed too often.
+type LoginGuard interface {
+	// Login logs in unless username or ip is blocked, or the second factor
+	// of the account is, in which case it returns a *TooManyAttemptsError.
+	Login(username, password, ip string) (*Login, error)
+	// VerifyMFA completes a two-factor login unless ip or the second factor
+	// of the account is blocked. Wrong codes count as failures of both, and
+	// only a successful VerifyMFA resets the account.
+	VerifyMFA(verification *MFAVerification, ip string) (*Login, error)
+}
+
+// PasswordResetGuard throttles password reset requests by username and
+// client IP, so that they cannot be used to flood an inbox.
+type PasswordResetGuard interface {
+	// RequestPasswordReset requests a reset unless username or ip asked too
+	// often, in which case it returns a *TooManyAttemptsError.
+	RequestPasswordReset(username, ip string) error
+}
+
+// limiter counts attempts per key and blocks keys as their throttle
+// demands.
+type limiter struct {
+	attempts LoginAttemptRepo
+	now      func() time.Time
+}
+
+type loginGuard struct {
+	service UserService
+	limiter
+}
+
+// NewLoginGuard returns a LoginGuard logging in with service. Lockouts
+// apply to unknown usernames as well, so they do not reveal which accounts
+// exist.
+func NewLoginGuard(service UserService, attempts LoginAttemptRepo) LoginGuard {
+	return &loginGuard{
+		service,
+		limiter{attempts, time.Now},
+	}
+}
+
+type passwordResetGuard struct {
+	service RecoveryService
+	limiter
+}
+
+// NewPasswordResetGuard returns a PasswordResetGuard requesting resets from
+// service. Unknown usernames are throttled alike.
+func NewPasswordResetGuard(service RecoveryService, attempts LoginAttemptRepo) PasswordResetGuard {
+	return &passwordResetGuard{
+		service,
+		limiter{attempts, time.Now},
+	}
+}
+
+func (g *loginGuard) Login(username, password, ip string) (*Login, error) {
+	user := usernameThrottle.attempt(username)
+	client := clientThrottle.attempt(ip)
+	mfaKey := mfaThrottle.prefix + username
+
+	// the second factor is checked up front, so that only the right
+	// password does not tell a locked account apart
+	if err := g.check(username, ip, user.key, client.key, mfaKey); err != nil {
+		return nil, err
+	}
+	if err := g.reserve(username, ip, user, client); err != nil {
+		return nil, err
+	}
+
+	login, err := g.service.Login(username, password)
+	if err == ErrInvalidLogin {
+		audit("login_failed", username, ip)
+		g.fail(username, ip, user, client)
+		return nil, err
+	}
+	if err != nil {
+		g.release(user, client)
+		return nil, err
+	}
+
+	// only the attempt is released for the client, so that an attacker
+	// with an account of their own cannot reset the count of their IP
+	if err := g.attempts.Reset(user.key); err != nil {
+		logrus.WithFields(logrus.Fields{"key": user.key, "error": err}).Error("Unable to reset login failures")
+	}
+	g.release(client)
+	audit("login_succeeded", username, ip)
+	return login, nil
+}
+
+func (g *loginGuard) VerifyMFA(verification *MFAVerification, ip string) (*Login, error) {
+	username := mfaTokenUsername(verification.MFAToken)
+	client := clientThrottle.attempt(ip)
+	mfa := mfaThrottle.attempt(username)
+	if err := g.check(username, ip, client.key, mfa.key); err != nil {
+		return nil, err
+	}
+	if err := g.reserve(username, ip, client, mfa); err != nil {
+		return nil, err
+	}
+
+	login, err := g.service.VerifyMFA(verification)
+	if err == ErrInvalidMFACode {
+		audit("mfa_failed", username, ip)
+		g.fail(username, ip, mfa, client)
+		return nil, err
+	}
+	if err != nil {
+		g.release(client, mfa)
+		return nil, err
+	}
+
+	if err := g.attempts.Reset(mfa.key); err != nil {
+		logrus.WithFields(logrus.Fields{"key": mfa.key, "error": err}).Error("Unable to reset login failures")
+	}
+	g.release(client)
+	audit("mfa_succeeded", login.Username, ip)
+	return login, nil
+}
+
+func (g *passwordResetGuard) RequestPasswordReset(username, ip string) error {
+	user := resetThrottle.attempt(username)
+	client := resetClientThrottle.attempt(ip)
+	if err := g.check(username, ip, user.key, client.key); err != nil {
+		return err
+	}
+	if err := g.reserve(username, ip, user, client); err != nil {
+		return err
+	}
+
+	if err := g.service.RequestPasswordReset(username); err != nil {
+		g.release(user, client)
+		return err
+	}
+	// successful requests stay counted like failed logins
+	g.fail(username, ip, user, client)
+	return nil
+}
+
+// mfaTokenUsername returns the username an MFA token was issued to. Only
+// tokens with the username they were issued with are accepted, so wrong
+// codes always count against the right account.
+func mfaTokenUsername(mfaToken string) string {
+	i := strings.LastIndex(mfaToken, mfaTokenSeparator)
+	if i < 0 {
+		return ""
+	}
+	return mfaToken[:i]
+}
+
+// check returns a *TooManyAttemptsError if any of keys is blocked.
+func (l *limiter) check(username, ip string, keys ...string) error {
+	for _, key := range keys {
+		until, err := l.attempts.BlockedUntil(key)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"key": key, "error": err}).Error("Unable to fetch login block")
+			return err
+		}
+		if wait := until.Sub(l.now()); wait > 0 {
+			audit("login_blocked", username, ip)
+			return &TooManyAttemptsError{Wait: wait}
+		}
+	}
+	return nil
+}
+
+// reserve counts attempts before the credentials are checked, so that
+// concurrent guesses cannot all get past check. Once the free attempts of a
+// key are used up, an attempt only goes ahead if it sets the block that
+// delays the next one; the others get a *TooManyAttemptsError.
+func (l *limiter) reserve(username, ip string, attempts ...*attempt) error {
+	for i, a := range attempts {
+		if err := l.reserveOne(username, ip, a); err != nil {
+			l.release(attempts[:i]...)
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *limiter) reserveOne(username, ip string, a *attempt) error {
+	count, err := l.attempts.Reserve(a.key, failureWindow)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"key": a.key, "error": err}).Error("Unable to count login attempt")
+		return err
+	}
+	a.count = count
+	if count <= a.policy.free {
+		return nil
+	}
+
+	wait := a.policy.delay(count)
+	if count >= a.policy.lockout {
+		wait = lockoutDuration
+	}
+	blocked, err := l.attempts.Block(a.key, l.now().Add(wait))
+	if err == nil && blocked {
+		return nil
+	}
+	l.release(a)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"key": a.key, "error": err}).Error("Unable to block login")
+		return err
+	}
+
+	// a concurrent attempt set the block first
+	if err := l.check(username, ip, a.key); err != nil {
+		return err
+	}
+	return &TooManyAttemptsError{Wait: firstDelay}
+}
+
+// fail keeps failed attempts counted and audits the keys they locked.
+func (l *limiter) fail(username, ip string, attempts ...*attempt) {
+	for _, a := range attempts {
+		if a.count >= a.policy.lockout {
+			audit(a.policy.lockEvent, username, ip)
+		}
+	}
+}
+
+// release uncounts attempts that were not guesses. Errors are logged rather
+// than returned, as the outcome of the attempt is already known. A block
+// set by an attempt is left to expire.
+func (l *limiter) release(attempts ...*attempt) {
+	for _, a := range attempts {
+		if err := l.attempts.Release(a.key); err != nil {
+			logrus.WithFields(logrus.Fields{"key": a.key, "error": err}).Error("Unable to release login attempt")
+		}
+	}
+}
+
+// delay returns the wait after the given number of failures.
+func (p throttle) delay(failures int) time.Duration {
+	wait := firstDelay
+	for i := p.free + 1; i < failures && wait < maxDelay; i++ {
+		wait *= 2
+	}
+	if wait > maxDelay {
+		return maxDelay
+	}
+	return wait
+}
+
+// audit logs a security event about a login.
+func audit(event, username, ip string) {
+	logrus.WithFields(logrus.Fields{
+		"event":    event,
+		"username": username,
+		"ip":       ip,
+	}).Warn("Login audit event")
+}
@@ -0,0 +1,196 @@
+package user
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"hex-example/internal/jwks"
+)
+
+func TestLoginGuardSuite(t *testing.T) {
+	suite.Run(t, new(LoginGuardTestSuite))
+}
+
+type LoginGuardTestSuite struct {
+	suite.Suite
+	attempts  *fakeLoginAttemptRepo
+	mfa       *fakeMFARepo
+	account   *Account
+	clock     time.Time
+	underTest *loginGuard
+}
+
+func (suite *LoginGuardTestSuite) SetupTest() {
+	keys, err := jwks.NewKeyRing(jwks.NewMemoryStore(), jwks.ES256, time.Hour, accessTokenTTL, 0)
+	suite.Require().NoError(err)
+	suite.mfa = newFakeMFARepo()
+	service := NewUserService(newFakeUserRepo(), newFakeTokenRepo(), suite.mfa, newFakeActionTokenRepo(), keys)
+	suite.account = &Account{Username: "joel", Password: "password1"}
+	suite.Require().NoError(service.CreateAccount(suite.account))
+
+	suite.attempts = newFakeLoginAttemptRepo()
+	suite.clock = time.Now()
+	suite.underTest = NewLoginGuard(service, suite.attempts).(*loginGuard)
+	suite.underTest.now = func() time.Time { return suite.clock }
+	suite.attempts.now = suite.underTest.now
+}
+
+// fail makes n failed logins, waiting out any delay in between.
+func (suite *LoginGuardTestSuite) fail(username, ip string, n int) {
+	for i := 0; i < n; i++ {
+		_, err := suite.underTest.Login(username, "wrong1", ip)
+		suite.Require().Equal(ErrInvalidLogin, err, "attempt %d", i+1)
+		suite.clock = suite.clock.Add(maxDelay)
+	}
+}
+
+func (suite *LoginGuardTestSuite) TestProgressiveDelay() {
+	suite.fail("joel", "10.0.0.1", usernameThrottle.free)
+	_, err := suite.underTest.Login("joel", "wrong1", "10.0.0.1")
+	suite.Equal(ErrInvalidLogin, err)
+
+	_, err = suite.underTest.Login("joel", "password1", "10.0.0.2")
+	if suite.IsType(&TooManyAttemptsError{}, err, "even the right password waits") {
+		suite.Equal(firstDelay, err.(*TooManyAttemptsError).RetryAfter())
+	}
+
+	suite.clock = suite.clock.Add(firstDelay)
+	_, err = suite.underTest.Login("joel", "password1", "10.0.0.2")
+	suite.NoError(err)
+	suite.Empty(suite.attempts.failures["username:joel"], "success resets the username")
+}
+
+func (suite *LoginGuardTestSuite) TestConcurrentGuessesAreThrottled() {
+	results := make(chan error, 20)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := suite.underTest.Login("joel", "wrong1", "10.0.0.1")
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	var guesses int
+	for err := range results {
+		if err == ErrInvalidLogin {
+			guesses++
+		} else {
+			suite.IsType(&TooManyAttemptsError{}, err)
+		}
+	}
+	suite.Equal(usernameThrottle.free+1, guesses, "only one guess gets past the free ones")
+	suite.Equal(guesses, suite.attempts.failures["username:joel"], "rejected attempts are not counted")
+}
+
+func (suite *LoginGuardTestSuite) TestLockout() {
+	suite.fail("joel", "10.0.0.1", usernameThrottle.lockout)
+
+	_, err := suite.underTest.Login("joel", "password1", "10.0.0.2")
+	if suite.IsType(&TooManyAttemptsError{}, err) {
+		suite.Equal(lockoutDuration-maxDelay, err.(*TooManyAttemptsError).RetryAfter())
+	}
+
+	suite.clock = suite.clock.Add(lockoutDuration)
+	_, err = suite.underTest.Login("joel", "password1", "10.0.0.2")
+	suite.NoError(err)
+}
+
+func (suite *LoginGuardTestSuite) TestUnknownUsernamesLockOutAlike() {
+	suite.fail("nobody", "10.0.0.1", usernameThrottle.lockout)
+
+	_, err := suite.underTest.Login("nobody", "password1", "10.0.0.2")
+	suite.IsType(&TooManyAttemptsError{}, err)
+}
+
+func (suite *LoginGuardTestSuite) TestClientLockoutAcrossUsernames() {
+	// the client already failed for many other usernames
+	suite.attempts.failures["ip:10.0.0.1"] = clientThrottle.lockout - 1
+	suite.fail("nobody", "10.0.0.1", 1)
+
+	_, err := suite.underTest.Login("joel", "password1", "10.0.0.1")
+	suite.IsType(&TooManyAttemptsError{}, err)
+	_, err = suite.underTest.Login("joel", "password1", "10.0.0.2")
+	suite.NoError(err, "other clients are unaffected")
+}
+
+// failMFA logs joel in and sends a wrong code, from a new client each time.
+func (suite *LoginGuardTestSuite) failMFA(n int) {
+	for i := 0; i < n; i++ {
+		login, err := suite.underTest.Login("joel", "password1", fmt.Sprintf("10.0.1.%d", i))
+		suite.Require().NoError(err, "attempt %d", i+1)
+		_, err = suite.underTest.VerifyMFA(&MFAVerification{MFAToken: login.MFAToken, Code: "000000"}, fmt.Sprintf("10.0.2.%d", i))
+		suite.Require().Equal(ErrInvalidMFACode, err, "attempt %d", i+1)
+		suite.clock = suite.clock.Add(maxDelay)
+	}
+}
+
+func (suite *LoginGuardTestSuite) TestMFALockoutAcrossChallenges() {
+	suite.Require().NoError(suite.mfa.SaveMFA(&MFA{AccountID: suite.account.ID, Secret: "JBSWY3DPEHPK3PXP", Enabled: true}))
+	suite.failMFA(mfaThrottle.lockout)
+
+	_, err := suite.underTest.Login("joel", "password1", "10.0.3.1")
+	suite.IsType(&TooManyAttemptsError{}, err, "no new challenges while locked")
+	_, err = suite.underTest.Login("joel", "wrong1", "10.0.3.1")
+	suite.IsType(&TooManyAttemptsError{}, err, "the password is not checked either")
+
+	suite.clock = suite.clock.Add(lockoutDuration)
+	login, err := suite.underTest.Login("joel", "password1", "10.0.3.1")
+	suite.Require().NoError(err)
+	suite.NotEmpty(login.MFAToken)
+}
+
+func (suite *LoginGuardTestSuite) TestMFATokenIsBoundToUsername() {
+	suite.Require().NoError(suite.mfa.SaveMFA(&MFA{AccountID: suite.account.ID, Secret: "JBSWY3DPEHPK3PXP", Enabled: true}))
+	login, err := suite.underTest.Login("joel", "password1", "10.0.0.1")
+	suite.Require().NoError(err)
+	suite.Equal("joel", mfaTokenUsername(login.MFAToken))
+
+	swapped := "other" + strings.TrimPrefix(login.MFAToken, "joel")
+	_, err = suite.underTest.VerifyMFA(&MFAVerification{MFAToken: swapped, Code: "000000"}, "10.0.0.1")
+	suite.Equal(ErrInvalidMFAToken, err)
+}
+
+// countingRecovery counts password reset requests.
+type countingRecovery struct {
+	RecoveryService
+	requests int
+}
+
+func (r *countingRecovery) RequestPasswordReset(username string) error {
+	r.requests++
+	return nil
+}
+
+func (suite *LoginGuardTestSuite) TestPasswordResetThrottle() {
+	recovery := &countingRecovery{}
+	guard := NewPasswordResetGuard(recovery, suite.attempts).(*passwordResetGuard)
+	guard.now = suite.underTest.now
+
+	for i := 0; i <= resetThrottle.free; i++ {
+		suite.Require().NoError(guard.RequestPasswordReset("joel", "10.0.0.1"), "request %d", i+1)
+	}
+	err := guard.RequestPasswordReset("joel", "10.0.0.2")
+	suite.IsType(&TooManyAttemptsError{}, err, "requests count even when they succeed")
+	suite.Equal(resetThrottle.free+1, recovery.requests)
+
+	suite.NoError(guard.RequestPasswordReset("nobody", "10.0.0.1"), "other usernames are unaffected")
+	suite.clock = suite.clock.Add(firstDelay)
+	suite.NoError(guard.RequestPasswordReset("joel", "10.0.0.1"))
+}
+
+func TestThrottleDelay(t *testing.T) {
+	policy := throttle{free: 3, lockout: 20}
+	assert.Equal(t, time.Second, policy.delay(4))
+	assert.Equal(t, 2*time.Second, policy.delay(5))
+	assert.Equal(t, 8*time.Second, policy.delay(7))
+	assert.Equal(t, maxDelay, policy.delay(19))
+}
@@ -2,23 +2,45 @@ package user
 
 import (
 	"encoding/json"
+	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+	"hex-example/internal/middleware"
+	"hex-example/internal/problem"
+	"net"
 	"net/http"
 )
 
 type UserHandler interface{
 	CreateAccount(w http.ResponseWriter, r *http.Request)
 	GetToken(w http.ResponseWriter, r *http.Request)
-	//TODO Refresh Token
+	VerifyMFA(w http.ResponseWriter, r *http.Request)
+	RefreshToken(w http.ResponseWriter, r *http.Request)
+	Logout(w http.ResponseWriter, r *http.Request)
+	ListAccounts(w http.ResponseWriter, r *http.Request)
+	SetRoles(w http.ResponseWriter, r *http.Request)
+	GetMe(w http.ResponseWriter, r *http.Request)
+	UpdateMe(w http.ResponseWriter, r *http.Request)
+	ChangePassword(w http.ResponseWriter, r *http.Request)
+	DeactivateMe(w http.ResponseWriter, r *http.Request)
+	DeleteMe(w http.ResponseWriter, r *http.Request)
+}
+
+// refreshRequest is the body of the refresh and logout endpoints.
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
 }
 
 type userHandler struct {
 	service UserService
+	guard LoginGuard
 }
 
-func NewUserHandler(service UserService) UserHandler {
+// NewUserHandler returns a UserHandler whose password logins go through
+// guard.
+func NewUserHandler(service UserService, guard LoginGuard) UserHandler {
 	return &userHandler{
 		service,
+		guard,
 	}
 }
 
@@ -27,19 +49,17 @@ func (h *userHandler) CreateAccount(w http.ResponseWriter, r *http.Request){
 	decoder := json.NewDecoder(r.Body)
 	if err := decoder.Decode(&account); err != nil{
 		logrus.Error("Unable to decode account")
-		http.Error(w, "Bad format for account", http.StatusBadRequest)
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for account"))
 		return
 	}
 	if err := h.service.CreateAccount(&account); err != nil {
-		logrus.WithField("error", err).Error("Unable to create account")
-		http.Error(w, "Unable to create account", http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 
 	response, err := json.Marshal(account)
 	if err != nil {
-		logrus.WithField("error", err).Error("Error unmarshalling response")
-		http.Error(w, "Unable to create account", http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 
@@ -53,24 +73,19 @@ func (h *userHandler) CreateAccount(w http.ResponseWriter, r *http.Request){
 func (h *userHandler) GetToken(w http.ResponseWriter, r *http.Request){
 	username, password, _ := r.BasicAuth()
 	if username == "" || password == "" {
-		http.Error(w, "No credentials provided", http.StatusUnauthorized)
+		problem.Write(w, r, problem.New(http.StatusUnauthorized, "No credentials provided"))
 		return
 	}
 
-	login, err := h.service.Login(username, password)
+	login, err := h.guard.Login(username, password, clientIP(r))
 	if err != nil{
-		logrus.WithFields(logrus.Fields{
-			"error": err,
-			"username": username,
-		}).Error("Error generating token")
-		http.Error(w, "Invalid login", http.StatusUnauthorized)
+		problem.Error(w, r, err)
 		return
 	}
 
 	response, err := json.Marshal(login)
 	if err != nil {
-		logrus.WithField("error", err).Error("Error unmarshalling response")
-		http.Error(w, "Unable to login", http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 
@@ -79,4 +94,231 @@ func (h *userHandler) GetToken(w http.ResponseWriter, r *http.Request){
 	if _, err = w.Write(response); err != nil{
 		logrus.WithField("error", err).Error("Error writing response")
 	}
-}
\ No newline at end of file
+}
+
+// VerifyMFA is the second step of a login for accounts with two-factor
+// authentication.
+func (h *userHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var verification MFAVerification
+	if err := json.NewDecoder(r.Body).Decode(&verification); err != nil || verification.MFAToken == "" {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for two-factor verification"))
+		return
+	}
+
+	login, err := h.guard.VerifyMFA(&verification, clientIP(r))
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, login)
+}
+
+func (h *userHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for refresh request"))
+		return
+	}
+
+	login, err := h.service.Refresh(req.RefreshToken)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	response, err := json.Marshal(login)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(response); err != nil {
+		logrus.WithField("error", err).Error("Error writing response")
+	}
+}
+
+func (h *userHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for logout request"))
+		return
+	}
+
+	if err := h.service.Logout(req.RefreshToken); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *userHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts, err := h.service.ListAccounts()
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	response, err := json.Marshal(accounts)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(response); err != nil {
+		logrus.WithField("error", err).Error("Error writing response")
+	}
+}
+
+func (h *userHandler) SetRoles(w http.ResponseWriter, r *http.Request) {
+	var roles Roles
+	if err := json.NewDecoder(r.Body).Decode(&roles); err != nil {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for roles"))
+		return
+	}
+
+	account, err := h.service.SetRoles(mux.Vars(r)["username"], &roles)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	response, err := json.Marshal(account)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(response); err != nil {
+		logrus.WithField("error", err).Error("Error writing response")
+	}
+}
+
+func (h *userHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+
+	account, err := h.service.GetAccount(id)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, account)
+}
+
+func (h *userHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+	var update AccountUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for account update"))
+		return
+	}
+
+	account, err := h.service.UpdateAccount(id, &update)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, account)
+}
+
+func (h *userHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+	var change PasswordChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for password change"))
+		return
+	}
+
+	if err := h.service.ChangePassword(id, &change); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *userHandler) DeactivateMe(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+	var confirmation PasswordConfirmation
+	if err := json.NewDecoder(r.Body).Decode(&confirmation); err != nil {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for password confirmation"))
+		return
+	}
+
+	if err := h.service.DeactivateAccount(id, confirmation.Password); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *userHandler) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+	var confirmation PasswordConfirmation
+	if err := json.NewDecoder(r.Body).Decode(&confirmation); err != nil {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for password confirmation"))
+		return
+	}
+
+	if err := h.service.DeleteAccount(id, confirmation.Password); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clientIP returns the IP address of the connecting client. Forwarding
+// headers are ignored since any client can set them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// currentAccount returns the account ID of the authenticated user, writing
+// a 403 for other principals such as service accounts.
+func currentAccount(w http.ResponseWriter, r *http.Request) (string, bool) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok || principal.Type != middleware.PrincipalUser {
+		problem.Write(w, r, problem.New(http.StatusForbidden, "Only user accounts have a profile"))
+		return "", false
+	}
+	return principal.ID, true
+}
+
+// writeJSON writes v as the JSON response body with status.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	response, err := json.Marshal(v)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err = w.Write(response); err != nil {
+		logrus.WithField("error", err).Error("Error writing response")
+	}
+}
@@ -0,0 +1,165 @@
+package user
+
+import (
+	"image"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const qrCodeSize = 256
+
+// MFAService enrolls accounts in TOTP two-factor authentication.
+type MFAService interface {
+	// Enroll generates a new secret for the account, replacing any earlier
+	// unconfirmed one. It takes effect once confirmed.
+	Enroll(accountID string) (*MFAEnrollment, error)
+	// QRCode renders the pending enrollment of the account.
+	QRCode(accountID string) (image.Image, error)
+	// Confirm enables two-factor authentication once the account holder
+	// proves their authenticator works, returning the recovery codes.
+	Confirm(accountID, code string) (*RecoveryCodes, error)
+	// RegenerateRecoveryCodes replaces every recovery code.
+	RegenerateRecoveryCodes(accountID, code string) (*RecoveryCodes, error)
+	// Disable removes the second factor after checking the password.
+	Disable(accountID, password string) error
+}
+
+type mfaService struct {
+	repo   UserRepo
+	mfa    MFARepo
+	issuer string
+}
+
+// NewMFAService returns an MFAService whose otpauth URIs name issuer, which
+// authenticator apps show next to the code.
+func NewMFAService(repo UserRepo, mfa MFARepo, issuer string) MFAService {
+	return &mfaService{
+		repo,
+		mfa,
+		issuer,
+	}
+}
+
+func (s *mfaService) Enroll(accountID string) (*MFAEnrollment, error) {
+	account, err := s.repo.GetUserByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.mfa.GetMFA(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.Enabled {
+		return nil, ErrMFAEnabled
+	}
+
+	key, err := totpKey(s.issuer, account, "")
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to generate TOTP secret")
+		return nil, err
+	}
+	if err := s.mfa.SaveMFA(&MFA{AccountID: accountID, Secret: key.Secret()}); err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to save second factor")
+		return nil, err
+	}
+	return &MFAEnrollment{Secret: key.Secret(), URI: key.URL()}, nil
+}
+
+func (s *mfaService) QRCode(accountID string) (image.Image, error) {
+	account, err := s.repo.GetUserByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	mfa, err := s.pending(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := totpKey(s.issuer, account, mfa.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return key.Image(qrCodeSize, qrCodeSize)
+}
+
+func (s *mfaService) Confirm(accountID, code string) (*RecoveryCodes, error) {
+	mfa, err := s.pending(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if !checkTOTP(mfa, code, time.Now()) {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	mfa.Enabled = true
+	mfa.RecoveryCodes = hashes
+	if err := s.mfa.SaveMFA(mfa); err != nil {
+		logrus.WithFields(logrus.Fields{"id": accountID, "error": err}).Error("Unable to save second factor")
+		return nil, err
+	}
+	logrus.WithField("id", accountID).Info("Two-factor authentication enabled")
+	return &RecoveryCodes{Codes: codes}, nil
+}
+
+func (s *mfaService) RegenerateRecoveryCodes(accountID, code string) (*RecoveryCodes, error) {
+	mfa, err := s.mfa.GetMFA(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil || !mfa.Enabled {
+		return nil, ErrMFANotEnrolled
+	}
+	if !checkTOTP(mfa, code, time.Now()) {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	mfa.RecoveryCodes = hashes
+	if err := s.mfa.SaveMFA(mfa); err != nil {
+		logrus.WithFields(logrus.Fields{"id": accountID, "error": err}).Error("Unable to save second factor")
+		return nil, err
+	}
+	return &RecoveryCodes{Codes: codes}, nil
+}
+
+func (s *mfaService) Disable(accountID, password string) error {
+	account, err := s.repo.GetUserByID(accountID)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(password)); err != nil {
+		logrus.WithField("username", account.Username).Info("Wrong password disabling two-factor authentication")
+		return ErrWrongPassword
+	}
+
+	if err := s.mfa.DeleteMFA(accountID); err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to delete second factor")
+		return err
+	}
+	logrus.WithField("username", account.Username).Info("Two-factor authentication disabled")
+	return nil
+}
+
+// pending returns the unconfirmed second factor of the account.
+func (s *mfaService) pending(accountID string) (*MFA, error) {
+	mfa, err := s.mfa.GetMFA(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if mfa.Enabled {
+		return nil, ErrMFAEnabled
+	}
+	return mfa, nil
+}
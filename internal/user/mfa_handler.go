@@ -0,0 +1,120 @@
+package user
+
+import (
+	"encoding/json"
+	"image/png"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/problem"
+)
+
+type MFAHandler interface {
+	Enroll(w http.ResponseWriter, r *http.Request)
+	QRCode(w http.ResponseWriter, r *http.Request)
+	Confirm(w http.ResponseWriter, r *http.Request)
+	RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request)
+	Disable(w http.ResponseWriter, r *http.Request)
+}
+
+type mfaHandler struct {
+	service MFAService
+}
+
+func NewMFAHandler(service MFAService) MFAHandler {
+	return &mfaHandler{
+		service,
+	}
+}
+
+func (h *mfaHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.service.Enroll(id)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	// the secret must not end up in shared caches
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, r, http.StatusCreated, enrollment)
+}
+
+func (h *mfaHandler) QRCode(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+
+	img, err := h.service.QRCode(id)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := png.Encode(w, img); err != nil {
+		logrus.WithField("error", err).Error("Error writing response")
+	}
+}
+
+func (h *mfaHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+	var code MFACode
+	if err := json.NewDecoder(r.Body).Decode(&code); err != nil {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for two-factor code"))
+		return
+	}
+
+	codes, err := h.service.Confirm(id, code.Code)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, r, http.StatusOK, codes)
+}
+
+func (h *mfaHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+	var code MFACode
+	if err := json.NewDecoder(r.Body).Decode(&code); err != nil {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for two-factor code"))
+		return
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(id, code.Code)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, r, http.StatusOK, codes)
+}
+
+func (h *mfaHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+	var confirmation PasswordConfirmation
+	if err := json.NewDecoder(r.Body).Decode(&confirmation); err != nil {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for password confirmation"))
+		return
+	}
+
+	if err := h.service.Disable(id, confirmation.Password); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,159 @@
+package user
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/suite"
+	"hex-example/internal/jwks"
+)
+
+func TestMFAServiceSuite(t *testing.T) {
+	suite.Run(t, new(MFAServiceTestSuite))
+}
+
+type MFAServiceTestSuite struct {
+	suite.Suite
+	users     *fakeUserRepo
+	mfa       *fakeMFARepo
+	accounts  UserService
+	underTest MFAService
+	id        string
+}
+
+func (suite *MFAServiceTestSuite) SetupTest() {
+	keys, err := jwks.NewKeyRing(jwks.NewMemoryStore(), jwks.ES256, time.Hour, accessTokenTTL, 0)
+	suite.Require().NoError(err)
+	suite.users = newFakeUserRepo()
+	suite.mfa = newFakeMFARepo()
+	suite.accounts = NewUserService(suite.users, newFakeTokenRepo(), suite.mfa, newFakeActionTokenRepo(), keys)
+	suite.underTest = NewMFAService(suite.users, suite.mfa, "hex-example")
+
+	account := &Account{Username: "joel", Password: "password1"}
+	suite.Require().NoError(suite.accounts.CreateAccount(account))
+	suite.id = account.ID
+}
+
+// code returns the TOTP code for the current secret, offset by steps.
+func (suite *MFAServiceTestSuite) code(steps int) string {
+	m, _ := suite.mfa.GetMFA(suite.id)
+	suite.Require().NotNil(m)
+	code, err := totp.GenerateCodeCustom(m.Secret, time.Now().Add(time.Duration(steps)*totpPeriod*time.Second), totpOpts)
+	suite.Require().NoError(err)
+	return code
+}
+
+// enable enrolls joel and returns the recovery codes.
+func (suite *MFAServiceTestSuite) enable() []string {
+	_, err := suite.underTest.Enroll(suite.id)
+	suite.Require().NoError(err)
+	codes, err := suite.underTest.Confirm(suite.id, suite.code(0))
+	suite.Require().NoError(err)
+	return codes.Codes
+}
+
+func (suite *MFAServiceTestSuite) TestEnroll() {
+	enrollment, err := suite.underTest.Enroll(suite.id)
+
+	suite.Require().NoError(err)
+	suite.NotEmpty(enrollment.Secret)
+	suite.True(strings.HasPrefix(enrollment.URI, "otpauth://totp/hex-example:joel?"), enrollment.URI)
+	img, err := suite.underTest.QRCode(suite.id)
+	if suite.NoError(err) {
+		suite.Equal(qrCodeSize, img.Bounds().Dx())
+	}
+
+	login, err := suite.accounts.Login("joel", "password1")
+	suite.Require().NoError(err)
+	suite.NotEmpty(login.Token, "unconfirmed enrollments do not change login")
+}
+
+func (suite *MFAServiceTestSuite) TestConfirm() {
+	_, err := suite.underTest.Confirm(suite.id, "123456")
+	suite.Equal(ErrMFANotEnrolled, err)
+
+	_, err = suite.underTest.Enroll(suite.id)
+	suite.Require().NoError(err)
+	_, err = suite.underTest.Confirm(suite.id, "not a code")
+	suite.Equal(ErrInvalidMFACode, err)
+
+	codes, err := suite.underTest.Confirm(suite.id, suite.code(0))
+	suite.Require().NoError(err)
+	suite.Len(codes.Codes, recoveryCodeCount)
+
+	_, err = suite.underTest.Enroll(suite.id)
+	suite.Equal(ErrMFAEnabled, err)
+}
+
+func (suite *MFAServiceTestSuite) TestTwoStepLogin() {
+	suite.enable()
+
+	login, err := suite.accounts.Login("joel", "password1")
+	suite.Require().NoError(err)
+	suite.Empty(login.Token)
+	suite.Empty(login.RefreshToken)
+	suite.Require().NotEmpty(login.MFAToken)
+
+	_, err = suite.accounts.VerifyMFA(&MFAVerification{MFAToken: login.MFAToken, Code: suite.code(0)})
+	suite.Equal(ErrInvalidMFACode, err, "the code used to confirm cannot be replayed")
+
+	verified, err := suite.accounts.VerifyMFA(&MFAVerification{MFAToken: login.MFAToken, Code: suite.code(1)})
+	suite.Require().NoError(err)
+	suite.NotEmpty(verified.Token)
+	suite.NotEmpty(verified.RefreshToken)
+
+	_, err = suite.accounts.VerifyMFA(&MFAVerification{MFAToken: login.MFAToken, Code: suite.code(1)})
+	suite.Equal(ErrInvalidMFAToken, err, "challenges are single-use")
+}
+
+func (suite *MFAServiceTestSuite) TestRecoveryCodes() {
+	codes := suite.enable()
+
+	login, _ := suite.accounts.Login("joel", "password1")
+	_, err := suite.accounts.VerifyMFA(&MFAVerification{MFAToken: login.MFAToken, Code: strings.ToUpper(codes[0])})
+	suite.NoError(err)
+
+	login, _ = suite.accounts.Login("joel", "password1")
+	_, err = suite.accounts.VerifyMFA(&MFAVerification{MFAToken: login.MFAToken, Code: codes[0]})
+	suite.Equal(ErrInvalidMFACode, err, "recovery codes are single-use")
+
+	m, _ := suite.mfa.GetMFA(suite.id)
+	suite.Len(m.RecoveryCodes, recoveryCodeCount-1)
+}
+
+func (suite *MFAServiceTestSuite) TestChallengeAttemptsAreLimited() {
+	suite.enable()
+	login, _ := suite.accounts.Login("joel", "password1")
+
+	for i := 0; i < maxMFAAttempts; i++ {
+		_, err := suite.accounts.VerifyMFA(&MFAVerification{MFAToken: login.MFAToken, Code: "000000"})
+		suite.Equal(ErrInvalidMFACode, err)
+	}
+	_, err := suite.accounts.VerifyMFA(&MFAVerification{MFAToken: login.MFAToken, Code: suite.code(1)})
+	suite.Equal(ErrInvalidMFAToken, err)
+}
+
+func (suite *MFAServiceTestSuite) TestDisable() {
+	suite.enable()
+
+	suite.Equal(ErrWrongPassword, suite.underTest.Disable(suite.id, "wrong1"))
+	suite.NoError(suite.underTest.Disable(suite.id, "password1"))
+
+	login, err := suite.accounts.Login("joel", "password1")
+	suite.Require().NoError(err)
+	suite.NotEmpty(login.Token)
+}
+
+func (suite *MFAServiceTestSuite) TestRegenerateRecoveryCodes() {
+	old := suite.enable()
+
+	codes, err := suite.underTest.RegenerateRecoveryCodes(suite.id, suite.code(1))
+	suite.Require().NoError(err)
+	suite.NotEqual(old, codes.Codes)
+
+	login, _ := suite.accounts.Login("joel", "password1")
+	_, err = suite.accounts.VerifyMFA(&MFAVerification{MFAToken: login.MFAToken, Code: old[0]})
+	suite.Equal(ErrInvalidMFACode, err)
+}
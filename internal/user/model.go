@@ -1,15 +1,339 @@
 package user
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
 type Account struct {
 	ID string `json:"id"`
 	Username string `json:"username"`
 	FirstName string `json:"firstName"`
 	LastName string `json:"lastName"`
 	Password string `json:"password,omitempty"`
+	// Email is optional; password resets are only mailed once it has been
+	// verified.
+	Email string `json:"email,omitempty"`
+	EmailVerified bool `json:"emailVerified,omitempty"`
+	// Role is the global rbac role; empty means none.
+	Role string `json:"role,omitempty"`
+	// Projects maps project IDs to the rbac role held in that project.
+	Projects map[string]string `json:"projects,omitempty"`
+	// Deactivated accounts can no longer log in or refresh tokens.
+	Deactivated bool `json:"deactivated,omitempty"`
+}
+
+// AccountUpdate is the body of a profile update. Nil fields are left
+// unchanged.
+type AccountUpdate struct {
+	FirstName *string `json:"firstName,omitempty"`
+	LastName *string `json:"lastName,omitempty"`
+	// Email changes clear the verified flag and are confirmed by mail.
+	Email *string `json:"email,omitempty"`
+}
+
+// PasswordChange is the body of a password change.
+type PasswordChange struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+// PasswordConfirmation re-authenticates destructive account operations.
+type PasswordConfirmation struct {
+	Password string `json:"password"`
+}
+
+// PasswordResetRequest is the body of a password reset request.
+type PasswordResetRequest struct {
+	Username string `json:"username"`
+}
+
+// PasswordReset is the body of a password reset, carrying the token that was
+// mailed to the account holder.
+type PasswordReset struct {
+	Token string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+// Roles is the body of a role assignment.
+type Roles struct {
+	Role string `json:"role"`
+	Projects map[string]string `json:"projects,omitempty"`
 }
 
 type Login struct {
 	Username string `json:"username"`
+	// AccountID is set once the account is logged in, with the tokens.
+	AccountID string `json:"accountId,omitempty"`
 	Password string `json:"password,omitempty"`
 	Token string `json:"token"`
-}
\ No newline at end of file
+	ExpiresIn int64 `json:"expiresIn"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	// MFAToken is returned instead of tokens for accounts with two-factor
+	// authentication. It is exchanged for tokens together with a code.
+	MFAToken string `json:"mfaToken,omitempty"`
+}
+
+// MFAVerification is the second step of a two-factor login.
+type MFAVerification struct {
+	MFAToken string `json:"mfaToken"`
+	// Code is a TOTP code or an unused recovery code.
+	Code string `json:"code"`
+}
+
+// MFA is the TOTP second factor of an account.
+type MFA struct {
+	AccountID string `json:"accountId"`
+	// Secret is the base32 encoded TOTP secret.
+	Secret string `json:"secret"`
+	// Enabled stays false until enrollment is confirmed with a first code.
+	Enabled bool `json:"enabled"`
+	// RecoveryCodes are hashes of the unused single-use recovery codes.
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+	// LastStep is the TOTP time step of the last accepted code, so that a
+	// code cannot be used twice.
+	LastStep int64 `json:"lastStep,omitempty"`
+}
+
+// MFAEnrollment is handed to the account holder to set up an authenticator
+// app.
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth:// URI, also served as a QR code.
+	URI string `json:"uri"`
+}
+
+// MFACode is the body of requests confirmed with a TOTP code.
+type MFACode struct {
+	Code string `json:"code"`
+}
+
+// RecoveryCodes are shown once when generated.
+type RecoveryCodes struct {
+	Codes []string `json:"recoveryCodes"`
+}
+
+// RefreshToken is the server-side record of an opaque refresh token. Only
+// a hash of the token handed to the client is stored. Every token issued by
+// rotating another belongs to the same Family as the token it replaced.
+type RefreshToken struct {
+	Hash string `json:"hash"`
+	Family string `json:"family"`
+	AccountID string `json:"accountId"`
+	Username string `json:"username"`
+	// AuthTime is when the account holder authenticated to start the family.
+	AuthTime time.Time `json:"authTime"`
+	Expires time.Time `json:"expires"`
+}
+// Purposes of an ActionToken.
+const (
+	PurposeVerifyEmail         = "verify_email"
+	PurposeResetPassword       = "reset_password"
+	PurposeMFAChallenge        = "mfa_challenge"
+	PurposePasskeyRegistration = "passkey_registration"
+	PurposePasskeyLogin        = "passkey_login"
+	PurposeAuthorizationCode   = "authorization_code"
+)
+
+// ActionToken is the server-side record of a single-use token mailed to an
+// account holder, or of a pending login or passkey ceremony. Only a hash of
+// the token is stored.
+type ActionToken struct {
+	Hash string `json:"hash"`
+	Purpose string `json:"purpose"`
+	AccountID string `json:"accountId"`
+	// Email is the address the token was sent to, so that a verification
+	// link stops working once the address is changed again.
+	Email string `json:"email"`
+	Expires time.Time `json:"expires"`
+	// Attempts counts wrong codes entered for an MFA challenge.
+	Attempts int `json:"attempts,omitempty"`
+	// Session is the WebAuthn session data of a passkey ceremony, or the
+	// AuthorizationGrant of an authorization code.
+	Session json.RawMessage `json:"session,omitempty"`
+}
+
+// Passkey is a WebAuthn credential registered to an account.
+type Passkey struct {
+	// ID is the base64url encoded credential ID.
+	ID string `json:"id"`
+	AccountID string `json:"accountId"`
+	Name string `json:"name,omitempty"`
+	Created time.Time `json:"created"`
+	LastUsed time.Time `json:"lastUsed,omitempty"`
+	// Credential holds the public key and signature counter. It is left out
+	// of API responses.
+	Credential *webauthn.Credential `json:"credential,omitempty"`
+}
+
+// PasskeyCeremony is handed to the browser to start a WebAuthn registration
+// or login. Its ID is passed back together with the authenticator response.
+type PasskeyCeremony struct {
+	ID string `json:"ceremonyId"`
+	// Options are the credential creation or request options for
+	// navigator.credentials.create or get.
+	Options interface{} `json:"options"`
+}
+
+// Identity links an account to a user of an external identity provider,
+// such as Facebook.
+type Identity struct {
+	Provider string `json:"provider"`
+	// Subject is the ID of the user at the provider.
+	Subject string `json:"subject"`
+	AccountID string `json:"accountId"`
+	Linked time.Time `json:"linked"`
+	// Created is set when the account was created for this identity, rather
+	// than found by its verified email address.
+	Created bool `json:"created"`
+}
+
+// ExternalProfile is what an identity provider tells about a user who
+// signed in with it.
+type ExternalProfile struct {
+	Provider string
+	Subject string
+	Name string
+	Email string
+	// EmailVerified is set when the provider vouches that the user owns
+	// Email.
+	EmailVerified bool
+}
+
+// OAuthClient is an application registered to log users in with their
+// accounts through OpenID Connect.
+type OAuthClient struct {
+	ID string `json:"clientId"`
+	Name string `json:"name"`
+	// RedirectURIs are the only URIs authorization responses are sent to,
+	// compared exactly.
+	RedirectURIs []string `json:"redirectUris"`
+	// Public clients, such as single-page and mobile apps, cannot keep a
+	// secret and authenticate with PKCE alone.
+	Public bool `json:"public"`
+	SecretHash string `json:"secretHash,omitempty"`
+	CreatedBy string `json:"createdBy,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// IssuedOAuthClient is a newly registered client. Secret is only ever
+// returned once, and never for public clients.
+type IssuedOAuthClient struct {
+	Secret string `json:"clientSecret,omitempty"`
+	*OAuthClient
+}
+
+// Consent records the scopes an account allowed a client to receive.
+type Consent struct {
+	AccountID string `json:"accountId"`
+	ClientID string `json:"clientId"`
+	Scopes []string `json:"scopes"`
+	Granted time.Time `json:"granted"`
+}
+
+// AuthorizationRequest is an OpenID Connect authentication request, as
+// received at the authorization endpoint and passed on to the consent page.
+type AuthorizationRequest struct {
+	ResponseType string `json:"responseType"`
+	ClientID string `json:"clientId"`
+	RedirectURI string `json:"redirectUri"`
+	Scope string `json:"scope"`
+	State string `json:"state,omitempty"`
+	Nonce string `json:"nonce,omitempty"`
+	CodeChallenge string `json:"codeChallenge"`
+	CodeChallengeMethod string `json:"codeChallengeMethod"`
+}
+
+// ConsentPrompt is what the consent page shows the account holder.
+type ConsentPrompt struct {
+	ClientID string `json:"clientId"`
+	ClientName string `json:"clientName"`
+	Scopes []string `json:"scopes"`
+	// Consented is set when the account already allowed every scope, so the
+	// page may approve without asking.
+	Consented bool `json:"consented"`
+}
+
+// ConsentDecision is the account holder's answer to a ConsentPrompt.
+type ConsentDecision struct {
+	AuthorizationRequest
+	Approve bool `json:"approve"`
+}
+
+// AuthorizationResponse tells the consent page where to send the browser.
+type AuthorizationResponse struct {
+	RedirectTo string `json:"redirectTo"`
+}
+
+// AuthorizationGrant is what an authorization code stands for until the
+// client exchanges it.
+type AuthorizationGrant struct {
+	ClientID string `json:"clientId"`
+	RedirectURI string `json:"redirectUri"`
+	Scopes []string `json:"scopes"`
+	Nonce string `json:"nonce,omitempty"`
+	CodeChallenge string `json:"codeChallenge"`
+	AuthTime int64 `json:"authTime"`
+}
+
+// TokenRequest is a token endpoint request for the authorization code
+// grant. ClientSecret is empty for public clients.
+type TokenRequest struct {
+	GrantType string
+	Code string
+	RedirectURI string
+	ClientID string
+	ClientSecret string
+	CodeVerifier string
+}
+
+// TokenResponse is the successful token endpoint response.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType string `json:"token_type"`
+	ExpiresIn int64 `json:"expires_in"`
+	IDToken string `json:"id_token"`
+	Scope string `json:"scope"`
+}
+
+// ServiceAccount is a non-human principal, such as a CI job or a bot, that
+// authenticates with API keys instead of a password.
+type ServiceAccount struct {
+	ID string `json:"id"`
+	Name string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Scopes are the rbac permissions keys of this account may be granted.
+	Scopes []string `json:"scopes"`
+	CreatedBy string `json:"createdBy,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// APIKey is the server-side record of an API key. Only a hash of the
+// secret handed to the client is stored; ID is the public part of the key
+// used to look it up.
+type APIKey struct {
+	ID string `json:"id"`
+	ServiceAccountID string `json:"serviceAccountId"`
+	Hash string `json:"hash,omitempty"`
+	Scopes []string `json:"scopes"`
+	Created time.Time `json:"created"`
+	// Expires is zero for keys that never expire.
+	Expires time.Time `json:"expires,omitempty"`
+	LastUsed time.Time `json:"lastUsed,omitempty"`
+}
+
+// APIKeyRequest is the body of an API key creation request.
+type APIKeyRequest struct {
+	// Scopes default to those of the service account.
+	Scopes []string `json:"scopes,omitempty"`
+	// ExpiresIn is the lifetime of the key in seconds; zero never expires.
+	ExpiresIn int64 `json:"expiresIn,omitempty"`
+}
+
+// IssuedAPIKey is a newly created key. Key is only ever returned once.
+type IssuedAPIKey struct {
+	Key string `json:"key"`
+	*APIKey
+}
@@ -0,0 +1,565 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/env"
+	"hex-example/internal/jwks"
+	"hex-example/internal/middleware"
+	"hex-example/internal/validation"
+)
+
+// Endpoints of the OpenID Connect provider, relative to the issuer.
+const (
+	OIDCDiscoveryPath = "/.well-known/openid-configuration"
+	OIDCAuthorizePath = "/oauth/authorize"
+	OIDCTokenPath     = "/oauth/token"
+	OIDCUserInfoPath  = "/oauth/userinfo"
+)
+
+// OpenID Connect scopes. Other requested scopes are ignored.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+const (
+	authorizationCodeTTL = time.Minute
+	clientIDLen          = 16
+	clientSecretLen      = 32
+	maxClientNameLength  = 64
+	maxRedirectURIs      = 10
+	// RFC 7636 4.1: a verifier has 43 to 128 characters, and an S256
+	// challenge is 43 characters.
+	minCodeVerifierLength = 43
+	maxCodeVerifierLength = 128
+	codeChallengeLength   = 43
+)
+
+var oidcScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+// OIDCDiscovery is the OpenID Provider Metadata served at
+// OIDCDiscoveryPath.
+type OIDCDiscovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// OIDCService makes the user API an OpenID Connect provider for registered
+// clients, using the authorization code flow with PKCE.
+type OIDCService interface {
+	Discovery() *OIDCDiscovery
+	RegisterClient(client *OAuthClient, createdBy string) (*IssuedOAuthClient, error)
+	ListClients() ([]*OAuthClient, error)
+	DeleteClient(id string) error
+	// ValidateAuthorization checks an authentication request before the
+	// account holder is asked for consent. A ValidationError means the
+	// client or redirect URI cannot be trusted, so the error must not be
+	// redirected; an OAuthError is sent back to the client.
+	ValidateAuthorization(req *AuthorizationRequest) error
+	// Prompt returns what the account holder is asked to consent to.
+	Prompt(accountID string, req *AuthorizationRequest) (*ConsentPrompt, error)
+	// Authorize records the decision of the account holder, who logged in
+	// at authTime, and returns where to send the browser: back to the
+	// client with an authorization code, or with access_denied.
+	Authorize(accountID string, authTime time.Time, decision *ConsentDecision) (*AuthorizationResponse, error)
+	// Exchange redeems an authorization code for an access token and an ID
+	// token.
+	Exchange(req *TokenRequest) (*TokenResponse, error)
+	// UserInfo returns the claims about the account released by scopes.
+	UserInfo(accountID string, scopes []string) (map[string]interface{}, error)
+	ListConsents(accountID string) ([]*Consent, error)
+	RevokeConsent(accountID, clientID string) error
+}
+
+type oidcService struct {
+	clients OAuthClientRepo
+	codes   ActionTokenRepo
+	repo    UserRepo
+	signer  Signer
+	issuer  string
+}
+
+// NewOIDCService returns an OIDCService signing tokens with signer, the key
+// ring that also signs user API access tokens. The issuer is JWT_ISSUER,
+// which must be the public URL of the user API. Authorization codes are
+// kept in codes until they are redeemed.
+func NewOIDCService(clients OAuthClientRepo, codes ActionTokenRepo, repo UserRepo, signer Signer) OIDCService {
+	return &oidcService{
+		clients,
+		codes,
+		repo,
+		signer,
+		strings.TrimSuffix(env.EnvString("JWT_ISSUER", middleware.DefaultIssuer), "/"),
+	}
+}
+
+func (s *oidcService) Discovery() *OIDCDiscovery {
+	return &OIDCDiscovery{
+		Issuer:                            s.issuer,
+		AuthorizationEndpoint:             s.issuer + OIDCAuthorizePath,
+		TokenEndpoint:                     s.issuer + OIDCTokenPath,
+		UserInfoEndpoint:                  s.issuer + OIDCUserInfoPath,
+		JWKSURI:                           s.issuer + jwks.Path,
+		ScopesSupported:                   oidcScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{jwks.ES256, jwks.RS256},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported: []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce",
+			"name", "given_name", "family_name", "preferred_username", "email", "email_verified"},
+	}
+}
+
+// RegisterClient registers a client. Confidential clients get a secret,
+// which is only returned here.
+func (s *oidcService) RegisterClient(client *OAuthClient, createdBy string) (*IssuedOAuthClient, error) {
+	v := new(validation.Validator)
+	v.Empty("clientId", client.ID != "")
+	v.Empty("secretHash", client.SecretHash != "")
+	if v.Required("name", client.Name) {
+		v.Length("name", client.Name, 1, maxClientNameLength)
+	}
+	if v.Check(len(client.RedirectURIs) > 0, "redirectUris", "at least one redirect URI is required") {
+		v.Check(len(client.RedirectURIs) <= maxRedirectURIs, "redirectUris", "too many redirect URIs")
+	}
+	for _, uri := range client.RedirectURIs {
+		v.Check(validRedirectURI(uri), "redirectUris", "invalid redirect URI "+uri)
+	}
+	if err := v.Err(); err != nil {
+		logrus.WithField("error", err).Info("Invalid OAuth client")
+		return nil, err
+	}
+
+	id := make([]byte, clientIDLen)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	client.ID = hex.EncodeToString(id)
+	client.CreatedBy = createdBy
+	client.Created = time.Now()
+	issued := &IssuedOAuthClient{OAuthClient: client}
+	if !client.Public {
+		secret := make([]byte, clientSecretLen)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		issued.Secret = base64.RawURLEncoding.EncodeToString(secret)
+		client.SecretHash = hashAPIKeySecret(issued.Secret)
+	}
+	if err := s.clients.CreateClient(client); err != nil {
+		logrus.WithField("error", err).Error("Unable to save OAuth client")
+		return nil, err
+	}
+	logrus.WithFields(logrus.Fields{"clientId": client.ID, "name": client.Name, "createdBy": createdBy}).Info("OAuth client registered")
+
+	stored := *client
+	stored.SecretHash = ""
+	issued.OAuthClient = &stored
+	return issued, nil
+}
+
+// validRedirectURI accepts absolute https URIs without a fragment, and http
+// URIs on the loopback interface for development.
+func validRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" || u.Fragment != "" {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	}
+	return false
+}
+
+func (s *oidcService) ListClients() ([]*OAuthClient, error) {
+	clients, err := s.clients.ListClients()
+	if err != nil {
+		return nil, err
+	}
+	for _, client := range clients {
+		client.SecretHash = ""
+	}
+	return clients, nil
+}
+
+func (s *oidcService) DeleteClient(id string) error {
+	if _, err := s.clients.GetClient(id); err != nil {
+		return err
+	}
+	if err := s.clients.DeleteClient(id); err != nil {
+		logrus.WithFields(logrus.Fields{"clientId": id, "error": err}).Error("Unable to delete OAuth client")
+		return err
+	}
+	logrus.WithField("clientId", id).Info("OAuth client deleted")
+	return nil
+}
+
+func (s *oidcService) ValidateAuthorization(req *AuthorizationRequest) error {
+	_, _, err := s.check(req)
+	return err
+}
+
+// check returns the client of req and the supported scopes it asks for.
+func (s *oidcService) check(req *AuthorizationRequest) (*OAuthClient, []string, error) {
+	client, err := s.clients.GetClient(req.ClientID)
+	if _, ok := err.(*OAuthClientNotFoundError); ok {
+		v := new(validation.Validator)
+		v.Check(false, "client_id", "unknown client")
+		return nil, nil, v.Err()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !contains(client.RedirectURIs, req.RedirectURI) {
+		v := new(validation.Validator)
+		v.Check(false, "redirect_uri", "not registered for the client")
+		return nil, nil, v.Err()
+	}
+
+	if req.ResponseType != "code" {
+		return nil, nil, &OAuthError{Code: "unsupported_response_type", Description: "Only the code response type is supported"}
+	}
+	var scopes []string
+	for _, scope := range strings.Fields(req.Scope) {
+		if contains(oidcScopes, scope) && !contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if !contains(scopes, ScopeOpenID) {
+		return nil, nil, &OAuthError{Code: "invalid_scope", Description: "The openid scope is required"}
+	}
+	if req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) != codeChallengeLength {
+		return nil, nil, &OAuthError{Code: "invalid_request", Description: "PKCE with the S256 method is required"}
+	}
+	return client, scopes, nil
+}
+
+func (s *oidcService) Prompt(accountID string, req *AuthorizationRequest) (*ConsentPrompt, error) {
+	client, scopes, err := s.check(req)
+	if err != nil {
+		return nil, err
+	}
+	consent, err := s.clients.GetConsent(accountID, client.ID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"id": accountID, "error": err}).Error("Unable to fetch consent")
+		return nil, err
+	}
+
+	consented := consent != nil
+	for _, scope := range scopes {
+		consented = consented && contains(consent.Scopes, scope)
+	}
+	return &ConsentPrompt{
+		ClientID:   client.ID,
+		ClientName: client.Name,
+		Scopes:     scopes,
+		Consented:  consented,
+	}, nil
+}
+
+func (s *oidcService) Authorize(accountID string, authTime time.Time, decision *ConsentDecision) (*AuthorizationResponse, error) {
+	req := &decision.AuthorizationRequest
+	client, scopes, err := s.check(req)
+	if oauthErr, ok := err.(*OAuthError); ok {
+		return &AuthorizationResponse{RedirectTo: AuthorizationErrorRedirect(req, oauthErr)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	account, err := s.repo.GetUserByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if account.Deactivated {
+		return nil, ErrAccountDeactivated
+	}
+	if !decision.Approve {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "clientId": client.ID}).Info("Authorization denied")
+		return &AuthorizationResponse{RedirectTo: AuthorizationErrorRedirect(req, ErrAccessDenied)}, nil
+	}
+
+	if err := s.consent(account, client, scopes); err != nil {
+		return nil, err
+	}
+	value, code, err := newActionToken(PurposeAuthorizationCode, account, authorizationCodeTTL)
+	if err != nil {
+		return nil, err
+	}
+	code.Session, err = json.Marshal(&AuthorizationGrant{
+		ClientID:      client.ID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        scopes,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      authTime.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.codes.SaveActionToken(code); err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to save authorization code")
+		return nil, err
+	}
+	logrus.WithFields(logrus.Fields{"username": account.Username, "clientId": client.ID}).Info("Authorization granted")
+
+	params := url.Values{"code": {value}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return &AuthorizationResponse{RedirectTo: withQuery(req.RedirectURI, params)}, nil
+}
+
+// consent adds scopes to those the account allowed the client.
+func (s *oidcService) consent(account *Account, client *OAuthClient, scopes []string) error {
+	consent, err := s.clients.GetConsent(account.ID, client.ID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to fetch consent")
+		return err
+	}
+	if consent == nil {
+		consent = &Consent{AccountID: account.ID, ClientID: client.ID}
+	}
+	for _, scope := range scopes {
+		if !contains(consent.Scopes, scope) {
+			consent.Scopes = append(consent.Scopes, scope)
+		}
+	}
+	consent.Granted = time.Now()
+	if err := s.clients.SaveConsent(consent); err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to save consent")
+		return err
+	}
+	return nil
+}
+
+// AuthorizationErrorRedirect returns the redirect URI of req with err and
+// the state of req added, as RFC 6749 4.1.2.1 sends errors to the client.
+func AuthorizationErrorRedirect(req *AuthorizationRequest, err *OAuthError) string {
+	params := url.Values{"error": {err.Code}, "error_description": {err.Description}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return withQuery(req.RedirectURI, params)
+}
+
+// withQuery returns uri with params added to its query.
+func withQuery(uri string, params url.Values) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	query := u.Query()
+	for name, values := range params {
+		query[name] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func (s *oidcService) Exchange(req *TokenRequest) (*TokenResponse, error) {
+	if req.GrantType != "authorization_code" {
+		return nil, &OAuthError{Code: "unsupported_grant_type", Description: "Only the authorization_code grant is supported"}
+	}
+	client, err := s.authenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.codes.TakeActionToken(PurposeAuthorizationCode, hashActionToken(req.Code))
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch authorization code")
+		return nil, err
+	}
+	if stored == nil || time.Now().After(stored.Expires) {
+		return nil, ErrInvalidGrant
+	}
+	grant := new(AuthorizationGrant)
+	if err := json.Unmarshal(stored.Session, grant); err != nil {
+		return nil, err
+	}
+	if grant.ClientID != client.ID || grant.RedirectURI != req.RedirectURI || !verifyCodeChallenge(grant.CodeChallenge, req.CodeVerifier) {
+		logrus.WithField("clientId", client.ID).Info("Authorization code presented with a wrong client, redirect URI or verifier")
+		return nil, ErrInvalidGrant
+	}
+
+	account, err := s.repo.GetUserByID(stored.AccountID)
+	if _, ok := err.(*NotFoundError); ok {
+		return nil, ErrInvalidGrant
+	}
+	if err != nil {
+		return nil, err
+	}
+	if account.Deactivated {
+		return nil, ErrInvalidGrant
+	}
+	return s.tokens(account, client, grant)
+}
+
+// authenticateClient returns the client with id once its secret matches.
+// Public clients have no secret and are authenticated by PKCE instead.
+func (s *oidcService) authenticateClient(id, secret string) (*OAuthClient, error) {
+	client, err := s.clients.GetClient(id)
+	if _, ok := err.(*OAuthClientNotFoundError); ok {
+		return nil, ErrInvalidClient
+	}
+	if err != nil {
+		return nil, err
+	}
+	if client.Public {
+		return client, nil
+	}
+	hash := hashAPIKeySecret(secret)
+	if secret == "" || subtle.ConstantTimeCompare([]byte(hash), []byte(client.SecretHash)) != 1 {
+		logrus.WithField("clientId", id).Info("OAuth client failed to authenticate")
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// verifyCodeChallenge checks verifier against an S256 challenge.
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if len(verifier) < minCodeVerifierLength || len(verifier) > maxCodeVerifierLength {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// tokens issues the access token and ID token for grant. The access token
+// has the issuer as its audience, so it is only accepted by the userinfo
+// endpoint and not by the APIs, which expect JWT_AUDIENCE.
+func (s *oidcService) tokens(account *Account, client *OAuthClient, grant *AuthorizationGrant) (*TokenResponse, error) {
+	if s.signer == nil {
+		return nil, ErrNoSigner
+	}
+	now := time.Now()
+	expires := now.Add(accessTokenTTL)
+	accessToken, err := s.signer.Sign(&middleware.Claims{
+		Subject:   account.ID,
+		Type:      middleware.PrincipalUser,
+		Issuer:    s.issuer,
+		Audience:  middleware.Audience{s.issuer},
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expires.Unix(),
+		Scopes:    grant.Scopes,
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to generate token")
+		return nil, err
+	}
+
+	claims := jwt.MapClaims(userClaims(account, grant.Scopes))
+	claims["iss"] = s.issuer
+	claims["aud"] = client.ID
+	claims["iat"] = now.Unix()
+	claims["exp"] = expires.Unix()
+	claims["auth_time"] = grant.AuthTime
+	if grant.Nonce != "" {
+		claims["nonce"] = grant.Nonce
+	}
+	idToken, err := s.signer.Sign(claims)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to generate ID token")
+		return nil, err
+	}
+	logrus.WithFields(logrus.Fields{"username": account.Username, "clientId": client.ID}).Info("ID token issued")
+
+	return &TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(accessTokenTTL / time.Second),
+		IDToken:     idToken,
+		Scope:       strings.Join(grant.Scopes, " "),
+	}, nil
+}
+
+func (s *oidcService) UserInfo(accountID string, scopes []string) (map[string]interface{}, error) {
+	account, err := s.repo.GetUserByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if account.Deactivated {
+		return nil, ErrAccountDeactivated
+	}
+	return userClaims(account, scopes), nil
+}
+
+// userClaims returns the standard claims about account released by scopes.
+func userClaims(account *Account, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{"sub": account.ID}
+	if contains(scopes, ScopeProfile) {
+		claims["preferred_username"] = account.Username
+		if name := strings.TrimSpace(account.FirstName + " " + account.LastName); name != "" {
+			claims["name"] = name
+		}
+		if account.FirstName != "" {
+			claims["given_name"] = account.FirstName
+		}
+		if account.LastName != "" {
+			claims["family_name"] = account.LastName
+		}
+	}
+	if contains(scopes, ScopeEmail) && account.Email != "" {
+		claims["email"] = account.Email
+		claims["email_verified"] = account.EmailVerified
+	}
+	return claims
+}
+
+func (s *oidcService) ListConsents(accountID string) ([]*Consent, error) {
+	consents, err := s.clients.ListConsents(accountID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"id": accountID, "error": err}).Error("Unable to fetch consents")
+		return nil, err
+	}
+	return consents, nil
+}
+
+// RevokeConsent forgets the consent, so the account holder is asked again
+// on the next authorization. Tokens already issued stay valid until they
+// expire.
+func (s *oidcService) RevokeConsent(accountID, clientID string) error {
+	consent, err := s.clients.GetConsent(accountID, clientID)
+	if err != nil {
+		return err
+	}
+	if consent == nil {
+		return &OAuthClientNotFoundError{ID: clientID}
+	}
+	if err := s.clients.DeleteConsent(accountID, clientID); err != nil {
+		logrus.WithFields(logrus.Fields{"id": accountID, "error": err}).Error("Unable to delete consent")
+		return err
+	}
+	logrus.WithFields(logrus.Fields{"id": accountID, "clientId": clientID}).Info("Consent revoked")
+	return nil
+}
@@ -0,0 +1,246 @@
+package user
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/middleware"
+	"hex-example/internal/problem"
+)
+
+type OIDCHandler interface {
+	Discovery(w http.ResponseWriter, r *http.Request)
+	Authorize(w http.ResponseWriter, r *http.Request)
+	Prompt(w http.ResponseWriter, r *http.Request)
+	Decide(w http.ResponseWriter, r *http.Request)
+	Token(w http.ResponseWriter, r *http.Request)
+	UserInfo(w http.ResponseWriter, r *http.Request)
+	RegisterClient(w http.ResponseWriter, r *http.Request)
+	ListClients(w http.ResponseWriter, r *http.Request)
+	DeleteClient(w http.ResponseWriter, r *http.Request)
+	ListConsents(w http.ResponseWriter, r *http.Request)
+	RevokeConsent(w http.ResponseWriter, r *http.Request)
+}
+
+type oidcHandler struct {
+	service    OIDCService
+	consentURL string
+}
+
+// NewOIDCHandler returns an OIDCHandler sending browsers with a valid
+// authentication request on to the consent page at consentURL. The page
+// logs the account holder in, then calls Prompt and Decide with the query
+// it was given.
+func NewOIDCHandler(service OIDCService, consentURL string) OIDCHandler {
+	return &oidcHandler{
+		service,
+		consentURL,
+	}
+}
+
+func (h *oidcHandler) Discovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	writeJSON(w, r, http.StatusOK, h.service.Discovery())
+}
+
+// authorizationRequest reads an authentication request from the query.
+func authorizationRequest(query url.Values) *AuthorizationRequest {
+	return &AuthorizationRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		Nonce:               query.Get("nonce"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+}
+
+func (h *oidcHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	req := authorizationRequest(r.URL.Query())
+	switch err := h.service.ValidateAuthorization(req).(type) {
+	case nil:
+		http.Redirect(w, r, h.consentURL+"?"+r.URL.RawQuery, http.StatusFound)
+	case *OAuthError:
+		http.Redirect(w, r, AuthorizationErrorRedirect(req, err), http.StatusFound)
+	default:
+		problem.Error(w, r, err)
+	}
+}
+
+func (h *oidcHandler) Prompt(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+	prompt, err := h.service.Prompt(id, authorizationRequest(r.URL.Query()))
+	if oauthErr, ok := err.(*OAuthError); ok {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, oauthErr.Description))
+		return
+	}
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, prompt)
+}
+
+func (h *oidcHandler) Decide(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+	var decision ConsentDecision
+	if err := json.NewDecoder(r.Body).Decode(&decision); err != nil {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for consent decision"))
+		return
+	}
+
+	// the access token carries when the account holder logged in; tokens
+	// issued before it did fall back to their own issue time
+	authTime := time.Now()
+	if claims, ok := middleware.ClaimsFromContext(r.Context()); ok {
+		switch {
+		case claims.AuthTime != 0:
+			authTime = time.Unix(claims.AuthTime, 0)
+		case claims.IssuedAt != 0:
+			authTime = time.Unix(claims.IssuedAt, 0)
+		}
+	}
+	response, err := h.service.Authorize(id, authTime, &decision)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, response)
+}
+
+// Token implements the token endpoint. Clients authenticate with HTTP Basic
+// or with client_id and client_secret in the form, and errors use the OAuth
+// format rather than problem details.
+func (h *oidcHandler) Token(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, r, &OAuthError{Code: "invalid_request", Description: "Malformed form body"})
+		return
+	}
+
+	req := &TokenRequest{
+		GrantType:    r.PostForm.Get("grant_type"),
+		Code:         r.PostForm.Get("code"),
+		RedirectURI:  r.PostForm.Get("redirect_uri"),
+		ClientID:     r.PostForm.Get("client_id"),
+		ClientSecret: r.PostForm.Get("client_secret"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+	}
+	if id, secret, ok := r.BasicAuth(); ok {
+		// RFC 6749 2.3.1: both are form-encoded before Basic encoding
+		req.ClientID, _ = url.QueryUnescape(id)
+		req.ClientSecret, _ = url.QueryUnescape(secret)
+	}
+
+	response, err := h.service.Exchange(req)
+	if oauthErr, ok := err.(*OAuthError); ok {
+		writeOAuthError(w, r, oauthErr)
+		return
+	}
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, response)
+}
+
+// writeOAuthError writes err as a token endpoint error response.
+func writeOAuthError(w http.ResponseWriter, r *http.Request, err *OAuthError) {
+	logrus.WithField("error", err).Info("Token request rejected")
+	status := http.StatusBadRequest
+	if err.Unauthorized() {
+		status = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+	}
+	writeJSON(w, r, status, err)
+}
+
+// UserInfo serves the claims released by the scopes of the access token.
+// It must run behind middleware.Authenticate with a validator accepting
+// tokens whose audience is the issuer.
+func (h *oidcHandler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok || claims.Type != middleware.PrincipalUser || !contains(claims.Scopes, ScopeOpenID) {
+		problem.Write(w, r, problem.New(http.StatusForbidden, "The access token was not issued for the userinfo endpoint"))
+		return
+	}
+	info, err := h.service.UserInfo(claims.Subject, claims.Scopes)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, r, http.StatusOK, info)
+}
+
+func (h *oidcHandler) RegisterClient(w http.ResponseWriter, r *http.Request) {
+	var client OAuthClient
+	if err := json.NewDecoder(r.Body).Decode(&client); err != nil {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for client"))
+		return
+	}
+
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	issued, err := h.service.RegisterClient(&client, principal.ID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, r, http.StatusCreated, issued)
+}
+
+func (h *oidcHandler) ListClients(w http.ResponseWriter, r *http.Request) {
+	clients, err := h.service.ListClients()
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, clients)
+}
+
+func (h *oidcHandler) DeleteClient(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.DeleteClient(mux.Vars(r)["id"]); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *oidcHandler) ListConsents(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+	consents, err := h.service.ListConsents(id)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, consents)
+}
+
+func (h *oidcHandler) RevokeConsent(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+	if err := h.service.RevokeConsent(id, mux.Vars(r)["clientId"]); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
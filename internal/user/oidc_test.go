@@ -0,0 +1,292 @@
+package user
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/suite"
+	"hex-example/internal/jwks"
+	"hex-example/internal/middleware"
+)
+
+const (
+	testRedirectURI  = "https://app.example/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r7wW1gFWFOEjXk"
+)
+
+func TestOIDCServiceSuite(t *testing.T) {
+	suite.Run(t, new(OIDCServiceTestSuite))
+}
+
+type OIDCServiceTestSuite struct {
+	suite.Suite
+	keys      *jwks.KeyRing
+	clients   *fakeOAuthClientRepo
+	underTest OIDCService
+	account   *Account
+	client    *IssuedOAuthClient
+}
+
+func (suite *OIDCServiceTestSuite) SetupTest() {
+	var err error
+	suite.keys, err = jwks.NewKeyRing(jwks.NewMemoryStore(), jwks.ES256, time.Hour, accessTokenTTL, 0)
+	suite.Require().NoError(err)
+	users := newFakeUserRepo()
+	suite.clients = newFakeOAuthClientRepo()
+	suite.underTest = NewOIDCService(suite.clients, newFakeActionTokenRepo(), users, suite.keys)
+
+	suite.account = &Account{ID: "account-id", Username: "joel", FirstName: "Joel", LastName: "Doe", Email: "joel@example.com", EmailVerified: true}
+	suite.Require().NoError(users.CreateAccount(suite.account))
+	suite.client, err = suite.underTest.RegisterClient(&OAuthClient{Name: "Wiki", RedirectURIs: []string{testRedirectURI}}, "admin-id")
+	suite.Require().NoError(err)
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (suite *OIDCServiceTestSuite) request(scope string) *AuthorizationRequest {
+	return &AuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            suite.client.ID,
+		RedirectURI:         testRedirectURI,
+		Scope:               scope,
+		State:               "af0ifjsldkj",
+		Nonce:               "n-0S6_WzA2Mj",
+		CodeChallenge:       codeChallenge(testCodeVerifier),
+		CodeChallengeMethod: "S256",
+	}
+}
+
+// authorize approves req and returns the authorization code.
+func (suite *OIDCServiceTestSuite) authorize(req *AuthorizationRequest) string {
+	response, err := suite.underTest.Authorize(suite.account.ID, time.Now(), &ConsentDecision{*req, true})
+	suite.Require().NoError(err)
+	redirect, err := url.Parse(response.RedirectTo)
+	suite.Require().NoError(err)
+	suite.Equal(req.State, redirect.Query().Get("state"))
+	return redirect.Query().Get("code")
+}
+
+func (suite *OIDCServiceTestSuite) exchange(code string) (*TokenResponse, error) {
+	return suite.underTest.Exchange(&TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		ClientID:     suite.client.ID,
+		ClientSecret: suite.client.Secret,
+		CodeVerifier: testCodeVerifier,
+	})
+}
+
+func (suite *OIDCServiceTestSuite) TestRegisterClient() {
+	suite.NotEmpty(suite.client.Secret)
+	suite.Empty(suite.client.SecretHash)
+	suite.NotEqual(suite.client.Secret, suite.clients.clients[suite.client.ID].SecretHash)
+
+	clients, err := suite.underTest.ListClients()
+	suite.Require().NoError(err)
+	suite.Require().Len(clients, 1)
+	suite.Empty(clients[0].SecretHash)
+}
+
+func (suite *OIDCServiceTestSuite) TestRegisterInvalidClient() {
+	cases := map[string][]string{
+		"no redirect URI":   nil,
+		"plain http":        {"http://app.example/callback"},
+		"fragment":          {"https://app.example/callback#x"},
+		"relative":          {"/callback"},
+		"custom scheme":     {"javascript://app.example/%0aalert(1)"},
+		"one of many wrong": {testRedirectURI, "ftp://app.example/"},
+	}
+	for name, uris := range cases {
+		_, err := suite.underTest.RegisterClient(&OAuthClient{Name: "App", RedirectURIs: uris}, "admin-id")
+		suite.IsType(ValidationError{}, err, name)
+	}
+
+	_, err := suite.underTest.RegisterClient(&OAuthClient{Name: "Dev", RedirectURIs: []string{"http://localhost:8080/callback"}}, "admin-id")
+	suite.NoError(err, "loopback redirects are allowed over http")
+}
+
+func (suite *OIDCServiceTestSuite) TestValidateAuthorization() {
+	unknownClient := suite.request("openid")
+	unknownClient.ClientID = "unknown"
+	wrongRedirect := suite.request("openid")
+	wrongRedirect.RedirectURI = "https://evil.example/callback"
+	implicit := suite.request("openid")
+	implicit.ResponseType = "token"
+	plainPKCE := suite.request("openid")
+	plainPKCE.CodeChallengeMethod = "plain"
+	noPKCE := suite.request("openid")
+	noPKCE.CodeChallenge = ""
+
+	suite.NoError(suite.underTest.ValidateAuthorization(suite.request("openid email")))
+	suite.IsType(ValidationError{}, suite.underTest.ValidateAuthorization(unknownClient))
+	suite.IsType(ValidationError{}, suite.underTest.ValidateAuthorization(wrongRedirect))
+	cases := map[string]struct {
+		req  *AuthorizationRequest
+		code string
+	}{
+		"implicit flow":  {implicit, "unsupported_response_type"},
+		"missing openid": {suite.request("email profile"), "invalid_scope"},
+		"plain PKCE":     {plainPKCE, "invalid_request"},
+		"no PKCE":        {noPKCE, "invalid_request"},
+	}
+	for name, c := range cases {
+		err, ok := suite.underTest.ValidateAuthorization(c.req).(*OAuthError)
+		if suite.True(ok, name) {
+			suite.Equal(c.code, err.Code, name)
+		}
+	}
+}
+
+func (suite *OIDCServiceTestSuite) TestAuthorizeAndExchange() {
+	authTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+	response, err := suite.underTest.Authorize(suite.account.ID, authTime, &ConsentDecision{*suite.request("openid profile email offline_access"), true})
+	suite.Require().NoError(err)
+	redirect, _ := url.Parse(response.RedirectTo)
+
+	tokens, err := suite.exchange(redirect.Query().Get("code"))
+	suite.Require().NoError(err)
+	suite.Equal("Bearer", tokens.TokenType)
+	suite.Equal("openid profile email", tokens.Scope, "unknown scopes are ignored")
+
+	idToken := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(tokens.IDToken, idToken, func(token *jwt.Token) (interface{}, error) {
+		return suite.keys.Key(token.Header["kid"].(string))
+	})
+	suite.Require().NoError(err)
+	suite.Equal(middleware.DefaultIssuer, idToken["iss"])
+	suite.Equal(suite.account.ID, idToken["sub"])
+	suite.Equal(suite.client.ID, idToken["aud"])
+	suite.Equal("n-0S6_WzA2Mj", idToken["nonce"])
+	suite.Equal(float64(authTime.Unix()), idToken["auth_time"])
+	suite.Equal("joel", idToken["preferred_username"])
+	suite.Equal("Joel Doe", idToken["name"])
+	suite.Equal("joel@example.com", idToken["email"])
+	suite.Equal(true, idToken["email_verified"])
+
+	userinfo := middleware.NewTokenValidator(suite.keys, middleware.Config{Issuer: middleware.DefaultIssuer, Audience: middleware.DefaultIssuer})
+	claims, err := userinfo.Validate(tokens.AccessToken)
+	suite.Require().NoError(err)
+	suite.Equal([]string{ScopeOpenID, ScopeProfile, ScopeEmail}, claims.Scopes)
+	api := middleware.NewTokenValidator(suite.keys, middleware.Config{Audience: middleware.DefaultAudience})
+	_, err = api.Validate(tokens.AccessToken)
+	suite.Equal(middleware.ErrInvalidAudience, err, "the access token is not accepted by the APIs")
+
+	info, err := suite.underTest.UserInfo(claims.Subject, claims.Scopes)
+	suite.Require().NoError(err)
+	suite.Equal("joel@example.com", info["email"])
+}
+
+func (suite *OIDCServiceTestSuite) TestScopesLimitClaims() {
+	tokens, err := suite.exchange(suite.authorize(suite.request("openid")))
+	suite.Require().NoError(err)
+
+	info, err := suite.underTest.UserInfo(suite.account.ID, []string{ScopeOpenID})
+	suite.Require().NoError(err)
+	suite.Equal(map[string]interface{}{"sub": suite.account.ID}, info)
+	suite.Equal("openid", tokens.Scope)
+}
+
+func (suite *OIDCServiceTestSuite) TestCodeIsSingleUse() {
+	code := suite.authorize(suite.request("openid"))
+	_, err := suite.exchange(code)
+	suite.Require().NoError(err)
+
+	_, err = suite.exchange(code)
+	suite.Equal(ErrInvalidGrant, err)
+}
+
+func (suite *OIDCServiceTestSuite) TestExchangeRejected() {
+	other, err := suite.underTest.RegisterClient(&OAuthClient{Name: "Other", RedirectURIs: []string{testRedirectURI}}, "admin-id")
+	suite.Require().NoError(err)
+	cases := map[string]struct {
+		change func(req *TokenRequest)
+		err    error
+	}{
+		"wrong grant type": {func(req *TokenRequest) { req.GrantType = "password" }, &OAuthError{Code: "unsupported_grant_type", Description: "Only the authorization_code grant is supported"}},
+		"unknown client":   {func(req *TokenRequest) { req.ClientID = "unknown" }, ErrInvalidClient},
+		"wrong secret":     {func(req *TokenRequest) { req.ClientSecret = "guess" }, ErrInvalidClient},
+		"no secret":        {func(req *TokenRequest) { req.ClientSecret = "" }, ErrInvalidClient},
+		"other client": {func(req *TokenRequest) {
+			req.ClientID, req.ClientSecret = other.ID, other.Secret
+		}, ErrInvalidGrant},
+		"other redirect URI": {func(req *TokenRequest) { req.RedirectURI = "https://app.example/other" }, ErrInvalidGrant},
+		"wrong verifier":     {func(req *TokenRequest) { req.CodeVerifier = testCodeVerifier[1:] + "x" }, ErrInvalidGrant},
+		"no verifier":        {func(req *TokenRequest) { req.CodeVerifier = "" }, ErrInvalidGrant},
+		"unknown code":       {func(req *TokenRequest) { req.Code = "unknown" }, ErrInvalidGrant},
+	}
+	for name, c := range cases {
+		req := &TokenRequest{
+			GrantType:    "authorization_code",
+			Code:         suite.authorize(suite.request("openid")),
+			RedirectURI:  testRedirectURI,
+			ClientID:     suite.client.ID,
+			ClientSecret: suite.client.Secret,
+			CodeVerifier: testCodeVerifier,
+		}
+		c.change(req)
+		_, err := suite.underTest.Exchange(req)
+		suite.Equal(c.err, err, name)
+	}
+}
+
+func (suite *OIDCServiceTestSuite) TestPublicClient() {
+	public, err := suite.underTest.RegisterClient(&OAuthClient{Name: "SPA", Public: true, RedirectURIs: []string{testRedirectURI}}, "admin-id")
+	suite.Require().NoError(err)
+	suite.Empty(public.Secret)
+
+	req := suite.request("openid")
+	req.ClientID = public.ID
+	_, err = suite.underTest.Exchange(&TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         suite.authorize(req),
+		RedirectURI:  testRedirectURI,
+		ClientID:     public.ID,
+		CodeVerifier: testCodeVerifier,
+	})
+	suite.NoError(err)
+}
+
+func (suite *OIDCServiceTestSuite) TestDeny() {
+	response, err := suite.underTest.Authorize(suite.account.ID, time.Now(), &ConsentDecision{*suite.request("openid"), false})
+	suite.Require().NoError(err)
+
+	redirect, _ := url.Parse(response.RedirectTo)
+	suite.Equal("app.example", redirect.Host)
+	suite.Equal("access_denied", redirect.Query().Get("error"))
+	suite.Equal("af0ifjsldkj", redirect.Query().Get("state"))
+	suite.Empty(redirect.Query().Get("code"))
+}
+
+func (suite *OIDCServiceTestSuite) TestConsentRemembered() {
+	prompt, err := suite.underTest.Prompt(suite.account.ID, suite.request("openid email"))
+	suite.Require().NoError(err)
+	suite.Equal("Wiki", prompt.ClientName)
+	suite.False(prompt.Consented)
+
+	suite.authorize(suite.request("openid email"))
+	prompt, _ = suite.underTest.Prompt(suite.account.ID, suite.request("openid"))
+	suite.True(prompt.Consented)
+	prompt, _ = suite.underTest.Prompt(suite.account.ID, suite.request("openid profile"))
+	suite.False(prompt.Consented, "a new scope needs consent")
+
+	suite.Require().NoError(suite.underTest.RevokeConsent(suite.account.ID, suite.client.ID))
+	prompt, _ = suite.underTest.Prompt(suite.account.ID, suite.request("openid"))
+	suite.False(prompt.Consented)
+	suite.IsType(&OAuthClientNotFoundError{}, suite.underTest.RevokeConsent(suite.account.ID, suite.client.ID))
+}
+
+func (suite *OIDCServiceTestSuite) TestDiscovery() {
+	discovery := suite.underTest.Discovery()
+	suite.Equal(middleware.DefaultIssuer, discovery.Issuer)
+	suite.Equal(middleware.DefaultIssuer+OIDCTokenPath, discovery.TokenEndpoint)
+	suite.Equal(middleware.DefaultIssuer+jwks.Path, discovery.JWKSURI)
+	suite.Equal([]string{"S256"}, discovery.CodeChallengeMethodsSupported)
+}
@@ -0,0 +1,291 @@
+package user
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	passkeyCeremonyTTL = 5 * time.Minute
+	maxPasskeyNameLen  = 64
+)
+
+// PasskeyService registers WebAuthn passkeys and logs in with them.
+type PasskeyService interface {
+	// BeginRegistration returns the options for creating a new passkey for
+	// the account.
+	BeginRegistration(accountID string) (*PasskeyCeremony, error)
+	// FinishRegistration verifies the authenticator response to the
+	// ceremony and stores the new passkey under name.
+	FinishRegistration(accountID, ceremonyID, name string, response io.Reader) (*Passkey, error)
+	ListPasskeys(accountID string) ([]*Passkey, error)
+	DeletePasskey(accountID, id string) error
+	// BeginLogin returns the options for asserting any passkey the browser
+	// holds for this site. Passkeys are discoverable, so no username is
+	// asked for and none can be probed.
+	BeginLogin() (*PasskeyCeremony, error)
+	// FinishLogin verifies the assertion for the ceremony and issues the
+	// same tokens as a password login.
+	FinishLogin(ceremonyID string, response io.Reader) (*Login, error)
+}
+
+type passkeyService struct {
+	repo       UserRepo
+	ceremonies ActionTokenRepo
+	accounts   UserService
+	webAuthn   *webauthn.WebAuthn
+}
+
+// NewPasskeyService returns a PasskeyService for the relying party in
+// config, keeping pending ceremonies in ceremonies and logging in through
+// accounts.
+func NewPasskeyService(repo UserRepo, ceremonies ActionTokenRepo, accounts UserService, config *webauthn.Config) (PasskeyService, error) {
+	webAuthn, err := webauthn.New(config)
+	if err != nil {
+		return nil, err
+	}
+	return &passkeyService{
+		repo,
+		ceremonies,
+		accounts,
+		webAuthn,
+	}, nil
+}
+
+// webAuthnUser adapts an account and its passkeys to webauthn.User. The user
+// handle is the account ID.
+type webAuthnUser struct {
+	account  *Account
+	passkeys []*Passkey
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte { return []byte(u.account.ID) }
+
+func (u *webAuthnUser) WebAuthnName() string { return u.account.Username }
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	if u.account.FirstName == "" && u.account.LastName == "" {
+		return u.account.Username
+	}
+	return u.account.FirstName + " " + u.account.LastName
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, len(u.passkeys))
+	for i, passkey := range u.passkeys {
+		credentials[i] = *passkey.Credential
+	}
+	return credentials
+}
+
+// passkey returns the passkey with the given raw credential ID.
+func (u *webAuthnUser) passkey(id []byte) *Passkey {
+	for _, passkey := range u.passkeys {
+		if bytes.Equal(passkey.Credential.ID, id) {
+			return passkey
+		}
+	}
+	return nil
+}
+
+func (s *passkeyService) user(account *Account) (*webAuthnUser, error) {
+	passkeys, err := s.repo.GetPasskeys(account.ID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to fetch passkeys")
+		return nil, err
+	}
+	return &webAuthnUser{account, passkeys}, nil
+}
+
+func (s *passkeyService) BeginRegistration(accountID string) (*PasskeyCeremony, error) {
+	account, err := s.repo.GetUserByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.user(account)
+	if err != nil {
+		return nil, err
+	}
+
+	creation, session, err := s.webAuthn.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(webauthn.Credentials(user.WebAuthnCredentials()).CredentialDescriptors()),
+	)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to begin passkey registration")
+		return nil, err
+	}
+	return s.newCeremony(PurposePasskeyRegistration, account, session, creation)
+}
+
+func (s *passkeyService) FinishRegistration(accountID, ceremonyID, name string, response io.Reader) (*Passkey, error) {
+	if len(name) > maxPasskeyNameLen {
+		name = name[:maxPasskeyNameLen]
+	}
+	session, stored, err := s.take(PurposePasskeyRegistration, ceremonyID)
+	if err != nil {
+		return nil, err
+	}
+	if stored.AccountID != accountID {
+		return nil, ErrInvalidPasskey
+	}
+	account, err := s.repo.GetUserByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.user(account)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(response)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Info("Malformed passkey registration")
+		return nil, ErrInvalidPasskey
+	}
+	credential, err := s.webAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Info("Passkey registration failed verification")
+		return nil, ErrInvalidPasskey
+	}
+
+	passkey := &Passkey{
+		ID:         base64.RawURLEncoding.EncodeToString(credential.ID),
+		AccountID:  accountID,
+		Name:       name,
+		Created:    time.Now(),
+		Credential: credential,
+	}
+	if err := s.repo.SavePasskey(passkey); err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to save passkey")
+		return nil, err
+	}
+	logrus.WithField("username", account.Username).Info("Passkey registered")
+	return withoutCredential(passkey), nil
+}
+
+func (s *passkeyService) ListPasskeys(accountID string) ([]*Passkey, error) {
+	passkeys, err := s.repo.GetPasskeys(accountID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"id": accountID, "error": err}).Error("Unable to fetch passkeys")
+		return nil, err
+	}
+	for i, passkey := range passkeys {
+		passkeys[i] = withoutCredential(passkey)
+	}
+	return passkeys, nil
+}
+
+func (s *passkeyService) DeletePasskey(accountID, id string) error {
+	if err := s.repo.DeletePasskey(accountID, id); err != nil {
+		return err
+	}
+	logrus.WithField("id", accountID).Info("Passkey deleted")
+	return nil
+}
+
+func (s *passkeyService) BeginLogin() (*PasskeyCeremony, error) {
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to begin passkey login")
+		return nil, err
+	}
+	return s.newCeremony(PurposePasskeyLogin, &Account{}, session, assertion)
+}
+
+func (s *passkeyService) FinishLogin(ceremonyID string, response io.Reader) (*Login, error) {
+	session, _, err := s.take(PurposePasskeyLogin, ceremonyID)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBody(response)
+	if err != nil {
+		logrus.WithField("error", err).Info("Malformed passkey assertion")
+		return nil, ErrInvalidPasskey
+	}
+
+	// the user handle returned by the authenticator names the account
+	account, err := s.repo.GetUserByID(string(parsed.Response.UserHandle))
+	if _, ok := err.(*NotFoundError); ok {
+		return nil, ErrInvalidPasskey
+	}
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.user(account)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.webAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		return user, nil
+	}, *session, parsed)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Info("Passkey assertion failed verification")
+		return nil, ErrInvalidPasskey
+	}
+	if credential.Authenticator.CloneWarning {
+		logrus.WithField("username", account.Username).Warn("Passkey signature counter went backwards, the authenticator may be cloned")
+		return nil, ErrInvalidPasskey
+	}
+
+	passkey := user.passkey(credential.ID)
+	if passkey == nil {
+		return nil, ErrInvalidPasskey
+	}
+	passkey.Credential = credential
+	passkey.LastUsed = time.Now()
+	if err := s.repo.SavePasskey(passkey); err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to save passkey")
+		return nil, err
+	}
+	logrus.WithField("username", account.Username).Info("Passkey login")
+	return s.accounts.LoginAccount(account.ID)
+}
+
+// newCeremony stores session for account and returns the ceremony to hand
+// to the browser.
+func (s *passkeyService) newCeremony(purpose string, account *Account, session *webauthn.SessionData, options interface{}) (*PasskeyCeremony, error) {
+	value, token, err := newActionToken(purpose, account, passkeyCeremonyTTL)
+	if err != nil {
+		return nil, err
+	}
+	if token.Session, err = json.Marshal(session); err != nil {
+		return nil, err
+	}
+	if err := s.ceremonies.SaveActionToken(token); err != nil {
+		logrus.WithField("error", err).Error("Unable to save passkey ceremony")
+		return nil, err
+	}
+	return &PasskeyCeremony{ID: value, Options: options}, nil
+}
+
+// take consumes the ceremony and returns its session data.
+func (s *passkeyService) take(purpose, ceremonyID string) (*webauthn.SessionData, *ActionToken, error) {
+	stored, err := s.ceremonies.TakeActionToken(purpose, hashActionToken(ceremonyID))
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch passkey ceremony")
+		return nil, nil, err
+	}
+	if stored == nil || time.Now().After(stored.Expires) {
+		return nil, nil, ErrInvalidPasskey
+	}
+	session := new(webauthn.SessionData)
+	if err := json.Unmarshal(stored.Session, session); err != nil {
+		return nil, nil, err
+	}
+	return session, stored, nil
+}
+
+func withoutCredential(passkey *Passkey) *Passkey {
+	copied := *passkey
+	copied.Credential = nil
+	return &copied
+}
@@ -0,0 +1,105 @@
+package user
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"hex-example/internal/problem"
+)
+
+type PasskeyHandler interface {
+	BeginRegistration(w http.ResponseWriter, r *http.Request)
+	FinishRegistration(w http.ResponseWriter, r *http.Request)
+	List(w http.ResponseWriter, r *http.Request)
+	Delete(w http.ResponseWriter, r *http.Request)
+	BeginLogin(w http.ResponseWriter, r *http.Request)
+	FinishLogin(w http.ResponseWriter, r *http.Request)
+}
+
+type passkeyHandler struct {
+	service PasskeyService
+}
+
+func NewPasskeyHandler(service PasskeyService) PasskeyHandler {
+	return &passkeyHandler{
+		service,
+	}
+}
+
+func (h *passkeyHandler) BeginRegistration(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+
+	ceremony, err := h.service.BeginRegistration(id)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, r, http.StatusOK, ceremony)
+}
+
+// FinishRegistration takes the response of navigator.credentials.create as
+// its body and the name of the passkey from the query string.
+func (h *passkeyHandler) FinishRegistration(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+
+	passkey, err := h.service.FinishRegistration(id, mux.Vars(r)["ceremonyId"], r.URL.Query().Get("name"), r.Body)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, passkey)
+}
+
+func (h *passkeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+
+	passkeys, err := h.service.ListPasskeys(id)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, passkeys)
+}
+
+func (h *passkeyHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeletePasskey(id, mux.Vars(r)["id"]); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *passkeyHandler) BeginLogin(w http.ResponseWriter, r *http.Request) {
+	ceremony, err := h.service.BeginLogin()
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, r, http.StatusOK, ceremony)
+}
+
+// FinishLogin takes the response of navigator.credentials.get as its body.
+func (h *passkeyHandler) FinishLogin(w http.ResponseWriter, r *http.Request) {
+	login, err := h.service.FinishLogin(mux.Vars(r)["ceremonyId"], r.Body)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, login)
+}
@@ -0,0 +1,304 @@
+package user
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/stretchr/testify/suite"
+	"hex-example/internal/jwks"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:3000"
+)
+
+func TestPasskeyServiceSuite(t *testing.T) {
+	suite.Run(t, new(PasskeyServiceTestSuite))
+}
+
+type PasskeyServiceTestSuite struct {
+	suite.Suite
+	users         *fakeUserRepo
+	accounts      UserService
+	underTest     PasskeyService
+	id            string
+	authenticator *softAuthenticator
+}
+
+func (suite *PasskeyServiceTestSuite) SetupTest() {
+	keys, err := jwks.NewKeyRing(jwks.NewMemoryStore(), jwks.ES256, time.Hour, accessTokenTTL, 0)
+	suite.Require().NoError(err)
+	suite.users = newFakeUserRepo()
+	suite.accounts = NewUserService(suite.users, newFakeTokenRepo(), newFakeMFARepo(), newFakeActionTokenRepo(), keys)
+	suite.underTest, err = NewPasskeyService(suite.users, newFakeActionTokenRepo(), suite.accounts, &webauthn.Config{
+		RPID:          testRPID,
+		RPDisplayName: "hex-example",
+		RPOrigins:     []string{testOrigin},
+	})
+	suite.Require().NoError(err)
+
+	account := &Account{Username: "joel", Password: "password1"}
+	suite.Require().NoError(suite.accounts.CreateAccount(account))
+	suite.id = account.ID
+	suite.authenticator = newSoftAuthenticator(suite.T())
+}
+
+// register runs a registration ceremony with the software authenticator.
+func (suite *PasskeyServiceTestSuite) register() *Passkey {
+	ceremony, err := suite.underTest.BeginRegistration(suite.id)
+	suite.Require().NoError(err)
+	response := suite.authenticator.create(ceremony, testOrigin)
+
+	passkey, err := suite.underTest.FinishRegistration(suite.id, ceremony.ID, "laptop", bytes.NewReader(response))
+	suite.Require().NoError(err)
+	return passkey
+}
+
+// login runs a login ceremony with the software authenticator.
+func (suite *PasskeyServiceTestSuite) login(origin string) (*Login, error) {
+	ceremony, err := suite.underTest.BeginLogin()
+	suite.Require().NoError(err)
+	return suite.underTest.FinishLogin(ceremony.ID, bytes.NewReader(suite.authenticator.get(ceremony, origin)))
+}
+
+func (suite *PasskeyServiceTestSuite) TestRegisterAndLogin() {
+	passkey := suite.register()
+	suite.Equal("laptop", passkey.Name)
+	suite.Equal(base64.RawURLEncoding.EncodeToString(suite.authenticator.credentialID), passkey.ID)
+	suite.Nil(passkey.Credential)
+
+	login, err := suite.login(testOrigin)
+	suite.Require().NoError(err)
+	suite.Equal("joel", login.Username)
+	suite.NotEmpty(login.Token)
+	suite.NotEmpty(login.RefreshToken)
+
+	passkeys, err := suite.underTest.ListPasskeys(suite.id)
+	suite.Require().NoError(err)
+	suite.Require().Len(passkeys, 1)
+	suite.Nil(passkeys[0].Credential)
+	suite.False(passkeys[0].LastUsed.IsZero())
+}
+
+func (suite *PasskeyServiceTestSuite) TestLoginTracksSignCount() {
+	suite.register()
+	for i := 0; i < 2; i++ {
+		_, err := suite.login(testOrigin)
+		suite.Require().NoError(err)
+	}
+
+	stored, err := suite.users.GetPasskeys(suite.id)
+	suite.Require().NoError(err)
+	suite.Equal(uint32(2), stored[0].Credential.Authenticator.SignCount)
+}
+
+func (suite *PasskeyServiceTestSuite) TestLoginClonedAuthenticator() {
+	suite.register()
+	_, err := suite.login(testOrigin)
+	suite.Require().NoError(err)
+
+	suite.authenticator.signCount = 0
+	_, err = suite.login(testOrigin)
+	suite.Equal(ErrInvalidPasskey, err)
+}
+
+func (suite *PasskeyServiceTestSuite) TestLoginWrongOrigin() {
+	suite.register()
+	_, err := suite.login("https://evil.example")
+	suite.Equal(ErrInvalidPasskey, err)
+}
+
+func (suite *PasskeyServiceTestSuite) TestLoginUnknownKey() {
+	suite.register()
+	suite.authenticator.key, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, err := suite.login(testOrigin)
+	suite.Equal(ErrInvalidPasskey, err)
+}
+
+func (suite *PasskeyServiceTestSuite) TestLoginCeremonyIsSingleUse() {
+	suite.register()
+	ceremony, err := suite.underTest.BeginLogin()
+	suite.Require().NoError(err)
+
+	_, err = suite.underTest.FinishLogin(ceremony.ID, bytes.NewReader(suite.authenticator.get(ceremony, testOrigin)))
+	suite.Require().NoError(err)
+	_, err = suite.underTest.FinishLogin(ceremony.ID, bytes.NewReader(suite.authenticator.get(ceremony, testOrigin)))
+	suite.Equal(ErrInvalidPasskey, err)
+}
+
+func (suite *PasskeyServiceTestSuite) TestLoginDeactivated() {
+	suite.register()
+	suite.Require().NoError(suite.accounts.DeactivateAccount(suite.id, "password1"))
+
+	_, err := suite.login(testOrigin)
+	suite.Equal(ErrAccountDeactivated, err)
+}
+
+func (suite *PasskeyServiceTestSuite) TestFinishRegistrationOfAnotherAccount() {
+	other := &Account{Username: "mallory", Password: "password1"}
+	suite.Require().NoError(suite.accounts.CreateAccount(other))
+	ceremony, err := suite.underTest.BeginRegistration(suite.id)
+	suite.Require().NoError(err)
+
+	_, err = suite.underTest.FinishRegistration(other.ID, ceremony.ID, "", bytes.NewReader(suite.authenticator.create(ceremony, testOrigin)))
+	suite.Equal(ErrInvalidPasskey, err)
+}
+
+func (suite *PasskeyServiceTestSuite) TestDeletePasskey() {
+	passkey := suite.register()
+
+	err := suite.underTest.DeletePasskey(suite.id, "unknown")
+	suite.IsType(&PasskeyNotFoundError{}, err)
+	suite.Require().NoError(suite.underTest.DeletePasskey(suite.id, passkey.ID))
+
+	_, err = suite.login(testOrigin)
+	suite.Equal(ErrInvalidPasskey, err)
+}
+
+// softAuthenticator is a software stand-in for a platform authenticator
+// holding one discoverable ES256 credential with "none" attestation.
+type softAuthenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		t.Fatal(err)
+	}
+	return &softAuthenticator{t: t, key: key, credentialID: id}
+}
+
+// create answers navigator.credentials.create for the ceremony, returning
+// the JSON a browser would post.
+func (a *softAuthenticator) create(ceremony *PasskeyCeremony, origin string) []byte {
+	var options protocol.CredentialCreation
+	a.decode(ceremony, &options)
+	userID, _ := options.Response.User.ID.(string)
+	handle, err := base64.RawURLEncoding.DecodeString(userID)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	a.userHandle = handle
+
+	publicKey, err := webauthncbor.Marshal(map[int]interface{}{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	authData := a.authData(options.Response.RelyingParty.ID, 0x45) // UP, UV, AT
+	authData = append(authData, make([]byte, 16)...)               // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.credential(map[string]string{
+		"clientDataJSON":    a.clientData("webauthn.create", options.Response.Challenge, origin),
+		"attestationObject": base64.RawURLEncoding.EncodeToString(attestation),
+	})
+}
+
+// get answers navigator.credentials.get for the ceremony.
+func (a *softAuthenticator) get(ceremony *PasskeyCeremony, origin string) []byte {
+	var options protocol.CredentialAssertion
+	a.decode(ceremony, &options)
+
+	a.signCount++
+	authData := a.authData(options.Response.RelyingPartyID, 0x05) // UP, UV
+	clientData := a.clientData("webauthn.get", options.Response.Challenge, origin)
+	rawClientData, _ := base64.RawURLEncoding.DecodeString(clientData)
+	clientDataHash := sha256.Sum256(rawClientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.credential(map[string]string{
+		"clientDataJSON":    clientData,
+		"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+		"signature":         base64.RawURLEncoding.EncodeToString(signature),
+		"userHandle":        base64.RawURLEncoding.EncodeToString(a.userHandle),
+	})
+}
+
+// decode reads the options of the ceremony the way a browser receives them.
+func (a *softAuthenticator) decode(ceremony *PasskeyCeremony, options interface{}) {
+	encoded, err := json.Marshal(ceremony)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	var wire struct {
+		Options json.RawMessage `json:"options"`
+	}
+	if err := json.Unmarshal(encoded, &wire); err != nil {
+		a.t.Fatal(err)
+	}
+	if err := json.Unmarshal(wire.Options, options); err != nil {
+		a.t.Fatal(err)
+	}
+}
+
+func (a *softAuthenticator) authData(rpID string, flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	authData := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(authData, a.signCount)
+}
+
+func (a *softAuthenticator) clientData(typ string, challenge protocol.URLEncodedBase64, origin string) string {
+	encoded, err := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": challenge.String(),
+		"origin":    origin,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func (a *softAuthenticator) credential(response map[string]string) []byte {
+	id := base64.RawURLEncoding.EncodeToString(a.credentialID)
+	encoded, err := json.Marshal(map[string]interface{}{
+		"id":       id,
+		"rawId":    id,
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return encoded
+}
@@ -0,0 +1,271 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"hex-example/internal/mail"
+	"hex-example/internal/validation"
+)
+
+const (
+	verifyEmailTTL   = 24 * time.Hour
+	resetPasswordTTL = time.Hour
+	actionTokenLen   = 32
+)
+
+// RecoveryService verifies email addresses and resets forgotten passwords
+// with single-use tokens delivered by mail.
+type RecoveryService interface {
+	// SendVerification mails a verification link to the address of the
+	// account. Verified accounts are left alone.
+	SendVerification(accountID string) error
+	VerifyEmail(token string) error
+	// RequestPasswordReset mails a reset token to the verified address of
+	// the account. It succeeds without sending anything for unknown
+	// usernames and unverified addresses, and sends in the background, so
+	// that callers can probe for accounts neither by the result nor by the
+	// time it takes.
+	RequestPasswordReset(username string) error
+	// ResetPassword replaces the password and logs out every session.
+	ResetPassword(reset *PasswordReset) error
+}
+
+type recoveryService struct {
+	repo    UserRepo
+	tokens  TokenRepo
+	actions ActionTokenRepo
+	mailer  mail.Mailer
+	baseURL string
+	// async runs work the caller does not wait for
+	async func(func())
+}
+
+// NewRecoveryService returns a RecoveryService whose verification links
+// point at the userAPI served from baseURL.
+func NewRecoveryService(repo UserRepo, tokens TokenRepo, actions ActionTokenRepo, mailer mail.Mailer, baseURL string) RecoveryService {
+	return &recoveryService{
+		repo,
+		tokens,
+		actions,
+		mailer,
+		baseURL,
+		func(f func()) { go f() },
+	}
+}
+
+func (s *recoveryService) SendVerification(accountID string) error {
+	account, err := s.repo.GetUserByID(accountID)
+	if err != nil {
+		return err
+	}
+	v := new(validation.Validator)
+	v.Required("email", account.Email)
+	if err := v.Err(); err != nil {
+		return err
+	}
+	if account.EmailVerified {
+		return nil
+	}
+
+	token, err := s.newToken(PurposeVerifyEmail, account, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+	link := s.baseURL + "/account/verify?token=" + url.QueryEscape(token)
+	return s.send(account, "Verify your email address", fmt.Sprintf(
+		"Hi %s,\n\nplease confirm that this is your email address by opening the link below within 24 hours:\n\n%s\n",
+		account.Username, link))
+}
+
+func (s *recoveryService) VerifyEmail(token string) error {
+	account, err := s.take(PurposeVerifyEmail, token)
+	if err != nil {
+		return err
+	}
+
+	account.EmailVerified = true
+	if err := s.repo.UpdateAccount(account); err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to update account")
+		return err
+	}
+	logrus.WithField("username", account.Username).Info("Email verified")
+	return nil
+}
+
+func (s *recoveryService) RequestPasswordReset(username string) error {
+	account, err := s.repo.GetUser(username)
+	if _, ok := err.(*NotFoundError); ok {
+		logrus.WithField("username", username).Info("Password reset for unknown account")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !account.EmailVerified {
+		logrus.WithField("username", username).Info("Password reset without a verified email")
+		return nil
+	}
+
+	s.async(func() { s.sendReset(account) })
+	return nil
+}
+
+// sendReset mails a reset token to the account holder. Errors are only
+// logged by newToken and send, as the request was answered already.
+func (s *recoveryService) sendReset(account *Account) {
+	token, err := s.newToken(PurposeResetPassword, account, resetPasswordTTL)
+	if err != nil {
+		return
+	}
+	s.send(account, "Reset your password", fmt.Sprintf(
+		"Hi %s,\n\nsomeone asked to reset your password. Use this token within an hour to choose a new one:\n\n%s\n\nIf it wasn't you, ignore this message and your password stays the same.\n",
+		account.Username, token))
+}
+
+func (s *recoveryService) ResetPassword(reset *PasswordReset) error {
+	v := new(validation.Validator)
+	v.Required("token", reset.Token)
+	validatePassword(v, "newPassword", reset.NewPassword)
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	account, err := s.take(PurposeResetPassword, reset.Token)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(reset.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to hash password: ")
+		return err
+	}
+	account.Password = string(hashedPassword)
+	if err := s.repo.UpdateAccount(account); err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to update account")
+		return err
+	}
+	logrus.WithField("username", account.Username).Info("Password reset")
+
+	if err := s.tokens.RevokeAccount(account.ID); err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to revoke refresh tokens")
+		return err
+	}
+	return nil
+}
+
+// newActionToken returns a new token value and its record.
+func newActionToken(purpose string, account *Account, ttl time.Duration) (string, *ActionToken, error) {
+	b := make([]byte, actionTokenLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	value := base64.RawURLEncoding.EncodeToString(b)
+	return value, &ActionToken{
+		Hash:      hashActionToken(value),
+		Purpose:   purpose,
+		AccountID: account.ID,
+		Email:     account.Email,
+		Expires:   time.Now().Add(ttl),
+	}, nil
+}
+
+// newToken stores a token for account and returns its value.
+func (s *recoveryService) newToken(purpose string, account *Account, ttl time.Duration) (string, error) {
+	value, token, err := newActionToken(purpose, account, ttl)
+	if err != nil {
+		return "", err
+	}
+	if err := s.actions.SaveActionToken(token); err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to save token")
+		return "", err
+	}
+	return value, nil
+}
+
+// take consumes a token and returns its account, provided the account still
+// has the address the token was sent to.
+func (s *recoveryService) take(purpose, value string) (*Account, error) {
+	stored, err := s.actions.TakeActionToken(purpose, hashActionToken(value))
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch token")
+		return nil, err
+	}
+	if stored == nil || time.Now().After(stored.Expires) {
+		return nil, ErrInvalidActionToken
+	}
+
+	account, err := s.repo.GetUserByID(stored.AccountID)
+	if _, ok := err.(*NotFoundError); ok {
+		return nil, ErrInvalidActionToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if account.Email != stored.Email {
+		logrus.WithField("username", account.Username).Info("Token sent to a previous email address")
+		return nil, ErrInvalidActionToken
+	}
+	return account, nil
+}
+
+func (s *recoveryService) send(account *Account, subject, body string) error {
+	if err := s.mailer.Send(&mail.Message{To: account.Email, Subject: subject, Body: body}); err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to mail account holder")
+		return err
+	}
+	return nil
+}
+
+func hashActionToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+type verifyingUserService struct {
+	UserService
+	recovery RecoveryService
+}
+
+// WithVerification returns service with a verification mail sent for every
+// new account with an email address and every changed address. Mail
+// failures are logged but do not fail the operation, since the account
+// holder can ask for another mail.
+func WithVerification(service UserService, recovery RecoveryService) UserService {
+	return &verifyingUserService{service, recovery}
+}
+
+func (s *verifyingUserService) CreateAccount(account *Account) error {
+	if err := s.UserService.CreateAccount(account); err != nil {
+		return err
+	}
+	if account.Email != "" {
+		s.sendVerification(account.ID)
+	}
+	return nil
+}
+
+func (s *verifyingUserService) UpdateAccount(id string, update *AccountUpdate) (*Account, error) {
+	account, err := s.UserService.UpdateAccount(id, update)
+	if err != nil {
+		return nil, err
+	}
+	if update.Email != nil && account.Email != "" && !account.EmailVerified {
+		s.sendVerification(account.ID)
+	}
+	return account, nil
+}
+
+func (s *verifyingUserService) sendVerification(id string) {
+	if err := s.recovery.SendVerification(id); err != nil {
+		logrus.WithFields(logrus.Fields{"id": id, "error": err}).Warn("Unable to send verification mail")
+	}
+}
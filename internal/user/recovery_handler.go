@@ -0,0 +1,78 @@
+package user
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"hex-example/internal/problem"
+)
+
+type RecoveryHandler interface {
+	VerifyEmail(w http.ResponseWriter, r *http.Request)
+	ResendVerification(w http.ResponseWriter, r *http.Request)
+	RequestPasswordReset(w http.ResponseWriter, r *http.Request)
+	ResetPassword(w http.ResponseWriter, r *http.Request)
+}
+
+type recoveryHandler struct {
+	service RecoveryService
+	guard   PasswordResetGuard
+}
+
+func NewRecoveryHandler(service RecoveryService, guard PasswordResetGuard) RecoveryHandler {
+	return &recoveryHandler{
+		service,
+		guard,
+	}
+}
+
+// VerifyEmail is the target of the link in verification mails, so it takes
+// the token from the query string.
+func (h *recoveryHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.VerifyEmail(r.URL.Query().Get("token")); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *recoveryHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentAccount(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.SendVerification(id); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *recoveryHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var request PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for password reset request"))
+		return
+	}
+
+	if err := h.guard.RequestPasswordReset(request.Username, clientIP(r)); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *recoveryHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var reset PasswordReset
+	if err := json.NewDecoder(r.Body).Decode(&reset); err != nil {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for password reset"))
+		return
+	}
+
+	if err := h.service.ResetPassword(&reset); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,150 @@
+package user
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"hex-example/internal/mail"
+	"hex-example/internal/mail/mailtest"
+)
+
+func TestRecoveryServiceSuite(t *testing.T) {
+	suite.Run(t, new(RecoveryServiceTestSuite))
+}
+
+// RecoveryServiceTestSuite delivers mail through a local SMTP server.
+type RecoveryServiceTestSuite struct {
+	suite.Suite
+	smtp      *mailtest.Server
+	users     *fakeUserRepo
+	tokens    *fakeTokenRepo
+	accounts  UserService
+	underTest RecoveryService
+}
+
+var (
+	verifyLink = regexp.MustCompile(`http://localhost:3000/account/verify\?token=(\S+)`)
+	resetToken = regexp.MustCompile(`\n\n([A-Za-z0-9_-]{43})\n`)
+)
+
+func (suite *RecoveryServiceTestSuite) SetupTest() {
+	server, err := mailtest.NewServer()
+	suite.Require().NoError(err)
+	suite.smtp = server
+
+	suite.users = newFakeUserRepo()
+	suite.tokens = newFakeTokenRepo()
+	mailer := mail.NewSMTPMailer(server.Addr, "noreply@example.com", nil)
+	suite.underTest = NewRecoveryService(suite.users, suite.tokens, newFakeActionTokenRepo(), mailer, "http://localhost:3000")
+	// mail is sent before RequestPasswordReset returns, so tests can read it
+	suite.underTest.(*recoveryService).async = func(f func()) { f() }
+	suite.accounts = WithVerification(NewUserService(suite.users, suite.tokens, newFakeMFARepo(), newFakeActionTokenRepo(), nil), suite.underTest)
+
+	err = suite.accounts.CreateAccount(&Account{Username: "joel", Email: "joel@example.com", Password: "password1"})
+	suite.Require().NoError(err)
+}
+
+func (suite *RecoveryServiceTestSuite) TearDownTest() {
+	suite.smtp.Close()
+}
+
+func (suite *RecoveryServiceTestSuite) account() *Account {
+	account, err := suite.users.GetUser("joel")
+	suite.Require().NoError(err)
+	return account
+}
+
+// lastMatch returns the first submatch of re in the last message received.
+func (suite *RecoveryServiceTestSuite) lastMatch(re *regexp.Regexp) string {
+	messages := suite.smtp.Messages()
+	suite.Require().NotEmpty(messages)
+	last := messages[len(messages)-1]
+	suite.Equal([]string{"joel@example.com"}, last.To)
+	match := re.FindStringSubmatch(last.Body)
+	suite.Require().NotNil(match, last.Body)
+	return match[1]
+}
+
+func (suite *RecoveryServiceTestSuite) verifyToken() string {
+	token, err := url.QueryUnescape(suite.lastMatch(verifyLink))
+	suite.Require().NoError(err)
+	return token
+}
+
+func (suite *RecoveryServiceTestSuite) TestCreateAccountSendsVerification() {
+	token := suite.verifyToken()
+	suite.False(suite.account().EmailVerified)
+
+	suite.NoError(suite.underTest.VerifyEmail(token))
+	suite.True(suite.account().EmailVerified)
+	suite.Equal(ErrInvalidActionToken, suite.underTest.VerifyEmail(token), "tokens are single-use")
+}
+
+func (suite *RecoveryServiceTestSuite) TestVerifyUnknownToken() {
+	suite.Equal(ErrInvalidActionToken, suite.underTest.VerifyEmail("unknown"))
+}
+
+func (suite *RecoveryServiceTestSuite) TestChangedEmailInvalidatesEarlierLink() {
+	token := suite.verifyToken()
+	email := "joel@example.org"
+
+	_, err := suite.accounts.UpdateAccount(suite.account().ID, &AccountUpdate{Email: &email})
+	suite.Require().NoError(err)
+
+	suite.Len(suite.smtp.Messages(), 2, "the new address is verified too")
+	suite.Equal(ErrInvalidActionToken, suite.underTest.VerifyEmail(token))
+}
+
+func (suite *RecoveryServiceTestSuite) TestPasswordReset() {
+	suite.Require().NoError(suite.underTest.VerifyEmail(suite.verifyToken()))
+	suite.tokens.SaveRefreshToken(&RefreshToken{Hash: "session", AccountID: suite.account().ID})
+
+	suite.Require().NoError(suite.underTest.RequestPasswordReset("joel"))
+	token := suite.lastMatch(resetToken)
+
+	err := suite.underTest.ResetPassword(&PasswordReset{Token: token, NewPassword: "short"})
+	suite.IsType(ValidationError{}, err)
+	suite.NoError(suite.underTest.ResetPassword(&PasswordReset{Token: token, NewPassword: "password2"}))
+	suite.Empty(suite.tokens.tokens, "sessions are logged out")
+
+	err = suite.underTest.ResetPassword(&PasswordReset{Token: token, NewPassword: "password3"})
+	suite.Equal(ErrInvalidActionToken, err, "tokens are single-use")
+	err = suite.accounts.ChangePassword(suite.account().ID, &PasswordChange{CurrentPassword: "password2", NewPassword: "password3"})
+	suite.NoError(err, "the reset password is in effect")
+}
+
+func (suite *RecoveryServiceTestSuite) TestPasswordResetNeedsVerifiedEmail() {
+	sent := len(suite.smtp.Messages())
+
+	suite.NoError(suite.underTest.RequestPasswordReset("joel"))
+	suite.NoError(suite.underTest.RequestPasswordReset("nobody"))
+
+	suite.Len(suite.smtp.Messages(), sent)
+}
+
+func (suite *RecoveryServiceTestSuite) TestPasswordResetSendsInBackground() {
+	account := suite.account()
+	account.EmailVerified = true
+	suite.Require().NoError(suite.users.UpdateAccount(account))
+	var pending []func()
+	suite.underTest.(*recoveryService).async = func(f func()) { pending = append(pending, f) }
+	sent := len(suite.smtp.Messages())
+
+	suite.NoError(suite.underTest.RequestPasswordReset("joel"))
+	suite.Len(suite.smtp.Messages(), sent, "the caller does not wait for the mail")
+
+	suite.Require().Len(pending, 1)
+	pending[0]()
+	suite.NotEmpty(suite.lastMatch(resetToken))
+}
+
+func (suite *RecoveryServiceTestSuite) TestResetTokenCannotVerifyEmail() {
+	account := suite.account()
+	account.EmailVerified = true
+	suite.Require().NoError(suite.users.UpdateAccount(account))
+	suite.Require().NoError(suite.underTest.RequestPasswordReset("joel"))
+
+	suite.Equal(ErrInvalidActionToken, suite.underTest.VerifyEmail(suite.lastMatch(resetToken)))
+}
@@ -1,6 +1,131 @@
 package user
 
+import "time"
+
 type UserRepo interface {
 	CreateAccount(account *Account) error
 	GetUser(username string) (*Account, error)
-}
\ No newline at end of file
+	// GetUserByID returns a NotFoundError if no account has id.
+	GetUserByID(id string) (*Account, error)
+	GetUsers(usernames []string) ([]*Account, error)
+	// ListUsers returns every account.
+	ListUsers() ([]*Account, error)
+	// UpdateAccount replaces an existing account, returning a NotFoundError
+	// if there is none with its username.
+	UpdateAccount(account *Account) error
+	// DeleteAccount removes the account and everything stored with it.
+	DeleteAccount(account *Account) error
+	// SavePasskey stores a new passkey or replaces an existing one.
+	SavePasskey(passkey *Passkey) error
+	// GetPasskeys returns the passkeys of the account, oldest first.
+	GetPasskeys(accountID string) ([]*Passkey, error)
+	// DeletePasskey returns a PasskeyNotFoundError if the account has no
+	// passkey with id.
+	DeletePasskey(accountID, id string) error
+	// GetUsersByEmail returns every account with the email address,
+	// compared case-insensitively.
+	GetUsersByEmail(email string) ([]*Account, error)
+	// GetUserByIdentity returns the account linked to the external
+	// identity, or a NotFoundError if there is none.
+	GetUserByIdentity(provider, subject string) (*Account, error)
+	// GetIdentity returns the link of the external identity, or a
+	// NotFoundError if there is none.
+	GetIdentity(provider, subject string) (*Identity, error)
+	// LinkIdentity links the external identity unless it is linked
+	// already, and reports whether it did, so that only one of concurrent
+	// first logins links it.
+	LinkIdentity(identity *Identity) (bool, error)
+	// UnlinkIdentity returns a NotFoundError if the external identity is not
+	// linked to any account.
+	UnlinkIdentity(provider, subject string) error
+}
+
+// TokenRepo stores refresh tokens and tracks their rotation.
+type TokenRepo interface {
+	// SaveRefreshToken stores token until it expires.
+	SaveRefreshToken(token *RefreshToken) error
+	// GetRefreshToken returns the token with the given hash, or nil once it
+	// expired or its family was revoked.
+	GetRefreshToken(hash string) (*RefreshToken, error)
+	// MarkRefreshTokenUsed atomically flags the token as used and reports
+	// whether it had already been used before.
+	MarkRefreshTokenUsed(token *RefreshToken) (bool, error)
+	// RevokeFamily deletes every token in the family.
+	RevokeFamily(family string) error
+	// RevokeAccount deletes every token issued to the account.
+	RevokeAccount(accountID string) error
+}
+
+// ActionTokenRepo stores single-use tokens for email verification and
+// password resets.
+type ActionTokenRepo interface {
+	// SaveActionToken stores token until it expires.
+	SaveActionToken(token *ActionToken) error
+	// TakeActionToken atomically returns and deletes the token with the
+	// given purpose and hash, or returns nil if there is none.
+	TakeActionToken(purpose, hash string) (*ActionToken, error)
+}
+
+// MFARepo stores the second factor of accounts.
+type MFARepo interface {
+	// GetMFA returns the second factor of the account, or nil if it has none.
+	GetMFA(accountID string) (*MFA, error)
+	SaveMFA(mfa *MFA) error
+	DeleteMFA(accountID string) error
+}
+
+// LoginAttemptRepo counts login attempts per key, such as a username or a
+// client IP, and blocks keys that failed too often. Attempts are counted
+// before they are made and released unless they fail.
+type LoginAttemptRepo interface {
+	// Reserve increments the attempt count of key and returns the new
+	// count. The count expires window after the last attempt.
+	Reserve(key string, window time.Duration) (int, error)
+	// Release decrements the attempt count of key.
+	Release(key string) error
+	// Block rejects logins for key until the given time unless key is
+	// blocked already, and reports whether it blocked key.
+	Block(key string, until time.Time) (bool, error)
+	// BlockedUntil returns the end of the block on key, or the zero time if
+	// it is not blocked.
+	BlockedUntil(key string) (time.Time, error)
+	// Reset clears the failure count and block of key.
+	Reset(key string) error
+}
+
+// ServiceAccountRepo stores service accounts and their API keys.
+type ServiceAccountRepo interface {
+	CreateServiceAccount(account *ServiceAccount) error
+	// GetServiceAccount returns a ServiceAccountNotFoundError if there is
+	// no account with id.
+	GetServiceAccount(id string) (*ServiceAccount, error)
+	ListServiceAccounts() ([]*ServiceAccount, error)
+	// DeleteServiceAccount deletes the account and every key it owns.
+	DeleteServiceAccount(id string) error
+	SaveAPIKey(key *APIKey) error
+	// GetAPIKey returns the key with id, or nil if there is none.
+	GetAPIKey(id string) (*APIKey, error)
+	ListAPIKeys(serviceAccountID string) ([]*APIKey, error)
+	DeleteAPIKey(key *APIKey) error
+	// TouchAPIKey records that the key was used at the given time.
+	TouchAPIKey(key *APIKey, at time.Time) error
+}
+
+// OAuthClientRepo stores OpenID Connect clients and the consents accounts
+// gave them.
+type OAuthClientRepo interface {
+	CreateClient(client *OAuthClient) error
+	// GetClient returns an OAuthClientNotFoundError if there is no client
+	// with id.
+	GetClient(id string) (*OAuthClient, error)
+	ListClients() ([]*OAuthClient, error)
+	// DeleteClient deletes the client. Consents given to it are left to be
+	// ignored, since a new client never reuses its ID.
+	DeleteClient(id string) error
+	SaveConsent(consent *Consent) error
+	// GetConsent returns the consent of the account for the client, or nil
+	// if there is none.
+	GetConsent(accountID, clientID string) (*Consent, error)
+	ListConsents(accountID string) ([]*Consent, error)
+	DeleteConsent(accountID, clientID string) error
+}
@@ -1,36 +1,88 @@
 package user
 
 import (
-	"github.com/dgrijalva/jwt-go"
 	"github.com/google/uuid"
-	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
 	"hex-example/internal/env"
+	"hex-example/internal/middleware"
+	"hex-example/internal/validation"
+	"errors"
+	"sync"
 	"time"
 )
 
 type UserService interface {
 	CreateAccount(account *Account) error
 	Login(username, password string) (*Login, error)
+	VerifyMFA(verification *MFAVerification) (*Login, error)
+	LoginAccount(id string) (*Login, error)
+	LoginExternal(profile *ExternalProfile) (*Login, error)
+	UnlinkExternal(provider, subject string) (*Account, error)
+	DeleteExternal(provider, subject string) (*Account, error)
+	Refresh(refreshToken string) (*Login, error)
+	Logout(refreshToken string) error
+	FindAccounts(usernames []string) ([]*Account, error)
+	ListAccounts() ([]*Account, error)
+	SetRoles(username string, roles *Roles) (*Account, error)
+	GetAccount(id string) (*Account, error)
+	UpdateAccount(id string, update *AccountUpdate) (*Account, error)
+	ChangePassword(id string, change *PasswordChange) error
+	DeactivateAccount(id, password string) error
+	DeleteAccount(id, password string) error
 }
 
 type userService struct {
 	repo UserRepo
-	key []byte
+	tokens TokenRepo
+	mfa MFARepo
+	challenges ActionTokenRepo
+	signer Signer
+	issuer string
+	audience string
 }
 
-func NewUserService(repo UserRepo) UserService {
-	key := env.EnvString("SECRET", "secret")
+// NewUserService returns a UserService that signs access tokens with signer.
+// Services that never issue tokens may pass a nil signer, in which case
+// Login and Refresh fail with ErrNoSigner. Tokens name JWT_ISSUER as their
+// issuer and JWT_AUDIENCE as their audience. Accounts with a second factor
+// in mfa log in in two steps, with the challenges in between stored in
+// challenges. Services that never log anyone in may pass a nil mfa, in which
+// case anything reading or changing a second factor fails with ErrNoMFARepo.
+func NewUserService(repo UserRepo, tokens TokenRepo, mfa MFARepo, challenges ActionTokenRepo, signer Signer) UserService {
+	if mfa == nil {
+		mfa = noMFARepo{}
+	}
 	return &userService{
 		repo,
-		[]byte(key),
+		tokens,
+		mfa,
+		challenges,
+		signer,
+		env.EnvString("JWT_ISSUER", middleware.DefaultIssuer),
+		env.EnvString("JWT_AUDIENCE", middleware.DefaultAudience),
 	}
 }
 
+// ErrNoMFARepo is returned when a service without an MFARepo needs a second
+// factor.
+var ErrNoMFARepo = errors.New("user: no second factor repository configured")
+
+// noMFARepo fails every operation, so that a service without second factors
+// never lets an account log in without its own.
+type noMFARepo struct{}
+
+func (noMFARepo) GetMFA(accountID string) (*MFA, error) { return nil, ErrNoMFARepo }
+func (noMFARepo) SaveMFA(mfa *MFA) error                { return ErrNoMFARepo }
+func (noMFARepo) DeleteMFA(accountID string) error      { return ErrNoMFARepo }
+
 func (s *userService) CreateAccount(account *Account) error {
 
-	logrus.Infof("Account password: %s", account.Password)
+	if err := validateNew(account); err != nil {
+		logrus.WithField("error", err).Info("Invalid account")
+		return err
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(account.Password), bcrypt.DefaultCost)
 
 	if err != nil {
@@ -56,44 +108,423 @@ func (s *userService) Login(username, password string) (*Login, error) {
 
 	account, err := s.repo.GetUser(username)
 
+	if _, ok := err.(*NotFoundError); ok || (err == nil && account == nil) {
+		logrus.WithField("username", username).Info("Login for unknown account")
+		// spend as long as for a wrong password so that timing does not
+		// reveal which usernames exist
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return nil, ErrInvalidLogin
+	}
 	if err != nil {
 		logrus.WithField("username", username).Error("Unable to fetch account")
 		return nil, err
 	}
 
-	if account == nil {
-		return nil, errors.New("Invalid Username")
-	}
-
 	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(password)); err != nil {
 		logrus.WithFields(logrus.Fields{"username": username, "error": err.Error()}).Error("Invalid login")
+		return nil, ErrInvalidLogin
+	}
+	if account.Deactivated {
+		logrus.WithField("username", username).Info("Login for deactivated account")
+		return nil, ErrAccountDeactivated
+	}
+
+	mfa, err := s.mfa.GetMFA(account.ID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"username": username, "error": err}).Error("Unable to fetch second factor")
+		return nil, err
+	}
+	if mfa != nil && mfa.Enabled {
+		return s.challenge(account)
+	}
+
+	return s.issue(account, uuid.New().String(), time.Now())
+}
+
+// challenge returns a Login carrying only an MFA token, to be exchanged by
+// VerifyMFA.
+func (s *userService) challenge(account *Account) (*Login, error) {
+	value, token, err := newActionToken(PurposeMFAChallenge, account, mfaChallengeTTL)
+	if err != nil {
+		return nil, err
+	}
+	// the username lets the LoginGuard count wrong codes per account; it is
+	// part of the hashed value, so it cannot be swapped
+	value = account.Username + mfaTokenSeparator + value
+	token.Hash = hashActionToken(value)
+	if err := s.challenges.SaveActionToken(token); err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to save two-factor challenge")
+		return nil, err
+	}
+	return &Login{
+		Username: account.Username,
+		ExpiresIn: int64(mfaChallengeTTL / time.Second),
+		MFAToken: value,
+	}, nil
+}
+
+// VerifyMFA completes a two-factor login. A challenge survives up to
+// maxMFAAttempts wrong codes.
+func (s *userService) VerifyMFA(verification *MFAVerification) (*Login, error) {
+	stored, err := s.challenges.TakeActionToken(PurposeMFAChallenge, hashActionToken(verification.MFAToken))
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch two-factor challenge")
+		return nil, err
+	}
+	if stored == nil || time.Now().After(stored.Expires) {
+		return nil, ErrInvalidMFAToken
+	}
+
+	account, err := s.repo.GetUserByID(stored.AccountID)
+	if _, ok := err.(*NotFoundError); ok {
+		return nil, ErrInvalidMFAToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	mfa, err := s.mfa.GetMFA(account.ID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to fetch second factor")
+		return nil, err
+	}
+	if account.Deactivated || mfa == nil || !mfa.Enabled {
+		return nil, ErrInvalidMFAToken
+	}
+
+	if !checkTOTP(mfa, verification.Code, time.Now()) && !useRecoveryCode(mfa, verification.Code) {
+		logrus.WithField("username", account.Username).Info("Wrong two-factor code")
+		stored.Attempts++
+		if stored.Attempts < maxMFAAttempts {
+			if err := s.challenges.SaveActionToken(stored); err != nil {
+				logrus.WithField("error", err).Error("Unable to save two-factor challenge")
+			}
+		}
+		return nil, ErrInvalidMFACode
+	}
+	if err := s.mfa.SaveMFA(mfa); err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to save second factor")
+		return nil, err
+	}
+
+	return s.issue(account, uuid.New().String(), time.Now())
+}
+
+// LoginAccount issues tokens to the account with id once it authenticated
+// by means other than its password, such as a passkey, which count as both
+// factors.
+func (s *userService) LoginAccount(id string) (*Login, error) {
+	account, err := s.repo.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if account.Deactivated {
+		logrus.WithField("username", account.Username).Info("Login for deactivated account")
+		return nil, ErrAccountDeactivated
+	}
+	return s.issue(account, uuid.New().String(), time.Now())
+}
+
+var (
+	dummyHashOnce  sync.Once
+	dummyHashValue []byte
+)
+
+// dummyHash returns a bcrypt hash at the cost of stored passwords, to
+// compare against when there is no account.
+func dummyHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyHashValue, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	})
+	return dummyHashValue
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token in the same family. Presenting a token that was already exchanged
+// means it leaked, so the whole family is revoked.
+func (s *userService) Refresh(refreshToken string) (*Login, error) {
+	stored, err := s.tokens.GetRefreshToken(hashRefreshToken(refreshToken))
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch refresh token")
+		return nil, err
+	}
+	if stored == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	reused, err := s.tokens.MarkRefreshTokenUsed(stored)
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to rotate refresh token")
+		return nil, err
+	}
+	if reused {
+		logrus.WithFields(logrus.Fields{"username": stored.Username, "family": stored.Family}).Warn("Refresh token reused, revoking family")
+		if err := s.tokens.RevokeFamily(stored.Family); err != nil {
+			logrus.WithField("error", err).Error("Unable to revoke refresh token family")
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if time.Now().After(stored.Expires) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	account, err := s.repo.GetUser(stored.Username)
+	if _, ok := err.(*NotFoundError); ok {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		logrus.WithField("username", stored.Username).Error("Unable to fetch account")
 		return nil, err
 	}
+	if account.Deactivated {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return s.issue(account, stored.Family, stored.AuthTime)
+}
+
+// Logout revokes the family of the given refresh token. Unknown tokens are
+// ignored so that logging out twice succeeds.
+func (s *userService) Logout(refreshToken string) error {
+	stored, err := s.tokens.GetRefreshToken(hashRefreshToken(refreshToken))
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch refresh token")
+		return err
+	}
+	if stored == nil {
+		return nil
+	}
+	return s.tokens.RevokeFamily(stored.Family)
+}
 
-	token, err := s.getToken(account)
+// issue returns a Login with a new access token and a new refresh token in
+// family. authTime is when the account holder authenticated and is carried
+// over from the refresh token on refresh.
+func (s *userService) issue(account *Account, family string, authTime time.Time) (*Login, error) {
+	token, err := s.getToken(account, authTime)
 	if err != nil {
-		logrus.WithFields(logrus.Fields{"username": username, "error": err}).Error("Unable to generate token")
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to generate token")
+		return nil, err
 	}
+
+	refreshToken, stored, err := newRefreshToken(account, family, authTime)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to generate refresh token")
+		return nil, err
+	}
+	if err := s.tokens.SaveRefreshToken(stored); err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to save refresh token")
+		return nil, err
+	}
+
 	login := &Login{
-		Username: username,
+		Username: account.Username,
+		AccountID: account.ID,
 		Token: token,
+		ExpiresIn: int64(accessTokenTTL / time.Second),
+		RefreshToken: refreshToken,
 	}
 	return login, nil
 }
 
-func (s *userService) getToken(account *Account) (string, error) {
+// FindAccounts returns the accounts for the given usernames in the same order,
+// with a nil entry for every username that has no account. Password hashes
+// are stripped before the accounts are returned.
+func (s *userService) FindAccounts(usernames []string) ([]*Account, error) {
+	accounts, err := s.repo.GetUsers(usernames)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"error": err, "usernames": usernames}).Error("Unable to fetch accounts")
+		return nil, err
+	}
 
-	/* Create the token */
-	token := jwt.New(jwt.SigningMethodHS256)
+	for _, account := range accounts {
+		if account != nil {
+			account.Password = ""
+		}
+	}
+	return accounts, nil
+}
 
-	/* Create a map to store our claims */
-	claims := token.Claims.(jwt.MapClaims)
+// ListAccounts returns every account without password hashes.
+func (s *userService) ListAccounts() ([]*Account, error) {
+	accounts, err := s.repo.ListUsers()
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to list accounts")
+		return nil, err
+	}
+
+	for _, account := range accounts {
+		account.Password = ""
+	}
+	return accounts, nil
+}
+
+// SetRoles replaces the global and per-project roles of an account. The new
+// roles are carried by access tokens issued from then on, so they apply to
+// existing sessions from their next refresh.
+func (s *userService) SetRoles(username string, roles *Roles) (*Account, error) {
+	if err := validateRoles(roles); err != nil {
+		logrus.WithField("error", err).Info("Invalid roles")
+		return nil, err
+	}
+
+	account, err := s.repo.GetUser(username)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"username": username, "error": err}).Info("Unable to fetch account")
+		return nil, err
+	}
+
+	account.Role = roles.Role
+	account.Projects = roles.Projects
+	if err := s.repo.UpdateAccount(account); err != nil {
+		logrus.WithFields(logrus.Fields{"username": username, "error": err}).Error("Unable to update account")
+		return nil, err
+	}
+	logrus.WithFields(logrus.Fields{"username": username, "role": roles.Role, "projects": roles.Projects}).Info("Roles changed")
+
+	account.Password = ""
+	return account, nil
+}
+
+// GetAccount returns the account with id without its password hash.
+func (s *userService) GetAccount(id string) (*Account, error) {
+	account, err := s.repo.GetUserByID(id)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"id": id, "error": err}).Info("Unable to fetch account")
+		return nil, err
+	}
+	account.Password = ""
+	return account, nil
+}
 
-	/* Set token claims */
-	claims["sub"] = account.ID
-	claims["type"] = "user"
-	claims["exp"] = time.Now().Add(time.Hour * 24).Unix()
+// UpdateAccount applies the non-nil fields of update to the profile of the
+// account with id.
+func (s *userService) UpdateAccount(id string, update *AccountUpdate) (*Account, error) {
+	if err := validateUpdate(update); err != nil {
+		logrus.WithField("error", err).Info("Invalid account update")
+		return nil, err
+	}
 
-	/* Sign the token with our secret */
-	return token.SignedString(s.key)
+	account, err := s.repo.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if update.FirstName != nil {
+		account.FirstName = *update.FirstName
+	}
+	if update.LastName != nil {
+		account.LastName = *update.LastName
+	}
+	if update.Email != nil && *update.Email != account.Email {
+		account.Email = *update.Email
+		account.EmailVerified = false
+	}
+	if err := s.repo.UpdateAccount(account); err != nil {
+		logrus.WithFields(logrus.Fields{"id": id, "error": err}).Error("Unable to update account")
+		return nil, err
+	}
+
+	account.Password = ""
+	return account, nil
+}
+
+// ChangePassword replaces the password after checking the current one and
+// logs out every session of the account.
+func (s *userService) ChangePassword(id string, change *PasswordChange) error {
+	v := new(validation.Validator)
+	v.Required("currentPassword", change.CurrentPassword)
+	validatePassword(v, "newPassword", change.NewPassword)
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	account, err := s.reauthenticate(id, change.CurrentPassword)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(change.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to hash password: ")
+		return err
+	}
+	account.Password = string(hashedPassword)
+	if err := s.repo.UpdateAccount(account); err != nil {
+		logrus.WithFields(logrus.Fields{"id": id, "error": err}).Error("Unable to update account")
+		return err
+	}
+	logrus.WithField("username", account.Username).Info("Password changed")
+
+	return s.revokeSessions(account)
+}
+
+// DeactivateAccount disables login for the account and logs out every
+// session. Access tokens already issued stay valid until they expire.
+func (s *userService) DeactivateAccount(id, password string) error {
+	account, err := s.reauthenticate(id, password)
+	if err != nil {
+		return err
+	}
+
+	account.Deactivated = true
+	if err := s.repo.UpdateAccount(account); err != nil {
+		logrus.WithFields(logrus.Fields{"id": id, "error": err}).Error("Unable to update account")
+		return err
+	}
+	logrus.WithField("username", account.Username).Info("Account deactivated")
+
+	return s.revokeSessions(account)
+}
+
+// DeleteAccount erases the account and its sessions. Tickets keep the
+// username they were created with.
+func (s *userService) DeleteAccount(id, password string) error {
+	account, err := s.reauthenticate(id, password)
+	if err != nil {
+		return err
+	}
+
+	if err := s.deleteAccount(account); err != nil {
+		return err
+	}
+	logrus.WithField("id", id).Info("Account deleted")
+	return nil
+}
+
+// deleteAccount revokes the sessions of account, then erases its second
+// factor and the account itself.
+func (s *userService) deleteAccount(account *Account) error {
+	if err := s.revokeSessions(account); err != nil {
+		return err
+	}
+	if err := s.mfa.DeleteMFA(account.ID); err != nil {
+		logrus.WithFields(logrus.Fields{"id": account.ID, "error": err}).Error("Unable to delete second factor")
+		return err
+	}
+	if err := s.repo.DeleteAccount(account); err != nil {
+		logrus.WithFields(logrus.Fields{"id": account.ID, "error": err}).Error("Unable to delete account")
+		return err
+	}
+	return nil
+}
+
+// reauthenticate returns the account with id if password is its password.
+func (s *userService) reauthenticate(id, password string) (*Account, error) {
+	account, err := s.repo.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(password)); err != nil {
+		logrus.WithField("username", account.Username).Info("Wrong password confirming account change")
+		return nil, ErrWrongPassword
+	}
+	return account, nil
+}
+
+func (s *userService) revokeSessions(account *Account) error {
+	if err := s.tokens.RevokeAccount(account.ID); err != nil {
+		logrus.WithFields(logrus.Fields{"username": account.Username, "error": err}).Error("Unable to revoke refresh tokens")
+		return err
+	}
+	return nil
 }
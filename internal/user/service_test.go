@@ -0,0 +1,246 @@
+package user
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"hex-example/internal/jwks"
+	"hex-example/internal/middleware"
+	"hex-example/internal/rbac"
+)
+
+func TestUserServiceSuite(t *testing.T) {
+	suite.Run(t, new(UserServiceTestSuite))
+}
+
+type UserServiceTestSuite struct {
+	suite.Suite
+	users     *fakeUserRepo
+	tokens    *fakeTokenRepo
+	keys      *jwks.KeyRing
+	underTest UserService
+}
+
+func (suite *UserServiceTestSuite) SetupTest() {
+	suite.users = newFakeUserRepo()
+	suite.tokens = newFakeTokenRepo()
+	keys, err := jwks.NewKeyRing(jwks.NewMemoryStore(), jwks.ES256, time.Hour, accessTokenTTL, 0)
+	suite.Require().NoError(err)
+	suite.keys = keys
+	suite.underTest = NewUserService(suite.users, suite.tokens, newFakeMFARepo(), newFakeActionTokenRepo(), keys)
+
+	err = suite.underTest.CreateAccount(&Account{Username: "joel", Password: "password1"})
+	suite.Require().NoError(err)
+}
+
+func (suite *UserServiceTestSuite) TestLogin() {
+	login, err := suite.underTest.Login("joel", "password1")
+
+	suite.NoError(err)
+	suite.NotEmpty(login.Token)
+	suite.NotEmpty(login.RefreshToken)
+	suite.Equal(int64(accessTokenTTL.Seconds()), login.ExpiresIn)
+	suite.Len(suite.tokens.tokens, 1, "only the hash of the refresh token is stored")
+	suite.NotContains(suite.tokens.tokens, login.RefreshToken)
+}
+
+func (suite *UserServiceTestSuite) TestLoginTokenVerifiesWithPublicKey() {
+	login, _ := suite.underTest.Login("joel", "password1")
+
+	claims, err := middleware.NewTokenValidator(suite.keys, middleware.ConfigFromEnv()).Validate(login.Token)
+
+	suite.Require().NoError(err)
+	suite.Equal(middleware.PrincipalUser, claims.Type)
+	suite.Equal(middleware.DefaultIssuer, claims.Issuer)
+	suite.Empty(claims.Role, "new accounts hold no global role")
+}
+
+func (suite *UserServiceTestSuite) TestLoginWithoutSigner() {
+	_, err := NewUserService(suite.users, suite.tokens, newFakeMFARepo(), newFakeActionTokenRepo(), nil).Login("joel", "password1")
+	suite.Equal(ErrNoSigner, err)
+}
+
+func (suite *UserServiceTestSuite) TestWithoutMFARepo() {
+	underTest := NewUserService(suite.users, suite.tokens, nil, newFakeActionTokenRepo(), suite.keys)
+	suite.NoError(underTest.CreateAccount(&Account{Username: "ivy", Password: "password1"}))
+
+	_, err := underTest.Login("ivy", "password1")
+	suite.Equal(ErrNoMFARepo, err, "logins fail rather than skip the second factor")
+}
+
+func (suite *UserServiceTestSuite) TestLoginUnknownUserAndWrongPasswordLookAlike() {
+	_, unknown := suite.underTest.Login("nobody", "password1")
+	_, wrong := suite.underTest.Login("joel", "password2")
+
+	suite.Equal(ErrInvalidLogin, unknown)
+	suite.Equal(ErrInvalidLogin, wrong)
+}
+
+func (suite *UserServiceTestSuite) TestRefreshRotates() {
+	login, _ := suite.underTest.Login("joel", "password1")
+
+	refreshed, err := suite.underTest.Refresh(login.RefreshToken)
+
+	suite.NoError(err)
+	suite.Equal("joel", refreshed.Username)
+	suite.NotEmpty(refreshed.Token)
+	suite.NotEqual(login.RefreshToken, refreshed.RefreshToken)
+}
+
+func (suite *UserServiceTestSuite) TestRefreshKeepsAuthTime() {
+	login, _ := suite.underTest.Login("joel", "password1")
+	validator := middleware.NewTokenValidator(suite.keys, middleware.ConfigFromEnv())
+	original, err := validator.Validate(login.Token)
+	suite.Require().NoError(err)
+	suite.Require().NotZero(original.AuthTime)
+
+	// an hour later the stored token family still dates from the login
+	for _, stored := range suite.tokens.tokens {
+		stored.AuthTime = stored.AuthTime.Add(-time.Hour)
+	}
+	refreshed, err := suite.underTest.Refresh(login.RefreshToken)
+	suite.Require().NoError(err)
+
+	claims, err := validator.Validate(refreshed.Token)
+	suite.Require().NoError(err)
+	suite.Equal(original.AuthTime-int64(time.Hour/time.Second), claims.AuthTime)
+	suite.NotEqual(claims.AuthTime, claims.IssuedAt)
+}
+
+func (suite *UserServiceTestSuite) TestRefreshReuseRevokesFamily() {
+	login, _ := suite.underTest.Login("joel", "password1")
+	rotated, err := suite.underTest.Refresh(login.RefreshToken)
+	suite.Require().NoError(err)
+
+	// the first token is replayed, e.g. by an attacker who stole it
+	_, err = suite.underTest.Refresh(login.RefreshToken)
+	suite.Equal(ErrInvalidRefreshToken, err)
+
+	// the legitimate holder of the rotated token is logged out as well
+	_, err = suite.underTest.Refresh(rotated.RefreshToken)
+	suite.Equal(ErrInvalidRefreshToken, err)
+}
+
+func (suite *UserServiceTestSuite) TestRefreshUnknownToken() {
+	_, err := suite.underTest.Refresh("unknown")
+	suite.Equal(ErrInvalidRefreshToken, err)
+}
+
+func (suite *UserServiceTestSuite) TestLogoutRevokesFamily() {
+	login, _ := suite.underTest.Login("joel", "password1")
+	other, _ := suite.underTest.Login("joel", "password1")
+
+	suite.NoError(suite.underTest.Logout(login.RefreshToken))
+	suite.NoError(suite.underTest.Logout(login.RefreshToken), "logging out twice succeeds")
+
+	_, err := suite.underTest.Refresh(login.RefreshToken)
+	suite.Equal(ErrInvalidRefreshToken, err)
+	_, err = suite.underTest.Refresh(other.RefreshToken)
+	suite.NoError(err, "other sessions stay logged in")
+}
+
+func (suite *UserServiceTestSuite) TestSetRolesAppearInToken() {
+	roles := &Roles{Role: rbac.RoleViewer, Projects: map[string]string{"infra": rbac.RoleAdmin}}
+	account, err := suite.underTest.SetRoles("joel", roles)
+	suite.Require().NoError(err)
+	suite.Empty(account.Password)
+
+	login, _ := suite.underTest.Login("joel", "password1")
+	claims, err := middleware.NewTokenValidator(suite.keys, middleware.Config{}).Validate(login.Token)
+
+	suite.Require().NoError(err)
+	suite.Equal(rbac.RoleViewer, claims.Role)
+	suite.Equal(roles.Projects, claims.Projects)
+}
+
+func (suite *UserServiceTestSuite) TestSetRolesInvalid() {
+	_, err := suite.underTest.SetRoles("joel", &Roles{Role: "root"})
+	suite.IsType(ValidationError{}, err)
+
+	_, err = suite.underTest.SetRoles("nobody", &Roles{Role: rbac.RoleAdmin})
+	suite.IsType(&NotFoundError{}, err)
+}
+
+func (suite *UserServiceTestSuite) TestListAccountsStripsPasswords() {
+	accounts, err := suite.underTest.ListAccounts()
+
+	suite.NoError(err)
+	if suite.Len(accounts, 1) {
+		suite.Empty(accounts[0].Password)
+	}
+}
+
+func (suite *UserServiceTestSuite) accountID() string {
+	account, err := suite.users.GetUser("joel")
+	suite.Require().NoError(err)
+	return account.ID
+}
+
+func (suite *UserServiceTestSuite) TestGetAndUpdateAccount() {
+	first := "Joel"
+	account, err := suite.underTest.UpdateAccount(suite.accountID(), &AccountUpdate{FirstName: &first})
+	suite.Require().NoError(err)
+	suite.Equal("Joel", account.FirstName)
+
+	account, err = suite.underTest.GetAccount(suite.accountID())
+	suite.Require().NoError(err)
+	suite.Equal("Joel", account.FirstName)
+	suite.Empty(account.Password)
+
+	_, err = suite.underTest.GetAccount("unknown")
+	suite.IsType(&NotFoundError{}, err)
+}
+
+func (suite *UserServiceTestSuite) TestUpdateAccountInvalid() {
+	long := strings.Repeat("x", maxNameLength+1)
+	_, err := suite.underTest.UpdateAccount(suite.accountID(), &AccountUpdate{LastName: &long})
+	suite.IsType(ValidationError{}, err)
+}
+
+func (suite *UserServiceTestSuite) TestChangePassword() {
+	login, _ := suite.underTest.Login("joel", "password1")
+
+	err := suite.underTest.ChangePassword(suite.accountID(), &PasswordChange{CurrentPassword: "wrong1", NewPassword: "password2"})
+	suite.Equal(ErrWrongPassword, err)
+	err = suite.underTest.ChangePassword(suite.accountID(), &PasswordChange{CurrentPassword: "password1", NewPassword: "short"})
+	suite.IsType(ValidationError{}, err)
+
+	suite.NoError(suite.underTest.ChangePassword(suite.accountID(), &PasswordChange{CurrentPassword: "password1", NewPassword: "password2"}))
+
+	_, err = suite.underTest.Login("joel", "password1")
+	suite.Equal(ErrInvalidLogin, err)
+	_, err = suite.underTest.Login("joel", "password2")
+	suite.NoError(err)
+	_, err = suite.underTest.Refresh(login.RefreshToken)
+	suite.Equal(ErrInvalidRefreshToken, err, "existing sessions are logged out")
+}
+
+func (suite *UserServiceTestSuite) TestDeactivateAccount() {
+	login, _ := suite.underTest.Login("joel", "password1")
+
+	suite.Equal(ErrWrongPassword, suite.underTest.DeactivateAccount(suite.accountID(), "wrong1"))
+	suite.NoError(suite.underTest.DeactivateAccount(suite.accountID(), "password1"))
+
+	_, err := suite.underTest.Login("joel", "password1")
+	suite.Equal(ErrAccountDeactivated, err)
+	_, err = suite.underTest.Refresh(login.RefreshToken)
+	suite.Equal(ErrInvalidRefreshToken, err)
+}
+
+func (suite *UserServiceTestSuite) TestDeleteAccount() {
+	id := suite.accountID()
+	login, _ := suite.underTest.Login("joel", "password1")
+
+	suite.Equal(ErrWrongPassword, suite.underTest.DeleteAccount(id, "wrong1"))
+	suite.NoError(suite.underTest.DeleteAccount(id, "password1"))
+
+	_, err := suite.underTest.GetAccount(id)
+	suite.IsType(&NotFoundError{}, err)
+	_, err = suite.underTest.Login("joel", "password1")
+	suite.Equal(ErrInvalidLogin, err)
+	suite.Empty(suite.tokens.tokens)
+	_, err = suite.underTest.Refresh(login.RefreshToken)
+	suite.Equal(ErrInvalidRefreshToken, err)
+}
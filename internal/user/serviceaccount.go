@@ -0,0 +1,260 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"hex-example/internal/middleware"
+	"hex-example/internal/rbac"
+	"hex-example/internal/validation"
+)
+
+const (
+	apiKeyIDLen     = 8
+	apiKeySecretLen = 32
+	// lastUsedGranularity bounds how often verifying a key writes to the
+	// store.
+	lastUsedGranularity  = time.Minute
+	maxServiceNameLength = 64
+	maxDescriptionLength = 255
+	// maxAPIKeyLifetime bounds ExpiresIn, in seconds, well below the
+	// overflow of a time.Duration.
+	maxAPIKeyLifetime = 10 * 365 * 24 * 60 * 60
+)
+
+// ServiceAccountService manages service accounts and their API keys and
+// verifies keys presented to the APIs.
+type ServiceAccountService interface {
+	CreateServiceAccount(account *ServiceAccount, createdBy string) error
+	ListServiceAccounts() ([]*ServiceAccount, error)
+	DeleteServiceAccount(id string) error
+	CreateAPIKey(serviceAccountID string, req *APIKeyRequest) (*IssuedAPIKey, error)
+	ListAPIKeys(serviceAccountID string) ([]*APIKey, error)
+	RevokeAPIKey(serviceAccountID, keyID string) error
+	VerifyAPIKey(key string) (*middleware.Claims, error)
+}
+
+type serviceAccountService struct {
+	repo ServiceAccountRepo
+}
+
+func NewServiceAccountService(repo ServiceAccountRepo) ServiceAccountService {
+	return &serviceAccountService{
+		repo,
+	}
+}
+
+func (s *serviceAccountService) CreateServiceAccount(account *ServiceAccount, createdBy string) error {
+	v := new(validation.Validator)
+	v.Empty("id", account.ID != "")
+	if v.Required("name", account.Name) {
+		v.Length("name", account.Name, 1, maxServiceNameLength)
+	}
+	v.Length("description", account.Description, 0, maxDescriptionLength)
+	validateScopes(v, "scopes", account.Scopes, nil)
+	if err := v.Err(); err != nil {
+		logrus.WithField("error", err).Info("Invalid service account")
+		return err
+	}
+
+	account.ID = uuid.New().String()
+	account.CreatedBy = createdBy
+	account.Created = time.Now()
+	if err := s.repo.CreateServiceAccount(account); err != nil {
+		logrus.WithField("error", err).Error("Unable to save service account")
+		return err
+	}
+	logrus.WithFields(logrus.Fields{"id": account.ID, "name": account.Name, "createdBy": createdBy}).Info("Service account created")
+	return nil
+}
+
+func (s *serviceAccountService) ListServiceAccounts() ([]*ServiceAccount, error) {
+	return s.repo.ListServiceAccounts()
+}
+
+func (s *serviceAccountService) DeleteServiceAccount(id string) error {
+	if _, err := s.repo.GetServiceAccount(id); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteServiceAccount(id); err != nil {
+		logrus.WithFields(logrus.Fields{"id": id, "error": err}).Error("Unable to delete service account")
+		return err
+	}
+	logrus.WithField("id", id).Info("Service account deleted")
+	return nil
+}
+
+// CreateAPIKey issues a key for the service account. The key is limited to
+// the requested scopes, which default to and must be within the account's.
+func (s *serviceAccountService) CreateAPIKey(serviceAccountID string, req *APIKeyRequest) (*IssuedAPIKey, error) {
+	account, err := s.repo.GetServiceAccount(serviceAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = account.Scopes
+	}
+	v := new(validation.Validator)
+	validateScopes(v, "scopes", scopes, account.Scopes)
+	v.Check(req.ExpiresIn >= 0 && req.ExpiresIn <= maxAPIKeyLifetime, "expiresIn", fmt.Sprintf("must be between 0 and %d seconds", maxAPIKeyLifetime))
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, apiKeyIDLen)
+	secret := make([]byte, apiKeySecretLen)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+
+	key := &APIKey{
+		ID:               hex.EncodeToString(id),
+		ServiceAccountID: account.ID,
+		Hash:             hashAPIKeySecret(encodedSecret),
+		Scopes:           scopes,
+		Created:          time.Now(),
+	}
+	if req.ExpiresIn > 0 {
+		key.Expires = key.Created.Add(time.Duration(req.ExpiresIn) * time.Second)
+	}
+	if err := s.repo.SaveAPIKey(key); err != nil {
+		logrus.WithField("error", err).Error("Unable to save API key")
+		return nil, err
+	}
+	logrus.WithFields(logrus.Fields{"serviceAccount": account.ID, "key": key.ID}).Info("API key created")
+
+	stored := *key
+	stored.Hash = ""
+	return &IssuedAPIKey{
+		Key:    middleware.APIKeyPrefix + key.ID + "_" + encodedSecret,
+		APIKey: &stored,
+	}, nil
+}
+
+func (s *serviceAccountService) ListAPIKeys(serviceAccountID string) ([]*APIKey, error) {
+	if _, err := s.repo.GetServiceAccount(serviceAccountID); err != nil {
+		return nil, err
+	}
+	keys, err := s.repo.ListAPIKeys(serviceAccountID)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		key.Hash = ""
+	}
+	return keys, nil
+}
+
+func (s *serviceAccountService) RevokeAPIKey(serviceAccountID, keyID string) error {
+	key, err := s.repo.GetAPIKey(keyID)
+	if err != nil {
+		return err
+	}
+	if key == nil || key.ServiceAccountID != serviceAccountID {
+		return &ServiceAccountNotFoundError{ID: keyID}
+	}
+	if err := s.repo.DeleteAPIKey(key); err != nil {
+		logrus.WithFields(logrus.Fields{"key": keyID, "error": err}).Error("Unable to revoke API key")
+		return err
+	}
+	logrus.WithFields(logrus.Fields{"serviceAccount": serviceAccountID, "key": keyID}).Info("API key revoked")
+	return nil
+}
+
+// VerifyAPIKey implements middleware.APIKeyVerifier. The claims name the
+// service account as a service principal holding the key's scopes.
+func (s *serviceAccountService) VerifyAPIKey(value string) (*middleware.Claims, error) {
+	id, secret, ok := parseAPIKey(value)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.repo.GetAPIKey(id)
+	if err != nil {
+		logrus.WithField("error", err).Error("Unable to fetch API key")
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	hash := hashAPIKeySecret(secret)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(key.Hash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if !key.Expires.IsZero() && now.After(key.Expires) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if now.Sub(key.LastUsed) >= lastUsedGranularity {
+		if err := s.repo.TouchAPIKey(key, now); err != nil {
+			logrus.WithFields(logrus.Fields{"key": key.ID, "error": err}).Warn("Unable to record API key use")
+		}
+	}
+
+	claims := &middleware.Claims{
+		Subject: key.ServiceAccountID,
+		Type:    middleware.PrincipalService,
+		Scopes:  key.Scopes,
+	}
+	if !key.Expires.IsZero() {
+		claims.ExpiresAt = key.Expires.Unix()
+	}
+	return claims, nil
+}
+
+// parseAPIKey splits hxk_<id>_<secret> into its id and secret.
+func parseAPIKey(value string) (string, string, bool) {
+	if !strings.HasPrefix(value, middleware.APIKeyPrefix) {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, middleware.APIKeyPrefix), "_", 2)
+	if len(parts) != 2 || len(parts[0]) != 2*apiKeyIDLen || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// hashAPIKeySecret returns the stored form of a key secret. The secret is
+// random, so a fast hash is enough.
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// validateScopes checks every scope is a known permission and, if within is
+// not nil, one of within.
+func validateScopes(v *validation.Validator, field string, scopes, within []string) {
+	v.Check(len(scopes) > 0, field, "at least one scope is required")
+	for _, scope := range scopes {
+		if !v.Check(rbac.ValidPermission(scope), field, "unknown scope "+scope) {
+			continue
+		}
+		if within != nil {
+			v.Check(contains(within, scope), field, "scope "+scope+" is not granted to the service account")
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package user
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"hex-example/internal/middleware"
+	"hex-example/internal/problem"
+)
+
+type ServiceAccountHandler interface {
+	Create(w http.ResponseWriter, r *http.Request)
+	List(w http.ResponseWriter, r *http.Request)
+	Delete(w http.ResponseWriter, r *http.Request)
+	CreateKey(w http.ResponseWriter, r *http.Request)
+	ListKeys(w http.ResponseWriter, r *http.Request)
+	RevokeKey(w http.ResponseWriter, r *http.Request)
+}
+
+type serviceAccountHandler struct {
+	service ServiceAccountService
+}
+
+func NewServiceAccountHandler(service ServiceAccountService) ServiceAccountHandler {
+	return &serviceAccountHandler{
+		service,
+	}
+}
+
+func (h *serviceAccountHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var account ServiceAccount
+	if err := json.NewDecoder(r.Body).Decode(&account); err != nil {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for service account"))
+		return
+	}
+
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	if err := h.service.CreateServiceAccount(&account, principal.ID); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, account)
+}
+
+func (h *serviceAccountHandler) List(w http.ResponseWriter, r *http.Request) {
+	accounts, err := h.service.ListServiceAccounts()
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, accounts)
+}
+
+func (h *serviceAccountHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.DeleteServiceAccount(mux.Vars(r)["id"]); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *serviceAccountHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	var req APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.New(http.StatusBadRequest, "Bad format for API key request"))
+		return
+	}
+
+	key, err := h.service.CreateAPIKey(mux.Vars(r)["id"], &req)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, r, http.StatusCreated, key)
+}
+
+func (h *serviceAccountHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.service.ListAPIKeys(mux.Vars(r)["id"])
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, keys)
+}
+
+func (h *serviceAccountHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := h.service.RevokeAPIKey(vars["id"], vars["keyId"]); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,121 @@
+package user
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"hex-example/internal/middleware"
+	"hex-example/internal/rbac"
+)
+
+func TestServiceAccountServiceSuite(t *testing.T) {
+	suite.Run(t, new(ServiceAccountServiceTestSuite))
+}
+
+type ServiceAccountServiceTestSuite struct {
+	suite.Suite
+	repo      *fakeServiceAccountRepo
+	account   *ServiceAccount
+	underTest ServiceAccountService
+}
+
+func (suite *ServiceAccountServiceTestSuite) SetupTest() {
+	suite.repo = newFakeServiceAccountRepo()
+	suite.underTest = NewServiceAccountService(suite.repo)
+
+	suite.account = &ServiceAccount{Name: "ci", Scopes: []string{"tickets:read", "tickets:create"}}
+	suite.Require().NoError(suite.underTest.CreateServiceAccount(suite.account, "admin-id"))
+}
+
+func (suite *ServiceAccountServiceTestSuite) TestCreateInvalid() {
+	err := suite.underTest.CreateServiceAccount(&ServiceAccount{Scopes: []string{"tickets:*"}}, "admin-id")
+
+	errs, ok := err.(ValidationError)
+	suite.Require().True(ok)
+	suite.Len(errs, 2)
+}
+
+func (suite *ServiceAccountServiceTestSuite) TestKeyVerifies() {
+	issued, err := suite.underTest.CreateAPIKey(suite.account.ID, &APIKeyRequest{})
+	suite.Require().NoError(err)
+	suite.True(strings.HasPrefix(issued.Key, middleware.APIKeyPrefix+issued.ID+"_"))
+	suite.Empty(issued.Hash)
+	suite.NotContains(suite.repo.keys[issued.ID].Hash, strings.TrimPrefix(issued.Key, middleware.APIKeyPrefix+issued.ID+"_"))
+
+	claims, err := suite.underTest.VerifyAPIKey(issued.Key)
+
+	suite.Require().NoError(err)
+	suite.Equal(suite.account.ID, claims.Subject)
+	suite.Equal(middleware.PrincipalService, claims.Type)
+	suite.Equal(suite.account.Scopes, claims.Scopes)
+	suite.False(suite.repo.keys[issued.ID].LastUsed.IsZero(), "last use is recorded")
+
+	suite.underTest.VerifyAPIKey(issued.Key)
+	suite.Equal(1, suite.repo.touches, "last use is recorded at most once a minute")
+}
+
+func (suite *ServiceAccountServiceTestSuite) TestKeyScopesWithinAccount() {
+	issued, err := suite.underTest.CreateAPIKey(suite.account.ID, &APIKeyRequest{Scopes: []string{"tickets:read"}})
+	suite.Require().NoError(err)
+	suite.Equal([]string{"tickets:read"}, issued.Scopes)
+
+	_, err = suite.underTest.CreateAPIKey(suite.account.ID, &APIKeyRequest{Scopes: []string{string(rbac.AccountsManage)}})
+	suite.IsType(ValidationError{}, err)
+}
+
+func (suite *ServiceAccountServiceTestSuite) TestKeyLifetimeBounds() {
+	for _, expiresIn := range []int64{-1, maxAPIKeyLifetime + 1, math.MaxInt64} {
+		_, err := suite.underTest.CreateAPIKey(suite.account.ID, &APIKeyRequest{ExpiresIn: expiresIn})
+		suite.IsType(ValidationError{}, err, expiresIn)
+	}
+
+	issued, err := suite.underTest.CreateAPIKey(suite.account.ID, &APIKeyRequest{ExpiresIn: maxAPIKeyLifetime})
+	suite.Require().NoError(err)
+	suite.True(suite.repo.keys[issued.ID].Expires.After(time.Now()))
+}
+
+func (suite *ServiceAccountServiceTestSuite) TestInvalidKeys() {
+	issued, _ := suite.underTest.CreateAPIKey(suite.account.ID, &APIKeyRequest{})
+
+	for _, key := range []string{
+		"",
+		"hxk_",
+		"hxk_nounderscore",
+		issued.Key + "x",
+		middleware.APIKeyPrefix + "0123456789abcdef_secret",
+	} {
+		_, err := suite.underTest.VerifyAPIKey(key)
+		suite.Equal(ErrInvalidAPIKey, err, key)
+	}
+}
+
+func (suite *ServiceAccountServiceTestSuite) TestExpiredKey() {
+	issued, _ := suite.underTest.CreateAPIKey(suite.account.ID, &APIKeyRequest{ExpiresIn: 60})
+	suite.repo.keys[issued.ID].Expires = time.Now().Add(-time.Second)
+
+	_, err := suite.underTest.VerifyAPIKey(issued.Key)
+	suite.Equal(ErrInvalidAPIKey, err)
+}
+
+func (suite *ServiceAccountServiceTestSuite) TestRevokeKey() {
+	issued, _ := suite.underTest.CreateAPIKey(suite.account.ID, &APIKeyRequest{})
+
+	suite.IsType(&ServiceAccountNotFoundError{}, suite.underTest.RevokeAPIKey("other", issued.ID))
+	suite.NoError(suite.underTest.RevokeAPIKey(suite.account.ID, issued.ID))
+
+	_, err := suite.underTest.VerifyAPIKey(issued.Key)
+	suite.Equal(ErrInvalidAPIKey, err)
+}
+
+func (suite *ServiceAccountServiceTestSuite) TestDeleteRevokesKeys() {
+	issued, _ := suite.underTest.CreateAPIKey(suite.account.ID, &APIKeyRequest{})
+
+	suite.NoError(suite.underTest.DeleteServiceAccount(suite.account.ID))
+
+	_, err := suite.underTest.VerifyAPIKey(issued.Key)
+	suite.Equal(ErrInvalidAPIKey, err)
+	suite.IsType(&ServiceAccountNotFoundError{}, suite.underTest.DeleteServiceAccount(suite.account.ID))
+}
@@ -0,0 +1,87 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"hex-example/internal/middleware"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+	refreshTokenLen = 32
+	mfaChallengeTTL = 5 * time.Minute
+	maxMFAAttempts  = 5
+	// mfaTokenSeparator ends the username that prefixes MFA tokens; it does
+	// not occur in base64url
+	mfaTokenSeparator = "."
+)
+
+// Signer signs access token claims, setting the kid header of the key used.
+// It is implemented by *jwks.KeyRing.
+type Signer interface {
+	Sign(claims jwt.Claims) (string, error)
+}
+
+// ErrNoSigner is returned when a service without a Signer is asked to issue
+// tokens.
+var ErrNoSigner = errors.New("user: no token signer configured")
+
+// ErrInvalidRefreshToken is returned for unknown, expired, reused or revoked
+// refresh tokens alike.
+var ErrInvalidRefreshToken = &UnauthorizedError{Reason: "Invalid refresh token"}
+
+// newRefreshToken returns a new opaque token value and its record.
+func newRefreshToken(account *Account, family string, authTime time.Time) (string, *RefreshToken, error) {
+	b := make([]byte, refreshTokenLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	value := base64.RawURLEncoding.EncodeToString(b)
+	return value, &RefreshToken{
+		Hash:      hashRefreshToken(value),
+		Family:    family,
+		AccountID: account.ID,
+		Username:  account.Username,
+		AuthTime:  authTime,
+		Expires:   time.Now().Add(refreshTokenTTL),
+	}, nil
+}
+
+// hashRefreshToken returns the storage key for a token value so that a leak
+// of the token store does not leak usable tokens.
+func hashRefreshToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *userService) getToken(account *Account, authTime time.Time) (string, error) {
+	if s.signer == nil {
+		return "", ErrNoSigner
+	}
+
+	/* Set token claims */
+	now := time.Now()
+	claims := &middleware.Claims{
+		Subject:   account.ID,
+		Type:      middleware.PrincipalUser,
+		Issuer:    s.issuer,
+		Audience:  middleware.Audience{s.audience},
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(accessTokenTTL).Unix(),
+		Role:      account.Role,
+		Projects:  account.Projects,
+	}
+	if !authTime.IsZero() {
+		claims.AuthTime = authTime.Unix()
+	}
+
+	/* Sign the token with the current key */
+	return s.signer.Sign(claims)
+}
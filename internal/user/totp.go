@@ -0,0 +1,103 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	totpPeriod = 30
+	// totpSkew accepts codes one step either side of now to allow for clock
+	// drift between server and phone
+	totpSkew          = 1
+	recoveryCodeCount = 10
+	recoveryCodeLen   = 10
+)
+
+var totpOpts = totp.ValidateOpts{
+	Period:    totpPeriod,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// totpKey returns the key of account with the given base32 secret, or
+// with a new random secret if secret is empty.
+func totpKey(issuer string, account *Account, secret string) (*otp.Key, error) {
+	raw, err := base32NoPadding.DecodeString(secret)
+	if err != nil {
+		return nil, err
+	}
+	return totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: account.Username,
+		Period:      totpPeriod,
+		Secret:      raw,
+		Digits:      totpOpts.Digits,
+		Algorithm:   totpOpts.Algorithm,
+	})
+}
+
+// checkTOTP reports whether code is valid for mfa at time now and was not
+// used before, recording its time step in mfa if so.
+func checkTOTP(mfa *MFA, code string, now time.Time) bool {
+	code = strings.TrimSpace(code)
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= mfa.LastStep {
+			continue
+		}
+		expected, err := totp.GenerateCodeCustom(mfa.Secret, time.Unix(step*totpPeriod, 0), totpOpts)
+		if err != nil {
+			return false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			mfa.LastStep = step
+			return true
+		}
+	}
+	return false
+}
+
+// useRecoveryCode reports whether code is one of the unused recovery codes
+// of mfa, removing it if so.
+func useRecoveryCode(mfa *MFA, code string) bool {
+	hash := hashActionToken(normalizeRecoveryCode(code))
+	for i, stored := range mfa.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			mfa.RecoveryCodes = append(mfa.RecoveryCodes[:i:i], mfa.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// newRecoveryCodes returns fresh recovery codes, formatted as xxxxx-xxxxx,
+// and their hashes.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, recoveryCodeLen*5/8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(base32NoPadding.EncodeToString(b))
+		codes[i] = code[:recoveryCodeLen/2] + "-" + code[recoveryCodeLen/2:]
+		hashes[i] = hashActionToken(code)
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode accepts codes typed with or without the dash and in
+// either case.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
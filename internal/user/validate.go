@@ -0,0 +1,102 @@
+package user
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"unicode"
+
+	"hex-example/internal/rbac"
+	"hex-example/internal/validation"
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{2,31}$`)
+
+const (
+	maxNameLength     = 64
+	minPasswordLength = 8
+	maxPasswordBytes  = 72 // bcrypt rejects anything longer
+	maxProjectLength  = 64
+	maxEmailLength    = 254
+)
+
+// validateNew checks an account submitted for creation.
+func validateNew(account *Account) error {
+	v := new(validation.Validator)
+	v.Empty("id", account.ID != "")
+	v.Empty("role", account.Role != "")
+	v.Empty("projects", len(account.Projects) != 0)
+	if v.Required("username", account.Username) {
+		v.Matches("username", account.Username, usernamePattern,
+			"must be 3-32 letters, digits, '.', '_' or '-' and start with a letter or digit")
+	}
+	v.Length("firstName", account.FirstName, 0, maxNameLength)
+	v.Length("lastName", account.LastName, 0, maxNameLength)
+	v.Empty("emailVerified", account.EmailVerified)
+	if account.Email != "" {
+		validateEmail(v, "email", account.Email)
+	}
+	validatePassword(v, "password", account.Password)
+	return v.Err()
+}
+
+// validateUpdate checks a profile update.
+func validateUpdate(update *AccountUpdate) error {
+	v := new(validation.Validator)
+	if update.FirstName != nil {
+		v.Length("firstName", *update.FirstName, 0, maxNameLength)
+	}
+	if update.LastName != nil {
+		v.Length("lastName", *update.LastName, 0, maxNameLength)
+	}
+	if update.Email != nil && *update.Email != "" {
+		validateEmail(v, "email", *update.Email)
+	}
+	return v.Err()
+}
+
+// validateRoles checks a role assignment.
+func validateRoles(roles *Roles) error {
+	v := new(validation.Validator)
+	if roles.Role != "" {
+		v.OneOf("role", roles.Role, rbac.Roles...)
+	}
+	for project, role := range roles.Projects {
+		field := "projects." + project
+		v.Length(field, project, 1, maxProjectLength)
+		v.OneOf(field, role, rbac.Roles...)
+	}
+	return v.Err()
+}
+
+// validateEmail accepts a bare address such as joel@example.com, without a
+// display name.
+func validateEmail(v *validation.Validator, field, email string) {
+	if !v.Length(field, email, 3, maxEmailLength) {
+		return
+	}
+	parsed, err := mail.ParseAddress(email)
+	v.Check(err == nil && parsed.Address == email, field, "must be an email address")
+}
+
+// validatePassword applies the password policy: at least 8 characters and
+// at most 72 bytes, with at least one letter and one digit. The upper bound
+// is in bytes because that is what bcrypt limits.
+func validatePassword(v *validation.Validator, field, password string) {
+	if !v.Length(field, password, minPasswordLength, 0) {
+		return
+	}
+	if !v.Check(len(password) <= maxPasswordBytes, field, fmt.Sprintf("must be at most %d bytes", maxPasswordBytes)) {
+		return
+	}
+	var letter, digit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	v.Check(letter && digit, field, "must contain at least one letter and one digit")
+}
@@ -0,0 +1,52 @@
+package user
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"hex-example/internal/validation"
+)
+
+func TestValidateNew(t *testing.T) {
+	valid := &Account{Username: "joel.h", FirstName: "Joel", Email: "joel@example.com", Password: "correct1horse"}
+	assert.Nil(t, validateNew(valid))
+}
+
+func TestValidateNew_Invalid(t *testing.T) {
+	cases := map[string]struct {
+		account *Account
+		field   string
+	}{
+		"empty username":       {&Account{Password: "password1"}, "username"},
+		"short username":       {&Account{Username: "jo", Password: "password1"}, "username"},
+		"bad username chars":   {&Account{Username: "joel h", Password: "password1"}, "username"},
+		"short password":       {&Account{Username: "joel", Password: "pass1"}, "password"},
+		"password no digit":    {&Account{Username: "joel", Password: "password"}, "password"},
+		"long password":        {&Account{Username: "joel", Password: "password1" + strings.Repeat("x", 64)}, "password"},
+		"multibyte password":   {&Account{Username: "joel", Password: "password1" + strings.Repeat("é", 32)}, "password"},
+		"password no letter":   {&Account{Username: "joel", Password: "12345678"}, "password"},
+		"client supplied id":   {&Account{ID: "x", Username: "joel", Password: "password1"}, "id"},
+		"client supplied role": {&Account{Role: "admin", Username: "joel", Password: "password1"}, "role"},
+		"bad email":            {&Account{Username: "joel", Email: "joel", Password: "password1"}, "email"},
+		"email display name":   {&Account{Username: "joel", Email: "Joel <joel@example.com>", Password: "password1"}, "email"},
+		"preverified email":    {&Account{Username: "joel", Email: "joel@example.com", EmailVerified: true, Password: "password1"}, "emailVerified"},
+	}
+	for name, c := range cases {
+		err := validateNew(c.account)
+		errs, ok := err.(validation.Errors)
+		if assert.True(t, ok, name) && assert.Len(t, errs, 1, name) {
+			assert.Equal(t, c.field, errs[0].Field, name)
+		}
+	}
+}
+
+func TestValidateRoles(t *testing.T) {
+	assert.Nil(t, validateRoles(&Roles{Role: "viewer", Projects: map[string]string{"infra": "admin"}}))
+
+	err := validateRoles(&Roles{Role: "root", Projects: map[string]string{"infra": "owner"}})
+	errs, ok := err.(validation.Errors)
+	if assert.True(t, ok) && assert.Len(t, errs, 2) {
+		assert.ElementsMatch(t, []string{"role", "projects.infra"}, []string{errs[0].Field, errs[1].Field})
+	}
+}
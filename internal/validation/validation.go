@@ -0,0 +1,91 @@
+// Package validation collects field-level rule violations so that every
+// invalid field of a payload can be reported at once.
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError is a single violated rule.
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// Errors is the list of rules a payload violated. It implements error.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	reasons := make([]string, len(e))
+	for i, fe := range e {
+		reasons[i] = fe.Field + ": " + fe.Reason
+	}
+	return "validation: " + strings.Join(reasons, "; ")
+}
+
+// Validator accumulates field errors. The zero value is ready to use.
+type Validator struct {
+	errs Errors
+}
+
+// Err returns the accumulated Errors, or nil if every rule passed.
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// Check records reason against field unless ok.
+func (v *Validator) Check(ok bool, field, reason string) bool {
+	if !ok {
+		v.errs = append(v.errs, FieldError{Field: field, Reason: reason})
+	}
+	return ok
+}
+
+// Required checks that value is not blank.
+func (v *Validator) Required(field, value string) bool {
+	return v.Check(strings.TrimSpace(value) != "", field, "is required")
+}
+
+// Length checks that value has between min and max characters. A max of 0
+// means unbounded.
+func (v *Validator) Length(field, value string, min, max int) bool {
+	n := utf8.RuneCountInString(value)
+	if n < min {
+		return v.Check(false, field, fmt.Sprintf("must be at least %d characters", min))
+	}
+	if max > 0 && n > max {
+		return v.Check(false, field, fmt.Sprintf("must be at most %d characters", max))
+	}
+	return true
+}
+
+// Range checks that min <= value <= max.
+func (v *Validator) Range(field string, value, min, max int) bool {
+	return v.Check(value >= min && value <= max, field, fmt.Sprintf("must be between %d and %d", min, max))
+}
+
+// OneOf checks that value is one of allowed.
+func (v *Validator) OneOf(field, value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return v.Check(false, field, "must be one of "+strings.Join(allowed, ", "))
+}
+
+// Matches checks that value matches re, recording reason otherwise.
+func (v *Validator) Matches(field, value string, re *regexp.Regexp, reason string) bool {
+	return v.Check(re.MatchString(value), field, reason)
+}
+
+// Empty checks that a server-assigned field was not supplied by the client.
+func (v *Validator) Empty(field string, set bool) bool {
+	return v.Check(!set, field, "is read-only")
+}
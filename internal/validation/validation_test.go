@@ -0,0 +1,43 @@
+package validation
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidator_NoErrors(t *testing.T) {
+	v := new(Validator)
+	v.Required("title", "A title")
+	v.Length("title", "A title", 1, 10)
+	v.Range("points", 3, 0, 5)
+	v.OneOf("status", "OPEN", "OPEN", "DONE")
+	v.Matches("username", "joel", regexp.MustCompile("^[a-z]+$"), "must be lowercase")
+	v.Empty("id", false)
+	assert.Nil(t, v.Err())
+}
+
+func TestValidator_CollectsEveryField(t *testing.T) {
+	v := new(Validator)
+	v.Required("title", "  ")
+	v.Length("description", "too long", 0, 3)
+	v.Range("points", -1, 0, 5)
+	v.OneOf("status", "LOST", "OPEN", "DONE")
+	v.Matches("username", "Joel!", regexp.MustCompile("^[a-z]+$"), "must be lowercase")
+	v.Empty("id", true)
+
+	err := v.Err()
+	errs, ok := err.(Errors)
+	if assert.True(t, ok) {
+		assert.Equal(t, Errors{
+			{Field: "title", Reason: "is required"},
+			{Field: "description", Reason: "must be at most 3 characters"},
+			{Field: "points", Reason: "must be between 0 and 5"},
+			{Field: "status", Reason: "must be one of OPEN, DONE"},
+			{Field: "username", Reason: "must be lowercase"},
+			{Field: "id", Reason: "is read-only"},
+		}, errs)
+	}
+	assert.Contains(t, err.Error(), "title: is required")
+}
@@ -0,0 +1,207 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: ticket.proto
+
+package ticketpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	TicketService_CreateTicket_FullMethodName   = "/ticket.TicketService/CreateTicket"
+	TicketService_FindTicketById_FullMethodName = "/ticket.TicketService/FindTicketById"
+	TicketService_FindAllTickets_FullMethodName = "/ticket.TicketService/FindAllTickets"
+)
+
+// TicketServiceClient is the client API for TicketService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// TicketService exposes ticket.TicketService to internal backends.
+type TicketServiceClient interface {
+	CreateTicket(ctx context.Context, in *CreateTicketRequest, opts ...grpc.CallOption) (*Ticket, error)
+	FindTicketById(ctx context.Context, in *FindTicketByIdRequest, opts ...grpc.CallOption) (*Ticket, error)
+	// FindAllTickets streams every ticket.
+	FindAllTickets(ctx context.Context, in *FindAllTicketsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Ticket], error)
+}
+
+type ticketServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTicketServiceClient(cc grpc.ClientConnInterface) TicketServiceClient {
+	return &ticketServiceClient{cc}
+}
+
+func (c *ticketServiceClient) CreateTicket(ctx context.Context, in *CreateTicketRequest, opts ...grpc.CallOption) (*Ticket, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Ticket)
+	err := c.cc.Invoke(ctx, TicketService_CreateTicket_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ticketServiceClient) FindTicketById(ctx context.Context, in *FindTicketByIdRequest, opts ...grpc.CallOption) (*Ticket, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Ticket)
+	err := c.cc.Invoke(ctx, TicketService_FindTicketById_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ticketServiceClient) FindAllTickets(ctx context.Context, in *FindAllTicketsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Ticket], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TicketService_ServiceDesc.Streams[0], TicketService_FindAllTickets_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[FindAllTicketsRequest, Ticket]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TicketService_FindAllTicketsClient = grpc.ServerStreamingClient[Ticket]
+
+// TicketServiceServer is the server API for TicketService service.
+// All implementations must embed UnimplementedTicketServiceServer
+// for forward compatibility.
+//
+// TicketService exposes ticket.TicketService to internal backends.
+type TicketServiceServer interface {
+	CreateTicket(context.Context, *CreateTicketRequest) (*Ticket, error)
+	FindTicketById(context.Context, *FindTicketByIdRequest) (*Ticket, error)
+	// FindAllTickets streams every ticket.
+	FindAllTickets(*FindAllTicketsRequest, grpc.ServerStreamingServer[Ticket]) error
+	mustEmbedUnimplementedTicketServiceServer()
+}
+
+// UnimplementedTicketServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTicketServiceServer struct{}
+
+func (UnimplementedTicketServiceServer) CreateTicket(context.Context, *CreateTicketRequest) (*Ticket, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateTicket not implemented")
+}
+func (UnimplementedTicketServiceServer) FindTicketById(context.Context, *FindTicketByIdRequest) (*Ticket, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindTicketById not implemented")
+}
+func (UnimplementedTicketServiceServer) FindAllTickets(*FindAllTicketsRequest, grpc.ServerStreamingServer[Ticket]) error {
+	return status.Errorf(codes.Unimplemented, "method FindAllTickets not implemented")
+}
+func (UnimplementedTicketServiceServer) mustEmbedUnimplementedTicketServiceServer() {}
+func (UnimplementedTicketServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeTicketServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TicketServiceServer will
+// result in compilation errors.
+type UnsafeTicketServiceServer interface {
+	mustEmbedUnimplementedTicketServiceServer()
+}
+
+func RegisterTicketServiceServer(s grpc.ServiceRegistrar, srv TicketServiceServer) {
+	// If the following call pancis, it indicates UnimplementedTicketServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&TicketService_ServiceDesc, srv)
+}
+
+func _TicketService_CreateTicket_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateTicketRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TicketServiceServer).CreateTicket(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TicketService_CreateTicket_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TicketServiceServer).CreateTicket(ctx, req.(*CreateTicketRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TicketService_FindTicketById_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindTicketByIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TicketServiceServer).FindTicketById(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TicketService_FindTicketById_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TicketServiceServer).FindTicketById(ctx, req.(*FindTicketByIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TicketService_FindAllTickets_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FindAllTicketsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TicketServiceServer).FindAllTickets(m, &grpc.GenericServerStream[FindAllTicketsRequest, Ticket]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TicketService_FindAllTicketsServer = grpc.ServerStreamingServer[Ticket]
+
+// TicketService_ServiceDesc is the grpc.ServiceDesc for TicketService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TicketService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ticket.TicketService",
+	HandlerType: (*TicketServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateTicket",
+			Handler:    _TicketService_CreateTicket_Handler,
+		},
+		{
+			MethodName: "FindTicketById",
+			Handler:    _TicketService_FindTicketById_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "FindAllTickets",
+			Handler:       _TicketService_FindAllTickets_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "ticket.proto",
+}
@@ -12,3 +12,59 @@ CREATE TABLE IF NOT EXISTS tickets
   updated timestamp NULL DEFAULT NULL,
   deleted timestamp NULL DEFAULT NULL
 );
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS project varchar(64) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS tickets_project ON tickets (project);
+CREATE INDEX IF NOT EXISTS tickets_creator ON tickets (creator);
+CREATE INDEX IF NOT EXISTS tickets_assigned ON tickets (assigned);
+CREATE TABLE IF NOT EXISTS accounts
+(
+  id uuid NOT NULL PRIMARY KEY,
+  username varchar(32) NOT NULL UNIQUE,
+  first_name varchar(64) NOT NULL DEFAULT '',
+  last_name varchar(64) NOT NULL DEFAULT '',
+  password varchar(72) NOT NULL,
+  role varchar(16) NOT NULL DEFAULT '',
+  projects jsonb NOT NULL DEFAULT '{}',
+  deactivated boolean NOT NULL DEFAULT false,
+  created timestamp NOT NULL DEFAULT current_timestamp
+);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS email varchar(254) NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS email_verified boolean NOT NULL DEFAULT false;
+CREATE TABLE IF NOT EXISTS migrations
+(
+  name varchar(64) NOT NULL PRIMARY KEY,
+  applied timestamp NOT NULL DEFAULT current_timestamp
+);
+-- accounts created while an empty role meant member keep that role, once
+WITH applied AS (
+  INSERT INTO migrations (name) VALUES ('member_role') ON CONFLICT DO NOTHING RETURNING name
+)
+UPDATE accounts SET role = 'member' WHERE role = '' AND EXISTS (SELECT 1 FROM applied);
+CREATE TABLE IF NOT EXISTS passkeys
+(
+  id varchar(1366) NOT NULL PRIMARY KEY,
+  account_id uuid NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+  name varchar(64) NOT NULL DEFAULT '',
+  created timestamp NOT NULL DEFAULT current_timestamp,
+  last_used timestamp NULL DEFAULT NULL,
+  credential jsonb NOT NULL
+);
+CREATE INDEX IF NOT EXISTS passkeys_account_id ON passkeys (account_id);
+CREATE TABLE IF NOT EXISTS identities
+(
+  provider varchar(32) NOT NULL,
+  subject varchar(255) NOT NULL,
+  account_id uuid NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+  linked timestamp NOT NULL DEFAULT current_timestamp,
+  PRIMARY KEY (provider, subject)
+);
+ALTER TABLE identities ADD COLUMN IF NOT EXISTS created boolean NOT NULL DEFAULT false;
+CREATE TABLE IF NOT EXISTS sessions
+(
+  id varchar(64) NOT NULL PRIMARY KEY,
+  user_id varchar(255) NOT NULL DEFAULT '',
+  data bytea NOT NULL,
+  expires timestamp NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sessions_user_id ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS sessions_expires ON sessions (expires);